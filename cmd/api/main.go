@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os/signal"
+	"syscall"
 
 	"token13/merchant-backend-go/internal/app"
 )
@@ -12,6 +15,12 @@ func main() {
 		log.Fatal(err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go c.Relay.Run(ctx)
+	go c.Tokens.Run(ctx)
+
 	addr := app.Addr(c.Cfg.HTTPPort)
 	c.Log.Info("api_starting", "addr", addr, "env", c.Cfg.AppEnv)
 
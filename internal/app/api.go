@@ -7,14 +7,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
 	"token13/merchant-backend-go/internal/transport/http/handlers"
+	"token13/merchant-backend-go/internal/transport/http/middleware"
 )
 
 type API struct {
 	Engine *gin.Engine
 }
 
-func NewAPI(log *slog.Logger, authH *handlers.AuthHandler) *API {
+func NewAPI(log *slog.Logger, jwtm *auth.JWTManager, apiKeys middleware.APIKeyAuthenticator, authH *handlers.AuthHandler, orderH *handlers.OrderHandler, merchantH *handlers.MerchantHandler, tokenH *handlers.TokenHandler, apiKeyH *handlers.APIKeyHandler) *API {
 	r := gin.New()
 	r.Use(gin.Recovery())
 
@@ -33,10 +36,43 @@ func NewAPI(log *slog.Logger, authH *handlers.AuthHandler) *API {
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 	})
 
+	// Public keys for services verifying our access tokens.
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwtm.JWKS())
+	})
+
+	authn := middleware.Auth(jwtm, apiKeys)
+
 	v1 := r.Group("/v1")
 	authGroup := v1.Group("/auth")
 	authGroup.POST("/register", authH.Register)
 	authGroup.POST("/login", authH.Login)
+	authGroup.POST("/refresh", authH.Refresh)
+	authGroup.POST("/logout", authH.Logout)
+	authGroup.POST("/logout-all", authn, middleware.RequireUser(), authH.LogoutAll)
+
+	v1.GET("/tokens", tokenH.List)
+
+	// Merchant backends may call these with an API key.
+	orders := v1.Group("/orders", authn, middleware.RequireMerchant())
+	orders.POST("", middleware.RequireScope(domain.ScopeOrdersWrite), orderH.Create)
+	orders.GET("", middleware.RequireScope(domain.ScopePaymentsRead), orderH.List)
+	orders.GET("/:order_id", middleware.RequireScope(domain.ScopePaymentsRead), orderH.Get)
+
+	me := v1.Group("/merchants/me", authn, middleware.RequireUser(), middleware.RequireMerchant())
+	me.GET("/receiver", merchantH.Receiver)
+	me.PUT("/receiver", merchantH.ChangeReceiver)
+	me.GET("/tokens", merchantH.Tokens)
+	me.PUT("/tokens/:token", merchantH.SetToken)
+	me.GET("/api-keys", apiKeyH.List)
+	me.POST("/api-keys", apiKeyH.Create)
+	me.DELETE("/api-keys/:key_id", apiKeyH.Revoke)
+
+	admin := v1.Group("/admin", authn)
+	admin.GET("/merchants/:merchant_id", middleware.RequireRole(auth.RoleAdmin, auth.RoleOperator), merchantH.Get)
+	admin.PUT("/merchants/:merchant_id/status", middleware.RequireRole(auth.RoleAdmin), merchantH.SetStatus)
+	admin.POST("/merchants/:merchant_id/onboard", middleware.RequireRole(auth.RoleAdmin), merchantH.RetryOnboarding)
 
 	return &API{Engine: r}
 }
\ No newline at end of file
@@ -1,17 +1,24 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
-	amqp "github.com/rabbitmq/amqp091-go"
-
 	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/chain/signer"
 	"token13/merchant-backend-go/internal/config"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/outbox"
 	applogger "token13/merchant-backend-go/internal/platform/logger"
+	"token13/merchant-backend-go/internal/ports"
 	"token13/merchant-backend-go/internal/queue/rabbit"
 	"token13/merchant-backend-go/internal/repository/postgres"
+	service "token13/merchant-backend-go/internal/services"
 	"token13/merchant-backend-go/internal/services/tron"
 	"token13/merchant-backend-go/internal/transport/http/handlers"
 )
@@ -25,9 +32,12 @@ type Container struct {
 	AuthRepo *postgres.AuthRepo
 	JWT      *auth.JWTManager
 	Tron     tron.Service
+	Orders   *service.OrderService
 
-	RabbitConn *amqp.Connection
+	RabbitConn *rabbit.Connection
 	Publisher  *rabbit.Publisher
+	Relay      *outbox.Relay
+	Tokens     *service.TokenLoader
 }
 
 func Wire() (*Container, error) {
@@ -44,27 +54,75 @@ func Wire() (*Container, error) {
 		return nil, err
 	}
 	authRepo := postgres.NewAuthRepo(db.SQL)
+	merchantRepo := postgres.NewMerchantRepo(db.SQL)
+	orderRepo := postgres.NewOrderRepo(db.SQL)
 
 	// JWT
-	jwtm := auth.NewJWTManager(cfg.JWTSecret, cfg.JWTIssuer, 15*time.Minute)
+	jwtm, err := newJWTManager(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	sessionSvc := service.NewSessionService(postgres.NewRefreshTokenRepo(db.SQL), log)
+	sessionSvc.TTL = time.Duration(cfg.JWTRefreshTTLHours) * time.Hour
+
+	// Contracts + Tron
+	bundle, err := contracts.Load(cfg.ContractsPath)
+	if err != nil {
+		return nil, err
+	}
+	tronSvc, err := newTronService(cfg, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens (the loader is started by cmd/api)
+	tokenRegistry, addresses, err := newTokenRegistry(cfg, bundle, db, log)
+	if err != nil {
+		return nil, err
+	}
+	tokens, tokenLoader, err := cachedTokens(tokenRegistry, addresses, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Orders
+	orderSvc, err := service.NewOrderService(orderRepo, merchantRepo, postgres.NewPaymentRepo(db.SQL), tokens)
+	if err != nil {
+		return nil, err
+	}
 
-	// Tron (stub for now)
-	tronSvc := tron.NewStub()
+	// Merchants
+	merchantSvc := service.NewMerchantService(merchantRepo, tronSvc, log)
+	merchantSvc.ReceiverCooldown = time.Duration(cfg.MerchantReceiverCooldownHours) * time.Hour
+	merchantSvc.Tokens = tokens
 
 	// RabbitMQ
-	rabbitConn, err := rabbit.Connect(cfg.RabbitURL)
+	rabbitConn, err := rabbit.Connect(cfg.RabbitURL, log)
 	if err != nil {
 		return nil, err
 	}
 
-	publisher, err := rabbit.NewPublisher(rabbitConn, cfg.RabbitExchange)
+	publisher, err := rabbit.NewPublisher(rabbitConn, cfg.RabbitExchange, notificationFeed, alertFeed)
 	if err != nil {
 		return nil, err
 	}
 
+	// Outbox relay (started by cmd/api)
+	relay := outbox.NewRelay(postgres.NewOutboxRepo(db.SQL), publisher, log)
+
 	// Handlers
-	authH := handlers.NewAuthHandler(authRepo, jwtm, tronSvc, publisher)
-	api := NewAPI(log, authH)
+	authH := handlers.NewAuthHandler(authRepo, jwtm, tronSvc, sessionSvc)
+	orderH := handlers.NewOrderHandler(orderSvc)
+	merchantH := handlers.NewMerchantHandler(merchantSvc, authRepo)
+	tokenH := handlers.NewTokenHandler(tokenRegistry)
+	apiKeySvc := service.NewAPIKeyService(postgres.NewAPIKeyRepo(db.SQL), log)
+	apiKeyH := handlers.NewAPIKeyHandler(apiKeySvc)
+	api := NewAPI(log, jwtm, apiKeySvc, authH, orderH, merchantH, tokenH, apiKeyH)
+	// API key allow-lists match c.ClientIP(): only trust forwarding headers
+	// from our own proxies.
+	if err := api.Engine.SetTrustedProxies(splitList(cfg.HTTPTrustedProxies)); err != nil {
+		return nil, fmt.Errorf("HTTP_TRUSTED_PROXIES: %w", err)
+	}
 
 	return &Container{
 		Cfg:        cfg,
@@ -73,12 +131,159 @@ func Wire() (*Container, error) {
 		AuthRepo:   authRepo,
 		JWT:        jwtm,
 		Tron:       tronSvc,
+		Orders:     orderSvc,
 		RabbitConn: rabbitConn,
 		Publisher:  publisher,
+		Relay:      relay,
+		Tokens:     tokenLoader,
 		API:        api,
 	}, nil
 }
 
+// newJWTManager loads the signing keys from JWT_KEYS_DIR. Without one it
+// signs with a key generated for this process, but only when APP_ENV=dev
+// was set explicitly.
+func newJWTManager(cfg *config.Config, log *slog.Logger) (*auth.JWTManager, error) {
+	var keys []auth.Key
+	if cfg.JWTKeysDir != "" {
+		loaded, err := auth.LoadKeys(cfg.JWTKeysDir)
+		if err != nil {
+			return nil, err
+		}
+		keys = loaded
+	} else {
+		if cfg.AppEnv != "dev" {
+			return nil, fmt.Errorf("JWT_KEYS_DIR is required outside APP_ENV=dev")
+		}
+		k, err := auth.GenerateKey("dev")
+		if err != nil {
+			return nil, err
+		}
+		log.Warn("jwt_ephemeral_key", "reason", "JWT_KEYS_DIR not set; tokens will not survive a restart")
+		keys = []auth.Key{k}
+	}
+
+	ttl := time.Duration(cfg.JWTAccessTTLMinutes) * time.Minute
+	return auth.NewJWTManager(keys, cfg.JWTSigningKID, cfg.JWTIssuer, cfg.JWTAudience, ttl)
+}
+
+// newTronService returns the real MerchantRegistry client when an operator
+// signer is configured, and the stub otherwise.
+func newTronService(cfg *config.Config, bundle *contracts.Bundle) (tron.Service, error) {
+	s, err := newSigner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return tron.NewStub(), nil
+	}
+
+	client := tron.NewClient(cfg.TronAPIBase, cfg.TronAPIKey, nil)
+	return tron.NewRegistry(client, bundle.MerchantRegistry, s, int64(cfg.TronFeeLimit))
+}
+
+// newSigner builds the operator signer selected by TRON_SIGNER, or nil
+// when none is configured.
+func newSigner(cfg *config.Config) (signer.Signer, error) {
+	kind := cfg.TronSigner
+	if kind == "" && cfg.TronOperatorKey != "" {
+		kind = "env"
+	}
+
+	switch kind {
+	case "":
+		return nil, nil
+	case "env":
+		return signer.FromHex(cfg.TronOperatorKey)
+	case "keystore":
+		if cfg.TronKeystorePath == "" {
+			return nil, fmt.Errorf("TRON_KEYSTORE_PATH is required for TRON_SIGNER=keystore")
+		}
+		return signer.LoadKeystore(cfg.TronKeystorePath, cfg.TronKeystorePassword)
+	case "remote":
+		if cfg.TronRemoteSignerURL == "" {
+			return nil, fmt.Errorf("TRON_REMOTE_SIGNER_URL is required for TRON_SIGNER=remote")
+		}
+		addr, err := domain.ParseTronAddress(cfg.TronRemoteSignerAddress)
+		if err != nil {
+			return nil, fmt.Errorf("TRON_REMOTE_SIGNER_ADDRESS: %w", err)
+		}
+		return signer.NewRemote(cfg.TronRemoteSignerURL, cfg.TronRemoteSignerToken, addr, nil), nil
+	}
+	return nil, fmt.Errorf("TRON_SIGNER %q: want env, keystore or remote", kind)
+}
+
+// newTokenRegistry returns the token registry and the addresses to
+// allow-list: the contracts bundle's USDT, the default, then TRON_TOKENS.
+func newTokenRegistry(cfg *config.Config, bundle *contracts.Bundle, db *postgres.DB, log *slog.Logger) (*service.TokenRegistry, []string, error) {
+	var chain ports.TokenChain
+	if len(bundle.TRC20) > 0 {
+		trc20, err := tron.NewTRC20(tron.NewClient(cfg.TronAPIBase, cfg.TronAPIKey, nil), bundle.TRC20)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain = trc20
+	}
+	registry := service.NewTokenRegistry(postgres.NewTokenRepo(db.SQL), chain, log)
+
+	var addresses []string
+	if bundle.USDT.Address != "" {
+		addresses = append(addresses, bundle.USDT.Address)
+	}
+	addresses = append(addresses, splitList(cfg.TronTokens)...)
+	return registry, addresses, nil
+}
+
+// cachedTokens starts a token set from the tokens cache, so startup does
+// not wait on the chain, and returns the loader that reads the tokens not
+// cached yet and applies the allow-list in the background.
+func cachedTokens(registry *service.TokenRegistry, addresses []string, log *slog.Logger) (*service.TokenSet, *service.TokenLoader, error) {
+	if len(addresses) == 0 {
+		return nil, nil, service.ErrNoOrderTokens
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tokens, complete, err := registry.Cached(ctx, addresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !complete {
+		log.Warn("tokens_not_cached", "cached", len(tokens), "configured", len(addresses))
+	}
+
+	set := service.NewTokenSet(tokens, complete)
+	return set, service.NewTokenLoader(registry, addresses, set, log), nil
+}
+
+// notificationFeed keeps the events published for outside subscribers
+// (events.Notifications) for a week.
+var notificationFeed = rabbit.FeedTopology{
+	Queue:       "token13.notifications.q",
+	RoutingKeys: events.Notifications,
+	TTL:         7 * 24 * time.Hour,
+	MaxLength:   100_000,
+}
+
+// alertFeed is where ops tooling picks up events.Alerts, such as the
+// operator account running short while chain jobs are held back.
+var alertFeed = rabbit.FeedTopology{
+	Queue:       "token13.ops.alerts.q",
+	RoutingKeys: events.Alerts,
+	TTL:         7 * 24 * time.Hour,
+	MaxLength:   10_000,
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func Addr(port int) string {
 	return fmt.Sprintf(":%d", port)
 }
@@ -1 +1,278 @@
 package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/chain/indexer"
+	"token13/merchant-backend-go/internal/config"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/outbox"
+	applogger "token13/merchant-backend-go/internal/platform/logger"
+	"token13/merchant-backend-go/internal/queue/rabbit"
+	"token13/merchant-backend-go/internal/repository/postgres"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/services/tron"
+)
+
+type Worker struct {
+	Cfg *config.Config
+	Log *slog.Logger
+	DB  *postgres.DB
+
+	RabbitConn *rabbit.Connection
+	Publisher  *rabbit.Publisher
+
+	Relay     *outbox.Relay
+	Consumers *rabbit.Consumers
+	Merchants *service.MerchantService
+
+	// Indexer, Tracker and Tokens are nil when no PaymentCoreV1 contract is
+	// configured. The indexer waits for Tokens to load every token.
+	Indexer *indexer.Indexer
+	Tracker *indexer.Tracker
+	Tokens  *service.TokenLoader
+}
+
+func WireWorker() (*Worker, error) {
+	cfg, err := config.LoadWorker()
+	if err != nil {
+		return nil, err
+	}
+
+	log := applogger.New(applogger.Options{Env: cfg.AppEnv})
+
+	db, err := postgres.Connect(cfg.DBDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := contracts.Load(cfg.ContractsPath)
+	if err != nil {
+		return nil, err
+	}
+	tronSvc, err := newTronService(cfg, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	rabbitConn, err := rabbit.Connect(cfg.RabbitURL, log)
+	if err != nil {
+		return nil, err
+	}
+	publisher, err := rabbit.NewPublisher(rabbitConn, cfg.RabbitExchange, notificationFeed, alertFeed)
+	if err != nil {
+		return nil, err
+	}
+
+	outboxRepo := postgres.NewOutboxRepo(db.SQL)
+	if reg, ok := tronSvc.(*tron.Registry); ok {
+		if reg.Guard, err = newOperatorBudget(cfg, reg, outboxRepo, log); err != nil {
+			return nil, err
+		}
+	}
+
+	merchantRepo := postgres.NewMerchantRepo(db.SQL)
+	merchants := service.NewMerchantService(merchantRepo, tronSvc, log)
+	relay := outbox.NewRelay(outboxRepo, publisher, log)
+
+	w := &Worker{
+		Cfg:        cfg,
+		Log:        log,
+		DB:         db,
+		RabbitConn: rabbitConn,
+		Publisher:  publisher,
+		Relay:      relay,
+		Consumers:  rabbit.NewConsumers(rabbitConn, cfg.RabbitExchange, events.Default, log),
+		Merchants:  merchants,
+	}
+	w.Consumers.Ledger = postgres.NewLedger(db.SQL)
+
+	if bundle.PaymentCore.Address != "" {
+		registry, addresses, err := newTokenRegistry(cfg, bundle, db, log)
+		if err != nil {
+			return nil, err
+		}
+		var tokens *service.TokenSet
+		tokens, w.Tokens, err = cachedTokens(registry, addresses, log)
+		if err != nil {
+			return nil, err
+		}
+		w.Indexer, w.Tracker, err = newPaymentIndexer(cfg, bundle, tokens, db, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.register()
+	return w, nil
+}
+
+// register declares every consumer the worker runs.
+func (w *Worker) register() {
+	// Chain calls are slow and share one operator account: one at a time.
+	rabbit.Handle(w.Consumers, rabbit.JobOptions{Concurrency: 1}, w.handleMerchantCreated)
+	rabbit.Handle(w.Consumers, rabbit.JobOptions{Concurrency: 1}, w.handleMerchantOnboardRequested)
+	rabbit.Handle(w.Consumers, rabbit.JobOptions{Concurrency: 1}, w.handleMerchantStatusChangeRequested)
+	rabbit.Handle(w.Consumers, rabbit.JobOptions{Concurrency: 1}, w.handleMerchantReceiverChangeRequested)
+	rabbit.Handle(w.Consumers, rabbit.JobOptions{Concurrency: 1}, w.handleMerchantTokenChangeRequested)
+}
+
+// Run consumes until ctx is cancelled, then drains in-flight messages.
+func (w *Worker) Run(ctx context.Context) error {
+	return w.Consumers.Run(ctx)
+}
+
+func (w *Worker) handleMerchantCreated(ctx context.Context, msg rabbit.Message, ev events.MerchantCreated) error {
+	merchantID, err := ids.HexToBytes32(ev.MerchantID)
+	if err != nil {
+		return rabbit.Permanent(fmt.Errorf("merchant_id: %w", err))
+	}
+
+	w.Log.Info("merchant_created_received", "event_id", msg.Envelope.EventID, "merchant_id", ev.MerchantID, "wallet", ev.WalletAddress)
+	return w.onboard(ctx, msg, merchantID)
+}
+
+func (w *Worker) handleMerchantOnboardRequested(ctx context.Context, msg rabbit.Message, ev events.MerchantOnboardRequested) error {
+	merchantID, err := ids.HexToBytes32(ev.MerchantID)
+	if err != nil {
+		return rabbit.Permanent(fmt.Errorf("merchant_id: %w", err))
+	}
+
+	w.Log.Info("merchant_onboard_requested_received", "event_id", msg.Envelope.EventID, "merchant_id", ev.MerchantID, "requested_by", ev.RequestedBy)
+	return w.onboard(ctx, msg, merchantID)
+}
+
+func (w *Worker) onboard(ctx context.Context, msg rabbit.Message, merchantID []byte) error {
+	if err := w.Merchants.OnboardOnChain(ctx, merchantID); err != nil {
+		if errors.Is(err, service.ErrMerchantNotFound) {
+			err = rabbit.Permanent(err)
+		}
+		return w.giveUp(err, func(ctx context.Context) error {
+			return w.Merchants.FailOnboarding(ctx, merchantID, err)
+		})
+	}
+	return nil
+}
+
+func (w *Worker) handleMerchantStatusChangeRequested(ctx context.Context, msg rabbit.Message, ev events.MerchantStatusChangeRequested) error {
+	merchantID, err := ids.HexToBytes32(ev.MerchantID)
+	if err != nil {
+		return rabbit.Permanent(fmt.Errorf("merchant_id: %w", err))
+	}
+
+	w.Log.Info("merchant_status_change_received", "event_id", msg.Envelope.EventID, "merchant_id", ev.MerchantID, "status", ev.Status)
+
+	// The merchant row, not the event, says what to apply: a later request
+	// is only accepted once this one has been confirmed.
+	if err := w.Merchants.SyncStatus(ctx, merchantID); err != nil {
+		if errors.Is(err, service.ErrMerchantNotFound) {
+			err = rabbit.Permanent(err)
+		}
+		return w.giveUp(err, func(ctx context.Context) error {
+			return w.Merchants.FailStatus(ctx, merchantID, err)
+		})
+	}
+	return nil
+}
+
+func (w *Worker) handleMerchantReceiverChangeRequested(ctx context.Context, msg rabbit.Message, ev events.MerchantReceiverChangeRequested) error {
+	merchantID, err := ids.HexToBytes32(ev.MerchantID)
+	if err != nil {
+		return rabbit.Permanent(fmt.Errorf("merchant_id: %w", err))
+	}
+
+	w.Log.Info("merchant_receiver_change_received", "event_id", msg.Envelope.EventID, "merchant_id", ev.MerchantID, "new_receiver", ev.NewReceiver)
+
+	// Like status changes, the pending row is applied rather than the event.
+	if err := w.Merchants.SyncReceiver(ctx, merchantID); err != nil {
+		return w.giveUp(err, func(ctx context.Context) error {
+			return w.Merchants.FailReceiver(ctx, merchantID, err)
+		})
+	}
+	return nil
+}
+
+func (w *Worker) handleMerchantTokenChangeRequested(ctx context.Context, msg rabbit.Message, ev events.MerchantTokenChangeRequested) error {
+	merchantID, err := ids.HexToBytes32(ev.MerchantID)
+	if err != nil {
+		return rabbit.Permanent(fmt.Errorf("merchant_id: %w", err))
+	}
+	token, err := domain.ParseTronAddress(ev.TokenAddress)
+	if err != nil {
+		return rabbit.Permanent(fmt.Errorf("token_address: %w", err))
+	}
+
+	w.Log.Info("merchant_token_change_received", "event_id", msg.Envelope.EventID, "merchant_id", ev.MerchantID, "token", ev.TokenAddress, "enabled", ev.Enabled)
+
+	if err := w.Merchants.SyncToken(ctx, merchantID, token); err != nil {
+		return w.giveUp(err, func(ctx context.Context) error {
+			return w.Merchants.FailToken(ctx, merchantID, token, err)
+		})
+	}
+	return nil
+}
+
+// giveUp has the consumer run fail, which marks the change the handler
+// was applying as failed, when err ends the message's retries (see
+// rabbit.OnGiveUp), so the change does not stay pending forever.
+//
+// An over-budget transaction is held back without using up a retry until
+// the operator is topped up; in refuse mode it is retried like any other
+// error instead.
+func (w *Worker) giveUp(err error, fail func(ctx context.Context) error) error {
+	if errors.Is(err, service.ErrOperatorLowResources) && !errors.Is(err, service.ErrOperatorBudgetRefused) {
+		return rabbit.Delay(err)
+	}
+	return rabbit.OnGiveUp(err, fail)
+}
+
+// newOperatorBudget guards every transaction the worker signs with the
+// operator's resource budget.
+func newOperatorBudget(cfg *config.Config, reg *tron.Registry, outbox *postgres.OutboxRepo, log *slog.Logger) (*service.OperatorBudget, error) {
+	budget := service.NewOperatorBudget(reg, outbox, log)
+	budget.MinBalanceSun = int64(cfg.TronOperatorMinBalanceSun)
+
+	switch cfg.TronOperatorBudgetMode {
+	case "queue":
+	case "refuse":
+		budget.Refuse = true
+	default:
+		return nil, fmt.Errorf("TRON_OPERATOR_BUDGET_MODE %q: want queue or refuse", cfg.TronOperatorBudgetMode)
+	}
+	return budget, nil
+}
+
+// newPaymentIndexer follows PaymentCoreV1 for PaymentDetected logs and
+// finalises the payments it records.
+func newPaymentIndexer(cfg *config.Config, bundle *contracts.Bundle, tokens *service.TokenSet, db *postgres.DB, log *slog.Logger) (*indexer.Indexer, *indexer.Tracker, error) {
+	decoder, err := tron.NewPaymentEventDecoder(bundle.PaymentCore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := tron.NewClient(cfg.TronAPIBase, cfg.TronAPIKey, nil)
+	txr := postgres.NewTransactor(db.SQL)
+	payments := postgres.NewPaymentRepo(db.SQL)
+
+	ix := indexer.New(client, decoder, txr, payments, postgres.NewChainCursorRepo(db.SQL), tokens, log)
+	ix.StartBlock = int64(cfg.TronIndexerStartBlock)
+
+	settler := service.NewPaymentService(postgres.NewOrderRepo(db.SQL), payments, tokens)
+	tracker := indexer.NewTracker(client, txr, payments, settler, log)
+	tracker.Confirmations = int64(cfg.TronPaymentConfirmations)
+	tracker.RevertAfter = cfg.TronPaymentRevertAfterMisses
+
+	return ix, tracker, nil
+}
+
+func (w *Worker) Close() {
+	_ = w.Publisher.Close()
+	_ = w.RabbitConn.Close()
+	_ = w.DB.Close()
+}
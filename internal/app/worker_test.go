@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/queue/rabbit"
+	service "token13/merchant-backend-go/internal/services"
+)
+
+// Every event the service publishes must reach a queue: the publisher
+// routes with mandatory=true and the relay parks unroutable messages.
+func TestEveryEventTypeIsRouted(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := &Worker{Log: log, Consumers: rabbit.NewConsumers(nil, "token13.events", events.Default, log)}
+	w.register()
+
+	routed := map[string]bool{}
+	for _, key := range w.Consumers.RoutingKeys() {
+		routed[key] = true
+	}
+	for _, feed := range []rabbit.FeedTopology{notificationFeed, alertFeed} {
+		for _, key := range feed.RoutingKeys {
+			routed[key] = true
+		}
+	}
+
+	for _, typ := range events.Default.Types() {
+		if !routed[typ] {
+			t.Errorf("%s has no queue bound", typ)
+		}
+	}
+}
+
+func TestGiveUpBudgetModes(t *testing.T) {
+	w := &Worker{}
+	fail := func(context.Context) error { return nil }
+
+	tests := []struct {
+		name    string
+		err     error
+		delayed bool
+	}{
+		{"queue mode holds the job back", fmt.Errorf("%w: short", service.ErrOperatorLowResources), true},
+		{"refuse mode retries it", fmt.Errorf("%w: short", service.ErrOperatorBudgetRefused), false},
+		{"other errors retry", errors.New("node down"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.giveUp(tt.err, fail)
+			if got := rabbit.IsDelayed(err); got != tt.delayed {
+				t.Fatalf("delayed = %v, want %v", got, tt.delayed)
+			}
+			if rabbit.IsPermanent(err) {
+				t.Fatal("budget errors must not park the job")
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("giveUp lost the cause: %v", err)
+			}
+		})
+	}
+}
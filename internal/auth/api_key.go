@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key, telling it apart from a JWT.
+const APIKeyPrefix = "tk_"
+
+// NewAPIKey returns a key "tk_<prefix>_<secret>", its public prefix and the
+// hash of its secret. Only prefix and hash are stored.
+func NewAPIKey() (key, prefix string, secretHash []byte, err error) {
+	p := make([]byte, 6)
+	s := make([]byte, 32)
+	if _, err := rand.Read(p); err != nil {
+		return "", "", nil, fmt.Errorf("generate api key: %w", err)
+	}
+	if _, err := rand.Read(s); err != nil {
+		return "", "", nil, fmt.Errorf("generate api key: %w", err)
+	}
+	prefix = hex.EncodeToString(p)
+	secret := base64.RawURLEncoding.EncodeToString(s)
+	return APIKeyPrefix + prefix + "_" + secret, prefix, HashAPIKeySecret(secret), nil
+}
+
+// ParseAPIKey splits key into its prefix and secret.
+func ParseAPIKey(key string) (prefix, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	prefix, secret, ok = strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// HashAPIKeySecret is what is stored for a key's secret. The secret is
+// random, so a fast hash is enough.
+func HashAPIKeySecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
@@ -3,12 +3,20 @@ package auth
 import (
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Roles a user can have (users.role).
+const (
+	RoleAdmin    = "ADMIN"
+	RoleOperator = "OPERATOR"
+	RoleMerchant = "MERCHANT"
+)
+
 type Claims struct {
 	UserUID string `json:"uid"`
 	Email   string `json:"email,omitempty"`
@@ -17,21 +25,77 @@ type Claims struct {
 	// Canonical merchant_id (bytes32) encoded as 0x-prefixed hex string for JWT/clients
 	MerchantID string `json:"merchant_id,omitempty"`
 
+	// Set instead of UserUID when the caller authenticated with a merchant
+	// API key, which may only do what Scopes allow. Never part of a JWT.
+	APIKeyID int64    `json:"-"`
+	Scopes   []string `json:"-"`
+
 	jwt.RegisteredClaims
 }
 
+// JWTManager signs access tokens with one asymmetric key and verifies
+// them against every key it holds, so keys can be rotated: add the new
+// key, sign with it, and keep the old one (public part is enough) until
+// its tokens have expired.
 type JWTManager struct {
-	Secret []byte
-	Issuer string
-	TTL    time.Duration
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+
+	signing Key
+	keys    map[string]Key // by kid
+	parser  *jwt.Parser
+}
+
+// NewJWTManager signs with the key signingKID, or with the only private
+// key in keys when signingKID is empty.
+func NewJWTManager(keys []Key, signingKID, issuer, audience string, ttl time.Duration) (*JWTManager, error) {
+	m := &JWTManager{
+		Issuer:   issuer,
+		Audience: audience,
+		TTL:      ttl,
+		keys:     make(map[string]Key, len(keys)),
+	}
+
+	var signers []Key
+	for _, k := range keys {
+		if _, dup := m.keys[k.ID]; dup {
+			return nil, fmt.Errorf("jwt: duplicate kid %q", k.ID)
+		}
+		m.keys[k.ID] = k
+		if k.Private != nil && (signingKID == "" || k.ID == signingKID) {
+			signers = append(signers, k)
+		}
+	}
+	switch {
+	case len(signers) == 1:
+		m.signing = signers[0]
+	case signingKID != "":
+		return nil, fmt.Errorf("jwt: no private key with kid %q", signingKID)
+	case len(signers) == 0:
+		return nil, fmt.Errorf("jwt: no private key to sign with")
+	default:
+		return nil, fmt.Errorf("jwt: %d private keys, set the signing kid", len(signers))
+	}
+
+	m.parser = jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	return m, nil
 }
 
-func NewJWTManager(secret, issuer string, ttl time.Duration) *JWTManager {
-	return &JWTManager{
-		Secret: []byte(secret),
-		Issuer: issuer,
-		TTL:    ttl,
+// JWKS returns the public keys tokens are verified with.
+func (m *JWTManager) JWKS() JWKS {
+	out := JWKS{Keys: make([]JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		out.Keys = append(out.Keys, k.JWK())
 	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Kid < out.Keys[j].Kid })
+	return out
 }
 
 // MerchantIDBytesToHex converts a 32-byte merchant_id to "0x..." hex string.
@@ -81,13 +145,15 @@ func (m *JWTManager) Sign(userUID, email, role string, merchantID []byte) (token
 		MerchantID: merchantHex,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    m.Issuer,
+			Audience:  jwt.ClaimStrings{m.Audience},
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(exp),
 		},
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := t.SignedString(m.Secret)
+	t := jwt.NewWithClaims(signingMethod(m.signing.Public), claims)
+	t.Header["kid"] = m.signing.ID
+	signed, err := t.SignedString(m.signing.Private)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -95,8 +161,17 @@ func (m *JWTManager) Sign(userUID, email, role string, merchantID []byte) (token
 }
 
 func (m *JWTManager) Verify(tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (any, error) {
-		return m.Secret, nil
+	token, err := m.parser.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, ok := m.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		// A key only verifies its own algorithm.
+		if t.Method.Alg() != k.Alg() {
+			return nil, fmt.Errorf("alg %s does not match key %q", t.Method.Alg(), kid)
+		}
+		return k.Public, nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("jwt parse: %w", err)
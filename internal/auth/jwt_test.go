@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testMerchantID = bytes.Repeat([]byte{0x42}, 32)
+
+func edKey(t *testing.T, kid string) Key {
+	t.Helper()
+	k, err := GenerateKey(kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func rsaTestKey(t *testing.T, kid string) Key {
+	priv := testRSAKey(t)
+	return Key{ID: kid, Private: priv, Public: &priv.PublicKey}
+}
+
+// public is k as a retired key: it only verifies.
+func public(k Key) Key {
+	return Key{ID: k.ID, Public: k.Public}
+}
+
+// tokenHeader returns token's JOSE header.
+func tokenHeader(t *testing.T, token string) map[string]any {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Header
+}
+
+func newManager(t *testing.T, keys []Key, signingKID string) *JWTManager {
+	t.Helper()
+	m, err := NewJWTManager(keys, signingKID, "token13", "token13-api", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestJWTSignVerify(t *testing.T) {
+	for _, k := range []Key{edKey(t, "ed"), rsaTestKey(t, "rsa")} {
+		t.Run(k.Alg(), func(t *testing.T) {
+			m := newManager(t, []Key{k}, "")
+
+			token, exp, err := m.Sign("user-1", "a@b.c", RoleMerchant, testMerchantID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := time.Until(exp); d < 59*time.Minute || d > time.Hour {
+				t.Fatalf("expires in %s", d)
+			}
+			if h := tokenHeader(t, token); h["kid"] != k.ID || h["alg"] != k.Alg() {
+				t.Fatalf("header = %v", h)
+			}
+
+			c, err := m.Verify(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.UserUID != "user-1" || c.Role != RoleMerchant || c.MerchantID != "0x"+strings.Repeat("42", 32) {
+				t.Fatalf("claims = %+v", c)
+			}
+		})
+	}
+}
+
+// Tokens signed with the old key keep verifying after the switch for as
+// long as its public key is kept.
+func TestJWTRotation(t *testing.T) {
+	old, next := edKey(t, "2025-09"), rsaTestKey(t, "2026-03")
+	oldToken, _, err := newManager(t, []Key{old}, "").Sign("user-1", "", RoleAdmin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newManager(t, []Key{public(old), next}, "")
+	if _, err := rotated.Verify(oldToken); err != nil {
+		t.Fatalf("old token after rotation: %v", err)
+	}
+	newToken, _, err := rotated.Sign("user-1", "", RoleAdmin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenHeader(t, newToken)["kid"]; kid != "2026-03" {
+		t.Fatalf("signed with kid %v", kid)
+	}
+
+	// Both keys private: the signing kid picks one.
+	both := newManager(t, []Key{old, next}, "2025-09")
+	if tok, _, _ := both.Sign("user-1", "", RoleAdmin, nil); tokenHeader(t, tok)["kid"] != "2025-09" {
+		t.Fatal("signing kid ignored")
+	}
+
+	retired := newManager(t, []Key{next}, "")
+	if _, err := retired.Verify(oldToken); err == nil || !strings.Contains(err.Error(), `unknown kid "2025-09"`) {
+		t.Fatalf("token of a dropped key: %v", err)
+	}
+}
+
+func TestJWTVerifyRejects(t *testing.T) {
+	ed, rsaKey := edKey(t, "ed"), rsaTestKey(t, "rsa")
+	m := newManager(t, []Key{ed, public(rsaKey)}, "")
+
+	claims := func(mod func(c *Claims)) *Claims {
+		now := time.Now()
+		c := &Claims{UserUID: "user-1", Role: RoleAdmin, RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "token13",
+			Audience:  jwt.ClaimStrings{"token13-api"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		}}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, c *Claims, key any) string {
+		tok := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{name: "wrong issuer", token: sign(jwt.SigningMethodEdDSA, "ed", claims(func(c *Claims) { c.Issuer = "other" }), ed.Private), err: "issuer"},
+		{name: "wrong audience", token: sign(jwt.SigningMethodEdDSA, "ed", claims(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }), ed.Private), err: "audience"},
+		{name: "expired", token: sign(jwt.SigningMethodEdDSA, "ed", claims(func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }), ed.Private), err: "expired"},
+		{name: "no expiry", token: sign(jwt.SigningMethodEdDSA, "ed", claims(func(c *Claims) { c.ExpiresAt = nil }), ed.Private), err: "exp claim is required"},
+		{name: "no kid", token: sign(jwt.SigningMethodEdDSA, "", claims(nil), ed.Private), err: `unknown kid ""`},
+		{name: "HS256 with the public key", token: sign(jwt.SigningMethodHS256, "ed", claims(nil), []byte(ed.Public.(ed25519.PublicKey))), err: "signing method HS256 is invalid"},
+		{name: "alg of another key", token: sign(jwt.SigningMethodRS256, "ed", claims(nil), testRSAKey(t)), err: `alg RS256 does not match key "ed"`},
+		{name: "forged signature", token: sign(jwt.SigningMethodEdDSA, "ed", claims(nil), edKey(t, "ed").Private), err: "signature is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Verify(tt.token); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewJWTManagerSigningKey(t *testing.T) {
+	a, b := edKey(t, "a"), edKey(t, "b")
+	tests := []struct {
+		name string
+		keys []Key
+		kid  string
+		err  string
+	}{
+		{name: "only public keys", keys: []Key{public(a)}, err: "no private key to sign with"},
+		{name: "two private keys", keys: []Key{a, b}, err: "2 private keys, set the signing kid"},
+		{name: "signing kid is public", keys: []Key{public(a), b}, kid: "a", err: `no private key with kid "a"`},
+		{name: "duplicate kid", keys: []Key{a, public(a)}, err: `duplicate kid "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewJWTManager(tt.keys, tt.kid, "token13", "token13-api", time.Hour); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ed, rsaKey := edKey(t, "b-ed"), rsaTestKey(t, "a-rsa")
+	jwks := newManager(t, []Key{ed, public(rsaKey)}, "").JWKS()
+
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "a-rsa" || jwks.Keys[1].Kid != "b-ed" {
+		t.Fatalf("keys = %+v", jwks.Keys)
+	}
+
+	r := jwks.Keys[0]
+	pub := rsaKey.Public.(*rsa.PublicKey)
+	n, _ := base64.RawURLEncoding.DecodeString(r.N)
+	e, _ := base64.RawURLEncoding.DecodeString(r.E)
+	if r.Kty != "RSA" || r.Alg != "RS256" || r.Use != "sig" || new(big.Int).SetBytes(n).Cmp(pub.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(pub.E) {
+		t.Fatalf("RSA JWK = %+v", r)
+	}
+
+	o := jwks.Keys[1]
+	x, _ := base64.RawURLEncoding.DecodeString(o.X)
+	if o.Kty != "OKP" || o.Crv != "Ed25519" || o.Alg != "EdDSA" || !bytes.Equal(x, ed.Public.(ed25519.PublicKey)) || o.N != "" {
+		t.Fatalf("OKP JWK = %+v", o)
+	}
+}
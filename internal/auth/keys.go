@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA modulus accepted for RS256.
+const minRSABits = 2048
+
+// Key is one JWT key. Private is nil for keys that only verify, e.g. a
+// retired signing key kept until the tokens it signed have expired.
+type Key struct {
+	ID      string // kid
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// Alg returns the JWS algorithm of the key: RS256 or EdDSA.
+func (k Key) Alg() string {
+	return signingMethod(k.Public).Alg()
+}
+
+func signingMethod(pub crypto.PublicKey) jwt.SigningMethod {
+	if _, ok := pub.(ed25519.PublicKey); ok {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// LoadKeys reads every *.pem file of dir as a key whose kid is the file
+// name without extension. A file holds either a private key (PKCS#8, or
+// PKCS#1 for RSA) or a public key (PKIX).
+func LoadKeys(dir string) ([]Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var keys []Key
+	for _, p := range paths {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key: %w", err)
+		}
+		k, err := ParseKey(strings.TrimSuffix(filepath.Base(p), ".pem"), raw)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", filepath.Base(p), err)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no jwt keys (*.pem) in %s", dir)
+	}
+	return keys, nil
+}
+
+// ParseKey parses a PEM-encoded RSA or Ed25519 key.
+func ParseKey(kid string, pemBytes []byte) (Key, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return Key{}, fmt.Errorf("no PEM block")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	k := Key{ID: kid}
+	switch v := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.Private, k.Public = v, &v.PublicKey
+	case ed25519.PrivateKey:
+		k.Private, k.Public = v, v.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		k.Public = v
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T: want RSA or Ed25519", parsed)
+	}
+	if pub, ok := k.Public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return Key{}, fmt.Errorf("RSA key has %d bits, want at least %d", pub.N.BitLen(), minRSABits)
+	}
+	return k, nil
+}
+
+// GenerateKey returns a fresh Ed25519 key. It is meant for development:
+// tokens signed with it stop verifying once the process exits.
+func GenerateKey(kid string) (Key, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, fmt.Errorf("generate jwt key: %w", err)
+	}
+	return Key{ID: kid, Private: priv, Public: pub}, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517, 8037).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k Key) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Alg: k.Alg(), Use: "sig"}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
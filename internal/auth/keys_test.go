@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	rsaOnce sync.Once
+	rsaKey  *rsa.PrivateKey
+)
+
+// testRSAKey is a 2048-bit key shared by the tests; generating one is slow.
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	rsaOnce.Do(func() {
+		rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	if rsaKey == nil {
+		t.Fatal("generate RSA key")
+	}
+	return rsaKey
+}
+
+func pemBlock(t *testing.T, typ string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func TestLoadKeys(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv := testRSAKey(t)
+
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	pubDER, pubErr := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	files := map[string][]byte{
+		"2026-03.pem":    pemBlock(t, "PRIVATE KEY", edDER, err),
+		"2025-09.pem":    pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPriv), nil),
+		"2025-01.pem":    pemBlock(t, "PUBLIC KEY", pubDER, pubErr),
+		"README.md":      []byte("not a key"),
+		"2026-03.pem.gz": []byte("not a key either"),
+	}
+	dir := t.TempDir()
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := LoadKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kid     string
+		alg     string
+		private bool
+	}{
+		{"2025-01", "RS256", false},
+		{"2025-09", "RS256", true},
+		{"2026-03", "EdDSA", true},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("loaded %d keys, want %d", len(keys), len(want))
+	}
+	for i, w := range want {
+		k := keys[i]
+		if k.ID != w.kid || k.Alg() != w.alg || (k.Private != nil) != w.private {
+			t.Fatalf("keys[%d] = %s %s private %v, want %+v", i, k.ID, k.Alg(), k.Private != nil, w)
+		}
+	}
+
+	if _, err := LoadKeys(t.TempDir()); err == nil || !strings.Contains(err.Error(), "no jwt keys") {
+		t.Fatalf("empty dir: %v", err)
+	}
+}
+
+func TestParseKeyRejects(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, ecErr := x509.MarshalPKCS8PrivateKey(ec)
+
+	tests := []struct {
+		name string
+		pem  []byte
+		err  string
+	}{
+		{name: "not PEM", pem: []byte("-----"), err: "no PEM block"},
+		{name: "certificate", pem: pemBlock(t, "CERTIFICATE", []byte{1}, nil), err: `unsupported PEM block "CERTIFICATE"`},
+		{name: "short RSA", pem: pemBlock(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(small), nil), err: "RSA key has 1024 bits"},
+		{name: "ECDSA", pem: pemBlock(t, "PRIVATE KEY", ecDER, ecErr), err: "unsupported key type *ecdsa.PrivateKey"},
+		{name: "corrupt", pem: pemBlock(t, "PRIVATE KEY", []byte{1, 2, 3}, nil), err: "asn1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseKey("k", tt.pem); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		token, hash, err := NewRefreshToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(token) != 43 || seen[token] {
+			t.Fatalf("token %q is reused or not 32 bytes of base64url", token)
+		}
+		seen[token] = true
+		if !bytes.Equal(hash, HashRefreshToken(token)) || len(hash) != 32 {
+			t.Fatalf("hash %x does not look the token up", hash)
+		}
+	}
+	if bytes.Equal(HashRefreshToken("a"), HashRefreshToken("b")) {
+		t.Fatal("different tokens share a hash")
+	}
+}
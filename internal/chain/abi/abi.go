@@ -0,0 +1,144 @@
+// Package abi parses Solidity/TVM contract ABIs and encodes and decodes
+// call data, return data, event logs and revert reasons.
+//
+// Supported types: address, bool, string, bytes, bytes1..bytes32 and
+// uint8..uint256. Addresses are Tron addresses (domain.TronAddress); they
+// are also accepted as base58check/hex strings or raw bytes when encoding.
+package abi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Argument is one input or output of a method, event or error.
+type Argument struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Indexed bool   `json:"indexed"` // events only
+}
+
+// Method is a contract function.
+type Method struct {
+	Name      string
+	Inputs    []Argument
+	Outputs   []Argument
+	Signature string // canonical, e.g. "onboardMerchant(bytes32,address)"
+	Selector  []byte // first 4 bytes of keccak256(Signature)
+}
+
+// Event is a contract event.
+type Event struct {
+	Name      string
+	Inputs    []Argument
+	Anonymous bool
+	Signature string
+	Topic     []byte // keccak256(Signature), topics[0] of its logs
+}
+
+// Error is a custom error, e.g. RevertedWithCode(uint16).
+type Error struct {
+	Name      string
+	Inputs    []Argument
+	Signature string
+	Selector  []byte
+}
+
+// ABI is a parsed contract ABI.
+type ABI struct {
+	Methods map[string]Method
+	Events  map[string]Event
+	Errors  map[string]Error
+}
+
+type entry struct {
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Inputs    []Argument `json:"inputs"`
+	Outputs   []Argument `json:"outputs"`
+	Anonymous bool       `json:"anonymous"`
+}
+
+// Parse reads a JSON ABI as stored in contract.json. Constructors,
+// fallback and receive entries are skipped. Entries using types this
+// package cannot encode are kept, and fail when used.
+func Parse(raw json.RawMessage) (*ABI, error) {
+	var entries []entry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("parse abi: %w", err)
+	}
+
+	a := &ABI{
+		Methods: map[string]Method{},
+		Events:  map[string]Event{},
+		Errors:  map[string]Error{},
+	}
+	for _, e := range entries {
+		sig := signature(e.Name, e.Inputs)
+		switch strings.ToLower(e.Type) {
+		case "function":
+			if _, dup := a.Methods[e.Name]; dup {
+				return nil, fmt.Errorf("parse abi: overloaded function %s is not supported", e.Name)
+			}
+			a.Methods[e.Name] = Method{
+				Name:      e.Name,
+				Inputs:    e.Inputs,
+				Outputs:   e.Outputs,
+				Signature: sig,
+				Selector:  Keccak256([]byte(sig))[:4],
+			}
+		case "event":
+			a.Events[e.Name] = Event{
+				Name:      e.Name,
+				Inputs:    e.Inputs,
+				Anonymous: e.Anonymous,
+				Signature: sig,
+				Topic:     Keccak256([]byte(sig)),
+			}
+		case "error":
+			a.Errors[e.Name] = Error{
+				Name:      e.Name,
+				Inputs:    e.Inputs,
+				Signature: sig,
+				Selector:  Keccak256([]byte(sig))[:4],
+			}
+		}
+	}
+	return a, nil
+}
+
+func (a *ABI) Method(name string) (Method, error) {
+	m, ok := a.Methods[name]
+	if !ok {
+		return Method{}, fmt.Errorf("function %s not found in abi", name)
+	}
+	return m, nil
+}
+
+func (a *ABI) Event(name string) (Event, error) {
+	e, ok := a.Events[name]
+	if !ok {
+		return Event{}, fmt.Errorf("event %s not found in abi", name)
+	}
+	return e, nil
+}
+
+// Keccak256 is the legacy (pre-FIPS) Keccak used by the EVM and TVM.
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+func signature(name string, args []Argument) string {
+	types := make([]string, 0, len(args))
+	for _, a := range args {
+		types = append(types, a.Type)
+	}
+	return name + "(" + strings.Join(types, ",") + ")"
+}
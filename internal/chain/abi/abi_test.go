@@ -0,0 +1,276 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// Golden values were computed independently of this package (legacy
+// Keccak-256 of the canonical signatures).
+
+const usdt = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t" // 41a614f803b6fd780986a42c78ec9c7f77e6ded13c
+
+// echoABI has one function per type, whose outputs mirror its inputs.
+const echoABI = `[
+	{"type":"function","name":"echo",
+	 "inputs":[{"name":"id","type":"bytes32"},{"name":"who","type":"address"},{"name":"n","type":"uint256"},{"name":"ok","type":"bool"},{"name":"s","type":"string"}],
+	 "outputs":[{"name":"id","type":"bytes32"},{"name":"who","type":"address"},{"name":"n","type":"uint256"},{"name":"ok","type":"bool"},{"name":"s","type":"string"}]}
+]`
+
+func loadBundle(t *testing.T) *contracts.Bundle {
+	t.Helper()
+	b, err := contracts.Load("../../config/contract.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func mustParse(t *testing.T, raw []byte) *ABI {
+	t.Helper()
+	a, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func word(n uint64) []byte {
+	return new(big.Int).SetUint64(n).FillBytes(make([]byte, 32))
+}
+
+func TestSelectorsAndTopics(t *testing.T) {
+	b := loadBundle(t)
+	registry := mustParse(t, b.MerchantRegistry.ABI)
+	core := mustParse(t, b.PaymentCore.ABI)
+
+	methods := []struct {
+		abi  *ABI
+		name string
+		want string
+	}{
+		{registry, "onboardMerchant", "1d1a1bb4"},
+		{registry, "updateMerchantStatus", "465a441c"},
+	}
+	for _, tt := range methods {
+		m, err := tt.abi.Method(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(m.Selector); got != tt.want {
+			t.Errorf("%s selector = %s, want %s", m.Signature, got, tt.want)
+		}
+	}
+
+	events := []struct {
+		abi  *ABI
+		name string
+		want string
+	}{
+		{core, "PaymentDetected", "a690b264336bd3a5548e783072f1127a58a7921d0bef1e52125741b5fb13ef07"},
+		{registry, "MerchantStatusUpdated", "64c32d2b25ce0efd217cc68af894764282c5e499870c8650ec3b769a582a0821"},
+	}
+	for _, tt := range events {
+		e, err := tt.abi.Event(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(e.Topic); got != tt.want {
+			t.Errorf("%s topic = %s, want %s", e.Signature, got, tt.want)
+		}
+	}
+}
+
+func TestCallDataOnboardMerchant(t *testing.T) {
+	m, err := mustParse(t, loadBundle(t).MerchantRegistry.ABI).Method("onboardMerchant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merchantID := mustHex(t, "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20")
+
+	// The 0x41 prefix of the Tron address is stripped to the 20-byte account.
+	want := mustHex(t, "1d1a1bb4"+
+		"0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"+
+		"000000000000000000000000a614f803b6fd780986a42c78ec9c7f77e6ded13c")
+	for _, addr := range []any{usdt, "41a614f803b6fd780986a42c78ec9c7f77e6ded13c"} {
+		got, err := m.CallData(merchantID, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("CallData(%v) =\n%x\nwant\n%x", addr, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	m, err := mustParse(t, []byte(echoABI)).Method("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := mustHex(t, "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20")
+	who, err := domain.ParseTronAddress(usdt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := new(big.Int).SetString("100000000000000000000000000000000000000000000003039", 16) // 2^200 + 12345
+
+	packed, err := m.Pack(id, who, n, true, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustHex(t, ""+
+		"0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"+
+		"000000000000000000000000a614f803b6fd780986a42c78ec9c7f77e6ded13c"+
+		"0000000000000100000000000000000000000000000000000000000000003039"+
+		"0000000000000000000000000000000000000000000000000000000000000001"+
+		"00000000000000000000000000000000000000000000000000000000000000a0"+ // offset of s
+		"0000000000000000000000000000000000000000000000000000000000000005"+
+		"68656c6c6f000000000000000000000000000000000000000000000000000000")
+	if !bytes.Equal(packed, want) {
+		t.Fatalf("Pack =\n%x\nwant\n%x", packed, want)
+	}
+
+	out, err := m.Unpack(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out[0].([]byte); !bytes.Equal(got, id) {
+		t.Errorf("bytes32 = %x, want %x", got, id)
+	}
+	if got := out[1].(domain.TronAddress); got != who || got.String() != usdt {
+		t.Errorf("address = %s, want %s", got, usdt)
+	}
+	if got := out[2].(*big.Int); got.Cmp(n) != 0 {
+		t.Errorf("uint256 = %s, want %s", got, n)
+	}
+	if got := out[3].(bool); !got {
+		t.Errorf("bool = false, want true")
+	}
+	if got := out[4].(string); got != "hello" {
+		t.Errorf("string = %q, want %q", got, "hello")
+	}
+}
+
+func TestEncodeRejectsOutOfRange(t *testing.T) {
+	a := mustParse(t, []byte(`[{"type":"function","name":"f","inputs":[{"name":"c","type":"uint16"}],"outputs":[]}]`))
+	m, _ := a.Method("f")
+	if _, err := m.Pack(uint64(1 << 16)); err == nil {
+		t.Fatal("uint16 accepted 65536")
+	}
+	if _, err := m.Pack(int64(-1)); err == nil {
+		t.Fatal("uint16 accepted -1")
+	}
+}
+
+func TestDecodePaymentDetected(t *testing.T) {
+	e, err := mustParse(t, loadBundle(t).PaymentCore.ABI).Event("PaymentDetected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merchantID, orderID, invoiceID := word(1), word(2), word(3)
+	amount, _ := new(big.Int).SetString("340282366920938463463374607431768211457", 10) // 2^128 + 1
+
+	data := append(mustHex(t, "000000000000000000000000a614f803b6fd780986a42c78ec9c7f77e6ded13c"),
+		amount.FillBytes(make([]byte, 32))...)
+	data = append(data, word(1700000000)...)
+
+	got, err := e.Decode(Log{Topics: [][]byte{e.Topic, merchantID, orderID, invoiceID}, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got["invoiceId"].([]byte), invoiceID) {
+		t.Errorf("invoiceId = %x", got["invoiceId"])
+	}
+	if a := got["paymentToken"].(domain.TronAddress); a.String() != usdt {
+		t.Errorf("paymentToken = %s, want %s", a, usdt)
+	}
+	if a := got["amount"].(*big.Int); a.Cmp(amount) != 0 {
+		t.Errorf("amount = %s, want %s", a, amount)
+	}
+	if ts := got["timestamp"].(*big.Int); ts.Uint64() != 1700000000 {
+		t.Errorf("timestamp = %s", ts)
+	}
+
+	if _, err := e.Decode(Log{Topics: [][]byte{e.Topic, merchantID}, Data: data}); err == nil {
+		t.Error("decoded a log with missing topics")
+	}
+}
+
+func TestDecodeRevert(t *testing.T) {
+	registry := mustParse(t, loadBundle(t).MerchantRegistry.ABI)
+
+	tests := []struct {
+		name        string
+		data        string
+		wantName    string
+		wantCode    uint64
+		wantMessage string
+	}{
+		{
+			name: "Error(string)",
+			data: "08c379a0" +
+				"0000000000000000000000000000000000000000000000000000000000000020" +
+				"0000000000000000000000000000000000000000000000000000000000000004" +
+				"626f6f6d00000000000000000000000000000000000000000000000000000000",
+			wantName:    "Error",
+			wantMessage: "boom",
+		},
+		{
+			name:     "Panic(uint256)",
+			data:     "4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011",
+			wantName: "Panic",
+			wantCode: 0x11,
+		},
+		{
+			name:     "RevertedWithCode(uint16)",
+			data:     "e9d31671" + "0000000000000000000000000000000000000000000000000000000000000007",
+			wantName: "RevertedWithCode",
+			wantCode: 7,
+		},
+		{
+			name: "RevertedWithMessage(string)",
+			data: "fcf6d37a" +
+				"0000000000000000000000000000000000000000000000000000000000000020" +
+				"0000000000000000000000000000000000000000000000000000000000000009" +
+				"6e6f74206f776e65720000000000000000000000000000000000000000000000",
+			wantName:    "RevertedWithMessage",
+			wantMessage: "not owner",
+		},
+		{
+			name: "unknown selector",
+			data: "deadbeef",
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustHex(t, tt.data)
+			re := registry.DecodeRevert(data)
+			if re.Name != tt.wantName || re.Code != tt.wantCode || re.Message != tt.wantMessage {
+				t.Errorf("DecodeRevert = {%q %d %q}, want {%q %d %q}",
+					re.Name, re.Code, re.Message, tt.wantName, tt.wantCode, tt.wantMessage)
+			}
+			if !bytes.Equal(re.Data, data) {
+				t.Errorf("Data = %x, want %x", re.Data, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package abi
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// Unpack decodes a method's return data. Values are returned in output
+// order as: address -> domain.TronAddress, bool -> bool, uintN -> *big.Int,
+// bytesN and bytes -> []byte, string -> string.
+func (m Method) Unpack(data []byte) ([]any, error) {
+	out, err := decodeArgs(m.Outputs, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Name, err)
+	}
+	return out, nil
+}
+
+// Log is a raw event log: topics and data as emitted by the contract.
+type Log struct {
+	Topics [][]byte
+	Data   []byte
+}
+
+// Matches reports whether l was emitted for e (always false for anonymous
+// events, which carry no signature topic).
+func (e Event) Matches(l Log) bool {
+	return !e.Anonymous && len(l.Topics) > 0 && bytes.Equal(l.Topics[0], e.Topic)
+}
+
+// Decode returns the event's arguments by name. Indexed arguments come
+// from the topics; indexed dynamic values are only available as their
+// keccak256 hash ([]byte).
+func (e Event) Decode(l Log) (map[string]any, error) {
+	topics := l.Topics
+	if !e.Anonymous {
+		if !e.Matches(l) {
+			return nil, fmt.Errorf("%s: log does not match event topic", e.Name)
+		}
+		topics = topics[1:]
+	}
+
+	var indexed, plain []Argument
+	for _, in := range e.Inputs {
+		if in.Indexed {
+			indexed = append(indexed, in)
+		} else {
+			plain = append(plain, in)
+		}
+	}
+	if len(topics) != len(indexed) {
+		return nil, fmt.Errorf("%s: expected %d indexed topics, got %d", e.Name, len(indexed), len(topics))
+	}
+
+	out := make(map[string]any, len(e.Inputs))
+	for i, in := range indexed {
+		t, err := parseType(in.Type)
+		if err != nil {
+			return nil, err
+		}
+		if len(topics[i]) != 32 {
+			return nil, fmt.Errorf("%s: topic %s is %d bytes", e.Name, in.Name, len(topics[i]))
+		}
+		if t.dynamic() {
+			out[in.Name] = append([]byte(nil), topics[i]...)
+			continue
+		}
+		v, err := decodeStatic(t, topics[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", e.Name, in.Name, err)
+		}
+		out[in.Name] = v
+	}
+
+	values, err := decodeArgs(plain, l.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.Name, err)
+	}
+	for i, in := range plain {
+		out[in.Name] = values[i]
+	}
+	return out, nil
+}
+
+func decodeArgs(params []Argument, data []byte) ([]any, error) {
+	if len(data) < 32*len(params) {
+		return nil, fmt.Errorf("data too short: %d bytes for %d values", len(data), len(params))
+	}
+
+	out := make([]any, 0, len(params))
+	for i, p := range params {
+		t, err := parseType(p.Type)
+		if err != nil {
+			return nil, err
+		}
+		word := data[32*i : 32*(i+1)]
+
+		if t.dynamic() {
+			v, err := decodeDynamic(t, data, word)
+			if err != nil {
+				return nil, fmt.Errorf("value %d (%s): %w", i, p.Type, err)
+			}
+			out = append(out, v)
+			continue
+		}
+
+		v, err := decodeStatic(t, word)
+		if err != nil {
+			return nil, fmt.Errorf("value %d (%s): %w", i, p.Type, err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func decodeStatic(t abiType, word []byte) (any, error) {
+	switch t.kind {
+	case kindAddress:
+		if !isZero(word[:12]) {
+			return nil, fmt.Errorf("malformed address word")
+		}
+		addr, err := domain.TronAddressFromBytes(word[12:])
+		if err != nil {
+			return nil, err
+		}
+		return addr, nil
+
+	case kindBool:
+		if !isZero(word[:31]) || word[31] > 1 {
+			return nil, fmt.Errorf("malformed bool word")
+		}
+		return word[31] == 1, nil
+
+	case kindUint:
+		n := new(big.Int).SetBytes(word)
+		if n.BitLen() > t.size {
+			return nil, fmt.Errorf("value out of range for uint%d", t.size)
+		}
+		return n, nil
+
+	case kindFixedBytes:
+		if !isZero(word[t.size:]) {
+			return nil, fmt.Errorf("malformed bytes%d word", t.size)
+		}
+		return append([]byte(nil), word[:t.size]...), nil
+	}
+	return nil, fmt.Errorf("not a static type")
+}
+
+func decodeDynamic(t abiType, data, offsetWord []byte) (any, error) {
+	offset, err := wordToInt(offsetWord, len(data))
+	if err != nil {
+		return nil, fmt.Errorf("offset: %w", err)
+	}
+	if offset+32 > len(data) {
+		return nil, fmt.Errorf("offset %d out of range", offset)
+	}
+	length, err := wordToInt(data[offset:offset+32], len(data))
+	if err != nil {
+		return nil, fmt.Errorf("length: %w", err)
+	}
+	start := offset + 32
+	if start+length > len(data) {
+		return nil, fmt.Errorf("length %d out of range", length)
+	}
+
+	b := append([]byte(nil), data[start:start+length]...)
+	if t.kind == kindString {
+		return string(b), nil
+	}
+	return b, nil
+}
+
+// wordToInt reads a 32-byte word as an int no larger than limit.
+func wordToInt(word []byte, limit int) (int, error) {
+	n := new(big.Int).SetBytes(word)
+	if !n.IsInt64() || n.Int64() > int64(limit) {
+		return 0, fmt.Errorf("value %s out of range", n)
+	}
+	return int(n.Int64()), nil
+}
+
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package abi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type kind int
+
+const (
+	kindAddress kind = iota
+	kindBool
+	kindUint
+	kindFixedBytes
+	kindString
+	kindBytes
+)
+
+// abiType is a parsed elementary type; size is the bit width of uintN or
+// the byte length of bytesN.
+type abiType struct {
+	kind kind
+	size int
+}
+
+func (t abiType) dynamic() bool {
+	return t.kind == kindString || t.kind == kindBytes
+}
+
+func parseType(s string) (abiType, error) {
+	switch {
+	case s == "address":
+		return abiType{kind: kindAddress}, nil
+	case s == "bool":
+		return abiType{kind: kindBool}, nil
+	case s == "string":
+		return abiType{kind: kindString}, nil
+	case s == "bytes":
+		return abiType{kind: kindBytes}, nil
+	case strings.HasPrefix(s, "uint"):
+		bits := 256
+		if s != "uint" {
+			n, err := strconv.Atoi(s[len("uint"):])
+			if err != nil || n <= 0 || n > 256 || n%8 != 0 {
+				return abiType{}, fmt.Errorf("abi: invalid type %s", s)
+			}
+			bits = n
+		}
+		return abiType{kind: kindUint, size: bits}, nil
+	case strings.HasPrefix(s, "bytes"):
+		n, err := strconv.Atoi(s[len("bytes"):])
+		if err != nil || n <= 0 || n > 32 {
+			return abiType{}, fmt.Errorf("abi: invalid type %s", s)
+		}
+		return abiType{kind: kindFixedBytes, size: n}, nil
+	}
+	return abiType{}, fmt.Errorf("abi: unsupported type %s", s)
+}
+
+// Pack encodes args as the method's call parameters, without the
+// selector. This is the "parameter" TronGrid expects next to
+// function_selector.
+func (m Method) Pack(args ...any) ([]byte, error) {
+	b, err := encodeArgs(m.Inputs, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Name, err)
+	}
+	return b, nil
+}
+
+// CallData is the selector followed by Pack(args).
+func (m Method) CallData(args ...any) ([]byte, error) {
+	b, err := m.Pack(args...)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, m.Selector...), b...), nil
+}
+
+func encodeArgs(params []Argument, args []any) ([]byte, error) {
+	if len(args) != len(params) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(params), len(args))
+	}
+
+	head := make([]byte, 0, 32*len(params))
+	var tail []byte
+	for i, p := range params {
+		t, err := parseType(p.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.dynamic() {
+			head = append(head, uintWord(uint64(32*len(params)+len(tail)))...)
+			enc, err := encodeDynamic(t, args[i])
+			if err != nil {
+				return nil, fmt.Errorf("argument %d (%s): %w", i, p.Type, err)
+			}
+			tail = append(tail, enc...)
+			continue
+		}
+
+		w, err := encodeStatic(t, args[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %w", i, p.Type, err)
+		}
+		head = append(head, w...)
+	}
+	return append(head, tail...), nil
+}
+
+func encodeStatic(t abiType, v any) ([]byte, error) {
+	word := make([]byte, 32)
+
+	switch t.kind {
+	case kindAddress:
+		var addr domain.TronAddress
+		var err error
+		switch a := v.(type) {
+		case domain.TronAddress:
+			addr = a
+		case string:
+			addr, err = domain.ParseTronAddress(a)
+		case []byte:
+			addr, err = domain.TronAddressFromBytes(a)
+		default:
+			return nil, fmt.Errorf("cannot encode %T as address", v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		raw := addr.Account()
+		copy(word[12:], raw)
+
+	case kindBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %T as bool", v)
+		}
+		if b {
+			word[31] = 1
+		}
+
+	case kindUint:
+		n, err := toBigInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if n.Sign() < 0 || n.BitLen() > t.size {
+			return nil, fmt.Errorf("value %s out of range for uint%d", n, t.size)
+		}
+		n.FillBytes(word)
+
+	case kindFixedBytes:
+		var b []byte
+		switch x := v.(type) {
+		case []byte:
+			b = x
+		case [32]byte:
+			b = x[:]
+		default:
+			return nil, fmt.Errorf("cannot encode %T as bytes%d", v, t.size)
+		}
+		if len(b) != t.size {
+			return nil, fmt.Errorf("bytes%d needs %d bytes, got %d", t.size, t.size, len(b))
+		}
+		copy(word, b) // left-aligned
+
+	default:
+		return nil, fmt.Errorf("not a static type")
+	}
+	return word, nil
+}
+
+func encodeDynamic(t abiType, v any) ([]byte, error) {
+	var b []byte
+	switch x := v.(type) {
+	case string:
+		if t.kind != kindString {
+			return nil, fmt.Errorf("cannot encode string as bytes")
+		}
+		b = []byte(x)
+	case []byte:
+		if t.kind != kindBytes {
+			return nil, fmt.Errorf("cannot encode []byte as string")
+		}
+		b = x
+	default:
+		return nil, fmt.Errorf("cannot encode %T", v)
+	}
+
+	out := uintWord(uint64(len(b)))
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return append(out, padded...), nil
+}
+
+func toBigInt(v any) (*big.Int, error) {
+	switch n := v.(type) {
+	case *big.Int:
+		if n == nil {
+			return nil, fmt.Errorf("nil *big.Int")
+		}
+		return new(big.Int).Set(n), nil
+	case int:
+		return big.NewInt(int64(n)), nil
+	case int64:
+		return big.NewInt(n), nil
+	case uint8:
+		return new(big.Int).SetUint64(uint64(n)), nil
+	case uint16:
+		return new(big.Int).SetUint64(uint64(n)), nil
+	case uint32:
+		return new(big.Int).SetUint64(uint64(n)), nil
+	case uint64:
+		return new(big.Int).SetUint64(n), nil
+	}
+	return nil, fmt.Errorf("cannot encode %T as uint", v)
+}
+
+func uintWord(n uint64) []byte {
+	word := make([]byte, 32)
+	binary.BigEndian.PutUint64(word[24:], n)
+	return word
+}
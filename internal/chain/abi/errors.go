@@ -0,0 +1,96 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Selectors of the revert payloads Solidity emits without an ABI entry.
+var (
+	errorStringSelector = Keccak256([]byte("Error(string)"))[:4]
+	panicSelector       = Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// RevertError is a decoded revert reason.
+//
+// Code is set for RevertedWithCode(uint16) and Panic(uint256); Message for
+// RevertedWithMessage(string) and require/revert("..."). Other custom
+// errors keep their decoded arguments in Args. Name is empty when the
+// revert carried no data or an unknown selector (see Data).
+type RevertError struct {
+	Name    string
+	Code    uint64
+	Message string
+	Args    []any
+	Data    []byte
+}
+
+func (e *RevertError) Error() string {
+	switch {
+	case e.Name == "" && len(e.Data) == 0:
+		return "execution reverted"
+	case e.Name == "":
+		return "execution reverted: 0x" + hex.EncodeToString(e.Data)
+	case e.Message != "":
+		return fmt.Sprintf("execution reverted: %s(%q)", e.Name, e.Message)
+	case e.Name == "RevertedWithCode" || e.Name == "Panic":
+		return fmt.Sprintf("execution reverted: %s(%d)", e.Name, e.Code)
+	}
+
+	args := make([]string, 0, len(e.Args))
+	for _, a := range e.Args {
+		args = append(args, fmt.Sprint(a))
+	}
+	return fmt.Sprintf("execution reverted: %s(%s)", e.Name, strings.Join(args, ", "))
+}
+
+// DecodeRevert turns revert data (the return data of a reverted call)
+// into a *RevertError, using the ABI's custom errors and Solidity's
+// built-in Error(string) and Panic(uint256).
+func (a *ABI) DecodeRevert(data []byte) *RevertError {
+	re := &RevertError{Data: append([]byte(nil), data...)}
+	if len(data) < 4 {
+		return re
+	}
+	selector, body := data[:4], data[4:]
+
+	switch {
+	case bytes.Equal(selector, errorStringSelector):
+		if v, err := decodeArgs([]Argument{{Type: "string"}}, body); err == nil {
+			re.Name, re.Message = "Error", v[0].(string)
+		}
+		return re
+	case bytes.Equal(selector, panicSelector):
+		if v, err := decodeArgs([]Argument{{Type: "uint256"}}, body); err == nil {
+			re.Name, re.Code = "Panic", v[0].(*big.Int).Uint64()
+		}
+		return re
+	}
+
+	if a == nil {
+		return re
+	}
+	for _, e := range a.Errors {
+		if !bytes.Equal(selector, e.Selector) {
+			continue
+		}
+		args, err := decodeArgs(e.Inputs, body)
+		if err != nil {
+			return re
+		}
+		re.Name, re.Args = e.Name, args
+		if len(args) == 1 {
+			switch v := args[0].(type) {
+			case *big.Int:
+				re.Code = v.Uint64()
+			case string:
+				re.Message = v
+			}
+		}
+		return re
+	}
+	return re
+}
@@ -29,6 +29,9 @@ type Bundle struct {
 	MerchantRegistry TronContract
 	PaymentCore      TronContract
 	USDT             TronContract
+
+	// TRC20 is the ABI shared by every TRC-20 token.
+	TRC20 json.RawMessage
 }
 
 func Load(path string) (*Bundle, error) {
@@ -56,6 +59,7 @@ func Load(path string) (*Bundle, error) {
 			Address: c.TRC_20_USDT_ADDRESS_TRON,
 			ABI:     c.TRC_20_ABI,
 		},
+		TRC20: c.TRC_20_ABI,
 	}
 
 	if out.MerchantRegistry.Address == "" || len(out.MerchantRegistry.ABI) == 0 {
@@ -0,0 +1,280 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/ports"
+	"token13/merchant-backend-go/internal/services/tron"
+)
+
+// CursorName is the chain_cursors row the payment indexer owns.
+const CursorName = "payment_core.payment_detected"
+
+// Chain is the subset of the TronGrid client the indexer reads blocks with.
+type Chain interface {
+	GetSolidBlockNumber(ctx context.Context) (int64, error)
+	GetSolidTransactionInfoByBlockNum(ctx context.Context, num int64) ([]tron.BlockTransaction, error)
+}
+
+// Tokens is the allow-list payments are valued in (service.TokenSet).
+type Tokens interface {
+	List() []domain.Token
+	// Complete reports whether every configured token has been loaded.
+	Complete() bool
+}
+
+// Indexer follows the solidified chain block by block from a persisted
+// cursor and records every PaymentCoreV1 PaymentDetected log as a PENDING
+// payment. Blocks behind the solid head can no longer be reorganised away,
+// so a block is never re-scanned once the cursor has passed it; the price is
+// that payments are detected about a minute (one solidification) late.
+//
+// Each block is committed in one transaction (payments, their
+// payment.detected outbox rows and the cursor), so a crash re-scans at most
+// the block in flight and payments_tx_log_uidx turns that into a no-op. A
+// payment for an order the backend never issued is kept for manual review
+// (unmatched_payments) in the same transaction, never skipped.
+type Indexer struct {
+	chain    Chain
+	decoder  *tron.PaymentEventDecoder
+	tx       ports.Transactor
+	payments ports.PaymentRepo
+	cursors  ports.ChainCursorRepo
+	tokens   Tokens
+	log      *slog.Logger
+
+	// StartBlock is where a fresh cursor starts; 0 = the current solid head.
+	StartBlock int64
+
+	Interval      time.Duration
+	BlocksPerTick int
+}
+
+func New(
+	chain Chain,
+	decoder *tron.PaymentEventDecoder,
+	tx ports.Transactor,
+	payments ports.PaymentRepo,
+	cursors ports.ChainCursorRepo,
+	tokens Tokens,
+	log *slog.Logger,
+) *Indexer {
+	return &Indexer{
+		chain:         chain,
+		decoder:       decoder,
+		tx:            tx,
+		payments:      payments,
+		cursors:       cursors,
+		tokens:        tokens,
+		log:           log,
+		Interval:      3 * time.Second, // Tron block time
+		BlocksPerTick: 100,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (ix *Indexer) Run(ctx context.Context) {
+	ix.log.Info("payment_indexer_started", "cursor", CursorName, "interval", ix.Interval.String())
+
+	ticker := time.NewTicker(ix.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := ix.tick(ctx); err != nil && ctx.Err() == nil {
+			ix.log.Error("payment_indexer_failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			ix.log.Info("payment_indexer_stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick indexes up to BlocksPerTick solidified blocks after the cursor.
+// It waits for the full token set, so that no payment in a token still
+// loading is valued as unknown.
+func (ix *Indexer) tick(ctx context.Context) error {
+	if !ix.tokens.Complete() {
+		ix.log.Debug("payment_indexer_waiting_for_tokens")
+		return nil
+	}
+
+	head, err := ix.chain.GetSolidBlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("solid block: %w", err)
+	}
+
+	last, err := ix.cursor(ctx, head)
+	if err != nil {
+		return err
+	}
+
+	for n := 0; last < head && n < ix.BlocksPerTick; n++ {
+		if err := ix.indexBlock(ctx, last+1); err != nil {
+			return fmt.Errorf("block %d: %w", last+1, err)
+		}
+		last++
+	}
+	return nil
+}
+
+// cursor returns the last indexed block, initialising it on first run.
+func (ix *Indexer) cursor(ctx context.Context, head int64) (int64, error) {
+	last, ok, err := ix.cursors.Get(ctx, CursorName)
+	if err != nil || ok {
+		return last, err
+	}
+
+	last = head - 1
+	if ix.StartBlock > 0 {
+		last = ix.StartBlock - 1
+	}
+	if err := ix.cursors.Set(ctx, CursorName, last); err != nil {
+		return 0, err
+	}
+	ix.log.Info("payment_indexer_cursor_initialised", "cursor", CursorName, "next_block", last+1)
+	return last, nil
+}
+
+func (ix *Indexer) indexBlock(ctx context.Context, num int64) error {
+	txs, err := ix.chain.GetSolidTransactionInfoByBlockNum(ctx, num)
+	if err != nil {
+		return err
+	}
+
+	var detected []*domain.Payment
+	for _, tx := range txs {
+		if !tx.Success {
+			continue // a reverted payTx emits nothing we can trust
+		}
+		for i, l := range tx.Logs {
+			ev, ok, err := ix.decoder.Decode(l)
+			if !ok {
+				continue
+			}
+			if err != nil {
+				ix.log.Error("payment_event_malformed", "tx_hash", tx.TxID, "log_index", i, "err", err)
+				continue
+			}
+			detected = append(detected, ix.toPayment(tx, i, ev))
+		}
+	}
+
+	return ix.tx.InTx(ctx, func(ctx context.Context) error {
+		for _, p := range detected {
+			if err := ix.record(ctx, p); err != nil {
+				return err
+			}
+		}
+		return ix.cursors.Set(ctx, CursorName, num)
+	})
+}
+
+func (ix *Indexer) toPayment(tx tron.BlockTransaction, logIndex int, ev tron.PaymentDetected) *domain.Payment {
+	p := &domain.Payment{
+		OrderID:      ev.OrderID,
+		InvoiceID:    ev.InvoiceID,
+		MerchantID:   ev.MerchantID,
+		TokenAddress: ev.Token.String(),
+		AmountRaw:    ev.Amount.String(),
+		Amount:       "0",
+		Currency:     "UNKNOWN",
+		TxHash:       tx.TxID,
+		BlockNumber:  tx.BlockNumber,
+		LogIndex:     logIndex,
+		Status:       domain.PaymentPending,
+		PaidAt:       tx.BlockTime,
+	}
+
+	token, ok := ix.token(p.TokenAddress)
+	if !ok {
+		ix.log.Warn("payment_unknown_token", "tx_hash", tx.TxID, "token", p.TokenAddress)
+		return p
+	}
+	amount, err := token.FromBaseUnits(ev.Amount)
+	if err != nil {
+		ix.log.Warn("payment_amount_out_of_range", "tx_hash", tx.TxID, "amount_raw", p.AmountRaw, "decimals", token.Decimals)
+		return p
+	}
+	p.Amount, p.Currency = amount, token.Symbol
+	return p
+}
+
+// token looks a payment's token up by address (base58). Payments in other
+// tokens are stored with Currency "UNKNOWN" and Amount 0; the exact value is
+// always kept in AmountRaw.
+func (ix *Indexer) token(address string) (domain.Token, bool) {
+	for _, t := range ix.tokens.List() {
+		if t.Address == address {
+			return t, true
+		}
+	}
+	return domain.Token{}, false
+}
+
+func (ix *Indexer) record(ctx context.Context, p *domain.Payment) error {
+	attrs := []any{
+		"tx_hash", p.TxHash,
+		"log_index", p.LogIndex,
+		"block", p.BlockNumber,
+		"merchant_id", hexID(p.MerchantID),
+		"order_id", hexID(p.OrderID),
+	}
+
+	env, err := events.New(ctx, events.PaymentDetected{
+		MerchantID:   hexID(p.MerchantID),
+		OrderID:      hexID(p.OrderID),
+		InvoiceID:    hexID(p.InvoiceID),
+		TokenAddress: p.TokenAddress,
+		Currency:     p.Currency,
+		Amount:       p.Amount,
+		AmountRaw:    p.AmountRaw,
+		TxHash:       p.TxHash,
+		LogIndex:     p.LogIndex,
+		BlockNumber:  p.BlockNumber,
+		PaidAt:       p.PaidAt,
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := ix.payments.InsertDetected(ctx, p, msg)
+	switch {
+	case errors.Is(err, domain.ErrUnknownOrder):
+		// Funds arrived on chain for an order we never issued: needs a human.
+		held, err := ix.payments.InsertUnmatched(ctx, p, "no order for this merchant and invoice")
+		if err != nil {
+			return err
+		}
+		if held {
+			ix.log.Error("payment_held_for_review", append(attrs, "reason", "unknown_order", "amount_raw", p.AmountRaw, "token", p.TokenAddress)...)
+		}
+		return nil
+	case err != nil:
+		return err
+	case inserted:
+		ix.log.Info("payment_detected", append(attrs, "amount", p.Amount, "currency", p.Currency)...)
+	default:
+		ix.log.Debug("payment_already_recorded", attrs...)
+	}
+	return nil
+}
+
+func hexID(b []byte) string {
+	s, _ := ids.Bytes32ToHex(b)
+	return s
+}
@@ -0,0 +1,256 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/big"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/chain/abi"
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/services/tron"
+)
+
+type fakeChain struct {
+	head   int64
+	blocks map[int64][]tron.BlockTransaction
+}
+
+func (c *fakeChain) GetSolidBlockNumber(context.Context) (int64, error) { return c.head, nil }
+
+func (c *fakeChain) GetSolidTransactionInfoByBlockNum(_ context.Context, num int64) ([]tron.BlockTransaction, error) {
+	return c.blocks[num], nil
+}
+
+// fakeTx records whether the cursor moved in the same unit as the writes.
+type fakeTx struct{ units int }
+
+func (t *fakeTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.units++
+	return fn(ctx)
+}
+
+type fakeCursors struct{ block map[string]int64 }
+
+func (c *fakeCursors) Get(_ context.Context, name string) (int64, bool, error) {
+	b, ok := c.block[name]
+	return b, ok, nil
+}
+
+func (c *fakeCursors) Set(_ context.Context, name string, block int64) error {
+	c.block[name] = block
+	return nil
+}
+
+// fakePayments knows the orders in orders (by order id); the rest of
+// ports.PaymentRepo is not used by the indexer.
+type fakePayments struct {
+	ports.PaymentRepo
+	orders    map[string]bool
+	detected  []domain.Payment
+	unmatched []domain.Payment
+	reasons   []string
+}
+
+func (r *fakePayments) InsertDetected(_ context.Context, p *domain.Payment, _ ...domain.OutboxMessage) (bool, error) {
+	if !r.orders[string(p.OrderID)] {
+		return false, domain.ErrUnknownOrder
+	}
+	r.detected = append(r.detected, *p)
+	return true, nil
+}
+
+func (r *fakePayments) InsertUnmatched(_ context.Context, p *domain.Payment, reason string) (bool, error) {
+	r.unmatched = append(r.unmatched, *p)
+	r.reasons = append(r.reasons, reason)
+	return true, nil
+}
+
+type fixture struct {
+	ix       *Indexer
+	chain    *fakeChain
+	tx       *fakeTx
+	cursors  *fakeCursors
+	payments *fakePayments
+	event    abi.Event
+	contract domain.TronAddress
+	token    domain.Token
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	bundle, err := contracts.Load("../../config/contract.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := tron.NewPaymentEventDecoder(bundle.PaymentCore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.Parse(bundle.PaymentCore.ABI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := parsed.Event(tron.PaymentDetectedEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, err := domain.ParseTronAddress(bundle.PaymentCore.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fixture{
+		chain:    &fakeChain{blocks: map[int64][]tron.BlockTransaction{}},
+		tx:       &fakeTx{},
+		cursors:  &fakeCursors{block: map[string]int64{}},
+		payments: &fakePayments{orders: map[string]bool{}},
+		event:    event,
+		contract: contract,
+		token:    domain.Token{Address: bundle.USDT.Address, Symbol: "USDT", Decimals: 6},
+	}
+	tokens := service.NewTokenSet([]domain.Token{f.token}, true)
+	f.ix = New(f.chain, decoder, f.tx, f.payments, f.cursors, tokens, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return f
+}
+
+func id32(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+// paymentLog encodes PaymentDetected(merchant, order, invoice, token, amount, ts).
+func (f *fixture) paymentLog(t *testing.T, order []byte, amount int64) tron.Log {
+	t.Helper()
+	token, err := domain.ParseTronAddress(f.token.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 96)
+	copy(data[12:32], token.Account())
+	big.NewInt(amount).FillBytes(data[32:64])
+	big.NewInt(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Unix()).FillBytes(data[64:96])
+	return tron.Log{
+		Address: f.contract,
+		Topics:  [][]byte{f.event.Topic, id32(1), order, id32(3)},
+		Data:    data,
+	}
+}
+
+func TestIndexBlockRecordsPayments(t *testing.T) {
+	f := newFixture(t)
+	f.payments.orders[string(id32(2))] = true
+	f.cursors.block[CursorName] = 99
+	f.chain.head = 100
+	f.chain.blocks[100] = []tron.BlockTransaction{{
+		TxID:        "aa",
+		BlockNumber: 100,
+		Success:     true,
+		Logs:        []tron.Log{f.paymentLog(t, id32(2), 12_500_000)},
+	}}
+
+	if err := f.ix.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.payments.detected) != 1 {
+		t.Fatalf("detected %d payments, want 1", len(f.payments.detected))
+	}
+	p := f.payments.detected[0]
+	if p.Amount != "12.5" || p.Currency != "USDT" || p.AmountRaw != "12500000" {
+		t.Fatalf("amount = %s %s (raw %s)", p.Amount, p.Currency, p.AmountRaw)
+	}
+	if p.TxHash != "aa" || p.LogIndex != 0 || p.BlockNumber != 100 {
+		t.Fatalf("payment = %+v", p)
+	}
+	if got := f.cursors.block[CursorName]; got != 100 {
+		t.Fatalf("cursor = %d, want 100", got)
+	}
+}
+
+func TestIndexBlockHoldsUnknownOrderForReview(t *testing.T) {
+	f := newFixture(t)
+	f.cursors.block[CursorName] = 99
+	f.chain.head = 100
+	f.chain.blocks[100] = []tron.BlockTransaction{{
+		TxID:        "bb",
+		BlockNumber: 100,
+		Success:     true,
+		Logs:        []tron.Log{f.paymentLog(t, id32(9), 1_000_000)},
+	}}
+
+	if err := f.ix.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.payments.detected) != 0 {
+		t.Fatalf("detected %d payments for an unknown order", len(f.payments.detected))
+	}
+	if len(f.payments.unmatched) != 1 {
+		t.Fatalf("held %d payments, want 1", len(f.payments.unmatched))
+	}
+	if p := f.payments.unmatched[0]; p.TxHash != "bb" || p.AmountRaw != "1000000" || !bytes.Equal(p.OrderID, id32(9)) {
+		t.Fatalf("held payment = %+v", p)
+	}
+	if f.payments.reasons[0] == "" {
+		t.Fatal("held without a reason")
+	}
+	// Held in the same unit that advanced the cursor.
+	if f.tx.units != 1 || f.cursors.block[CursorName] != 100 {
+		t.Fatalf("units = %d, cursor = %d", f.tx.units, f.cursors.block[CursorName])
+	}
+}
+
+func TestIndexBlockSkipsFailedTransactions(t *testing.T) {
+	f := newFixture(t)
+	f.payments.orders[string(id32(2))] = true
+	f.cursors.block[CursorName] = 99
+	f.chain.head = 100
+	f.chain.blocks[100] = []tron.BlockTransaction{{
+		TxID:        "cc",
+		BlockNumber: 100,
+		Success:     false,
+		Logs:        []tron.Log{f.paymentLog(t, id32(2), 1)},
+	}}
+
+	if err := f.ix.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.payments.detected)+len(f.payments.unmatched) != 0 {
+		t.Fatal("recorded a payment from a reverted tx")
+	}
+}
+
+func TestIndexBlockWaitsForTokens(t *testing.T) {
+	f := newFixture(t)
+	f.payments.orders[string(id32(2))] = true
+	f.cursors.block[CursorName] = 99
+	f.chain.head = 100
+	f.chain.blocks[100] = []tron.BlockTransaction{{
+		TxID:        "dd",
+		BlockNumber: 100,
+		Success:     true,
+		Logs:        []tron.Log{f.paymentLog(t, id32(2), 2_000_000)},
+	}}
+
+	// Only cached metadata so far: the block stays unindexed.
+	tokens := service.NewTokenSet(nil, false)
+	f.ix.tokens = tokens
+	if err := f.ix.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.payments.detected) != 0 || f.cursors.block[CursorName] != 99 {
+		t.Fatalf("indexed before the tokens loaded: %d payments, cursor %d", len(f.payments.detected), f.cursors.block[CursorName])
+	}
+
+	tokens.Set([]domain.Token{f.token})
+	if err := f.ix.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.payments.detected) != 1 || f.payments.detected[0].Currency != "USDT" {
+		t.Fatalf("detected = %+v", f.payments.detected)
+	}
+}
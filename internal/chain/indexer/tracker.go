@@ -0,0 +1,265 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// SolidChain is the subset of the TronGrid client the tracker needs.
+type SolidChain interface {
+	GetSolidBlockNumber(ctx context.Context) (int64, error)
+	GetSolidTransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error)
+	GetTransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error)
+}
+
+// Settler re-matches an order's payments after one of them is confirmed.
+type Settler interface {
+	Settle(ctx context.Context, orderID []byte) (domain.MatchResult, error)
+}
+
+// Tracker finalises payments recorded by the Indexer.
+//
+// A payment is promoted to SUCCESS once Confirmations solidified blocks
+// (its own included) cover it and the solidity node still has the tx,
+// successful, in a block; its order is then settled in the same
+// transaction. If the solidity node has the tx failed, or RevertAfter polls
+// in a row find it nowhere on chain, the payment is marked FAILED and
+// payment.reverted is emitted; the misses are counted on the payment.
+//
+// A payment that cannot be checked is logged and retried on the next tick
+// without holding up the rest of the batch; one whose settlement fails with
+// domain.ErrNeedsReview is held for manual review instead.
+type Tracker struct {
+	chain    SolidChain
+	tx       ports.Transactor
+	payments ports.PaymentRepo
+	settler  Settler
+	log      *slog.Logger
+
+	// Confirmations is the number of solidified blocks, the payment's own
+	// included, required before it is final (default 1).
+	Confirmations int64
+
+	// RevertAfter is how many polls in a row must miss the tx before the
+	// payment is reverted (default 10), so a lagging node or a dropped
+	// lookup does not fail a good payment.
+	RevertAfter int
+
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewTracker(chain SolidChain, tx ports.Transactor, payments ports.PaymentRepo, settler Settler, log *slog.Logger) *Tracker {
+	return &Tracker{
+		chain:         chain,
+		tx:            tx,
+		payments:      payments,
+		settler:       settler,
+		log:           log,
+		Confirmations: 1,
+		RevertAfter:   10,
+		Interval:      3 * time.Second,
+		BatchSize:     100,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	t.log.Info("payment_tracker_started", "confirmations", t.Confirmations, "interval", t.Interval.String())
+
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.tick(ctx); err != nil && ctx.Err() == nil {
+			t.log.Error("payment_tracker_failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			t.log.Info("payment_tracker_stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tracker) tick(ctx context.Context) error {
+	solid, err := t.chain.GetSolidBlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("solid block: %w", err)
+	}
+
+	due, err := t.payments.ListPending(ctx, t.finalBlock(solid), t.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range due {
+		p := &due[i]
+		err := t.check(ctx, p, solid)
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, domain.ErrNeedsReview):
+			if herr := t.payments.HoldForReview(ctx, p.PaymentUID, err.Error()); herr != nil {
+				t.log.Error("payment_check_failed", "tx_hash", p.TxHash, "log_index", p.LogIndex, "err", err, "hold_err", herr)
+				continue
+			}
+			t.log.Error("payment_held_for_review", "tx_hash", p.TxHash, "log_index", p.LogIndex, "order_id", hexID(p.OrderID), "reason", err)
+		default:
+			t.log.Error("payment_check_failed", "tx_hash", p.TxHash, "log_index", p.LogIndex, "err", err)
+		}
+	}
+	return nil
+}
+
+// finalBlock is the highest block with enough solidified confirmations.
+func (t *Tracker) finalBlock(solid int64) int64 {
+	n := t.Confirmations
+	if n < 1 {
+		n = 1
+	}
+	return solid - n + 1
+}
+
+func (t *Tracker) check(ctx context.Context, p *domain.Payment, solid int64) error {
+	receipt, err := t.chain.GetSolidTransactionInfo(ctx, p.TxHash)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case receipt.Found && receipt.Success && receipt.BlockNumber <= t.finalBlock(solid):
+		return t.confirm(ctx, p, receipt.BlockNumber)
+
+	case receipt.Found && receipt.Success:
+		// Re-included in a later block that is not final yet.
+		if err := t.found(ctx, p); err != nil {
+			return err
+		}
+		return t.move(ctx, p, receipt.BlockNumber)
+
+	case receipt.Found:
+		return t.revert(ctx, p, "tx failed on canonical chain: "+receipt.Result)
+	}
+
+	// Not solidified: it may have been re-included above the solid head.
+	live, err := t.chain.GetTransactionInfo(ctx, p.TxHash)
+	if err != nil {
+		return err
+	}
+	if live.Found && live.Success && live.BlockNumber > solid {
+		if err := t.found(ctx, p); err != nil {
+			return err
+		}
+		return t.move(ctx, p, live.BlockNumber)
+	}
+	return t.miss(ctx, p)
+}
+
+// miss records a poll that could not find p's tx, and reverts p once
+// RevertAfter of them came in a row.
+func (t *Tracker) miss(ctx context.Context, p *domain.Payment) error {
+	misses, err := t.payments.RecordMiss(ctx, p.PaymentUID)
+	if err != nil {
+		return err
+	}
+	if misses < t.RevertAfter {
+		t.log.Warn("payment_tx_missing", "tx_hash", p.TxHash, "log_index", p.LogIndex, "misses", misses, "revert_after", t.RevertAfter)
+		return nil
+	}
+	return t.revert(ctx, p, fmt.Sprintf("tx not on canonical chain after %d polls", misses))
+}
+
+// found resets the misses of a payment whose tx turned up again.
+func (t *Tracker) found(ctx context.Context, p *domain.Payment) error {
+	if p.MissedPolls == 0 {
+		return nil
+	}
+	return t.payments.ClearMisses(ctx, p.PaymentUID)
+}
+
+func (t *Tracker) confirm(ctx context.Context, p *domain.Payment, block int64) error {
+	now := time.Now().UTC()
+	env, err := events.New(ctx, events.PaymentConfirmed{
+		MerchantID:  hexID(p.MerchantID),
+		OrderID:     hexID(p.OrderID),
+		InvoiceID:   hexID(p.InvoiceID),
+		TxHash:      p.TxHash,
+		LogIndex:    p.LogIndex,
+		BlockNumber: block,
+		ConfirmedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	return t.tx.InTx(ctx, func(ctx context.Context) error {
+		changed, err := t.payments.MarkConfirmed(ctx, p.PaymentUID, block, now, msg)
+		if err != nil || !changed {
+			return err
+		}
+
+		res, err := t.settler.Settle(ctx, p.OrderID)
+		if err != nil {
+			return fmt.Errorf("settle order: %w", err)
+		}
+		t.log.Info("payment_confirmed",
+			"tx_hash", p.TxHash,
+			"log_index", p.LogIndex,
+			"block", block,
+			"order_id", hexID(p.OrderID),
+			"match", res.Matches[p.PaymentUID],
+			"settlement", res.Settlement,
+		)
+		return nil
+	})
+}
+
+func (t *Tracker) move(ctx context.Context, p *domain.Payment, block int64) error {
+	if block == p.BlockNumber {
+		return nil
+	}
+	t.log.Warn("payment_block_changed", "tx_hash", p.TxHash, "from", p.BlockNumber, "to", block)
+	return t.payments.SetBlockNumber(ctx, p.PaymentUID, block)
+}
+
+func (t *Tracker) revert(ctx context.Context, p *domain.Payment, reason string) error {
+	env, err := events.New(ctx, events.PaymentReverted{
+		MerchantID:  hexID(p.MerchantID),
+		OrderID:     hexID(p.OrderID),
+		InvoiceID:   hexID(p.InvoiceID),
+		TxHash:      p.TxHash,
+		LogIndex:    p.LogIndex,
+		BlockNumber: p.BlockNumber,
+		Reason:      reason,
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	changed, err := t.payments.MarkReverted(ctx, p.PaymentUID, msg)
+	if err != nil {
+		return err
+	}
+	if changed {
+		t.log.Warn("payment_reverted", "tx_hash", p.TxHash, "log_index", p.LogIndex, "block", p.BlockNumber, "order_id", hexID(p.OrderID), "reason", reason)
+	}
+	return nil
+}
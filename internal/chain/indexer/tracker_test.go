@@ -0,0 +1,364 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// solidChain answers lookups from the solidity node (solid) and the full
+// node (live); a tx in neither is not on chain.
+type solidChain struct {
+	head  int64
+	solid map[string]domain.ChainReceipt
+	live  map[string]domain.ChainReceipt
+	err   map[string]error
+}
+
+func (c *solidChain) GetSolidBlockNumber(context.Context) (int64, error) { return c.head, nil }
+
+func (c *solidChain) GetSolidTransactionInfo(_ context.Context, txid string) (domain.ChainReceipt, error) {
+	if err := c.err[txid]; err != nil {
+		return domain.ChainReceipt{}, err
+	}
+	return c.solid[txid], nil
+}
+
+func (c *solidChain) GetTransactionInfo(_ context.Context, txid string) (domain.ChainReceipt, error) {
+	return c.live[txid], nil
+}
+
+// trackedPayments holds PENDING payments by uid and what the tracker did
+// to them.
+type trackedPayments struct {
+	ports.PaymentRepo
+	pending   []domain.Payment
+	confirmed map[string]int64 // block
+	reverted  map[string]bool
+	held      map[string]string
+	misses    map[string]int
+	outbox    []domain.OutboxMessage
+}
+
+func newTrackedPayments(pending ...domain.Payment) *trackedPayments {
+	r := &trackedPayments{
+		pending:   pending,
+		confirmed: map[string]int64{},
+		reverted:  map[string]bool{},
+		held:      map[string]string{},
+		misses:    map[string]int{},
+	}
+	for _, p := range pending {
+		r.misses[p.PaymentUID] = p.MissedPolls
+	}
+	return r
+}
+
+func (r *trackedPayments) get(uid string) *domain.Payment {
+	for i := range r.pending {
+		if r.pending[i].PaymentUID == uid {
+			return &r.pending[i]
+		}
+	}
+	return nil
+}
+
+func (r *trackedPayments) ListPending(_ context.Context, maxBlock int64, limit int) ([]domain.Payment, error) {
+	var out []domain.Payment
+	for _, p := range r.pending {
+		if p.BlockNumber <= maxBlock && len(out) < limit && r.held[p.PaymentUID] == "" {
+			p.MissedPolls = r.misses[p.PaymentUID]
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *trackedPayments) HoldForReview(_ context.Context, uid, reason string) error {
+	r.held[uid] = reason
+	return nil
+}
+
+func (r *trackedPayments) RecordMiss(_ context.Context, uid string) (int, error) {
+	r.misses[uid]++
+	return r.misses[uid], nil
+}
+
+func (r *trackedPayments) ClearMisses(_ context.Context, uid string) error {
+	r.misses[uid] = 0
+	return nil
+}
+
+func (r *trackedPayments) SetBlockNumber(_ context.Context, uid string, block int64) error {
+	r.get(uid).BlockNumber = block
+	return nil
+}
+
+func (r *trackedPayments) MarkConfirmed(_ context.Context, uid string, block int64, _ time.Time, outbox ...domain.OutboxMessage) (bool, error) {
+	r.confirmed[uid] = block
+	r.outbox = append(r.outbox, outbox...)
+	return true, nil
+}
+
+func (r *trackedPayments) MarkReverted(_ context.Context, uid string, outbox ...domain.OutboxMessage) (bool, error) {
+	r.reverted[uid] = true
+	r.outbox = append(r.outbox, outbox...)
+	return true, nil
+}
+
+// rollbackTx undoes the confirmations made in a unit that fails, as the
+// database transaction would.
+type rollbackTx struct{ payments *trackedPayments }
+
+func (t rollbackTx) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	confirmed := map[string]int64{}
+	for uid, b := range t.payments.confirmed {
+		confirmed[uid] = b
+	}
+	outbox := len(t.payments.outbox)
+	if err := fn(ctx); err != nil {
+		t.payments.confirmed, t.payments.outbox = confirmed, t.payments.outbox[:outbox]
+		return err
+	}
+	return nil
+}
+
+// fakeSettler fails its first failures calls with err.
+type fakeSettler struct {
+	err      error
+	failures int
+	settled  int
+}
+
+func (s *fakeSettler) Settle(context.Context, []byte) (domain.MatchResult, error) {
+	s.settled++
+	if s.settled <= s.failures {
+		return domain.MatchResult{}, s.err
+	}
+	return domain.MatchResult{Settlement: domain.SettlementPaid}, nil
+}
+
+func pendingPayment(uid string, block int64) domain.Payment {
+	return domain.Payment{
+		PaymentUID:  uid,
+		OrderID:     id32(2),
+		InvoiceID:   id32(3),
+		MerchantID:  id32(1),
+		TxHash:      "tx-" + uid,
+		BlockNumber: block,
+		Status:      domain.PaymentPending,
+	}
+}
+
+func newTestTracker(chain *solidChain, payments *trackedPayments, settler *fakeSettler) *Tracker {
+	tr := NewTracker(chain, rollbackTx{payments}, payments, settler, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	tr.RevertAfter = 3
+	return tr
+}
+
+func succeeded(block int64) domain.ChainReceipt {
+	return domain.ChainReceipt{Found: true, Success: true, BlockNumber: block, Result: "SUCCESS"}
+}
+
+func TestTrackerTick(t *testing.T) {
+	tests := []struct {
+		name          string
+		confirmations int64
+		payment       domain.Payment
+		solid, live   map[string]domain.ChainReceipt
+
+		confirmed  bool
+		reverted   string // part of the payment.reverted reason
+		block      int64  // payment block afterwards
+		misses     int
+		settlement int // Settle calls
+	}{
+		{
+			name:      "confirmed once solidified",
+			payment:   pendingPayment("p1", 100),
+			solid:     map[string]domain.ChainReceipt{"tx-p1": succeeded(100)},
+			confirmed: true, block: 100, settlement: 1,
+		},
+		{
+			name:          "re-included in a block not final yet",
+			confirmations: 3,
+			payment:       pendingPayment("p1", 95),
+			solid:         map[string]domain.ChainReceipt{"tx-p1": succeeded(99)},
+			block:         99,
+		},
+		{
+			name:     "failed on the canonical chain",
+			payment:  pendingPayment("p1", 100),
+			solid:    map[string]domain.ChainReceipt{"tx-p1": {Found: true, BlockNumber: 100, Result: "REVERT"}},
+			reverted: "tx failed on canonical chain: REVERT", block: 100,
+		},
+		{
+			name:    "missing once",
+			payment: pendingPayment("p1", 100),
+			block:   100, misses: 1,
+		},
+		{
+			name: "missing RevertAfter polls in a row",
+			payment: func() domain.Payment {
+				p := pendingPayment("p1", 100)
+				p.MissedPolls = 2
+				return p
+			}(),
+			reverted: "after 3 polls", block: 100, misses: 3,
+		},
+		{
+			name: "re-included above the solid head",
+			payment: func() domain.Payment {
+				p := pendingPayment("p1", 100)
+				p.MissedPolls = 2
+				return p
+			}(),
+			live:  map[string]domain.ChainReceipt{"tx-p1": succeeded(102)},
+			block: 102,
+		},
+		{
+			name:    "seen by a full node at or below the solid head only",
+			payment: pendingPayment("p1", 100),
+			live:    map[string]domain.ChainReceipt{"tx-p1": succeeded(100)},
+			block:   100, misses: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &solidChain{head: 100, solid: tt.solid, live: tt.live}
+			payments, settler := newTrackedPayments(tt.payment), &fakeSettler{}
+			tr := newTestTracker(chain, payments, settler)
+			if tt.confirmations > 0 {
+				tr.Confirmations = tt.confirmations
+			}
+
+			if err := tr.tick(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := payments.confirmed["p1"]; ok != tt.confirmed {
+				t.Fatalf("confirmed = %v, want %v", ok, tt.confirmed)
+			}
+			if payments.reverted["p1"] != (tt.reverted != "") {
+				t.Fatalf("reverted = %v, want %v", payments.reverted["p1"], tt.reverted != "")
+			}
+			if got := payments.get("p1").BlockNumber; got != tt.block {
+				t.Fatalf("block = %d, want %d", got, tt.block)
+			}
+			if payments.misses["p1"] != tt.misses {
+				t.Fatalf("misses = %d, want %d", payments.misses["p1"], tt.misses)
+			}
+			if settler.settled != tt.settlement {
+				t.Fatalf("settled %d times, want %d", settler.settled, tt.settlement)
+			}
+
+			switch {
+			case tt.confirmed:
+				ev := trackerEvent[events.PaymentConfirmed](t, payments.outbox)
+				if ev.TxHash != "tx-p1" || ev.BlockNumber != 100 || ev.OrderID != "0x"+strings.Repeat("02", 32) {
+					t.Fatalf("event = %+v", ev)
+				}
+			case tt.reverted != "":
+				ev := trackerEvent[events.PaymentReverted](t, payments.outbox)
+				if ev.TxHash != "tx-p1" || !strings.Contains(ev.Reason, tt.reverted) {
+					t.Fatalf("event = %+v, want reason %q", ev, tt.reverted)
+				}
+			case len(payments.outbox) != 0:
+				t.Fatalf("enqueued %d events", len(payments.outbox))
+			}
+		})
+	}
+}
+
+// Payments above the last final block are not looked at yet.
+func TestTrackerWaitsForConfirmations(t *testing.T) {
+	chain := &solidChain{head: 100, solid: map[string]domain.ChainReceipt{"tx-p1": succeeded(99)}}
+	payments := newTrackedPayments(pendingPayment("p1", 99))
+	tr := newTestTracker(chain, payments, &fakeSettler{})
+	tr.Confirmations = 3
+
+	if err := tr.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(payments.confirmed) != 0 {
+		t.Fatal("confirmed with 2 of 3 confirmations")
+	}
+
+	chain.head = 101
+	if err := tr.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if payments.confirmed["p1"] != 99 {
+		t.Fatalf("confirmed = %v", payments.confirmed)
+	}
+}
+
+// A payment whose order cannot be settled is held for review with the
+// confirmation rolled back; one that cannot be looked up is retried next
+// tick. Neither holds up the rest of the batch.
+func TestTrackerKeepsGoingPastFailedPayments(t *testing.T) {
+	chain := &solidChain{
+		head: 100,
+		solid: map[string]domain.ChainReceipt{
+			"tx-p1": succeeded(98),
+			"tx-p3": succeeded(100),
+		},
+		err: map[string]error{"tx-p2": errors.New("node timeout")},
+	}
+	payments := newTrackedPayments(pendingPayment("p1", 98), pendingPayment("p2", 99), pendingPayment("p3", 100))
+	settler := &fakeSettler{err: fmt.Errorf("%w: token not loaded", domain.ErrNeedsReview), failures: 1}
+	tr := newTestTracker(chain, payments, settler)
+
+	if err := tr.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(payments.held["p1"], "token not loaded") {
+		t.Fatalf("held = %v", payments.held)
+	}
+	if _, ok := payments.confirmed["p1"]; ok {
+		t.Fatal("confirmation of an unsettled payment was kept")
+	}
+	if payments.held["p2"] != "" || payments.misses["p2"] != 0 {
+		t.Fatal("a failed lookup counted against the payment")
+	}
+	if len(payments.confirmed) != 1 || payments.confirmed["p3"] != 100 {
+		t.Fatalf("confirmed = %v", payments.confirmed)
+	}
+
+	// The node is back: p2 goes through, p1 stays held.
+	delete(chain.err, "tx-p2")
+	chain.solid["tx-p2"] = succeeded(99)
+	if err := tr.tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payments.confirmed["p1"]; ok || payments.confirmed["p2"] != 99 {
+		t.Fatalf("confirmed = %v", payments.confirmed)
+	}
+}
+
+func trackerEvent[T events.Event](t *testing.T, msgs []domain.OutboxMessage) T {
+	t.Helper()
+	var payload T
+	if len(msgs) != 1 || msgs[0].RoutingKey != payload.EventType() {
+		t.Fatalf("outbox = %+v, want one %s", msgs, payload.EventType())
+	}
+	var env events.Envelope
+	if err := json.Unmarshal(msgs[0].Payload, &env); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
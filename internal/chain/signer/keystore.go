@@ -0,0 +1,170 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+
+	"token13/merchant-backend-go/internal/chain/abi"
+)
+
+// keystoreFile is the Web3 Secret Storage (v3) layout written by TronLink,
+// tronweb and geth-compatible tooling.
+type keystoreFile struct {
+	Address string          `json:"address"`
+	Version int             `json:"version"`
+	Crypto  keystoreCrypto  `json:"crypto"`
+	Legacy  *keystoreCrypto `json:"Crypto"` // older geth files
+}
+
+type keystoreCrypto struct {
+	Cipher       string `json:"cipher"`
+	CipherText   string `json:"ciphertext"`
+	CipherParams struct {
+		IV string `json:"iv"`
+	} `json:"cipherparams"`
+	KDF       string          `json:"kdf"`
+	KDFParams json.RawMessage `json:"kdfparams"`
+	MAC       string          `json:"mac"`
+}
+
+type scryptParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+type pbkdf2Params struct {
+	C     int    `json:"c"`
+	DKLen int    `json:"dklen"`
+	PRF   string `json:"prf"`
+	Salt  string `json:"salt"`
+}
+
+// LoadKeystore decrypts the v3 keystore at path with passphrase.
+func LoadKeystore(path, passphrase string) (*Local, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keystore: %w", err)
+	}
+	return DecryptKeystore(data, passphrase)
+}
+
+// DecryptKeystore decrypts a v3 keystore. A wrong passphrase is reported
+// as a MAC mismatch.
+func DecryptKeystore(data []byte, passphrase string) (*Local, error) {
+	var f keystoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse keystore: %w", err)
+	}
+	if f.Version != 3 {
+		return nil, fmt.Errorf("keystore version %d not supported", f.Version)
+	}
+	c := f.Crypto
+	if c.Cipher == "" && f.Legacy != nil {
+		c = *f.Legacy
+	}
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("keystore cipher %q not supported", c.Cipher)
+	}
+
+	derived, err := deriveKey(c, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("keystore ciphertext: %w", err)
+	}
+	mac, err := hex.DecodeString(c.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("keystore mac: %w", err)
+	}
+	if subtle.ConstantTimeCompare(abi.Keccak256(derived[16:32], cipherText), mac) != 1 {
+		return nil, fmt.Errorf("keystore mac mismatch (wrong passphrase?)")
+	}
+
+	iv, err := hex.DecodeString(c.CipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("keystore iv must be %d bytes of hex", aes.BlockSize)
+	}
+	block, err := aes.NewCipher(derived[:16])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(cipherText))
+	cipher.NewCTR(block, iv).XORKeyStream(plain, cipherText)
+	if len(plain) != 32 {
+		return nil, fmt.Errorf("%w: keystore holds %d bytes", ErrInvalidKey, len(plain))
+	}
+
+	l, err := fromBytes(plain)
+	if err != nil {
+		return nil, err
+	}
+	clear(plain)
+
+	// The address field is optional and stored as the bare 20-byte account.
+	if f.Address != "" {
+		want, err := hex.DecodeString(f.Address)
+		if err != nil || !bytes.Equal(want, l.addr.Account()) {
+			return nil, fmt.Errorf("keystore address %s does not match its key", f.Address)
+		}
+	}
+	return l, nil
+}
+
+func deriveKey(c keystoreCrypto, passphrase string) ([]byte, error) {
+	switch c.KDF {
+	case "scrypt":
+		var p scryptParams
+		if err := json.Unmarshal(c.KDFParams, &p); err != nil {
+			return nil, fmt.Errorf("keystore kdfparams: %w", err)
+		}
+		salt, err := hex.DecodeString(p.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("keystore salt: %w", err)
+		}
+		if p.DKLen < 32 {
+			return nil, fmt.Errorf("keystore dklen %d too short", p.DKLen)
+		}
+		dk, err := scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, p.DKLen)
+		if err != nil {
+			return nil, fmt.Errorf("keystore scrypt: %w", err)
+		}
+		return dk, nil
+
+	case "pbkdf2":
+		var p pbkdf2Params
+		if err := json.Unmarshal(c.KDFParams, &p); err != nil {
+			return nil, fmt.Errorf("keystore kdfparams: %w", err)
+		}
+		if p.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("keystore prf %q not supported", p.PRF)
+		}
+		salt, err := hex.DecodeString(p.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("keystore salt: %w", err)
+		}
+		if p.DKLen < 32 || p.C <= 0 {
+			return nil, fmt.Errorf("keystore pbkdf2 params invalid")
+		}
+		dk, err := pbkdf2.Key(sha256.New, passphrase, salt, p.C, p.DKLen)
+		if err != nil {
+			return nil, fmt.Errorf("keystore pbkdf2: %w", err)
+		}
+		return dk, nil
+	}
+	return nil, fmt.Errorf("keystore kdf %q not supported", c.KDF)
+}
@@ -0,0 +1,104 @@
+package signer
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// The Web3 Secret Storage test vectors: both files hold testKey under the
+// passphrase "testpassword".
+const (
+	testKey        = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	testPassphrase = "testpassword"
+
+	pbkdf2Keystore = `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {
+				"c": 262144,
+				"dklen": 32,
+				"prf": "hmac-sha256",
+				"salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+			},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+
+	scryptKeystore = `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "83dbcc02d8ccb40e466191a123791e0e"},
+			"ciphertext": "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+			"kdf": "scrypt",
+			"kdfparams": {
+				"dklen": 32,
+				"n": 262144,
+				"r": 1,
+				"p": 8,
+				"salt": "ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19"
+			},
+			"mac": "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+)
+
+func TestDecryptKeystore(t *testing.T) {
+	want, err := FromHex(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := hex.EncodeToString(want.Address().Account())
+
+	tests := []struct {
+		name       string
+		keystore   string
+		passphrase string
+		err        string // substring; empty when it decrypts
+	}{
+		{"pbkdf2", pbkdf2Keystore, testPassphrase, ""},
+		{"scrypt", scryptKeystore, testPassphrase, ""},
+		{"matching address", withAddress(pbkdf2Keystore, account), testPassphrase, ""},
+		{"legacy Crypto field", strings.Replace(pbkdf2Keystore, `"crypto"`, `"Crypto"`, 1), testPassphrase, ""},
+		{"wrong passphrase, pbkdf2", pbkdf2Keystore, "testpassword!", "mac mismatch"},
+		{"wrong passphrase, scrypt", scryptKeystore, "", "mac mismatch"},
+		{"tampered ciphertext", strings.Replace(pbkdf2Keystore, "5318b4d5", "5318b4d6", 1), testPassphrase, "mac mismatch"},
+		{"tampered mac", strings.Replace(pbkdf2Keystore, "517ead92", "517ead93", 1), testPassphrase, "mac mismatch"},
+		{"other address", withAddress(pbkdf2Keystore, strings.Repeat("ab", 20)), testPassphrase, "does not match its key"},
+		{"unsupported cipher", strings.Replace(pbkdf2Keystore, "aes-128-ctr", "aes-128-cbc", 1), testPassphrase, "cipher"},
+		{"unsupported prf", strings.Replace(pbkdf2Keystore, "hmac-sha256", "hmac-sha512", 1), testPassphrase, "prf"},
+		{"unsupported kdf", strings.Replace(pbkdf2Keystore, `"kdf": "pbkdf2"`, `"kdf": "argon2"`, 1), testPassphrase, "kdf"},
+		{"version 1", strings.Replace(pbkdf2Keystore, `"version": 3`, `"version": 1`, 1), testPassphrase, "version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := DecryptKeystore([]byte(tt.keystore), tt.passphrase)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("DecryptKeystore = %v, want an error containing %q", err, tt.err)
+				}
+				if strings.Contains(err.Error(), tt.passphrase) && tt.passphrase != "" {
+					t.Fatalf("error echoes the passphrase: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.Address() != want.Address() {
+				t.Fatalf("address = %s, want %s", l.Address(), want.Address())
+			}
+		})
+	}
+}
+
+func withAddress(keystore, account string) string {
+	return strings.Replace(keystore, `"version": 3`, `"address": "`+account+`", "version": 3`, 1)
+}
@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// Local signs in process with a secp256k1 key held in memory.
+type Local struct {
+	key  *secp256k1.PrivateKey
+	addr domain.TronAddress
+}
+
+func NewLocal(key *secp256k1.PrivateKey) *Local {
+	return &Local{
+		key:  key,
+		addr: addressFromPublicKey(key.PubKey().SerializeUncompressed()),
+	}
+}
+
+// FromHex decodes a 32-byte hex key (optionally 0x-prefixed), as injected
+// through TRON_OPERATOR_KEY in development. The error never echoes the
+// input.
+func FromHex(s string) (*Local, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("%w: must be 32 bytes of hex", ErrInvalidKey)
+	}
+	return fromBytes(b)
+}
+
+func fromBytes(b []byte) (*Local, error) {
+	key := secp256k1.PrivKeyFromBytes(b)
+	if key.Key.IsZero() {
+		return nil, fmt.Errorf("%w: zero key", ErrInvalidKey)
+	}
+	return NewLocal(key), nil
+}
+
+func (l *Local) Address() domain.TronAddress { return l.addr }
+
+// Sign never blocks, so ctx is unused.
+func (l *Local) Sign(_ context.Context, txID []byte) ([]byte, error) {
+	if len(txID) != 32 {
+		return nil, fmt.Errorf("txID must be 32 bytes, got %d", len(txID))
+	}
+	compact := ecdsa.SignCompact(l.key, txID, false) // [v][r][s]
+	sig := make([]byte, 65)
+	copy(sig, compact[1:])
+	sig[64] = compact[0]
+	return sig, nil
+}
+
+// String, GoString and LogValue keep the key out of fmt and slog output.
+func (l *Local) String() string       { return "signer.Local(" + l.addr.String() + ")" }
+func (l *Local) GoString() string     { return l.String() }
+func (l *Local) LogValue() slog.Value { return slog.StringValue(l.String()) }
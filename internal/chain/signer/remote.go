@@ -0,0 +1,119 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// SignPath is the remote signer endpoint.
+//
+//	POST /v1/sign
+//	Authorization: Bearer <token>            (when a token is configured)
+//	{"address": "T...", "tx_id": "<32 bytes hex>"}
+//
+//	200 {"signature": "<65 bytes hex, r||s||v>"}
+//	4xx/5xx {"error": "..."}
+const SignPath = "/v1/sign"
+
+// SignRequest and SignResponse are the remote signer wire format.
+type SignRequest struct {
+	Address string `json:"address"`
+	TxID    string `json:"tx_id"`
+}
+
+type SignResponse struct {
+	Signature string `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Remote asks an external signing service (HSM/KMS front, or signertest
+// locally) to sign. It checks every signature recovers to the expected
+// address, so a misconfigured signer cannot make us broadcast garbage.
+type Remote struct {
+	baseURL string
+	token   string
+	addr    domain.TronAddress
+	http    *http.Client
+}
+
+func NewRemote(baseURL, token string, addr domain.TronAddress, httpClient *http.Client) *Remote {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Remote{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		addr:    addr,
+		http:    httpClient,
+	}
+}
+
+func (r *Remote) Address() domain.TronAddress { return r.addr }
+
+func (r *Remote) Sign(ctx context.Context, txID []byte) ([]byte, error) {
+	if len(txID) != 32 {
+		return nil, fmt.Errorf("txID must be 32 bytes, got %d", len(txID))
+	}
+	body, err := json.Marshal(SignRequest{Address: r.addr.String(), TxID: hex.EncodeToString(txID)})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+SignPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: read body: %w", err)
+	}
+	var out SignResponse
+	_ = json.Unmarshal(respBody, &out)
+	if resp.StatusCode != http.StatusOK {
+		msg := out.Error
+		if msg == "" {
+			msg = strings.TrimSpace(string(respBody))
+		}
+		return nil, fmt.Errorf("remote signer: http %d: %s", resp.StatusCode, msg)
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(out.Signature, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: signature is not hex")
+	}
+	got, err := Recover(txID, sig)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	if got != r.addr {
+		return nil, fmt.Errorf("remote signer: signature is from %s, want %s", got, r.addr)
+	}
+	return sig, nil
+}
+
+// String and LogValue keep the bearer token out of fmt and slog output.
+func (r *Remote) String() string       { return "signer.Remote(" + r.addr.String() + ")" }
+func (r *Remote) GoString() string     { return r.String() }
+func (r *Remote) LogValue() slog.Value { return slog.StringValue(r.String()) }
@@ -0,0 +1,129 @@
+package signer_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"token13/merchant-backend-go/internal/chain/signer"
+	"token13/merchant-backend-go/internal/chain/signer/signertest"
+	"token13/merchant-backend-go/internal/domain"
+)
+
+const (
+	operatorKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	otherKey    = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+)
+
+func localSigner(t *testing.T, key string) *signer.Local {
+	t.Helper()
+	l, err := signer.FromHex(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestRemoteSign(t *testing.T) {
+	operator := localSigner(t, operatorKey)
+	srv := signertest.New(operator, "s3cret")
+	defer srv.Close()
+
+	r := signer.NewRemote(srv.URL()+"/", "s3cret", operator.Address(), nil)
+	txID := sha256.Sum256([]byte("raw_data"))
+
+	sig, err := r.Sign(context.Background(), txID[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 65 || sig[64] < 27 {
+		t.Fatalf("signature = %x", sig)
+	}
+	got, err := signer.Recover(txID[:], sig)
+	if err != nil || got != operator.Address() {
+		t.Fatalf("Recover = %s, %v; want %s", got, err, operator.Address())
+	}
+
+	// The same key signs identically in process.
+	local, err := operator.Sign(context.Background(), txID[:])
+	if err != nil || !bytes.Equal(local, sig) {
+		t.Fatalf("local signature = %x, remote %x", local, sig)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].Address != operator.Address().String() || reqs[0].TxID != hex.EncodeToString(txID[:]) {
+		t.Fatalf("requests = %+v", reqs)
+	}
+}
+
+func TestRemoteSignRejected(t *testing.T) {
+	operator := localSigner(t, operatorKey)
+	other := localSigner(t, otherKey)
+	txID := sha256.Sum256([]byte("raw_data"))
+
+	tests := []struct {
+		name   string
+		server signer.Signer // what signertest signs with
+		token  string        // what the client sends
+		addr   signer.Signer // whose address the client expects
+		err    string
+	}{
+		{"wrong token", operator, "wrong", operator, "http 401"},
+		{"no token", operator, "", operator, "http 401"},
+		{"unknown address", operator, "s3cret", other, "http 404"},
+		{"signed by another key", signerAs{other, operator}, "s3cret", operator, "signature is from " + other.Address().String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := signertest.New(tt.server, "s3cret")
+			defer srv.Close()
+
+			r := signer.NewRemote(srv.URL(), tt.token, tt.addr.Address(), nil)
+			sig, err := r.Sign(context.Background(), txID[:])
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Sign = %x, %v; want an error containing %q", sig, err, tt.err)
+			}
+			if tt.token != "" && strings.Contains(err.Error(), tt.token) {
+				t.Fatalf("error echoes the token: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteSignChecksTxID(t *testing.T) {
+	operator := localSigner(t, operatorKey)
+	srv := signertest.New(operator, "")
+	defer srv.Close()
+
+	r := signer.NewRemote(srv.URL(), "", operator.Address(), nil)
+	if _, err := r.Sign(context.Background(), []byte("short")); err == nil {
+		t.Fatal("signed a txID that is not 32 bytes")
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Fatalf("sent %d requests for a bad txID", n)
+	}
+}
+
+func TestRemoteKeepsTokenOutOfLogs(t *testing.T) {
+	r := signer.NewRemote("http://signer", "s3cret", localSigner(t, operatorKey).Address(), nil)
+	for _, s := range []string{r.String(), r.GoString(), r.LogValue().String()} {
+		if strings.Contains(s, "s3cret") {
+			t.Fatalf("%q shows the token", s)
+		}
+	}
+}
+
+// signerAs answers for addr's account but signs with key, like a signing
+// service configured with the wrong key.
+type signerAs struct {
+	key, addr signer.Signer
+}
+
+func (s signerAs) Address() domain.TronAddress { return s.addr.Address() }
+
+func (s signerAs) Sign(ctx context.Context, txID []byte) ([]byte, error) {
+	return s.key.Sign(ctx, txID)
+}
@@ -0,0 +1,61 @@
+// Package signer signs Tron transactions with the operator key.
+//
+// A Signer only ever sees the transaction id (sha256 of raw_data), never
+// the transaction itself, so the same interface fits a key held in
+// process (Local, from an env hex key or an encrypted keystore) and one
+// held by a remote signing service (Remote).
+//
+// Key material never leaves this package: signers format as their
+// address only, and errors never echo key bytes or passphrases.
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+
+	"token13/merchant-backend-go/internal/chain/abi"
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// Signer signs Tron transaction ids on behalf of one account.
+type Signer interface {
+	// Address is the account transactions are sent from.
+	Address() domain.TronAddress
+
+	// Sign returns the 65-byte r||s||v signature of a 32-byte txID, with
+	// v = 27 + recovery id as Tron nodes expect.
+	Sign(ctx context.Context, txID []byte) ([]byte, error)
+}
+
+var ErrInvalidKey = errors.New("invalid operator key")
+
+// Recover returns the address whose key produced sig over txID.
+func Recover(txID, sig []byte) (domain.TronAddress, error) {
+	if len(txID) != 32 {
+		return domain.TronAddress{}, fmt.Errorf("txID must be 32 bytes, got %d", len(txID))
+	}
+	if len(sig) != 65 {
+		return domain.TronAddress{}, fmt.Errorf("signature must be 65 bytes, got %d", len(sig))
+	}
+
+	compact := append([]byte{sig[64]}, sig[:64]...)
+	if compact[0] < 27 {
+		compact[0] += 27
+	}
+	pub, _, err := ecdsa.RecoverCompact(compact, txID)
+	if err != nil {
+		return domain.TronAddress{}, fmt.Errorf("recover signer: %w", err)
+	}
+	return addressFromPublicKey(pub.SerializeUncompressed()), nil
+}
+
+// addressFromPublicKey derives the Tron address of an uncompressed
+// secp256k1 public key (65 bytes, 0x04 prefix).
+func addressFromPublicKey(pub []byte) domain.TronAddress {
+	sum := abi.Keccak256(pub[1:])
+	addr, _ := domain.TronAddressFromBytes(sum[12:])
+	return addr
+}
@@ -0,0 +1,83 @@
+// Package signertest provides an in-process remote signer backed by a
+// local key, so signer.Remote and everything built on it can run without
+// an HSM.
+package signertest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"token13/merchant-backend-go/internal/chain/signer"
+)
+
+type Server struct {
+	srv    *httptest.Server
+	signer signer.Signer
+	token  string
+
+	mu       sync.Mutex
+	requests []signer.SignRequest
+}
+
+// New starts a remote signer that signs with s. If token is non-empty
+// every request must carry it as a bearer token.
+func New(s signer.Signer, token string) *Server {
+	srv := &Server{signer: s, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+signer.SignPath, srv.handleSign)
+	srv.srv = httptest.NewServer(mux)
+	return srv
+}
+
+func (s *Server) URL() string { return s.srv.URL }
+
+func (s *Server) Close() { s.srv.Close() }
+
+// Requests returns the sign requests received so far.
+func (s *Server) Requests() []signer.SignRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]signer.SignRequest(nil), s.requests...)
+}
+
+func (s *Server) handleSign(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeJSON(w, http.StatusUnauthorized, signer.SignResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req signer.SignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, signer.SignResponse{Error: "bad request"})
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if req.Address != s.signer.Address().String() {
+		writeJSON(w, http.StatusNotFound, signer.SignResponse{Error: "unknown address"})
+		return
+	}
+	txID, err := hex.DecodeString(req.TxID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, signer.SignResponse{Error: "tx_id must be hex"})
+		return
+	}
+	sig, err := s.signer.Sign(r.Context(), txID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, signer.SignResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, signer.SignResponse{Signature: hex.EncodeToString(sig)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
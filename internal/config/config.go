@@ -7,49 +7,159 @@ import (
 )
 
 type Config struct {
+	// "dev" relaxes production requirements; it has no default, so a
+	// deployment that forgets APP_ENV is treated as production.
 	AppEnv   string
 	HTTPPort int
 
+	// Proxies (IPs or CIDRs, comma separated) whose X-Forwarded-For is
+	// trusted for the client address. Empty: the peer address is used.
+	HTTPTrustedProxies string
+
 	DBDSN string
 
 	RabbitURL      string
 	RabbitExchange string
 
-	JWTSecret string
-	JWTIssuer string
+	// Directory of PEM keys (RSA or Ed25519) named <kid>.pem. Public-only
+	// files verify but never sign. Required unless APP_ENV=dev, where an
+	// empty value generates a key at startup.
+	JWTKeysDir    string
+	JWTSigningKID string // empty: the only private key in JWTKeysDir
+	JWTIssuer     string
+	JWTAudience   string
+
+	// Lifetime of access tokens (minutes) and of a session's refresh
+	// tokens from login (hours).
+	JWTAccessTTLMinutes int
+	JWTRefreshTTLHours  int
 
 	TronAPIBase string
 	TronAPIKey  string
+
+	// Operator signer for the account holding MANAGER_ROLE: "env"
+	// (TronOperatorKey, dev only), "keystore" or "remote". Empty picks
+	// "env" when TronOperatorKey is set; no signer = chain calls are
+	// stubbed out.
+	TronSigner      string
+	TronOperatorKey string
+	TronFeeLimit    int
+
+	// Encrypted (web3 v3) keystore holding the operator key.
+	TronKeystorePath     string
+	TronKeystorePassword string
+
+	// Remote signer endpoint and the operator address it signs for.
+	TronRemoteSignerURL     string
+	TronRemoteSignerToken   string
+	TronRemoteSignerAddress string
+
+	// TRX (sun) the operator must keep after paying for a transaction.
+	// Below it transactions are held back: "queue" (retry later) or
+	// "refuse" (dead-letter).
+	TronOperatorMinBalanceSun int
+	TronOperatorBudgetMode    string
+
+	// First block the payment indexer scans when it has no cursor yet.
+	// 0 = start at the current head.
+	TronIndexerStartBlock int
+
+	// Solidified blocks (the payment's own included) before a payment is final.
+	TronPaymentConfirmations int
+
+	// Tracker polls in a row that must miss a payment's tx before it is
+	// reverted.
+	TronPaymentRevertAfterMisses int
+
+	ContractsPath string
+
+	// TRC-20 addresses, comma separated, allow-listed next to the
+	// contracts bundle's USDT (which stays the default).
+	TronTokens string
+
+	// Minimum hours between two fund-receiver rotations of a merchant.
+	MerchantReceiverCooldownHours int
 }
 
 func Load() (*Config, error) {
-	cfg := &Config{
-		AppEnv:   getEnv("APP_ENV", "dev"),
+	cfg := load()
+
+	// Minimal validation
+	if cfg.JWTKeysDir == "" && cfg.AppEnv != "dev" {
+		return nil, fmt.Errorf("JWT_KEYS_DIR is required")
+	}
+	if err := cfg.validateInfra(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadWorker is Load without the HTTP-only requirements (JWT).
+func LoadWorker() (*Config, error) {
+	cfg := load()
+	if err := cfg.validateInfra(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func load() *Config {
+	return &Config{
+		AppEnv:   os.Getenv("APP_ENV"),
 		HTTPPort: getEnvInt("HTTP_PORT", 8080),
 		DBDSN:    os.Getenv("DB_DSN"),
 
+		HTTPTrustedProxies: os.Getenv("HTTP_TRUSTED_PROXIES"),
+
 		RabbitURL:      os.Getenv("RABBIT_URL"),
 		RabbitExchange: getEnv("RABBIT_EXCHANGE", "token13.events"),
 
-		JWTSecret: os.Getenv("JWT_SECRET"),
-		JWTIssuer: getEnv("JWT_ISSUER", "merchant-backend"),
+		JWTKeysDir:    os.Getenv("JWT_KEYS_DIR"),
+		JWTSigningKID: os.Getenv("JWT_SIGNING_KID"),
+		JWTIssuer:     getEnv("JWT_ISSUER", "merchant-backend"),
+		JWTAudience:   getEnv("JWT_AUDIENCE", "merchant-api"),
+
+		JWTAccessTTLMinutes: getEnvInt("JWT_ACCESS_TTL_MINUTES", 15),
+		JWTRefreshTTLHours:  getEnvInt("JWT_REFRESH_TTL_HOURS", 720),
 
 		TronAPIBase: getEnv("TRON_API_BASE", "https://api.trongrid.io"),
 		TronAPIKey:  os.Getenv("TRON_API_KEY"),
-	}
 
-	// Minimal validation
-	if cfg.JWTSecret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+		TronSigner:      os.Getenv("TRON_SIGNER"),
+		TronOperatorKey: os.Getenv("TRON_OPERATOR_KEY"),
+		TronFeeLimit:    getEnvInt("TRON_FEE_LIMIT", 100_000_000),
+
+		TronKeystorePath:     os.Getenv("TRON_KEYSTORE_PATH"),
+		TronKeystorePassword: os.Getenv("TRON_KEYSTORE_PASSWORD"),
+
+		TronRemoteSignerURL:     os.Getenv("TRON_REMOTE_SIGNER_URL"),
+		TronRemoteSignerToken:   os.Getenv("TRON_REMOTE_SIGNER_TOKEN"),
+		TronRemoteSignerAddress: os.Getenv("TRON_REMOTE_SIGNER_ADDRESS"),
+
+		TronOperatorMinBalanceSun: getEnvInt("TRON_OPERATOR_MIN_BALANCE_SUN", 20_000_000),
+		TronOperatorBudgetMode:    getEnv("TRON_OPERATOR_BUDGET_MODE", "queue"),
+
+		TronIndexerStartBlock:    getEnvInt("TRON_INDEXER_START_BLOCK", 0),
+		TronPaymentConfirmations: getEnvInt("TRON_PAYMENT_CONFIRMATIONS", 1),
+
+		TronPaymentRevertAfterMisses: getEnvInt("TRON_PAYMENT_REVERT_AFTER_MISSES", 10),
+
+		ContractsPath: getEnv("CONTRACTS_PATH", "internal/config/contract.json"),
+		TronTokens:    os.Getenv("TRON_TOKENS"),
+
+		MerchantReceiverCooldownHours: getEnvInt("MERCHANT_RECEIVER_COOLDOWN_HOURS", 24),
 	}
+}
+
+func (cfg *Config) validateInfra() error {
 	if cfg.DBDSN == "" {
-		return nil, fmt.Errorf("DB_DSN is required")
+		return fmt.Errorf("DB_DSN is required")
 	}
 	if cfg.RabbitURL == "" {
-		return nil, fmt.Errorf("RABBIT_URL is required")
+		return fmt.Errorf("RABBIT_URL is required")
 	}
-
-	return cfg, nil
+	return nil
 }
 
 func getEnv(key, fallback string) string {
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadRequiresJWTKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		appEnv  string
+		keysDir string
+		err     string
+	}{
+		{name: "unset env", err: "JWT_KEYS_DIR is required"},
+		{name: "production", appEnv: "prod", err: "JWT_KEYS_DIR is required"},
+		{name: "dev", appEnv: "dev"},
+		{name: "keys configured", appEnv: "prod", keysDir: "/etc/token13/jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("JWT_KEYS_DIR", tt.keysDir)
+			t.Setenv("DB_DSN", "postgres://localhost/token13")
+			t.Setenv("RABBIT_URL", "amqp://localhost/")
+
+			cfg, err := Load()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.JWTKeysDir != tt.keysDir {
+				t.Fatalf("JWTKeysDir = %q", cfg.JWTKeysDir)
+			}
+		})
+	}
+
+	// The worker never signs tokens.
+	t.Setenv("APP_ENV", "prod")
+	t.Setenv("JWT_KEYS_DIR", "")
+	t.Setenv("DB_DSN", "postgres://localhost/token13")
+	t.Setenv("RABBIT_URL", "amqp://localhost/")
+	if _, err := LoadWorker(); err != nil {
+		t.Fatalf("LoadWorker without JWT keys: %v", err)
+	}
+}
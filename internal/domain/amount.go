@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Amounts are stored as NUMERIC(36,18): at most 18 integer and 18
+// fractional digits.
+const (
+	AmountIntDigits  = 18
+	AmountFracDigits = 18
+)
+
+var (
+	ErrInvalidAmount   = errors.New("amount must be a positive decimal with at most 18 integer and 18 fractional digits")
+	ErrAmountPrecision = errors.New("amount has more decimals than the token supports")
+)
+
+// NormalizeAmount validates a plain decimal string ("12", "0.5", "10.250")
+// and returns its canonical form without leading or trailing zeros
+// ("10.25"). Signs, exponents and separators are rejected so the value is
+// never rounded on its way into NUMERIC(36,18).
+func NormalizeAmount(s string) (string, error) {
+	out, err := normalizeNonNegative(s)
+	if err != nil {
+		return "", err
+	}
+	if out == "0" {
+		return "", ErrInvalidAmount
+	}
+	return out, nil
+}
+
+// normalizeNonNegative is NormalizeAmount that also accepts zero.
+func normalizeNonNegative(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || (hasDot && fracPart == "") {
+		return "", ErrInvalidAmount
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return "", ErrInvalidAmount
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(intPart) > AmountIntDigits || len(fracPart) > AmountFracDigits {
+		return "", ErrInvalidAmount
+	}
+
+	if intPart == "" {
+		intPart = "0"
+	}
+	if fracPart == "" {
+		return intPart, nil
+	}
+	return intPart + "." + fracPart, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatUnits renders an on-chain integer amount with the token's decimals
+// ("12500000", 6 -> "12.5"). It fails if the result does not fit
+// NUMERIC(36,18) exactly.
+func FormatUnits(raw *big.Int, decimals int) (string, error) {
+	if raw == nil || raw.Sign() < 0 || decimals < 0 {
+		return "", ErrInvalidAmount
+	}
+
+	digits := raw.String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	intPart, fracPart := digits[:len(digits)-decimals], digits[len(digits)-decimals:]
+
+	intPart = strings.TrimLeft(intPart, "0")
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(intPart) > AmountIntDigits || len(fracPart) > AmountFracDigits {
+		return "", ErrInvalidAmount
+	}
+
+	if intPart == "" {
+		intPart = "0"
+	}
+	if fracPart == "" {
+		return intPart, nil
+	}
+	return intPart + "." + fracPart, nil
+}
+
+// ParseUnits converts a decimal amount to on-chain integer units
+// ("12.5", 6 -> 12500000). It fails rather than round when amount has
+// more fractional digits than the token supports.
+func ParseUnits(amount string, decimals int) (*big.Int, error) {
+	amount, err := normalizeNonNegative(amount)
+	if err != nil {
+		return nil, err
+	}
+	intPart, fracPart, _ := strings.Cut(amount, ".")
+	if decimals < 0 || len(fracPart) > decimals {
+		return nil, fmt.Errorf("%w (%d)", ErrAmountPrecision, decimals)
+	}
+
+	n, ok := new(big.Int).SetString(intPart+fracPart+strings.Repeat("0", decimals-len(fracPart)), 10)
+	if !ok {
+		return nil, ErrInvalidAmount
+	}
+	return n, nil
+}
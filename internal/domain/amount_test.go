@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAmount(t *testing.T) {
+	tests := []struct {
+		in, want string // want empty: ErrInvalidAmount
+	}{
+		{"12", "12"},
+		{"0.5", "0.5"},
+		{"10.250", "10.25"},
+		{"007.100", "7.1"},
+		{" 1.0 ", "1"},
+		{"0.000000000000000001", "0.000000000000000001"},
+		{"999999999999999999.999999999999999999", "999999999999999999.999999999999999999"},
+		{"0", ""},
+		{"0.000", ""},
+		{"-1", ""},
+		{"+1", ""},
+		{"1e6", ""},
+		{"1,5", ""},
+		{".5", ""},
+		{"5.", ""},
+		{"", ""},
+		{"1000000000000000000", ""},    // 19 integer digits
+		{"0.0000000000000000001", ""},  // 19 fractional digits
+		{"0.00000000000000000010", ""}, // still 19 once trimmed
+	}
+	for _, tt := range tests {
+		got, err := NormalizeAmount(tt.in)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("NormalizeAmount(%q) = %q, %v; want ErrInvalidAmount", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeAmount(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals int
+		want     string // base units; empty when it fails
+		err      error
+	}{
+		{"12.5", 6, "12500000", nil},
+		{"12.500000", 6, "12500000", nil},
+		{"0.000001", 6, "1", nil},
+		{"0", 6, "0", nil},
+		{"5", 0, "5", nil},
+		{"1", 18, "1000000000000000000", nil},
+		{"0.000000000000000001", 18, "1", nil},
+		{"999999999999999999.999999999999999999", 18, strings.Repeat("9", 36), nil},
+		{"0.0000001", 6, "", ErrAmountPrecision},
+		{"0.5", 0, "", ErrAmountPrecision},
+		{"1.5", -1, "", ErrAmountPrecision},
+		{"-1", 6, "", ErrInvalidAmount},
+		{"1e6", 6, "", ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnits(tt.amount, tt.decimals)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ParseUnits(%q, %d) = %v, %v; want %v", tt.amount, tt.decimals, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ParseUnits(%q, %d) = %v, %v; want %s", tt.amount, tt.decimals, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		raw      string
+		decimals int
+		want     string // empty: ErrInvalidAmount
+	}{
+		{"12500000", 6, "12.5"},
+		{"1", 6, "0.000001"},
+		{"0", 6, "0"},
+		{"1000000", 6, "1"},
+		{"5", 0, "5"},
+		{"1", 18, "0.000000000000000001"},
+		{strings.Repeat("9", 36), 18, "999999999999999999.999999999999999999"},
+		{"1" + strings.Repeat("0", 36), 18, ""}, // 19 integer digits
+		{"1", 19, ""},                           // 19 fractional digits
+		{"10", 19, "0.000000000000000001"},      // exact after trimming
+		{"-1", 6, ""},
+		{"1", -1, ""},
+	}
+	for _, tt := range tests {
+		raw, _ := new(big.Int).SetString(tt.raw, 10)
+		got, err := FormatUnits(raw, tt.decimals)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("FormatUnits(%s, %d) = %q, %v; want ErrInvalidAmount", tt.raw, tt.decimals, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("FormatUnits(%s, %d) = %q, %v; want %q", tt.raw, tt.decimals, got, err, tt.want)
+		}
+	}
+
+	if _, err := FormatUnits(nil, 6); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("FormatUnits(nil) = %v", err)
+	}
+}
+
+// Every amount a token can express survives the round trip to base units.
+func TestUnitsRoundTrip(t *testing.T) {
+	for _, decimals := range []int{0, 2, 6, 8, 18} {
+		for _, amount := range []string{"1", "123456789012345678", "0.5"} {
+			raw, err := ParseUnits(amount, decimals)
+			if err != nil {
+				if decimals == 0 && amount == "0.5" {
+					continue
+				}
+				t.Fatalf("ParseUnits(%q, %d): %v", amount, decimals, err)
+			}
+			back, err := FormatUnits(raw, decimals)
+			if err != nil || back != amount {
+				t.Fatalf("round trip of %q at %d decimals = %q, %v", amount, decimals, back, err)
+			}
+		}
+	}
+}
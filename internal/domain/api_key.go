@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+var ErrInvalidScope = errors.New("scope must be orders:write or payments:read")
+
+// Scope limits what an API key may do.
+type Scope string
+
+const (
+	ScopeOrdersWrite  Scope = "orders:write"  // create orders
+	ScopePaymentsRead Scope = "payments:read" // read orders and their payments
+)
+
+func (s Scope) Valid() bool {
+	return s == ScopeOrdersWrite || s == ScopePaymentsRead
+}
+
+// APIKey lets a merchant's backend call the API without a user's JWT.
+// Only the SHA-256 of its secret is stored.
+type APIKey struct {
+	ID         int64
+	MerchantID []byte // bytes32
+	Name       string
+	Prefix     string // public part, identifies the key
+	SecretHash []byte
+
+	Scopes     []Scope
+	AllowedIPs []netip.Prefix // empty = any address
+
+	CreatedBy  string // user_uid
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (k *APIKey) HasScope(s Scope) bool {
+	return slices.Contains(k.Scopes, s)
+}
+
+// AllowsIP reports whether the key may be used from ip.
+func (k *APIKey) AllowsIP(ip netip.Addr) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	ip = ip.Unmap()
+	for _, p := range k.AllowedIPs {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
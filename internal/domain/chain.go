@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// ChainReceipt is the execution outcome of a broadcast Tron transaction.
+type ChainReceipt struct {
+	TxID        string
+	Found       bool // false while the tx is not yet in a block
+	BlockNumber int64
+	BlockTime   time.Time
+	Success     bool
+	Result      string // node result code, e.g. SUCCESS / REVERT / OUT_OF_ENERGY
+	ReturnData  []byte // contract return data; the revert reason when Result is REVERT
+}
+
+// AccountResources is what a Tron account can spend on transactions.
+// Energy and bandwidth used beyond what is available are paid by burning
+// TRX at the given prices.
+type AccountResources struct {
+	Address           TronAddress
+	BalanceSun        int64
+	Energy            int64 // available: staked/delegated limit minus used
+	Bandwidth         int64 // available: free plus staked, minus used
+	EnergyPriceSun    int64 // sun burnt per missing energy unit
+	BandwidthPriceSun int64 // sun burnt per missing bandwidth byte
+}
+
+// TxCost is the estimated resource usage of one transaction.
+type TxCost struct {
+	Energy    int64
+	Bandwidth int64 // serialized size in bytes
+}
+
+// BurnSun returns the TRX (in sun) r would burn to cover c.
+//
+// Bandwidth is all-or-nothing on Tron: a transaction larger than the
+// available bandwidth burns TRX for its full size.
+func (r AccountResources) BurnSun(c TxCost) int64 {
+	var burn int64
+	if c.Energy > r.Energy {
+		burn += (c.Energy - r.Energy) * r.EnergyPriceSun
+	}
+	if c.Bandwidth > r.Bandwidth {
+		burn += c.Bandwidth * r.BandwidthPriceSun
+	}
+	return burn
+}
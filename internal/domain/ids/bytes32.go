@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 func NewBytes32() ([]byte, error) {
@@ -20,3 +21,16 @@ func Bytes32ToHex(b []byte) (string, error) {
 	}
 	return "0x" + hex.EncodeToString(b), nil
 }
+
+// HexToBytes32 parses a "0x..." (or bare) hex string into 32 bytes.
+func HexToBytes32(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode bytes32: %w", err)
+	}
+	if len(b) != 32 {
+		return nil, fmt.Errorf("expected 32 bytes, got %d", len(b))
+	}
+	return b, nil
+}
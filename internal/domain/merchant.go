@@ -1 +1,92 @@
 package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrWalletInUse is returned when a wallet already receives funds for
+// another merchant.
+var ErrWalletInUse = errors.New("wallet already belongs to another merchant")
+
+type MerchantStatus string
+
+const (
+	MerchantPending  MerchantStatus = "PENDING"
+	MerchantActive   MerchantStatus = "ACTIVE"
+	MerchantInactive MerchantStatus = "INACTIVE"
+)
+
+type Merchant struct {
+	MerchantID    []byte // bytes32
+	Name          string
+	WalletAddress TronAddress
+	Status        MerchantStatus
+
+	// Set once onboardMerchant has been broadcast / confirmed.
+	ChainTxID         string
+	ChainRegisteredAt *time.Time
+
+	// Set when the worker gave up onboarding; cleared by a retry.
+	ChainError    string
+	ChainFailedAt *time.Time
+
+	// An ACTIVE/INACTIVE change waiting for updateMerchantStatus to be
+	// confirmed on chain; StatusTxID is set once it has been broadcast.
+	RequestedStatus   MerchantStatus
+	StatusTxID        string
+	StatusRequestedAt *time.Time
+
+	// Why the last status change failed; cleared by the next request.
+	StatusError    string
+	StatusFailedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OnChain reports whether onboarding has been confirmed on chain.
+func (m *Merchant) OnChain() bool {
+	return m.ChainRegisteredAt != nil
+}
+
+// OnboardingFailed reports whether the worker gave up onboarding and it
+// waits for a retry.
+func (m *Merchant) OnboardingFailed() bool {
+	return !m.OnChain() && m.ChainFailedAt != nil
+}
+
+// StatusPending reports whether a status change awaits chain confirmation.
+func (m *Merchant) StatusPending() bool {
+	return m.RequestedStatus != ""
+}
+
+type ReceiverChangeStatus string
+
+const (
+	ReceiverChangePending   ReceiverChangeStatus = "PENDING"
+	ReceiverChangeConfirmed ReceiverChangeStatus = "CONFIRMED"
+	ReceiverChangeFailed    ReceiverChangeStatus = "FAILED"
+)
+
+// ReceiverChange is one rotation of a merchant's fund receiver
+// (wallet_address). wallet_address only follows once
+// updateMerchantReceiverAddress is confirmed on chain.
+type ReceiverChange struct {
+	ID          int64
+	MerchantID  []byte // bytes32
+	OldReceiver TronAddress
+	NewReceiver TronAddress
+	Status      ReceiverChangeStatus
+
+	// Set once updateMerchantReceiverAddress has been broadcast.
+	TxID string
+
+	RequestedBy string // user_uid
+	RequestedAt time.Time
+	ConfirmedAt *time.Time
+
+	// Set when the worker gave up the rotation (FAILED).
+	Error    string
+	FailedAt *time.Time
+}
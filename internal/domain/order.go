@@ -1 +1,38 @@
 package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrExternalRefExists is returned when a merchant reuses an external_ref.
+var ErrExternalRefExists = errors.New("order with this external_ref already exists")
+
+// PaymentStatus is shared by orders.payment_status and payments.status.
+type PaymentStatus string
+
+const (
+	PaymentPending PaymentStatus = "PENDING"
+	PaymentSuccess PaymentStatus = "SUCCESS"
+	PaymentFailed  PaymentStatus = "FAILED"
+)
+
+type Order struct {
+	OrderID    []byte // bytes32
+	InvoiceID  []byte // bytes32
+	MerchantID []byte // bytes32
+
+	Amount       string // decimal, see NormalizeAmount
+	Currency     string
+	TokenAddress string
+	ExternalRef  string
+
+	PaymentStatus PaymentStatus
+
+	// Matching outcome of the order's confirmed payments.
+	AmountPaid string // decimal, in Currency
+	Settlement Settlement
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
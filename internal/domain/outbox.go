@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrOutboxDead marks a publish failure retrying will not fix. The row is
+// parked (failed_at) for inspection instead of being tried again.
+var ErrOutboxDead = errors.New("outbox message dead")
+
+// OutboxMessage is an event stored alongside the business rows it
+// describes, to be relayed to the message broker after commit.
+type OutboxMessage struct {
+	ID         int64
+	RoutingKey string
+	Payload    json.RawMessage
+	Attempts   int
+}
+
+func NewOutboxMessage(routingKey string, payload any) (OutboxMessage, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxMessage{}, fmt.Errorf("marshal %s payload: %w", routingKey, err)
+	}
+	return OutboxMessage{RoutingKey: routingKey, Payload: b}, nil
+}
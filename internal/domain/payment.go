@@ -1 +1,54 @@
 package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnknownOrder is returned when a payment references an order (and
+// merchant) the backend does not know.
+var ErrUnknownOrder = errors.New("payment for unknown order")
+
+// ErrNeedsReview is wrapped by settlement errors retrying cannot fix, such
+// as an order in a token that is no longer supported. The tracker holds
+// the payment for manual review instead of retrying it.
+var ErrNeedsReview = errors.New("payment needs manual review")
+
+// Payment is a PaymentCoreV1 payment seen on chain.
+type Payment struct {
+	PaymentUID string
+
+	OrderID    []byte // bytes32
+	InvoiceID  []byte // bytes32
+	MerchantID []byte // bytes32
+
+	TokenAddress    string // base58
+	PayerAddress    string
+	MerchantAddress string
+
+	Amount    string // decimal, scaled by the token's decimals
+	AmountRaw string // uint256 base units, as emitted
+	Currency  string
+
+	TxHash      string
+	BlockNumber int64
+	LogIndex    int
+
+	Status      PaymentStatus
+	Match       PaymentMatch // set once confirmed
+	PaidAt      time.Time    // block time of the payTx
+	ConfirmedAt *time.Time
+
+	// ReviewReason is set while the tracker holds the payment for manual
+	// review; ReviewAt is when it was held.
+	ReviewReason string
+	ReviewAt     *time.Time
+
+	// MissedPolls counts the tracker polls in a row that could not find the
+	// tx on chain; LastMissedAt is the latest of them.
+	MissedPolls  int
+	LastMissedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// RevokeReason records why a refresh token family was revoked.
+type RevokeReason string
+
+const (
+	RevokeLogout    RevokeReason = "LOGOUT"
+	RevokeLogoutAll RevokeReason = "LOGOUT_ALL"
+	RevokeReuse     RevokeReason = "REUSE" // a rotated token was presented again
+)
+
+// RefreshToken is one link of a login session: every refresh rotates it
+// for a successor in the same family. Only its SHA-256 is stored.
+type RefreshToken struct {
+	ID       int64
+	Hash     []byte
+	FamilyID string // uuid, shared by every token of the session
+	UserUID  string
+
+	ExpiresAt time.Time  // the family's, set at login and kept on rotation
+	RotatedAt *time.Time // exchanged for its successor
+	RevokedAt *time.Time
+
+	CreatedAt time.Time
+}
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// PaymentMatch classifies a confirmed payment against its order.
+type PaymentMatch string
+
+const (
+	MatchExact      PaymentMatch = "EXACT"       // brings the total paid to exactly the amount due
+	MatchPartial    PaymentMatch = "PARTIAL"     // total paid is still below the amount due
+	MatchOverpaid   PaymentMatch = "OVERPAID"    // total paid exceeds the amount due
+	MatchWrongToken PaymentMatch = "WRONG_TOKEN" // paid in a token other than the order's
+)
+
+// Settlement summarises how much of an order has been paid.
+type Settlement string
+
+const (
+	SettlementUnpaid   Settlement = "UNPAID"
+	SettlementPartial  Settlement = "PARTIAL"
+	SettlementPaid     Settlement = "PAID"
+	SettlementOverpaid Settlement = "OVERPAID"
+)
+
+// Settled reports whether the order has received at least what it owes.
+func (s Settlement) Settled() bool {
+	return s == SettlementPaid || s == SettlementOverpaid
+}
+
+// MatchResult is the outcome of matching an order's payments.
+type MatchResult struct {
+	Paid       *big.Int // in the order token's base units
+	Settlement Settlement
+	Matches    map[string]PaymentMatch // by payment UID, confirmed payments only
+}
+
+// MatchPayments compares the order's confirmed payments, in the order
+// given (oldest first), against due base units of token. Each payment is
+// classified by the running total it brings the order to, so an order may
+// be paid across several payments: PARTIAL, PARTIAL, EXACT. Payments that
+// are not SUCCESS are ignored.
+func MatchPayments(due *big.Int, token string, payments []Payment) (MatchResult, error) {
+	res := MatchResult{
+		Paid:       new(big.Int),
+		Settlement: SettlementUnpaid,
+		Matches:    map[string]PaymentMatch{},
+	}
+
+	for _, p := range payments {
+		if p.Status != PaymentSuccess {
+			continue
+		}
+		if p.TokenAddress != token {
+			res.Matches[p.PaymentUID] = MatchWrongToken
+			continue
+		}
+
+		amount, ok := new(big.Int).SetString(p.AmountRaw, 10)
+		if !ok || amount.Sign() < 0 {
+			return MatchResult{}, fmt.Errorf("payment %s: invalid amount_raw %q", p.PaymentUID, p.AmountRaw)
+		}
+		res.Paid.Add(res.Paid, amount)
+
+		switch res.Paid.Cmp(due) {
+		case -1:
+			res.Matches[p.PaymentUID] = MatchPartial
+		case 0:
+			res.Matches[p.PaymentUID] = MatchExact
+		default:
+			res.Matches[p.PaymentUID] = MatchOverpaid
+		}
+	}
+
+	switch {
+	case res.Paid.Sign() == 0:
+		res.Settlement = SettlementUnpaid
+	case res.Paid.Cmp(due) < 0:
+		res.Settlement = SettlementPartial
+	case res.Paid.Cmp(due) == 0:
+		res.Settlement = SettlementPaid
+	default:
+		res.Settlement = SettlementOverpaid
+	}
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"math/big"
+	"time"
+)
+
+// MaxTokenDecimals is the most decimals a token may have: beyond it a
+// single base unit is not representable in NUMERIC(36,18).
+const MaxTokenDecimals = AmountFracDigits
+
+// Token is a TRC-20 token orders can be priced and paid in.
+type Token struct {
+	Address  string // base58
+	Symbol   string
+	Name     string
+	Decimals int
+}
+
+// ToBaseUnits converts a NUMERIC(36,18) amount to the token's uint256 base
+// units. It fails rather than round; with Decimals <= MaxTokenDecimals the
+// result always fits a uint256.
+func (t Token) ToBaseUnits(amount string) (*big.Int, error) {
+	return ParseUnits(amount, t.Decimals)
+}
+
+// FromBaseUnits converts uint256 base units to a NUMERIC(36,18) amount. It
+// fails if the amount is too large to be stored exactly.
+func (t Token) FromBaseUnits(raw *big.Int) (string, error) {
+	return FormatUnits(raw, t.Decimals)
+}
+
+// MerchantToken is whether a merchant accepts a token in MerchantRegistryV1.
+// Orders can only be created in tokens the chain has confirmed enabled.
+type MerchantToken struct {
+	MerchantID []byte // bytes32
+	Token      TronAddress
+	Symbol     string // from the token list, not stored
+
+	Enabled bool // as confirmed on chain
+
+	// A change waiting for updateMerchantTokenStatus to be confirmed;
+	// TxID is set once it has been broadcast.
+	RequestedEnabled *bool
+	TxID             string
+	RequestedAt      *time.Time
+
+	// Why the last change failed; cleared by the next request.
+	Error    string
+	FailedAt *time.Time
+
+	UpdatedAt time.Time
+}
+
+// Pending reports whether a change awaits chain confirmation.
+func (t *MerchantToken) Pending() bool {
+	return t.RequestedEnabled != nil
+}
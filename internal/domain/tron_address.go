@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// TronAddressPrefix is the first byte of every mainnet/testnet Tron address.
+const TronAddressPrefix = 0x41
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var ErrInvalidTronAddress = errors.New("invalid tron address")
+
+// TronAddress is a Tron account or contract address: the 0x41 prefix
+// followed by the 20-byte account. The zero value is not a valid address.
+//
+// Its canonical text form is base58check ("T..."); Hex gives the
+// 41-prefixed form TronGrid uses when visible=false.
+type TronAddress [21]byte
+
+// ParseTronAddress accepts a base58check ("T...") or 41-prefixed hex
+// address, verifying the checksum of the former. Surrounding whitespace
+// is ignored.
+func ParseTronAddress(s string) (TronAddress, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return TronAddress{}, fmt.Errorf("%w: empty", ErrInvalidTronAddress)
+	}
+
+	if len(s) == 42 && strings.HasPrefix(s, "41") {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return TronAddress{}, fmt.Errorf("%w: bad hex", ErrInvalidTronAddress)
+		}
+		return TronAddressFromBytes(b)
+	}
+
+	raw, err := base58Decode(s)
+	if err != nil {
+		return TronAddress{}, err
+	}
+	if len(raw) != 25 {
+		return TronAddress{}, fmt.Errorf("%w: decodes to %d bytes, want 25", ErrInvalidTronAddress, len(raw))
+	}
+
+	payload, checksum := raw[:21], raw[21:]
+	if !bytes.Equal(checksum, addressChecksum(payload)) {
+		return TronAddress{}, fmt.Errorf("%w: checksum mismatch", ErrInvalidTronAddress)
+	}
+	return TronAddressFromBytes(payload)
+}
+
+// TronAddressFromBytes accepts the 21-byte form or the bare 20-byte
+// account used inside ABI words and node logs.
+func TronAddressFromBytes(b []byte) (TronAddress, error) {
+	var a TronAddress
+	switch len(b) {
+	case 20:
+		a[0] = TronAddressPrefix
+		copy(a[1:], b)
+	case 21:
+		if b[0] != TronAddressPrefix {
+			return TronAddress{}, fmt.Errorf("%w: prefix 0x%02x, want 0x41", ErrInvalidTronAddress, b[0])
+		}
+		copy(a[:], b)
+	default:
+		return TronAddress{}, fmt.Errorf("%w: %d bytes", ErrInvalidTronAddress, len(b))
+	}
+	return a, nil
+}
+
+// String returns the base58check form.
+func (a TronAddress) String() string {
+	return base58Encode(append(a[:], addressChecksum(a[:])...))
+}
+
+// Hex returns the 41-prefixed hex form.
+func (a TronAddress) Hex() string {
+	return hex.EncodeToString(a[:])
+}
+
+// Account returns the 20 bytes without the 0x41 prefix.
+func (a TronAddress) Account() []byte {
+	return append([]byte(nil), a[1:]...)
+}
+
+// IsZero reports whether a is unset or the all-zero account, which
+// contracts return for "not set".
+func (a TronAddress) IsZero() bool {
+	for _, b := range a[1:] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (a TronAddress) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *TronAddress) UnmarshalText(b []byte) error {
+	v, err := ParseTronAddress(string(b))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value stores the address as base58check text.
+func (a TronAddress) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *TronAddress) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return a.UnmarshalText([]byte(v))
+	case []byte:
+		return a.UnmarshalText(v)
+	}
+	return fmt.Errorf("scan tron address: unsupported type %T", src)
+}
+
+func addressChecksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
+
+func base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+
+	for _, c := range s {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: invalid base58 character %q", ErrInvalidTronAddress, c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+
+	out := n.Bytes()
+	for _, c := range s {
+		if c != rune(base58Alphabet[0]) {
+			break
+		}
+		out = append([]byte{0}, out...)
+	}
+	return out, nil
+}
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// USDT's mainnet contract, in both forms.
+const (
+	usdtBase58 = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	usdtHex    = "41a614f803b6fd780986a42c78ec9c7f77e6ded13c"
+)
+
+// base58check encodes payload with a valid checksum, whatever its prefix.
+func base58check(payload []byte) string {
+	return base58Encode(append(append([]byte(nil), payload...), addressChecksum(payload)...))
+}
+
+func TestParseTronAddress(t *testing.T) {
+	usdt, _ := hex.DecodeString(usdtHex)
+	ethPrefixed := append([]byte{0xa0}, usdt[1:]...)
+
+	tests := []struct {
+		name string
+		in   string
+		want string // hex; empty when the input is invalid
+	}{
+		{"base58check", usdtBase58, usdtHex},
+		{"hex", usdtHex, usdtHex},
+		{"upper-case hex", "41A614F803B6FD780986A42C78EC9C7F77E6DED13C", usdtHex},
+		{"surrounding whitespace", "  " + usdtBase58 + "\n", usdtHex},
+		{"zero account", "T9yD14Nj9j7xAB4dbGeiX9h8unkKHxuWwb", "410000000000000000000000000000000000000000"},
+		{"empty", "", ""},
+		{"checksum mismatch", usdtBase58[:33] + "u", ""},
+		{"one character changed", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj7t", ""},
+		{"zero is not base58", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj60", ""},
+		{"capital O is not base58", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLjOt", ""},
+		{"too short", usdtBase58[:33], ""},
+		{"too long", usdtBase58 + "1", ""},
+		{"valid checksum, wrong prefix", base58check(ethPrefixed), ""},
+		{"hex with wrong prefix", "42" + usdtHex[2:], ""},
+		{"bad hex", "41" + "zz" + usdtHex[4:], ""},
+		{"bare 20-byte hex", usdtHex[2:], ""},
+		{"0x-prefixed hex", "0x" + usdtHex[2:], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := ParseTronAddress(tt.in)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidTronAddress) {
+					t.Fatalf("ParseTronAddress(%q) = %s, %v; want ErrInvalidTronAddress", tt.in, a.Hex(), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTronAddress(%q): %v", tt.in, err)
+			}
+			if a.Hex() != tt.want {
+				t.Fatalf("ParseTronAddress(%q) = %s, want %s", tt.in, a.Hex(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTronAddressString(t *testing.T) {
+	a, err := ParseTronAddress(usdtHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != usdtBase58 {
+		t.Fatalf("String = %s, want %s", a.String(), usdtBase58)
+	}
+	if a[0] != TronAddressPrefix {
+		t.Fatalf("prefix = 0x%02x", a[0])
+	}
+	if got := hex.EncodeToString(a.Account()); got != usdtHex[2:] {
+		t.Fatalf("Account = %s", got)
+	}
+}
+
+func TestTronAddressFromBytes(t *testing.T) {
+	usdt, _ := hex.DecodeString(usdtHex)
+
+	tests := []struct {
+		name string
+		in   []byte
+		ok   bool
+	}{
+		{"21 bytes", usdt, true},
+		{"20-byte account", usdt[1:], true},
+		{"21 bytes, wrong prefix", append([]byte{0x00}, usdt[1:]...), false},
+		{"19 bytes", usdt[2:], false},
+		{"22 bytes", append(append([]byte(nil), usdt...), 0), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := TronAddressFromBytes(tt.in)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidTronAddress) {
+					t.Fatalf("TronAddressFromBytes = %s, %v; want ErrInvalidTronAddress", a.Hex(), err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.String() != usdtBase58 {
+				t.Fatalf("TronAddressFromBytes = %s", a.String())
+			}
+		})
+	}
+}
+
+func TestTronAddressIsZero(t *testing.T) {
+	var unset TronAddress
+	zero, _ := TronAddressFromBytes(make([]byte, 20))
+	usdt, _ := ParseTronAddress(usdtBase58)
+
+	if !unset.IsZero() || !zero.IsZero() {
+		t.Fatal("unset or all-zero address not zero")
+	}
+	if usdt.IsZero() {
+		t.Fatal("USDT address reported zero")
+	}
+}
+
+func TestTronAddressScanValue(t *testing.T) {
+	tests := []struct {
+		name string
+		src  any
+		ok   bool
+	}{
+		{"base58 text", usdtBase58, true},
+		{"base58 bytes", []byte(usdtBase58), true},
+		{"legacy hex text", usdtHex, true},
+		{"bad checksum", usdtBase58[:33] + "u", false},
+		{"empty", "", false},
+		{"NULL", nil, false},
+		{"unsupported type", int64(41), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a TronAddress
+			err := a.Scan(tt.src)
+			if !tt.ok {
+				if err == nil {
+					t.Fatalf("Scan(%v) = %s, want an error", tt.src, a.Hex())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Stored back, it is always the canonical base58check text.
+			v, err := a.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != usdtBase58 {
+				t.Fatalf("Value = %v, want %s", v, usdtBase58)
+			}
+			var back TronAddress
+			if err := back.Scan(v); err != nil || back != a {
+				t.Fatalf("round trip = %s, %v", back.Hex(), err)
+			}
+		})
+	}
+}
+
+func TestTronAddressJSON(t *testing.T) {
+	in := struct {
+		Wallet TronAddress `json:"wallet"`
+	}{}
+	if err := json.Unmarshal([]byte(`{"wallet":"`+usdtHex+`"}`), &in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, []byte(`{"wallet":"`+usdtBase58+`"}`)) {
+		t.Fatalf("json = %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"wallet":"`+usdtBase58[:33]+`u"}`), &in); !errors.Is(err, ErrInvalidTronAddress) {
+		t.Fatalf("Unmarshal bad checksum = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event is implemented by every payload type. Type doubles as the
+// RabbitMQ routing key.
+type Event interface {
+	EventType() string
+	EventVersion() int
+}
+
+// Envelope is the wire format of every domain event, on the broker and in
+// the outbox table.
+type Envelope struct {
+	EventID       string          `json:"event_id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// New wraps payload in a fresh envelope. The correlation id is taken from
+// ctx (see WithCorrelationID); a new chain starts at the event itself.
+func New(ctx context.Context, payload Event) (Envelope, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("marshal %s payload: %w", payload.EventType(), err)
+	}
+
+	env := Envelope{
+		EventID:       NewEventID(),
+		Type:          payload.EventType(),
+		Version:       payload.EventVersion(),
+		OccurredAt:    time.Now().UTC(),
+		CorrelationID: CorrelationID(ctx),
+		Payload:       body,
+	}
+	if env.CorrelationID == "" {
+		env.CorrelationID = env.EventID
+	}
+	return env, nil
+}
+
+// NewEventID returns a random UUIDv4 string.
+func NewEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+type correlationKey struct{}
+
+// WithCorrelationID makes events created from ctx carry id, so the chain
+// request -> merchant.created -> merchant.onchain_registered shares one id.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNew(t *testing.T) {
+	payload := MerchantCreated{MerchantID: "0x01", WalletAddress: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Name: "Shop"}
+
+	env, err := New(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uuidV4.MatchString(env.EventID) {
+		t.Fatalf("event id %q is not a UUIDv4", env.EventID)
+	}
+	if env.Type != MerchantCreatedKey || env.Version != 1 {
+		t.Fatalf("envelope = %s v%d", env.Type, env.Version)
+	}
+	if env.CorrelationID != env.EventID {
+		t.Fatalf("a new chain starts at the event: correlation %q, event %q", env.CorrelationID, env.EventID)
+	}
+	if env.OccurredAt.Location() != time.UTC || time.Since(env.OccurredAt) > time.Minute {
+		t.Fatalf("occurred at %v", env.OccurredAt)
+	}
+	var got MerchantCreated
+	if err := json.Unmarshal(env.Payload, &got); err != nil || got != payload {
+		t.Fatalf("payload = %+v, %v", got, err)
+	}
+
+	// Events created while handling another carry its correlation id.
+	ctx := WithCorrelationID(context.Background(), env.CorrelationID)
+	next, err := New(ctx, MerchantOnchainRegistered{MerchantID: "0x01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.CorrelationID != env.EventID || next.EventID == env.EventID {
+		t.Fatalf("follow-up event: id %q, correlation %q", next.EventID, next.CorrelationID)
+	}
+
+	if WithCorrelationID(context.Background(), "") != context.Background() {
+		t.Fatal("an empty correlation id must leave ctx alone")
+	}
+}
+
+// v2 of MerchantCreated, as a later release would register it.
+type merchantCreatedV2 struct {
+	MerchantID string `json:"merchant_id"`
+	Country    string `json:"country"`
+}
+
+func (merchantCreatedV2) EventType() string { return MerchantCreatedKey }
+func (merchantCreatedV2) EventVersion() int { return 2 }
+
+func encode(t *testing.T, payload Event) []byte {
+	t.Helper()
+	env, err := New(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRegistryDecode(t *testing.T) {
+	r := NewRegistry()
+	Register[MerchantCreated](r)
+	Register[merchantCreatedV2](r)
+
+	env, payload, err := r.Decode(encode(t, MerchantCreated{MerchantID: "0x01", Name: "Shop"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1, ok := payload.(MerchantCreated); !ok || v1.Name != "Shop" || env.Version != 1 {
+		t.Fatalf("v1 decoded as %T %+v", payload, payload)
+	}
+
+	_, payload, err = r.Decode(encode(t, merchantCreatedV2{MerchantID: "0x01", Country: "DE"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2, ok := payload.(merchantCreatedV2); !ok || v2.Country != "DE" {
+		t.Fatalf("v2 decoded as %T %+v", payload, payload)
+	}
+}
+
+func TestRegistryDecodeRejects(t *testing.T) {
+	r := NewRegistry()
+	Register[MerchantCreated](r)
+
+	v3 := encode(t, MerchantCreated{MerchantID: "0x01"})
+	v3 = []byte(strings.Replace(string(v3), `"version":1`, `"version":3`, 1))
+
+	tests := []struct {
+		name string
+		body []byte
+		err  error  // errors.Is target, if any
+		msg  string // prefix of the error otherwise
+	}{
+		{name: "unknown type", body: encode(t, PaymentDetected{}), err: ErrUnknownType},
+		{name: "unknown version", body: v3, err: ErrUnsupportedVersion},
+		{name: "not json", body: []byte("merchant.created"), msg: "decode envelope"},
+		{name: "no event id", body: []byte(`{"type":"merchant.created","version":1,"payload":{}}`), msg: "decode envelope: missing event_id"},
+		{name: "no type", body: []byte(`{"event_id":"e1","version":1,"payload":{}}`), msg: "decode envelope: missing event_id"},
+		{name: "bad payload", body: []byte(`{"event_id":"e1","type":"merchant.created","version":1,"payload":{"merchant_id":7}}`), msg: "decode merchant.created v1 payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, payload, err := r.Decode(tt.body)
+			if err == nil || payload != nil {
+				t.Fatalf("Decode = %v, %v", payload, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.msg != "" && !strings.HasPrefix(err.Error(), tt.msg) {
+				t.Fatalf("err = %v, want %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+// Every event in the feeds must be one the service can decode.
+func TestFeedsAreRegistered(t *testing.T) {
+	known := map[string]bool{}
+	for _, typ := range Default.Types() {
+		known[typ] = true
+	}
+	for _, typ := range append(append([]string(nil), Notifications...), Alerts...) {
+		if !known[typ] {
+			t.Errorf("%s is fed but not registered", typ)
+		}
+	}
+}
@@ -2,6 +2,13 @@ package events
 
 import "time"
 
+const (
+	MerchantCreatedKey           = "merchant.created"
+	MerchantOnboardRequestedKey  = "merchant.onboard_requested"
+	MerchantOnchainRegisteredKey = "merchant.onchain_registered"
+)
+
+// MerchantCreated is emitted when a merchant registers (status PENDING).
 type MerchantCreated struct {
 	MerchantID    string    `json:"merchant_id"`    // 0x... bytes32
 	WalletAddress string    `json:"wallet_address"` // Tron base58
@@ -9,3 +16,123 @@ type MerchantCreated struct {
 	Email         string    `json:"email"`
 	CreatedAt     time.Time `json:"created_at"`
 }
+
+func (MerchantCreated) EventType() string { return MerchantCreatedKey }
+func (MerchantCreated) EventVersion() int { return 1 }
+
+// MerchantOnboardRequested is emitted when an admin retries an onboarding
+// the worker gave up on. The worker onboards the merchant as it would
+// after MerchantCreated.
+type MerchantOnboardRequested struct {
+	MerchantID  string    `json:"merchant_id"` // 0x... bytes32
+	RequestedBy string    `json:"requested_by"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+func (MerchantOnboardRequested) EventType() string { return MerchantOnboardRequestedKey }
+func (MerchantOnboardRequested) EventVersion() int { return 1 }
+
+// MerchantOnchainRegistered is emitted once onboardMerchant is confirmed
+// and the merchant is ACTIVE.
+type MerchantOnchainRegistered struct {
+	MerchantID    string    `json:"merchant_id"`    // 0x... bytes32
+	WalletAddress string    `json:"wallet_address"` // Tron base58
+	TxID          string    `json:"txid,omitempty"` // empty if found already onboarded on chain
+	RegisteredAt  time.Time `json:"registered_at"`
+}
+
+func (MerchantOnchainRegistered) EventType() string { return MerchantOnchainRegisteredKey }
+func (MerchantOnchainRegistered) EventVersion() int { return 1 }
+
+const (
+	MerchantStatusChangeRequestedKey = "merchant.status_change_requested"
+	MerchantStatusUpdatedKey         = "merchant.status_updated"
+)
+
+// MerchantStatusChangeRequested is emitted when an admin asks for a
+// merchant to be activated or deactivated. The worker applies it on chain.
+type MerchantStatusChangeRequested struct {
+	MerchantID  string    `json:"merchant_id"` // 0x... bytes32
+	Status      string    `json:"status"`      // ACTIVE / INACTIVE
+	RequestedBy string    `json:"requested_by"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+func (MerchantStatusChangeRequested) EventType() string { return MerchantStatusChangeRequestedKey }
+func (MerchantStatusChangeRequested) EventVersion() int { return 1 }
+
+// MerchantStatusUpdated is emitted once MerchantRegistryV1 has confirmed
+// the change (its MerchantStatusUpdated event) and the DB follows it.
+type MerchantStatusUpdated struct {
+	MerchantID string    `json:"merchant_id"`    // 0x... bytes32
+	Status     string    `json:"status"`         // ACTIVE / INACTIVE
+	TxID       string    `json:"txid,omitempty"` // empty if the chain already matched
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (MerchantStatusUpdated) EventType() string { return MerchantStatusUpdatedKey }
+func (MerchantStatusUpdated) EventVersion() int { return 1 }
+
+const (
+	MerchantReceiverChangeRequestedKey = "merchant.receiver_change_requested"
+	MerchantReceiverUpdatedKey         = "merchant.receiver_updated"
+)
+
+// MerchantReceiverChangeRequested is emitted when a merchant asks to move
+// its fund receiver to a new wallet. The worker applies it on chain.
+type MerchantReceiverChangeRequested struct {
+	MerchantID  string    `json:"merchant_id"`  // 0x... bytes32
+	OldReceiver string    `json:"old_receiver"` // Tron base58
+	NewReceiver string    `json:"new_receiver"` // Tron base58
+	RequestedBy string    `json:"requested_by"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+func (MerchantReceiverChangeRequested) EventType() string { return MerchantReceiverChangeRequestedKey }
+func (MerchantReceiverChangeRequested) EventVersion() int { return 1 }
+
+// MerchantReceiverUpdated is emitted once MerchantRegistryV1 has confirmed
+// the rotation (its MerchantReceiverAddressUpdated event) and
+// wallet_address follows it.
+type MerchantReceiverUpdated struct {
+	MerchantID  string    `json:"merchant_id"`    // 0x... bytes32
+	ChangeID    int64     `json:"change_id"`      // merchant_receiver_changes.id
+	OldReceiver string    `json:"old_receiver"`   // Tron base58
+	NewReceiver string    `json:"new_receiver"`   // Tron base58
+	TxID        string    `json:"txid,omitempty"` // empty if the chain already matched
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (MerchantReceiverUpdated) EventType() string { return MerchantReceiverUpdatedKey }
+func (MerchantReceiverUpdated) EventVersion() int { return 1 }
+
+const (
+	MerchantTokenChangeRequestedKey = "merchant.token_change_requested"
+	MerchantTokenUpdatedKey         = "merchant.token_updated"
+)
+
+// MerchantTokenChangeRequested is emitted when a merchant asks to start or
+// stop accepting a token. The worker applies it on chain.
+type MerchantTokenChangeRequested struct {
+	MerchantID   string    `json:"merchant_id"`   // 0x... bytes32
+	TokenAddress string    `json:"token_address"` // Tron base58
+	Enabled      bool      `json:"enabled"`
+	RequestedBy  string    `json:"requested_by"`
+	RequestedAt  time.Time `json:"requested_at"`
+}
+
+func (MerchantTokenChangeRequested) EventType() string { return MerchantTokenChangeRequestedKey }
+func (MerchantTokenChangeRequested) EventVersion() int { return 1 }
+
+// MerchantTokenUpdated is emitted once MerchantRegistryV1 has confirmed the
+// change (its MerchantTokenUpdated event) and merchant_tokens follows it.
+type MerchantTokenUpdated struct {
+	MerchantID   string    `json:"merchant_id"`   // 0x... bytes32
+	TokenAddress string    `json:"token_address"` // Tron base58
+	Enabled      bool      `json:"enabled"`
+	TxID         string    `json:"txid,omitempty"` // empty if the chain already matched
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (MerchantTokenUpdated) EventType() string { return MerchantTokenUpdatedKey }
+func (MerchantTokenUpdated) EventVersion() int { return 1 }
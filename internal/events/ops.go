@@ -0,0 +1,25 @@
+package events
+
+import "time"
+
+const OperatorLowResourcesKey = "ops.operator_low_resources"
+
+// OperatorLowResources is an alert: a transaction from the operator account
+// did not fit its resource budget and was held back (or refused). Someone
+// needs to top up TRX or stake for energy/bandwidth.
+type OperatorLowResources struct {
+	Address           string    `json:"address"`  // operator, Tron base58
+	Function          string    `json:"function"` // contract method held back
+	BalanceSun        int64     `json:"balance_sun"`
+	Energy            int64     `json:"energy"`    // available
+	Bandwidth         int64     `json:"bandwidth"` // available
+	RequiredEnergy    int64     `json:"required_energy"`
+	RequiredBandwidth int64     `json:"required_bandwidth"`
+	BurnSun           int64     `json:"burn_sun"` // TRX the tx would burn
+	MinBalanceSun     int64     `json:"min_balance_sun"`
+	Refused           bool      `json:"refused"` // false = queued for retry
+	DetectedAt        time.Time `json:"detected_at"`
+}
+
+func (OperatorLowResources) EventType() string { return OperatorLowResourcesKey }
+func (OperatorLowResources) EventVersion() int { return 1 }
@@ -0,0 +1,59 @@
+package events
+
+import "time"
+
+const PaymentDetectedKey = "payment.detected"
+
+// PaymentDetected is emitted when the indexer sees a PaymentCoreV1
+// PaymentDetected log for a known order. The payment is not final yet.
+type PaymentDetected struct {
+	MerchantID   string    `json:"merchant_id"` // 0x... bytes32
+	OrderID      string    `json:"order_id"`    // 0x... bytes32
+	InvoiceID    string    `json:"invoice_id"`  // 0x... bytes32
+	TokenAddress string    `json:"token_address"`
+	Currency     string    `json:"currency"`
+	Amount       string    `json:"amount"`     // decimal
+	AmountRaw    string    `json:"amount_raw"` // uint256 base units
+	TxHash       string    `json:"tx_hash"`
+	LogIndex     int       `json:"log_index"` // a tx may pay several orders
+	BlockNumber  int64     `json:"block_number"`
+	PaidAt       time.Time `json:"paid_at"`
+}
+
+func (PaymentDetected) EventType() string { return PaymentDetectedKey }
+func (PaymentDetected) EventVersion() int { return 1 }
+
+const (
+	PaymentConfirmedKey = "payment.confirmed"
+	PaymentRevertedKey  = "payment.reverted"
+)
+
+// PaymentConfirmed is emitted once a detected payment's block is
+// solidified; the payment and its order are SUCCESS.
+type PaymentConfirmed struct {
+	MerchantID  string    `json:"merchant_id"` // 0x... bytes32
+	OrderID     string    `json:"order_id"`    // 0x... bytes32
+	InvoiceID   string    `json:"invoice_id"`  // 0x... bytes32
+	TxHash      string    `json:"tx_hash"`
+	LogIndex    int       `json:"log_index"`
+	BlockNumber int64     `json:"block_number"`
+	ConfirmedAt time.Time `json:"confirmed_at"`
+}
+
+func (PaymentConfirmed) EventType() string { return PaymentConfirmedKey }
+func (PaymentConfirmed) EventVersion() int { return 1 }
+
+// PaymentReverted is emitted when a detected payment's transaction is no
+// longer on the canonical chain (or failed there); the payment is FAILED.
+type PaymentReverted struct {
+	MerchantID  string `json:"merchant_id"` // 0x... bytes32
+	OrderID     string `json:"order_id"`    // 0x... bytes32
+	InvoiceID   string `json:"invoice_id"`  // 0x... bytes32
+	TxHash      string `json:"tx_hash"`
+	LogIndex    int    `json:"log_index"`
+	BlockNumber int64  `json:"block_number"` // block it was first seen in
+	Reason      string `json:"reason"`
+}
+
+func (PaymentReverted) EventType() string { return PaymentRevertedKey }
+func (PaymentReverted) EventVersion() int { return 1 }
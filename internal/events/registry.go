@@ -0,0 +1,114 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownType        = errors.New("unknown event type")
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+)
+
+// Registry maps (type, version) to a payload decoder.
+type Registry struct {
+	decoders map[string]map[int]func(json.RawMessage) (Event, error)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{decoders: map[string]map[int]func(json.RawMessage) (Event, error){}}
+}
+
+// Register adds payload type T under T's EventType/EventVersion.
+func Register[T Event](r *Registry) {
+	var zero T
+	t, v := zero.EventType(), zero.EventVersion()
+
+	if r.decoders[t] == nil {
+		r.decoders[t] = map[int]func(json.RawMessage) (Event, error){}
+	}
+	r.decoders[t][v] = func(raw json.RawMessage) (Event, error) {
+		var p T
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("decode %s v%d payload: %w", t, v, err)
+		}
+		return p, nil
+	}
+}
+
+// Decode parses an envelope and its payload. Unregistered types return
+// ErrUnknownType; registered types with an unknown version return
+// ErrUnsupportedVersion.
+func (r *Registry) Decode(body []byte) (Envelope, Event, error) {
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return Envelope{}, nil, fmt.Errorf("decode envelope: %w", err)
+	}
+	if env.EventID == "" || env.Type == "" {
+		return env, nil, fmt.Errorf("decode envelope: missing event_id or type")
+	}
+
+	versions, ok := r.decoders[env.Type]
+	if !ok {
+		return env, nil, fmt.Errorf("%w: %s", ErrUnknownType, env.Type)
+	}
+	decode, ok := versions[env.Version]
+	if !ok {
+		return env, nil, fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, env.Type, env.Version)
+	}
+
+	payload, err := decode(env.Payload)
+	if err != nil {
+		return env, nil, err
+	}
+	return env, payload, nil
+}
+
+// Default knows every event this service publishes.
+var Default = func() *Registry {
+	r := NewRegistry()
+	Register[MerchantCreated](r)
+	Register[MerchantOnboardRequested](r)
+	Register[MerchantOnchainRegistered](r)
+	Register[MerchantStatusChangeRequested](r)
+	Register[MerchantStatusUpdated](r)
+	Register[MerchantReceiverChangeRequested](r)
+	Register[MerchantReceiverUpdated](r)
+	Register[MerchantTokenChangeRequested](r)
+	Register[MerchantTokenUpdated](r)
+	Register[PaymentDetected](r)
+	Register[PaymentConfirmed](r)
+	Register[PaymentReverted](r)
+	Register[OperatorLowResources](r)
+	return r
+}()
+
+// Notifications are the events published for subscribers outside this
+// service: outcomes of merchant changes and payment progress. They are
+// routed to a feed queue (see rabbit.FeedTopology) so they are never
+// unroutable, whether or not the worker also consumes them.
+var Notifications = []string{
+	MerchantOnchainRegisteredKey,
+	MerchantStatusUpdatedKey,
+	MerchantReceiverUpdatedKey,
+	MerchantTokenUpdatedKey,
+	PaymentDetectedKey,
+	PaymentConfirmedKey,
+	PaymentRevertedKey,
+}
+
+// Alerts are the events that need an operator. They get a feed of their
+// own, so ops tooling can subscribe to them alone.
+var Alerts = []string{
+	OperatorLowResourcesKey,
+}
+
+// Types returns every registered event type.
+func (r *Registry) Types() []string {
+	out := make([]string, 0, len(r.decoders))
+	for t := range r.decoders {
+		out = append(out, t)
+	}
+	return out
+}
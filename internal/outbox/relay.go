@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/queue/rabbit"
+)
+
+type Store interface {
+	Dispatch(
+		ctx context.Context,
+		limit int,
+		publish func(ctx context.Context, m domain.OutboxMessage) error,
+		backoff func(attempts int) time.Duration,
+	) (int, error)
+}
+
+type Publisher interface {
+	PublishEvent(ctx context.Context, env events.Envelope) error
+}
+
+// Relay drains the outbox table to the broker. Delivery is at-least-once:
+// a crash between publish and marking the row, or a mark that fails,
+// re-sends the message once its claim lapses. A row is only marked
+// dispatched once the broker has confirmed it; nacked messages stay in the
+// table and are retried with backoff. Unroutable messages are retried
+// MaxUnroutable times, then parked with bad payloads.
+type Relay struct {
+	store Store
+	pub   Publisher
+	log   *slog.Logger
+
+	Interval   time.Duration
+	BatchSize  int
+	MaxBackoff time.Duration
+
+	// Attempts after which a message no queue is bound for is parked.
+	MaxUnroutable int
+}
+
+func NewRelay(store Store, pub Publisher, log *slog.Logger) *Relay {
+	return &Relay{
+		store:         store,
+		pub:           pub,
+		log:           log,
+		Interval:      time.Second,
+		BatchSize:     100,
+		MaxBackoff:    5 * time.Minute,
+		MaxUnroutable: 5,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	r.log.Info("outbox_relay_started", "interval", r.Interval.String())
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back.
+		for {
+			n, err := r.store.Dispatch(ctx, r.BatchSize, r.publish, r.backoff)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.log.Error("outbox_dispatch_failed", "err", err)
+				}
+				break
+			}
+			if n < r.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			r.log.Info("outbox_relay_stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, m domain.OutboxMessage) error {
+	var env events.Envelope
+	if err := json.Unmarshal(m.Payload, &env); err != nil || env.EventID == "" {
+		// Not an envelope: retrying will not fix it, the row is parked for inspection.
+		r.log.Error("outbox_bad_payload", "id", m.ID, "routing_key", m.RoutingKey, "err", err)
+		return fmt.Errorf("%w: payload is not an event envelope", domain.ErrOutboxDead)
+	}
+
+	if err := r.pub.PublishEvent(ctx, env); err != nil {
+		attrs := []any{"id", m.ID, "routing_key", m.RoutingKey, "attempt", m.Attempts + 1, "err", err}
+		switch {
+		case rabbit.IsUnroutable(err) && m.Attempts+1 >= r.MaxUnroutable:
+			// Still no queue bound: nobody consumes this type, stop retrying.
+			r.log.Error("outbox_unroutable_parked", attrs...)
+			return fmt.Errorf("%w: %w", domain.ErrOutboxDead, err)
+		case rabbit.IsUnroutable(err):
+			// No queue bound for this key: a consumer may not be deployed yet.
+			r.log.Warn("outbox_unroutable", attrs...)
+		case errors.Is(err, rabbit.ErrNacked):
+			r.log.Warn("outbox_nacked", attrs...)
+		default:
+			r.log.Warn("outbox_publish_failed", attrs...)
+		}
+		return err
+	}
+	r.log.Debug("outbox_published", "id", m.ID, "routing_key", m.RoutingKey)
+	return nil
+}
+
+// backoff doubles from one second up to MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	return d
+}
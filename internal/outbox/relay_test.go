@@ -0,0 +1,271 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/queue/rabbit"
+)
+
+// memStore follows OutboxRepo.Dispatch: up to limit rows per call,
+// failures bump attempts, failures wrapping domain.ErrOutboxDead park the
+// row, parked rows are skipped.
+type memStore struct {
+	rows   []domain.OutboxMessage
+	parked map[int64]bool
+	sent   map[int64]bool
+	calls  int
+}
+
+func newMemStore(rows ...domain.OutboxMessage) *memStore {
+	return &memStore{rows: rows, parked: map[int64]bool{}, sent: map[int64]bool{}}
+}
+
+func (s *memStore) Dispatch(ctx context.Context, limit int, publish func(context.Context, domain.OutboxMessage) error, _ func(int) time.Duration) (int, error) {
+	s.calls++
+	n := 0
+	for i := range s.rows {
+		m := &s.rows[i]
+		if s.parked[m.ID] || s.sent[m.ID] {
+			continue
+		}
+		if n == limit {
+			break
+		}
+		n++
+		err := publish(ctx, *m)
+		m.Attempts++
+		switch {
+		case errors.Is(err, domain.ErrOutboxDead):
+			s.parked[m.ID] = true
+		case err == nil:
+			s.sent[m.ID] = true
+		}
+	}
+	return n, nil
+}
+
+type stubPublisher struct {
+	err   error
+	calls int
+	sent  []events.Envelope
+
+	// Called after each publish, e.g. to stop Run once all rows are out.
+	after func(calls int)
+}
+
+func (p *stubPublisher) PublishEvent(_ context.Context, env events.Envelope) error {
+	p.calls++
+	if p.err == nil {
+		p.sent = append(p.sent, env)
+	}
+	if p.after != nil {
+		p.after(p.calls)
+	}
+	return p.err
+}
+
+func newTestRelay(store Store, pub Publisher) *Relay {
+	return NewRelay(store, pub, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func envelopeMessage(t *testing.T, id int64) domain.OutboxMessage {
+	t.Helper()
+	env, err := events.New(context.Background(), events.PaymentDetected{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ID = id
+	return m
+}
+
+func TestRelayParksUnroutableAfterMaxAttempts(t *testing.T) {
+	store := newMemStore(envelopeMessage(t, 1))
+	pub := &stubPublisher{err: &rabbit.UnroutableError{Exchange: "token13.events", RoutingKey: "payment.detected", ReplyCode: 312, ReplyText: "NO_ROUTE"}}
+	r := newTestRelay(store, pub)
+
+	for i := 0; i < 2*r.MaxUnroutable; i++ {
+		if _, err := store.Dispatch(context.Background(), r.BatchSize, r.publish, r.backoff); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !store.parked[1] {
+		t.Fatalf("row not parked after %d attempts", store.rows[0].Attempts)
+	}
+	if pub.calls != r.MaxUnroutable {
+		t.Fatalf("published %d times, want %d", pub.calls, r.MaxUnroutable)
+	}
+}
+
+func TestRelayPublishClassifiesFailures(t *testing.T) {
+	unroutable := &rabbit.UnroutableError{RoutingKey: "payment.detected", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+
+	tests := []struct {
+		name     string
+		msg      func(t *testing.T) domain.OutboxMessage
+		err      error
+		wantDead bool
+	}{
+		{
+			name: "unroutable below limit retries",
+			msg:  func(t *testing.T) domain.OutboxMessage { return envelopeMessage(t, 1) },
+			err:  unroutable,
+		},
+		{
+			name: "unroutable at limit parks",
+			msg: func(t *testing.T) domain.OutboxMessage {
+				m := envelopeMessage(t, 1)
+				m.Attempts = 4
+				return m
+			},
+			err:      unroutable,
+			wantDead: true,
+		},
+		{
+			name: "nack retries forever",
+			msg: func(t *testing.T) domain.OutboxMessage {
+				m := envelopeMessage(t, 1)
+				m.Attempts = 100
+				return m
+			},
+			err: rabbit.ErrNacked,
+		},
+		{
+			name: "bad payload parks",
+			msg: func(t *testing.T) domain.OutboxMessage {
+				return domain.OutboxMessage{ID: 1, RoutingKey: "x", Payload: []byte(`{}`)}
+			},
+			wantDead: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRelay(nil, &stubPublisher{err: tt.err})
+			r.MaxUnroutable = 5
+			err := r.publish(context.Background(), tt.msg(t))
+			if err == nil {
+				t.Fatal("publish succeeded")
+			}
+			if dead := errors.Is(err, domain.ErrOutboxDead); dead != tt.wantDead {
+				t.Fatalf("dead = %v, want %v (err: %v)", dead, tt.wantDead, err)
+			}
+		})
+	}
+}
+
+// runRelay runs r until it returns, failing the test if it does not
+// within a few seconds.
+func runRelay(t *testing.T, ctx context.Context, r *Relay) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("relay did not stop")
+	}
+}
+
+// Run keeps dispatching while full batches come back and publishes each
+// stored envelope as it is, in order.
+func TestRelayRunDrainsFullBatches(t *testing.T) {
+	var rows []domain.OutboxMessage
+	for id := int64(1); id <= 5; id++ {
+		rows = append(rows, envelopeMessage(t, id))
+	}
+	store := newMemStore(rows...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pub := &stubPublisher{after: func(calls int) {
+		if calls == len(rows) {
+			cancel()
+		}
+	}}
+	r := newTestRelay(store, pub)
+	r.BatchSize, r.Interval = 2, time.Hour
+
+	runRelay(t, ctx, r)
+
+	if store.calls != 3 {
+		t.Fatalf("dispatched %d batches, want 3", store.calls)
+	}
+	if len(pub.sent) != len(rows) {
+		t.Fatalf("published %d messages, want %d", len(pub.sent), len(rows))
+	}
+	for i, env := range pub.sent {
+		var want events.Envelope
+		if err := json.Unmarshal(rows[i].Payload, &want); err != nil {
+			t.Fatal(err)
+		}
+		if env.EventID != want.EventID || env.Type != events.PaymentDetectedKey || string(env.Payload) != string(want.Payload) {
+			t.Fatalf("message %d = %+v, want %+v", i, env, want)
+		}
+		if !store.sent[rows[i].ID] {
+			t.Fatalf("row %d not marked dispatched", rows[i].ID)
+		}
+	}
+}
+
+// failingStore stands in for an outbox table that cannot be read.
+type failingStore struct {
+	calls int
+	stop  func()
+}
+
+func (s *failingStore) Dispatch(context.Context, int, func(context.Context, domain.OutboxMessage) error, func(int) time.Duration) (int, error) {
+	s.calls++
+	if s.calls == 3 {
+		s.stop()
+	}
+	return 0, errors.New("connection refused")
+}
+
+// A failed poll is logged and retried on the next tick.
+func TestRelayRunSurvivesDispatchErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &failingStore{stop: cancel}
+	r := newTestRelay(store, &stubPublisher{})
+	r.Interval = time.Millisecond
+
+	runRelay(t, ctx, r)
+
+	if store.calls != 3 {
+		t.Fatalf("dispatched %d times, want 3", store.calls)
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	r := newTestRelay(nil, nil)
+	r.MaxBackoff = time.Minute
+
+	for attempts, want := range map[int]time.Duration{
+		0:  time.Second,
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		6:  32 * time.Second,
+		7:  time.Minute,
+		50: time.Minute,
+	} {
+		if got := r.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type APIKeyRepo interface {
+	// Create stores k and sets its ID and CreatedAt.
+	Create(ctx context.Context, k *domain.APIKey) error
+
+	// GetByPrefix returns the key with prefix, revoked or not, or (nil, nil).
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+
+	// List returns the merchant's keys, newest first.
+	List(ctx context.Context, merchantID []byte) ([]domain.APIKey, error)
+
+	// Revoke revokes key id of the merchant. It reports false when the
+	// merchant has no such live key.
+	Revoke(ctx context.Context, merchantID []byte, id int64, at time.Time) (bool, error)
+
+	// Touch records that key id was used at at.
+	Touch(ctx context.Context, id int64, at time.Time) error
+}
@@ -1 +1,102 @@
 package ports
+
+import (
+	"context"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type MerchantRepo interface {
+	// GetByID returns (nil, nil) when the merchant does not exist.
+	GetByID(ctx context.Context, merchantID []byte) (*domain.Merchant, error)
+
+	// SetChainTxID records (or clears, with "") the onboarding txid before
+	// the transaction is confirmed, so a restart does not rebroadcast it.
+	SetChainTxID(ctx context.Context, merchantID []byte, txid string) error
+
+	// MarkChainRegistered moves a PENDING merchant to ACTIVE, stamps
+	// chain_registered_at and enqueues outbox in the same transaction.
+	// Already-registered merchants are left unchanged.
+	MarkChainRegistered(ctx context.Context, merchantID []byte, txid string, at time.Time, outbox ...domain.OutboxMessage) error
+
+	// FailOnboarding records why onboarding was given up and clears its
+	// txid, committed even if the caller's transaction rolls back.
+	FailOnboarding(ctx context.Context, merchantID []byte, reason string) error
+
+	// RetryOnboarding clears a failed onboarding and enqueues outbox with
+	// it. It reports false, writing nothing, unless onboarding had failed.
+	RetryOnboarding(ctx context.Context, merchantID []byte, outbox ...domain.OutboxMessage) (bool, error)
+
+	// RequestStatus records a status change for an on-chain merchant with
+	// none pending and enqueues outbox with it. It reports false, writing
+	// nothing, if another change got there first.
+	RequestStatus(ctx context.Context, merchantID []byte, status domain.MerchantStatus, at time.Time, outbox ...domain.OutboxMessage) (bool, error)
+
+	// SetStatusTxID records (or clears) the updateMerchantStatus txid,
+	// committed even if the caller's transaction rolls back.
+	SetStatusTxID(ctx context.Context, merchantID []byte, txid string) error
+
+	// ConfirmStatus applies the pending change as status and clears it,
+	// enqueueing outbox in the same transaction. No-op without a pending
+	// change.
+	ConfirmStatus(ctx context.Context, merchantID []byte, status domain.MerchantStatus, outbox ...domain.OutboxMessage) error
+
+	// FailStatus clears the pending change, recording why it failed, so a
+	// new one can be requested. Committed even if the caller's transaction
+	// rolls back; no-op without a pending change.
+	FailStatus(ctx context.Context, merchantID []byte, reason string) error
+
+	// LatestReceiverChange returns the merchant's most recent receiver
+	// rotation (the pending one, if any), or (nil, nil) if there is none.
+	LatestReceiverChange(ctx context.Context, merchantID []byte) (*domain.ReceiverChange, error)
+
+	// ListReceiverChanges returns the merchant's rotations, newest first.
+	ListReceiverChanges(ctx context.Context, merchantID []byte) ([]domain.ReceiverChange, error)
+
+	// RequestReceiverChange inserts a PENDING rotation (setting ch.ID) and
+	// enqueues outbox with it. It reports false, writing nothing, if one
+	// is already pending, and fails with domain.ErrWalletInUse if the new
+	// receiver belongs to another merchant.
+	RequestReceiverChange(ctx context.Context, ch *domain.ReceiverChange, outbox ...domain.OutboxMessage) (bool, error)
+
+	// SetReceiverTxID records (or clears) the updateMerchantReceiverAddress
+	// txid, committed even if the caller's transaction rolls back.
+	SetReceiverTxID(ctx context.Context, changeID int64, txid string) error
+
+	// ConfirmReceiverChange marks the rotation CONFIRMED, moves the
+	// merchant's wallet_address to its new receiver and enqueues outbox in
+	// the same transaction. No-op unless the rotation is pending.
+	ConfirmReceiverChange(ctx context.Context, changeID int64, at time.Time, outbox ...domain.OutboxMessage) error
+
+	// FailReceiverChange marks a pending rotation FAILED with reason and
+	// clears its txid, committed even if the caller's transaction rolls
+	// back. wallet_address is left as it was.
+	FailReceiverChange(ctx context.Context, changeID int64, reason string) error
+
+	// GetToken returns the merchant's state for token, or (nil, nil) if it
+	// was never requested.
+	GetToken(ctx context.Context, merchantID []byte, token domain.TronAddress) (*domain.MerchantToken, error)
+
+	// ListTokens returns every token the merchant has requested.
+	ListTokens(ctx context.Context, merchantID []byte) ([]domain.MerchantToken, error)
+
+	// RequestToken records an enable/disable of token with none pending
+	// and enqueues outbox with it. It reports false, writing nothing, if
+	// another change got there first.
+	RequestToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool, requestedBy string, at time.Time, outbox ...domain.OutboxMessage) (bool, error)
+
+	// SetTokenTxID records (or clears) the updateMerchantTokenStatus txid,
+	// committed even if the caller's transaction rolls back.
+	SetTokenTxID(ctx context.Context, merchantID []byte, token domain.TronAddress, txid string) error
+
+	// ConfirmToken applies the pending change as enabled and clears it,
+	// enqueueing outbox in the same transaction. No-op without a pending
+	// change.
+	ConfirmToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool, outbox ...domain.OutboxMessage) error
+
+	// FailToken clears the pending change of token, recording why it
+	// failed, so a new one can be requested. Committed even if the
+	// caller's transaction rolls back; no-op without a pending change.
+	FailToken(ctx context.Context, merchantID []byte, token domain.TronAddress, reason string) error
+}
@@ -1 +1,27 @@
 package ports
+
+import (
+	"context"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type OrderRepo interface {
+	// Create inserts o. It returns domain.ErrExternalRefExists when the
+	// merchant already has an order with o.ExternalRef.
+	Create(ctx context.Context, o *domain.Order) error
+
+	// GetByOrderID returns (nil, nil) when the merchant has no such order.
+	GetByOrderID(ctx context.Context, merchantID, orderID []byte) (*domain.Order, error)
+
+	// ListByMerchant returns the merchant's orders, newest first.
+	ListByMerchant(ctx context.Context, merchantID []byte, limit, offset int) ([]domain.Order, error)
+
+	// GetForUpdate loads any merchant's order and locks it for the rest of
+	// the ambient transaction. It returns (nil, nil) when not found.
+	GetForUpdate(ctx context.Context, orderID []byte) (*domain.Order, error)
+
+	// SetSettlement stores the matching outcome and the resulting
+	// payment_status.
+	SetSettlement(ctx context.Context, orderID []byte, amountPaid string, settlement domain.Settlement, status domain.PaymentStatus) error
+}
@@ -1 +1,69 @@
 package ports
+
+import (
+	"context"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type PaymentRepo interface {
+	// InsertDetected stores p unless a payment for the same log (p.TxHash,
+	// p.LogIndex) already exists, and enqueues outbox in the same
+	// transaction only if it inserted. It returns domain.ErrUnknownOrder
+	// when p's order does not exist for p's merchant and invoice.
+	InsertDetected(ctx context.Context, p *domain.Payment, outbox ...domain.OutboxMessage) (inserted bool, err error)
+
+	// InsertUnmatched keeps p, whose order InsertDetected did not know,
+	// for manual review with reason. Like InsertDetected it stores each
+	// log once.
+	InsertUnmatched(ctx context.Context, p *domain.Payment, reason string) (inserted bool, err error)
+
+	// ListPending returns PENDING payments seen at or below maxBlock,
+	// oldest block first, skipping those held for review.
+	ListPending(ctx context.Context, maxBlock int64, limit int) ([]domain.Payment, error)
+
+	// HoldForReview takes a PENDING payment out of ListPending until an
+	// operator clears reason.
+	HoldForReview(ctx context.Context, paymentUID string, reason string) error
+
+	// RecordMiss counts a poll in which a PENDING payment's tx was not on
+	// chain and returns the misses in a row so far.
+	RecordMiss(ctx context.Context, paymentUID string) (int, error)
+
+	// ClearMisses resets the count once the tx is found again.
+	ClearMisses(ctx context.Context, paymentUID string) error
+
+	// SetBlockNumber moves a PENDING payment whose tx was re-included in
+	// another block.
+	SetBlockNumber(ctx context.Context, paymentUID string, block int64) error
+
+	// MarkConfirmed moves a PENDING payment to SUCCESS and enqueues outbox
+	// in the same transaction. It returns false if the payment was not
+	// PENDING. The order is settled separately (see PaymentService.Settle).
+	MarkConfirmed(ctx context.Context, paymentUID string, block int64, at time.Time, outbox ...domain.OutboxMessage) (bool, error)
+
+	// MarkReverted moves a PENDING payment to FAILED and enqueues outbox.
+	// Its order becomes FAILED unless another payment for it is still
+	// PENDING or SUCCESS. It returns false if the payment was not PENDING.
+	MarkReverted(ctx context.Context, paymentUID string, outbox ...domain.OutboxMessage) (bool, error)
+
+	// ListByOrder returns every payment for the order, oldest block first.
+	ListByOrder(ctx context.Context, orderID []byte) ([]domain.Payment, error)
+
+	// SetMatch records how a confirmed payment matched its order.
+	SetMatch(ctx context.Context, paymentUID string, match domain.PaymentMatch) error
+}
+
+// ChainCursorRepo persists how far a chain follower has progressed.
+type ChainCursorRepo interface {
+	// Get returns ok=false when name has no cursor yet.
+	Get(ctx context.Context, name string) (block int64, ok bool, err error)
+	Set(ctx context.Context, name string, block int64) error
+}
+
+// Transactor runs fn in one database transaction. Repositories called
+// with fn's ctx join it.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
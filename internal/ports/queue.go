@@ -1 +1,14 @@
 package ports
+
+import (
+	"context"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// Outbox enqueues events that are not tied to a business row change.
+type Outbox interface {
+	// Enqueue commits msgs in their own transaction, ignoring any ambient
+	// one, so alerts survive the caller rolling back.
+	Enqueue(ctx context.Context, msgs ...domain.OutboxMessage) error
+}
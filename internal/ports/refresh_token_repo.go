@@ -0,0 +1,28 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type RefreshTokenRepo interface {
+	// Create stores t. An empty FamilyID starts a new family, whose id is
+	// set on t.
+	Create(ctx context.Context, t *domain.RefreshToken) error
+
+	// GetByHash returns the token stored under hash, or (nil, nil).
+	GetByHash(ctx context.Context, hash []byte) (*domain.RefreshToken, error)
+
+	// Rotate marks token id rotated and stores next in one transaction. It
+	// reports false, storing nothing, when id was already rotated or
+	// revoked.
+	Rotate(ctx context.Context, id int64, next *domain.RefreshToken, at time.Time) (bool, error)
+
+	// RevokeFamily revokes every live token of the family.
+	RevokeFamily(ctx context.Context, familyID string, reason domain.RevokeReason, at time.Time) error
+
+	// RevokeUser revokes every live token of the user.
+	RevokeUser(ctx context.Context, userUID string, reason domain.RevokeReason, at time.Time) error
+}
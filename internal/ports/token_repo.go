@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"context"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type TokenRepo interface {
+	// ListAllowed returns the allow-listed tokens, by symbol.
+	ListAllowed(ctx context.Context) ([]domain.Token, error)
+
+	// Get returns the cached metadata of any token, allowed or not, or
+	// (nil, nil) if it was never loaded.
+	Get(ctx context.Context, address string) (*domain.Token, error)
+
+	// Save caches t's metadata without changing whether it is allowed.
+	Save(ctx context.Context, t domain.Token) error
+
+	// SetAllowed allow-lists exactly addresses, in one transaction.
+	SetAllowed(ctx context.Context, addresses []string) error
+}
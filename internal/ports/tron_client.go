@@ -1 +1,45 @@
 package ports
+
+import (
+	"context"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// TronClient is the subset of the chain client the services depend on.
+type TronClient interface {
+	RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress domain.TronAddress) (txid string, err error)
+	IsMerchantOnboarded(ctx context.Context, merchantID []byte) (bool, error)
+	TransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error)
+
+	UpdateMerchantStatus(ctx context.Context, merchantID []byte, active bool) (txid string, err error)
+	IsMerchantActive(ctx context.Context, merchantID []byte) (bool, error)
+
+	// MerchantStatusUpdated reads the MerchantStatusUpdated event txid
+	// emitted for merchantID; found is false if it emitted none.
+	MerchantStatusUpdated(ctx context.Context, txid string, merchantID []byte) (active, found bool, err error)
+
+	UpdateMerchantReceiver(ctx context.Context, merchantID []byte, receiver domain.TronAddress) (txid string, err error)
+	MerchantFundReceiver(ctx context.Context, merchantID []byte) (domain.TronAddress, error)
+
+	// MerchantReceiverUpdated reads the MerchantReceiverAddressUpdated event
+	// txid emitted for merchantID and returns its new receiver.
+	MerchantReceiverUpdated(ctx context.Context, txid string, merchantID []byte) (receiver domain.TronAddress, found bool, err error)
+
+	UpdateMerchantToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool) (txid string, err error)
+	IsMerchantTokenSupported(ctx context.Context, merchantID []byte, token domain.TronAddress) (bool, error)
+
+	// MerchantTokenUpdated reads the MerchantTokenUpdated event txid
+	// emitted for merchantID and returns the token and its new status.
+	MerchantTokenUpdated(ctx context.Context, txid string, merchantID []byte) (token domain.TronAddress, enabled, found bool, err error)
+}
+
+// OperatorChain reports what the operator account can still spend.
+type OperatorChain interface {
+	OperatorResources(ctx context.Context) (domain.AccountResources, error)
+}
+
+// TokenChain reads TRC-20 metadata (decimals, symbol, name) from chain.
+type TokenChain interface {
+	TokenMetadata(ctx context.Context, token domain.TronAddress) (domain.Token, error)
+}
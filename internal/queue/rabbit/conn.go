@@ -1,15 +1,206 @@
 package rabbit
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func Connect(url string) (*amqp.Connection, error) {
+// ErrNotConnected is returned while the broker connection is being re-established.
+var ErrNotConnected = errors.New("rabbit: not connected")
+
+// ErrClosed is returned after Close.
+var ErrClosed = errors.New("rabbit: connection closed")
+
+// Connection wraps an AMQP connection and transparently re-dials it when
+// the broker goes away. Components that hold channels register a hook with
+// OnReconnect to re-open them (and redeclare their topology) on the new
+// connection.
+type Connection struct {
+	url string
+	log *slog.Logger
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu     sync.RWMutex
+	conn   *amqp.Connection
+	ready  chan struct{} // closed while connected
+	hooks  []func(*amqp.Connection) error
+	closed bool
+	done   chan struct{}
+}
+
+// Connect dials once (so startup fails fast on bad config) and then keeps
+// the connection alive in the background.
+func Connect(url string, log *slog.Logger) (*Connection, error) {
 	conn, err := amqp.Dial(url)
 	if err != nil {
 		return nil, fmt.Errorf("rabbit dial: %w", err)
 	}
-	return conn, nil
+
+	c := &Connection{
+		url:        url,
+		log:        log,
+		MinBackoff: time.Second,
+		MaxBackoff: 30 * time.Second,
+		conn:       conn,
+		ready:      make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	close(c.ready)
+
+	go c.watch(conn)
+	return c, nil
+}
+
+// Channel opens a channel on the current connection.
+func (c *Connection) Channel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+	return c.conn.Channel()
+}
+
+// OnReconnect registers fn to run after every successful re-dial. Hooks run
+// in registration order; if one fails the connection is dropped and dialled
+// again.
+func (c *Connection) OnReconnect(fn func(*amqp.Connection) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = append(c.hooks, fn)
+}
+
+// Ready blocks until the connection is up or ctx is done.
+func (c *Connection) Ready(ctx context.Context) error {
+	c.mu.RLock()
+	ready, closed := c.ready, c.closed
+	c.mu.RUnlock()
+
+	if closed {
+		return ErrClosed
+	}
+	select {
+	case <-ready:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	close(c.done)
+	c.mu.Unlock()
+
+	if conn != nil && !conn.IsClosed() {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (c *Connection) watch(conn *amqp.Connection) {
+	for {
+		notify := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-c.done:
+			return
+		case amqpErr := <-notify:
+			if c.isClosed() {
+				return
+			}
+			c.log.Warn("rabbit_connection_lost", "err", amqpErr)
+		}
+
+		c.mu.Lock()
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+
+		next, ok := c.redial()
+		if !ok {
+			return
+		}
+		conn = next
+	}
+}
+
+// redial loops with exponential backoff until a connection is up and every
+// hook succeeded. Returns false if Close was called meanwhile.
+func (c *Connection) redial() (*amqp.Connection, bool) {
+	backoff := c.MinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.done:
+			return nil, false
+		case <-time.After(backoff):
+		}
+
+		conn, err := amqp.Dial(c.url)
+		if err == nil {
+			err = c.runHooks(conn)
+			if err != nil {
+				_ = conn.Close()
+			}
+		}
+		if err != nil {
+			c.log.Warn("rabbit_reconnect_failed", "attempt", attempt, "retry_in", backoff.String(), "err", err)
+			backoff *= 2
+			if backoff > c.MaxBackoff {
+				backoff = c.MaxBackoff
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return nil, false
+		}
+		c.conn = conn
+		close(c.ready)
+		c.mu.Unlock()
+
+		c.log.Info("rabbit_reconnected", "attempt", attempt)
+		return conn, true
+	}
+}
+
+func (c *Connection) runHooks(conn *amqp.Connection) error {
+	c.mu.RLock()
+	hooks := append([]func(*amqp.Connection) error(nil), c.hooks...)
+	c.mu.RUnlock()
+
+	for _, fn := range hooks {
+		if err := fn(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Connection) isClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closed
 }
@@ -1 +1,295 @@
 package rabbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"token13/merchant-backend-go/internal/events"
+)
+
+// Ledger deduplicates deliveries. Process must record (eventID, consumer)
+// as done only together with fn's writes, and report duplicate=true,
+// without calling fn, if that pair was already done. A transient error
+// (another delivery still working on the event) is retried like any other.
+type Ledger interface {
+	Process(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (duplicate bool, err error)
+}
+
+// Consumers runs a set of Jobs against one exchange. For every job it
+// declares the queue topology, consumes with the job's prefetch and
+// concurrency, settles each delivery (ack / retry / DLQ) and re-subscribes
+// after a broker reconnect.
+type Consumers struct {
+	conn     *Connection
+	exchange string
+	registry *events.Registry
+	log      *slog.Logger
+
+	// ShutdownTimeout bounds how long Run waits for in-flight handlers after
+	// ctx is cancelled before cancelling their context as well.
+	ShutdownTimeout time.Duration
+
+	// Ledger, when set, makes every job skip events it already processed.
+	// The consumer name recorded is the job's queue.
+	Ledger Ledger
+
+	// GiveUpTimeout bounds an OnGiveUp callback.
+	GiveUpTimeout time.Duration
+
+	jobs []*Job
+}
+
+func NewConsumers(conn *Connection, exchange string, registry *events.Registry, log *slog.Logger) *Consumers {
+	return &Consumers{
+		conn:            conn,
+		exchange:        exchange,
+		registry:        registry,
+		log:             log,
+		ShutdownTimeout: 30 * time.Second,
+		GiveUpTimeout:   10 * time.Second,
+	}
+}
+
+func (c *Consumers) add(j *Job) {
+	c.jobs = append(c.jobs, j)
+}
+
+// RoutingKeys returns the routing keys the registered jobs consume.
+func (c *Consumers) RoutingKeys() []string {
+	var out []string
+	for _, j := range c.jobs {
+		out = append(out, j.Topology.RoutingKeys...)
+	}
+	return out
+}
+
+// Run consumes until ctx is cancelled, then stops taking new deliveries,
+// lets in-flight handlers finish and closes the channels before returning.
+func (c *Consumers) Run(ctx context.Context) error {
+	if len(c.jobs) == 0 {
+		return errors.New("rabbit: no consumers registered")
+	}
+
+	// Handlers outlive ctx so a SIGTERM does not abort half-done work.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	var wg sync.WaitGroup
+	for _, j := range c.jobs {
+		wg.Add(1)
+		go func(j *Job) {
+			defer wg.Done()
+			c.runJob(ctx, handlerCtx, j)
+		}(j)
+	}
+
+	<-ctx.Done()
+	c.log.Info("consumers_stopping", "timeout", c.ShutdownTimeout.String())
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(c.ShutdownTimeout):
+		c.log.Warn("consumers_shutdown_timeout")
+		cancelHandlers()
+		<-done
+	}
+
+	c.log.Info("consumers_stopped")
+	return nil
+}
+
+func (c *Consumers) runJob(ctx, handlerCtx context.Context, j *Job) {
+	c.log.Info("consumer_started", "queue", j.Topology.Queue, "concurrency", j.Concurrency, "prefetch", j.Prefetch)
+
+	for {
+		err := c.consume(ctx, handlerCtx, j)
+		if ctx.Err() != nil {
+			return
+		}
+		c.log.Warn("consumer_interrupted", "queue", j.Topology.Queue, "err", err)
+
+		if err := c.conn.Ready(ctx); err != nil {
+			return
+		}
+
+		// Don't spin if the connection is not yet known to be lost.
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// consume runs one subscription on a fresh channel until ctx is cancelled
+// or the channel closes.
+func (c *Consumers) consume(ctx, handlerCtx context.Context, j *Job) error {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := DeclareExchange(ch, c.exchange); err != nil {
+		return err
+	}
+	if err := DeclareQueue(ch, c.exchange, j.Topology); err != nil {
+		return err
+	}
+	if err := ch.Qos(j.Prefetch, 0, false); err != nil {
+		return err
+	}
+	// Retry copies are published on this channel; confirms let settle ack
+	// the original only once the broker has the copy.
+	if err := ch.Confirm(false); err != nil {
+		return err
+	}
+
+	tag := fmt.Sprintf("%s-%s", j.Topology.Queue, events.NewEventID()[:8])
+	deliveries, err := ch.Consume(j.Topology.Queue, tag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < j.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				if ctx.Err() != nil {
+					// Shutting down: hand prefetched messages back untouched.
+					_ = d.Nack(false, true)
+					continue
+				}
+				c.dispatch(handlerCtx, ch, j, d)
+			}
+		}()
+	}
+
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	select {
+	case <-ctx.Done():
+		// Stop new deliveries; the deliveries channel closes once the
+		// broker confirms, which lets the workers drain and exit.
+		_ = ch.Cancel(tag, false)
+		wg.Wait()
+		return nil
+	case amqpErr := <-closed:
+		wg.Wait()
+		if amqpErr != nil {
+			return amqpErr
+		}
+		return errors.New("rabbit channel closed")
+	}
+}
+
+// dispatch decodes, handles and settles one delivery.
+func (c *Consumers) dispatch(ctx context.Context, ch *amqp.Channel, j *Job, d amqp.Delivery) {
+	queue := j.Topology.Queue
+
+	env, payload, err := c.registry.Decode(d.Body)
+	if err != nil {
+		c.log.Error("message_parked", "queue", queue, "routing_key", d.RoutingKey, "err", err)
+		_ = Park(d)
+		return
+	}
+
+	retries := RetryCount(d, queue)
+	msg := Message{
+		Envelope:    env,
+		RoutingKey:  OriginalRoutingKey(d),
+		Attempt:     retries + 1,
+		LastAttempt: retries >= len(j.Topology.Retry.Delays),
+		Redelivered: d.Redelivered,
+	}
+	ctx = events.WithCorrelationID(ctx, env.CorrelationID)
+
+	if c.Ledger == nil {
+		c.settle(ctx, ch, j, d, msg, safeHandle(ctx, j, msg, payload))
+		return
+	}
+
+	duplicate, err := c.Ledger.Process(ctx, queue, env.EventID, func(ctx context.Context) error {
+		return safeHandle(ctx, j, msg, payload)
+	})
+	if duplicate {
+		c.log.Info("message_duplicate_skipped", "queue", queue, "event_id", env.EventID, "type", env.Type)
+	}
+	c.settle(ctx, ch, j, d, msg, err)
+}
+
+func safeHandle(ctx context.Context, j *Job, msg Message, payload events.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return j.handle(ctx, msg, payload)
+}
+
+// settle acks a handled message, parks poison messages in the DLQ and
+// schedules transient failures on the retry queues.
+func (c *Consumers) settle(ctx context.Context, ch *amqp.Channel, j *Job, d amqp.Delivery, msg Message, err error) {
+	queue := j.Topology.Queue
+	attrs := []any{"queue", queue, "event_id", msg.Envelope.EventID, "type", msg.Envelope.Type, "attempt", msg.Attempt}
+
+	if err == nil {
+		_ = d.Ack(false)
+		return
+	}
+
+	if IsPermanent(err) {
+		c.log.Error("message_parked", append(attrs, "err", err)...)
+		_ = Park(d)
+		c.giveUp(ctx, attrs, err)
+		return
+	}
+
+	if IsDelayed(err) {
+		if herr := Hold(ctx, ch, d, queue, j.Topology.Retry); herr != nil {
+			c.log.Error("message_hold_failed", append(attrs, "err", herr)...)
+			return
+		}
+		c.log.Warn("message_held", append(attrs, "err", err)...)
+		return
+	}
+
+	parked, rerr := Retry(ctx, ch, d, queue, j.Topology.Retry)
+	switch {
+	case rerr != nil:
+		c.log.Error("message_retry_failed", append(attrs, "err", rerr)...)
+	case parked:
+		c.log.Error("message_parked", append(attrs, "reason", "retries_exhausted", "err", err)...)
+		c.giveUp(ctx, attrs, err)
+	default:
+		c.log.Warn("message_retry_scheduled", append(attrs, "err", err)...)
+	}
+}
+
+// giveUp runs the OnGiveUp callback of a parked message's error. A
+// shutdown is not a failure: the message was only parked because the
+// handler was cut short.
+func (c *Consumers) giveUp(ctx context.Context, attrs []any, err error) {
+	var g *givenUpError
+	if !errors.As(err, &g) || ctx.Err() != nil {
+		return
+	}
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.GiveUpTimeout)
+	defer cancel()
+	if ferr := g.fail(fctx); ferr != nil {
+		c.log.Error("message_give_up_failed", append(attrs, "err", ferr)...)
+	}
+}
@@ -0,0 +1,335 @@
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"token13/merchant-backend-go/internal/events"
+)
+
+// lockingLedger holds a row lock for as long as fn runs, like the deferred
+// transaction of postgres.Ledger after a unit has updated the row.
+type lockingLedger struct {
+	row chan struct{}
+}
+
+func (l *lockingLedger) Process(ctx context.Context, _, _ string, fn func(ctx context.Context) error) (bool, error) {
+	l.row <- struct{}{}
+	defer func() { <-l.row }()
+	return false, fn(ctx)
+}
+
+// lock waits for the row like a statement outside the transaction would.
+func (l *lockingLedger) lock(ctx context.Context) error {
+	select {
+	case l.row <- struct{}{}:
+		<-l.row
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type ackRecorder struct {
+	acked, nacked, requeued bool
+}
+
+func (a *ackRecorder) Ack(uint64, bool) error { a.acked = true; return nil }
+func (a *ackRecorder) Nack(_ uint64, _ bool, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+func (a *ackRecorder) Reject(_ uint64, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+
+func newTestConsumers(ledger Ledger) *Consumers {
+	c := NewConsumers(nil, "token13.events", events.Default, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Ledger = ledger
+	c.GiveUpTimeout = 200 * time.Millisecond
+	return c
+}
+
+func testDelivery(t *testing.T, ack *ackRecorder) amqp.Delivery {
+	t.Helper()
+	env, err := events.New(context.Background(), events.MerchantCreated{MerchantID: "0x01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return amqp.Delivery{Acknowledger: ack, Body: body, RoutingKey: env.Type}
+}
+
+func TestGiveUpRunsOutsideLedgerTransaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		policy RetryPolicy
+	}{
+		{name: "permanent", err: Permanent(errors.New("merchant not found")), policy: DefaultRetryPolicy},
+		{name: "retries exhausted", err: errors.New("tx not confirmed"), policy: RetryPolicy{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &lockingLedger{row: make(chan struct{}, 1)}
+			c := newTestConsumers(ledger)
+
+			var failErr error
+			failed := false
+			Handle(c, JobOptions{Retry: &tt.policy}, func(ctx context.Context, _ Message, _ events.MerchantCreated) error {
+				return OnGiveUp(tt.err, func(ctx context.Context) error {
+					failed = true
+					failErr = ledger.lock(ctx)
+					return failErr
+				})
+			})
+
+			ack := &ackRecorder{}
+			c.dispatch(context.Background(), nil, c.jobs[0], testDelivery(t, ack))
+
+			if !failed {
+				t.Fatal("give-up callback not run")
+			}
+			if failErr != nil {
+				t.Fatalf("give-up callback waited on the handler's lock: %v", failErr)
+			}
+			if !ack.nacked || ack.requeued {
+				t.Fatalf("message not parked: %+v", ack)
+			}
+		})
+	}
+}
+
+func TestGiveUpSkippedOnShutdown(t *testing.T) {
+	c := newTestConsumers(&lockingLedger{row: make(chan struct{}, 1)})
+
+	failed := false
+	Handle(c, JobOptions{}, func(ctx context.Context, _ Message, _ events.MerchantCreated) error {
+		return OnGiveUp(Permanent(ctx.Err()), func(context.Context) error {
+			failed = true
+			return nil
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.dispatch(ctx, nil, c.jobs[0], testDelivery(t, &ackRecorder{}))
+
+	if failed {
+		t.Fatal("give-up callback run for a cancelled handler")
+	}
+}
+
+func TestHandleDefaults(t *testing.T) {
+	c := newTestConsumers(nil)
+	noop := func(context.Context, Message, events.MerchantCreated) error { return nil }
+
+	Handle(c, JobOptions{}, noop)
+	j := c.jobs[0]
+	if j.Topology.Queue != "merchant.created.q" || j.Concurrency != 1 || j.Prefetch != 1 {
+		t.Fatalf("defaults: queue %q, concurrency %d, prefetch %d", j.Topology.Queue, j.Concurrency, j.Prefetch)
+	}
+	if len(j.Topology.Retry.Delays) != len(DefaultRetryPolicy.Delays) {
+		t.Fatalf("retry = %v, want the default policy", j.Topology.Retry.Delays)
+	}
+
+	Handle(c, JobOptions{Queue: "merchant.onboard", Concurrency: 4, Retry: &RetryPolicy{}}, noop)
+	j = c.jobs[1]
+	if j.Topology.Queue != "merchant.onboard" || j.Concurrency != 4 || j.Prefetch != 4 || len(j.Topology.Retry.Delays) != 0 {
+		t.Fatalf("options: %+v, concurrency %d, prefetch %d", j.Topology, j.Concurrency, j.Prefetch)
+	}
+
+	keys := c.RoutingKeys()
+	if len(keys) != 2 || keys[0] != events.MerchantCreatedKey || keys[1] != events.MerchantCreatedKey {
+		t.Fatalf("routing keys = %v", keys)
+	}
+}
+
+func TestRunWithoutJobs(t *testing.T) {
+	if err := newTestConsumers(nil).Run(context.Background()); err == nil {
+		t.Fatal("Run started without consumers")
+	}
+}
+
+// Without retries left every outcome settles the delivery without
+// touching the channel: an ack, or a nack that parks it in the DLQ.
+func TestDispatchSettles(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(context.Context, Message, events.MerchantCreated) error
+		body   []byte
+		acked  bool
+	}{
+		{name: "handled", handle: func(context.Context, Message, events.MerchantCreated) error { return nil }, acked: true},
+		{name: "permanent error", handle: func(context.Context, Message, events.MerchantCreated) error {
+			return Permanent(errors.New("merchant not found"))
+		}},
+		{name: "error on the last attempt", handle: func(context.Context, Message, events.MerchantCreated) error {
+			return errors.New("node down")
+		}},
+		{name: "handler panic", handle: func(context.Context, Message, events.MerchantCreated) error {
+			panic("nil merchant")
+		}},
+		{name: "not an envelope", body: []byte(`{"merchant_id":"0x01"}`)},
+		{name: "unknown event type", body: []byte(`{"event_id":"e1","type":"merchant.deleted","version":1,"payload":{}}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConsumers(nil)
+			called := false
+			Handle(c, JobOptions{Retry: &RetryPolicy{}}, func(ctx context.Context, msg Message, p events.MerchantCreated) error {
+				called = true
+				if tt.handle == nil {
+					return nil
+				}
+				return tt.handle(ctx, msg, p)
+			})
+
+			ack := &ackRecorder{}
+			d := testDelivery(t, ack)
+			if tt.body != nil {
+				d.Body = tt.body
+			}
+			c.dispatch(context.Background(), nil, c.jobs[0], d)
+
+			if tt.body != nil && called {
+				t.Fatal("handler called for an undecodable message")
+			}
+			if tt.acked != ack.acked || tt.acked == ack.nacked || ack.requeued {
+				t.Fatalf("ack = %+v, want acked %v", ack, tt.acked)
+			}
+		})
+	}
+}
+
+// The handler sees where the message is in its retries, the key it was
+// published with and the event's correlation id.
+func TestDispatchMessage(t *testing.T) {
+	c := newTestConsumers(nil)
+	policy := RetryPolicy{Delays: []time.Duration{time.Second, time.Minute}}
+
+	var (
+		got         Message
+		correlation string
+	)
+	Handle(c, JobOptions{Queue: "merchant.onboard", Retry: &policy}, func(ctx context.Context, msg Message, p events.MerchantCreated) error {
+		got, correlation = msg, events.CorrelationID(ctx)
+		if p.MerchantID != "0x01" {
+			t.Errorf("payload = %+v", p)
+		}
+		return nil
+	})
+
+	d := testDelivery(t, &ackRecorder{})
+	d.RoutingKey, d.Redelivered = "merchant.onboard", true
+	d.Headers = amqp.Table{
+		originalRoutingKeyHeader: events.MerchantCreatedKey,
+		"x-death": []any{
+			amqp.Table{"queue": "merchant.onboard.retry.2", "reason": "expired", "count": int64(1)},
+			amqp.Table{"queue": "merchant.onboard.retry.1", "reason": "expired", "count": int64(1)},
+		},
+	}
+	c.dispatch(context.Background(), nil, c.jobs[0], d)
+
+	if got.Attempt != 3 || !got.LastAttempt || !got.Redelivered || got.RoutingKey != events.MerchantCreatedKey {
+		t.Fatalf("message = %+v", got)
+	}
+	if correlation == "" || correlation != got.Envelope.CorrelationID {
+		t.Fatalf("correlation id in ctx = %q, envelope %q", correlation, got.Envelope.CorrelationID)
+	}
+}
+
+// A payload of another type than the job's is a bug in the registry, not
+// something a retry fixes.
+func TestHandleRejectsWrongPayload(t *testing.T) {
+	c := newTestConsumers(nil)
+	Handle(c, JobOptions{}, func(context.Context, Message, events.MerchantCreated) error { return nil })
+
+	err := c.jobs[0].handle(context.Background(), Message{}, events.PaymentDetected{})
+	if !IsPermanent(err) {
+		t.Fatalf("err = %v, want a permanent error", err)
+	}
+}
+
+// memLedger records (consumer, event) as done only when fn succeeds, as
+// postgres.Ledger does.
+type memLedger struct {
+	done map[string]bool
+}
+
+func (l *memLedger) Process(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	key := consumer + "/" + eventID
+	if l.done[key] {
+		return true, nil
+	}
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+	l.done[key] = true
+	return false, nil
+}
+
+func TestDuplicateDeliveriesSkipped(t *testing.T) {
+	ledger := &memLedger{done: map[string]bool{}}
+	c := newTestConsumers(ledger)
+
+	calls := 0
+	fail := true
+	Handle(c, JobOptions{Retry: &RetryPolicy{}}, func(context.Context, Message, events.MerchantCreated) error {
+		calls++
+		if fail {
+			return Permanent(errors.New("node down"))
+		}
+		return nil
+	})
+
+	first := testDelivery(t, &ackRecorder{})
+	deliver := func(d amqp.Delivery) *ackRecorder {
+		ack := &ackRecorder{}
+		d.Acknowledger = ack
+		c.dispatch(context.Background(), nil, c.jobs[0], d)
+		return ack
+	}
+
+	// A failed attempt is not recorded: replaying the event handles it.
+	if ack := deliver(first); !ack.nacked {
+		t.Fatalf("failed delivery settled as %+v", ack)
+	}
+	fail = false
+	if ack := deliver(first); !ack.acked || calls != 2 {
+		t.Fatalf("replay: ack %+v, %d calls", ack, calls)
+	}
+
+	// The same event again, e.g. redelivered after a lost ack: acked unseen.
+	redelivered := first
+	redelivered.Redelivered = true
+	if ack := deliver(redelivered); !ack.acked || calls != 2 {
+		t.Fatalf("duplicate: ack %+v, %d calls", ack, calls)
+	}
+
+	// Another event is handled, and so is this one on another queue.
+	if ack := deliver(testDelivery(t, nil)); !ack.acked || calls != 3 {
+		t.Fatalf("new event: ack %+v, %d calls", ack, calls)
+	}
+	Handle(c, JobOptions{Queue: "merchant.audit"}, func(context.Context, Message, events.MerchantCreated) error {
+		calls++
+		return nil
+	})
+	ack := &ackRecorder{}
+	first.Acknowledger = ack
+	c.dispatch(context.Background(), nil, c.jobs[1], first)
+	if !ack.acked || calls != 4 {
+		t.Fatalf("other consumer: ack %+v, %d calls", ack, calls)
+	}
+}
@@ -0,0 +1,28 @@
+package rabbit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNacked means the broker refused responsibility for a published message
+// (e.g. disk alarm, queue overflow). The message may be published again.
+var ErrNacked = errors.New("rabbit: message nacked by broker")
+
+// UnroutableError means a mandatory message matched no queue binding and was
+// returned by the broker. Usually a consumer has not declared its queue yet.
+type UnroutableError struct {
+	Exchange   string
+	RoutingKey string
+	ReplyCode  uint16
+	ReplyText  string
+}
+
+func (e *UnroutableError) Error() string {
+	return fmt.Sprintf("rabbit: unroutable message %s/%s: %d %s", e.Exchange, e.RoutingKey, e.ReplyCode, e.ReplyText)
+}
+
+func IsUnroutable(err error) bool {
+	var u *UnroutableError
+	return errors.As(err, &u)
+}
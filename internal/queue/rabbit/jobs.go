@@ -1 +1,86 @@
 package rabbit
+
+import (
+	"context"
+	"fmt"
+
+	"token13/merchant-backend-go/internal/events"
+)
+
+// Message carries the delivery metadata a handler may need besides its
+// typed payload.
+type Message struct {
+	Envelope    events.Envelope
+	RoutingKey  string // key the event was originally published with
+	Attempt     int    // 1 on first delivery, +1 per retry round-trip
+	LastAttempt bool   // retries are used up: an error now parks the message
+	Redelivered bool   // broker redelivery (e.g. consumer crashed before ack)
+}
+
+// JobOptions tunes one consumer. Zero values get sensible defaults.
+type JobOptions struct {
+	// Queue defaults to "<routing key>.q".
+	Queue string
+
+	// Concurrency is the number of messages handled in parallel (default 1).
+	Concurrency int
+
+	// Prefetch is the channel QoS (default = Concurrency).
+	Prefetch int
+
+	// Retry defaults to DefaultRetryPolicy.
+	Retry *RetryPolicy
+}
+
+// Job is a registered consumer: one queue, one payload type, one handler.
+type Job struct {
+	Topology    QueueTopology
+	Concurrency int
+	Prefetch    int
+
+	handle func(ctx context.Context, msg Message, payload events.Event) error
+}
+
+// Handle registers fn for events of type T on c. The routing key is T's
+// EventType; the event registry used by c must know T.
+//
+// Handlers return nil to ack, Permanent(err) to park the message in the
+// DLQ, Delay(err) to hold it back without using up a retry, or any other
+// error to retry it with backoff. OnGiveUp(err, fail) adds a callback for
+// when the message is parked.
+func Handle[T events.Event](c *Consumers, opts JobOptions, fn func(ctx context.Context, msg Message, payload T) error) {
+	var zero T
+	key := zero.EventType()
+
+	j := &Job{
+		Topology: QueueTopology{
+			Queue:       opts.Queue,
+			RoutingKeys: []string{key},
+			Retry:       DefaultRetryPolicy,
+		},
+		Concurrency: opts.Concurrency,
+		Prefetch:    opts.Prefetch,
+	}
+	if j.Topology.Queue == "" {
+		j.Topology.Queue = key + ".q"
+	}
+	if opts.Retry != nil {
+		j.Topology.Retry = *opts.Retry
+	}
+	if j.Concurrency <= 0 {
+		j.Concurrency = 1
+	}
+	if j.Prefetch <= 0 {
+		j.Prefetch = j.Concurrency
+	}
+
+	j.handle = func(ctx context.Context, msg Message, payload events.Event) error {
+		p, ok := payload.(T)
+		if !ok {
+			return Permanent(fmt.Errorf("queue %s: unexpected payload %T for %s", j.Topology.Queue, payload, msg.Envelope.Type))
+		}
+		return fn(ctx, msg, p)
+	}
+
+	c.add(j)
+}
@@ -2,29 +2,107 @@ package rabbit
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+
+	"token13/merchant-backend-go/internal/events"
 )
 
+// Publisher publishes to one exchange in confirm mode with mandatory
+// routing: Publish returns only once the broker has taken responsibility
+// for the message, and reports nacks and unroutable messages as errors.
+//
+// Its channel is swapped for a fresh one whenever the underlying
+// Connection reconnects, so callers can hold a *Publisher for the life of
+// the process. The feeds it was created with are (re)declared with it, so
+// the events they collect always have a route.
 type Publisher struct {
-	ch       *amqp.Channel
+	conn     *Connection
 	exchange string
+	feeds    []FeedTopology
+
+	// ConfirmTimeout bounds the wait for an ack when ctx has no deadline.
+	ConfirmTimeout time.Duration
+
+	// publishMu serialises publishes so that a basic.return can be matched
+	// to the publish it belongs to (the broker sends it before the ack).
+	publishMu sync.Mutex
+
+	mu      sync.Mutex
+	ch      *amqp.Channel
+	returns chan amqp.Return
 }
 
-func NewPublisher(conn *amqp.Connection, exchange string) (*Publisher, error) {
-	ch, err := conn.Channel()
-	if err != nil {
+func NewPublisher(conn *Connection, exchange string, feeds ...FeedTopology) (*Publisher, error) {
+	p := &Publisher{conn: conn, exchange: exchange, feeds: feeds, ConfirmTimeout: 10 * time.Second}
+
+	if _, _, err := p.channel(); err != nil {
 		return nil, err
 	}
 
+	conn.OnReconnect(func(c *amqp.Connection) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.open(c.Channel)
+	})
+	return p, nil
+}
+
+// open gets a new channel, declares the exchange, enables confirms and makes
+// it the active one. Caller holds p.mu.
+func (p *Publisher) open(newChannel func() (*amqp.Channel, error)) error {
+	ch, err := newChannel()
+	if err != nil {
+		return err
+	}
+
 	// topic exchange is flexible
-	if err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
-		return nil, err
+	if err := DeclareExchange(ch, p.exchange); err != nil {
+		_ = ch.Close()
+		return err
 	}
+	for _, f := range p.feeds {
+		if err := DeclareFeed(ch, p.exchange, f); err != nil {
+			_ = ch.Close()
+			return err
+		}
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return fmt.Errorf("rabbit confirm mode: %w", err)
+	}
+
+	// Buffered so the connection's dispatcher never blocks on us; drained
+	// by Publish after every confirmation.
+	returns := ch.NotifyReturn(make(chan amqp.Return, 64))
+
+	if p.ch != nil && !p.ch.IsClosed() {
+		_ = p.ch.Close()
+	}
+	p.ch = ch
+	p.returns = returns
+	return nil
+}
+
+// channel returns the active channel, re-opening it if only the channel
+// (not the connection) was closed, e.g. after a channel-level exception.
+func (p *Publisher) channel() (*amqp.Channel, chan amqp.Return, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	return &Publisher{ch: ch, exchange: exchange}, nil
+	if p.ch != nil && !p.ch.IsClosed() {
+		return p.ch, p.returns, nil
+	}
+	if err := p.open(p.conn.Channel); err != nil {
+		return nil, nil, err
+	}
+	return p.ch, p.returns, nil
 }
 
 func (p *Publisher) PublishJSON(ctx context.Context, routingKey string, v any) error {
@@ -33,7 +111,7 @@ func (p *Publisher) PublishJSON(ctx context.Context, routingKey string, v any) e
 		return err
 	}
 
-	return p.ch.PublishWithContext(ctx, p.exchange, routingKey, false, false, amqp.Publishing{
+	return p.Publish(ctx, routingKey, amqp.Publishing{
 		ContentType:  "application/json",
 		Body:         body,
 		DeliveryMode: amqp.Persistent,
@@ -41,6 +119,114 @@ func (p *Publisher) PublishJSON(ctx context.Context, routingKey string, v any) e
 	})
 }
 
+// PublishEvent publishes env under its type as routing key. The event id
+// becomes the AMQP message id so consumers and the broker agree on identity.
+func (p *Publisher) PublishEvent(ctx context.Context, env events.Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	return p.Publish(ctx, env.Type, amqp.Publishing{
+		ContentType:   "application/json",
+		Body:          body,
+		DeliveryMode:  amqp.Persistent,
+		Timestamp:     env.OccurredAt,
+		MessageId:     env.EventID,
+		CorrelationId: env.CorrelationID,
+		Type:          env.Type,
+	})
+}
+
+// Publish sends msg with mandatory=true and waits for the broker's ack.
+// It returns ErrNacked, *UnroutableError, or a connection/context error.
+// A MessageId is generated when msg has none.
+func (p *Publisher) Publish(ctx context.Context, routingKey string, msg amqp.Publishing) error {
+	if msg.MessageId == "" {
+		msg.MessageId = newMessageID()
+	}
+	if _, ok := ctx.Deadline(); !ok && p.ConfirmTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.ConfirmTimeout)
+		defer cancel()
+	}
+
+	p.publishMu.Lock()
+	defer p.publishMu.Unlock()
+
+	ch, returns, err := p.channel()
+	if err != nil {
+		return err
+	}
+
+	// Returns left over from publishes that gave up waiting.
+	drainReturns(returns, "")
+
+	dc, err := ch.PublishWithDeferredConfirmWithContext(ctx, p.exchange, routingKey, true, false, msg)
+	if err != nil {
+		return fmt.Errorf("rabbit publish %s: %w", routingKey, err)
+	}
+
+	acked, err := dc.WaitContext(ctx)
+	if err != nil {
+		drainReturns(returns, "")
+		return fmt.Errorf("rabbit publish %s: wait confirm: %w", routingKey, err)
+	}
+
+	// The broker sends basic.return before the ack, and the dispatcher has
+	// already put it into the buffered channel.
+	if ret, ok := drainReturns(returns, msg.MessageId); ok {
+		return &UnroutableError{
+			Exchange:   ret.Exchange,
+			RoutingKey: ret.RoutingKey,
+			ReplyCode:  ret.ReplyCode,
+			ReplyText:  ret.ReplyText,
+		}
+	}
+
+	if !acked {
+		if ch.IsClosed() {
+			return fmt.Errorf("rabbit publish %s: %w", routingKey, ErrNotConnected)
+		}
+		return fmt.Errorf("rabbit publish %s: %w", routingKey, ErrNacked)
+	}
+	return nil
+}
+
 func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil || p.ch.IsClosed() {
+		return nil
+	}
 	return p.ch.Close()
 }
+
+// drainReturns empties returns without blocking and reports the return
+// matching messageID, if any.
+func drainReturns(returns chan amqp.Return, messageID string) (amqp.Return, bool) {
+	var (
+		match amqp.Return
+		found bool
+	)
+	for {
+		select {
+		case r, ok := <-returns:
+			if !ok {
+				return match, found
+			}
+			if messageID != "" && r.MessageId == messageID {
+				match, found = r, true
+			}
+		default:
+			return match, found
+		}
+	}
+}
+
+func newMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,77 @@
+package rabbit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestDrainReturns(t *testing.T) {
+	returns := make(chan amqp.Return, 4)
+	// A return left over from a publish that gave up waiting, then ours.
+	returns <- amqp.Return{MessageId: "stale", RoutingKey: "payment.detected", ReplyCode: 312}
+	returns <- amqp.Return{MessageId: "m1", RoutingKey: "merchant.created", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+
+	ret, ok := drainReturns(returns, "m1")
+	if !ok || ret.RoutingKey != "merchant.created" || ret.ReplyText != "NO_ROUTE" {
+		t.Fatalf("drainReturns = %+v, %v", ret, ok)
+	}
+	if len(returns) != 0 {
+		t.Fatalf("%d returns left behind", len(returns))
+	}
+
+	// Nothing returned for this publish: it was routed.
+	returns <- amqp.Return{MessageId: "stale"}
+	if _, ok := drainReturns(returns, "m2"); ok {
+		t.Fatal("matched another message's return")
+	}
+	if len(returns) != 0 {
+		t.Fatal("stale return not drained")
+	}
+
+	// Draining without an id only empties the channel.
+	returns <- amqp.Return{MessageId: "m3"}
+	if _, ok := drainReturns(returns, ""); ok || len(returns) != 0 {
+		t.Fatal("drain without id matched or left returns")
+	}
+
+	// The channel closes with its amqp channel.
+	close(returns)
+	if _, ok := drainReturns(returns, "m4"); ok {
+		t.Fatal("matched on a closed channel")
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	unroutable := fmt.Errorf("outbox 7: %w", &UnroutableError{
+		Exchange: "token13.events", RoutingKey: "merchant.created", ReplyCode: 312, ReplyText: "NO_ROUTE",
+	})
+	if !IsUnroutable(unroutable) {
+		t.Fatal("wrapped UnroutableError not recognised")
+	}
+	if want := "outbox 7: rabbit: unroutable message token13.events/merchant.created: 312 NO_ROUTE"; unroutable.Error() != want {
+		t.Fatalf("error = %q", unroutable)
+	}
+
+	nacked := fmt.Errorf("rabbit publish merchant.created: %w", ErrNacked)
+	if IsUnroutable(nacked) || !errors.Is(nacked, ErrNacked) {
+		t.Fatalf("nack misclassified: %v", nacked)
+	}
+	if IsUnroutable(ErrNotConnected) {
+		t.Fatal("connection loss reported as unroutable")
+	}
+}
+
+// Returns are matched by message id, so every publish needs its own.
+func TestNewMessageID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := newMessageID()
+		if len(id) != 32 || seen[id] {
+			t.Fatalf("message id %q is reused or not 16 bytes of hex", id)
+		}
+		seen[id] = true
+	}
+}
@@ -1 +1,115 @@
 package rabbit
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Queue topology per consumer:
+//
+//	<exchange> --routing keys--> <queue>
+//	<queue>         rejected       --> <queue>.dlq            (parked for inspection)
+//	<queue>         retry attempt N --> <queue>.retry.N        (TTL = Delays[N-1])
+//	<queue>.retry.N expired        --> <queue>                (redelivered)
+//
+// Queue arguments cannot change on an existing queue; a queue declared
+// before this topology existed must be deleted once before redeploying.
+
+// RetryPolicy is the list of delays applied before each redelivery.
+// len(Delays) is the maximum number of retries before a message is parked.
+type RetryPolicy struct {
+	Delays []time.Duration
+}
+
+// DefaultRetryPolicy backs off over roughly a quarter of an hour, long enough
+// to ride out a TronGrid hiccup or a broker restart.
+var DefaultRetryPolicy = RetryPolicy{
+	Delays: []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute, 10 * time.Minute},
+}
+
+type QueueTopology struct {
+	Queue       string
+	RoutingKeys []string
+	Retry       RetryPolicy
+}
+
+// FeedTopology is a queue collecting events for subscribers outside this
+// service. Nothing here consumes it, so it is bounded: messages expire
+// after TTL and the oldest are dropped beyond MaxLength.
+type FeedTopology struct {
+	Queue       string
+	RoutingKeys []string
+	TTL         time.Duration
+	MaxLength   int
+}
+
+func DLQName(queue string) string { return queue + ".dlq" }
+
+func RetryQueueName(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+// DeclareExchange declares the durable topic exchange all events go through.
+func DeclareExchange(ch *amqp.Channel, exchange string) error {
+	if err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare exchange %s: %w", exchange, err)
+	}
+	return nil
+}
+
+// DeclareFeed declares a feed queue and binds it to exchange.
+func DeclareFeed(ch *amqp.Channel, exchange string, t FeedTopology) error {
+	args := amqp.Table{"x-overflow": "drop-head"}
+	if t.TTL > 0 {
+		args["x-message-ttl"] = t.TTL.Milliseconds()
+	}
+	if t.MaxLength > 0 {
+		args["x-max-length"] = int64(t.MaxLength)
+	}
+	if _, err := ch.QueueDeclare(t.Queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("declare %s: %w", t.Queue, err)
+	}
+	for _, key := range t.RoutingKeys {
+		if err := ch.QueueBind(t.Queue, key, exchange, false, nil); err != nil {
+			return fmt.Errorf("bind %s to %s: %w", t.Queue, key, err)
+		}
+	}
+	return nil
+}
+
+// DeclareQueue declares a consumer queue with its DLQ and retry queues and
+// binds it to exchange.
+func DeclareQueue(ch *amqp.Channel, exchange string, t QueueTopology) error {
+	if _, err := ch.QueueDeclare(DLQName(t.Queue), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare %s: %w", DLQName(t.Queue), err)
+	}
+
+	for i, delay := range t.Retry.Delays {
+		name := RetryQueueName(t.Queue, i+1)
+		_, err := ch.QueueDeclare(name, true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": t.Queue,
+		})
+		if err != nil {
+			return fmt.Errorf("declare %s: %w", name, err)
+		}
+	}
+
+	_, err := ch.QueueDeclare(t.Queue, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": DLQName(t.Queue),
+	})
+	if err != nil {
+		return fmt.Errorf("declare %s: %w", t.Queue, err)
+	}
+
+	for _, key := range t.RoutingKeys {
+		if err := ch.QueueBind(t.Queue, key, exchange, false, nil); err != nil {
+			return fmt.Errorf("bind %s to %s: %w", t.Queue, key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// originalRoutingKeyHeader keeps the routing key the event was published
+// with; after a retry round-trip the broker redelivers it under the queue name.
+const originalRoutingKeyHeader = "x-original-routing-key"
+
+// heldCountHeader counts the trips Hold sent a message on; they go through
+// the last retry queue but are not retries.
+const heldCountHeader = "x-held-count"
+
+// retryConfirmTimeout bounds the wait for the broker to confirm a retry
+// copy before the original is handed back instead.
+const retryConfirmTimeout = 10 * time.Second
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the message goes straight to the DLQ.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+type delayedError struct{ err error }
+
+func (e *delayedError) Error() string { return e.err.Error() }
+func (e *delayedError) Unwrap() error { return e.err }
+
+// Delay marks err as a wait rather than a failure: the message is held back
+// for the retry policy's longest delay without using up a retry, for as
+// long as the handler keeps returning it.
+func Delay(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &delayedError{err: err}
+}
+
+func IsDelayed(err error) bool {
+	var d *delayedError
+	return errors.As(err, &d)
+}
+
+type givenUpError struct {
+	err  error
+	fail func(ctx context.Context) error
+}
+
+func (e *givenUpError) Error() string { return e.err.Error() }
+func (e *givenUpError) Unwrap() error { return e.err }
+
+// OnGiveUp attaches fail to err. If err ends the message's retries (it is
+// permanent, or the retries are used up) the consumer runs fail once the
+// handler has returned and its Ledger work was rolled back, so fail never
+// waits on row locks the handler's own transaction still holds.
+func OnGiveUp(err error, fail func(ctx context.Context) error) error {
+	if err == nil {
+		return nil
+	}
+	return &givenUpError{err: err, fail: fail}
+}
+
+// RetryCount returns how many times d has already gone through the retry
+// queues of queue, based on the x-death entries the broker appends on
+// expiry. Trips it was held back on (Hold) do not count.
+func RetryCount(d amqp.Delivery, queue string) int {
+	return deathCount(d, queue) - headerInt(d.Headers[heldCountHeader])
+}
+
+func deathCount(d amqp.Delivery, queue string) int {
+	deaths, ok := d.Headers["x-death"].([]any)
+	if !ok {
+		return 0
+	}
+
+	prefix := queue + ".retry."
+	total := 0
+	for _, raw := range deaths {
+		death, ok := raw.(amqp.Table)
+		if !ok {
+			continue
+		}
+		q, _ := death["queue"].(string)
+		reason, _ := death["reason"].(string)
+		if reason != "expired" || !strings.HasPrefix(q, prefix) {
+			continue
+		}
+		total += headerInt(death["count"])
+	}
+	return total
+}
+
+func headerInt(v any) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case int32:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// OriginalRoutingKey returns the routing key d was first published with.
+func OriginalRoutingKey(d amqp.Delivery) string {
+	if k, ok := d.Headers[originalRoutingKeyHeader].(string); ok && k != "" {
+		return k
+	}
+	return d.RoutingKey
+}
+
+// Retry schedules d for redelivery through the next retry queue of queue,
+// or parks it in the DLQ once policy is exhausted. d is settled either way.
+// parked reports whether the message ended up in the DLQ. ch must be in
+// confirm mode: d is acked only once the broker confirmed the retry copy.
+func Retry(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, queue string, policy RetryPolicy) (parked bool, err error) {
+	attempt := RetryCount(d, queue) + 1
+	if attempt > len(policy.Delays) {
+		return true, d.Nack(false, false)
+	}
+	return false, republish(ctx, ch, d, RetryQueueName(queue, attempt), retryHeaders(d))
+}
+
+// Hold sends d back through the last retry queue of queue without counting
+// it as a retry (see Delay). Without retry queues it is parked instead.
+func Hold(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, queue string, policy RetryPolicy) error {
+	if len(policy.Delays) == 0 {
+		return Park(d)
+	}
+	headers := retryHeaders(d)
+	headers[heldCountHeader] = int64(headerInt(d.Headers[heldCountHeader]) + 1)
+	return republish(ctx, ch, d, RetryQueueName(queue, len(policy.Delays)), headers)
+}
+
+func retryHeaders(d amqp.Delivery) amqp.Table {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	if _, ok := headers[originalRoutingKeyHeader]; !ok {
+		headers[originalRoutingKeyHeader] = d.RoutingKey
+	}
+	return headers
+}
+
+// republish copies d to queue and acks it once the broker confirmed the
+// copy, or hands d back to the broker if the copy was not confirmed.
+func republish(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, queue string, headers amqp.Table) error {
+	dc, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   d.ContentType,
+		Body:          d.Body,
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     d.MessageId,
+		CorrelationId: d.CorrelationId,
+		Timestamp:     d.Timestamp,
+		Type:          d.Type,
+	})
+	if err == nil {
+		err = waitConfirm(ctx, dc)
+	}
+	if err != nil {
+		// Could not schedule the retry: hand it back to the broker as-is.
+		_ = d.Nack(false, true)
+		return err
+	}
+	return d.Ack(false)
+}
+
+func waitConfirm(ctx context.Context, dc *amqp.DeferredConfirmation) error {
+	if dc == nil {
+		return errors.New("rabbit retry: channel not in confirm mode")
+	}
+	ctx, cancel := context.WithTimeout(ctx, retryConfirmTimeout)
+	defer cancel()
+
+	acked, err := dc.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("rabbit retry: wait confirm: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("rabbit retry: %w", ErrNacked)
+	}
+	return nil
+}
+
+// Park sends d straight to the DLQ of its queue.
+func Park(d amqp.Delivery) error {
+	return d.Nack(false, false)
+}
@@ -0,0 +1,167 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// death is one x-death entry as the broker appends it on expiry or rejection.
+func death(queue, reason string, count int64) amqp.Table {
+	return amqp.Table{"queue": queue, "reason": reason, "count": count, "exchange": ""}
+}
+
+func TestRetryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		want    int
+	}{
+		{name: "first delivery", want: 0},
+		{
+			name:    "one retry",
+			headers: amqp.Table{"x-death": []any{death("merchant.onboard.retry.1", "expired", 1)}},
+			want:    1,
+		},
+		{
+			name: "across retry queues",
+			headers: amqp.Table{"x-death": []any{
+				death("merchant.onboard.retry.2", "expired", 1),
+				death("merchant.onboard.retry.1", "expired", 1),
+			}},
+			want: 2,
+		},
+		{
+			name: "rejections and other queues do not count",
+			headers: amqp.Table{"x-death": []any{
+				death("merchant.onboard", "rejected", 3),
+				death("merchant.status.retry.1", "expired", 2),
+				death("merchant.onboard.dlq", "expired", 1),
+				death("merchant.onboard.retry.1", "expired", 1),
+			}},
+			want: 1,
+		},
+		{
+			name: "held trips are not retries",
+			headers: amqp.Table{
+				"x-death": []any{
+					death("merchant.onboard.retry.4", "expired", 3),
+					death("merchant.onboard.retry.1", "expired", 1),
+				},
+				heldCountHeader: int64(3),
+			},
+			want: 1,
+		},
+		{
+			name:    "count as int32",
+			headers: amqp.Table{"x-death": []any{amqp.Table{"queue": "merchant.onboard.retry.1", "reason": "expired", "count": int32(2)}}},
+			want:    2,
+		},
+		{
+			name:    "malformed header",
+			headers: amqp.Table{"x-death": "expired"},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := amqp.Delivery{Headers: tt.headers}
+			if got := RetryCount(d, "merchant.onboard"); got != tt.want {
+				t.Fatalf("RetryCount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginalRoutingKey(t *testing.T) {
+	first := amqp.Delivery{RoutingKey: "merchant.created"}
+	if got := OriginalRoutingKey(first); got != "merchant.created" {
+		t.Fatalf("first delivery: %q", got)
+	}
+
+	// After a round trip through a retry queue the broker redelivers under
+	// the queue name; the header keeps the key it was published with.
+	retried := amqp.Delivery{
+		RoutingKey: "merchant.onboard",
+		Headers:    retryHeaders(first),
+	}
+	if got := OriginalRoutingKey(retried); got != "merchant.created" {
+		t.Fatalf("retried delivery: %q", got)
+	}
+	if got := retryHeaders(retried)[originalRoutingKeyHeader]; got != "merchant.created" {
+		t.Fatalf("second retry overwrote the original key with %v", got)
+	}
+}
+
+func TestQueueNames(t *testing.T) {
+	if got := DLQName("merchant.onboard"); got != "merchant.onboard.dlq" {
+		t.Fatalf("DLQName = %q", got)
+	}
+	if got := RetryQueueName("merchant.onboard", 3); got != "merchant.onboard.retry.3" {
+		t.Fatalf("RetryQueueName = %q", got)
+	}
+}
+
+// Once the policy is used up the message is parked in the DLQ without
+// another copy being published.
+func TestRetryParksWhenExhausted(t *testing.T) {
+	policy := RetryPolicy{Delays: []time.Duration{time.Second, time.Minute}}
+	ack := &ackRecorder{}
+	d := amqp.Delivery{
+		Acknowledger: ack,
+		RoutingKey:   "merchant.onboard",
+		Headers: amqp.Table{"x-death": []any{
+			death("merchant.onboard.retry.2", "expired", 1),
+			death("merchant.onboard.retry.1", "expired", 1),
+		}},
+	}
+
+	parked, err := Retry(context.Background(), nil, d, "merchant.onboard", policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parked || !ack.nacked || ack.requeued || ack.acked {
+		t.Fatalf("parked = %v, ack = %+v", parked, ack)
+	}
+}
+
+func TestHoldParksWithoutRetryQueues(t *testing.T) {
+	ack := &ackRecorder{}
+	d := amqp.Delivery{Acknowledger: ack, RoutingKey: "merchant.onboard"}
+
+	if err := Hold(context.Background(), nil, d, "merchant.onboard", RetryPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if !ack.nacked || ack.requeued {
+		t.Fatalf("ack = %+v", ack)
+	}
+}
+
+func TestErrorMarkers(t *testing.T) {
+	base := errors.New("node down")
+
+	if Permanent(nil) != nil || Delay(nil) != nil || OnGiveUp(nil, nil) != nil {
+		t.Fatal("marking a nil error must stay nil")
+	}
+	if IsPermanent(base) || IsDelayed(base) {
+		t.Fatal("plain error is marked")
+	}
+
+	perm := fmt.Errorf("onboard: %w", Permanent(base))
+	if !IsPermanent(perm) || IsDelayed(perm) || !errors.Is(perm, base) || perm.Error() != "onboard: node down" {
+		t.Fatalf("permanent: %v", perm)
+	}
+	delayed := fmt.Errorf("budget: %w", Delay(base))
+	if !IsDelayed(delayed) || IsPermanent(delayed) || !errors.Is(delayed, base) {
+		t.Fatalf("delayed: %v", delayed)
+	}
+	// OnGiveUp keeps whatever err already was.
+	given := OnGiveUp(Permanent(base), func(context.Context) error { return nil })
+	if !IsPermanent(given) || !errors.Is(given, base) || given.Error() != "node down" {
+		t.Fatalf("given up: %v", given)
+	}
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// lastUsedGranularity bounds how often Touch writes for a busy key.
+const lastUsedGranularity = time.Minute
+
+type APIKeyRepo struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepo(db *sql.DB) *APIKeyRepo {
+	return &APIKeyRepo{db: db}
+}
+
+const apiKeyColumns = `
+	id, merchant_id, name, prefix, secret_hash,
+	array_to_string(scopes, ','), array_to_string(allowed_ips, ','),
+	COALESCE(created_by::text, ''), created_at, last_used_at, revoked_at`
+
+func (r *APIKeyRepo) Create(ctx context.Context, k *domain.APIKey) error {
+	scopes := make([]string, len(k.Scopes))
+	for i, s := range k.Scopes {
+		scopes[i] = string(s)
+	}
+	ips := make([]string, len(k.AllowedIPs))
+	for i, p := range k.AllowedIPs {
+		ips[i] = p.String()
+	}
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO api_keys (merchant_id, name, prefix, secret_hash, scopes, allowed_ips, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid)
+		RETURNING id, created_at
+	`, k.MerchantID, k.Name, k.Prefix, k.SecretHash, scopes, ips, k.CreatedBy).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create api key: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	k, err := scanAPIKey(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE prefix = $1
+	`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	return k, nil
+}
+
+func (r *APIKeyRepo) List(ctx context.Context, merchantID []byte) ([]domain.APIKey, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE merchant_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list api keys: %w", err)
+		}
+		out = append(out, *k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	return out, nil
+}
+
+func (r *APIKeyRepo) Revoke(ctx context.Context, merchantID []byte, id int64, at time.Time) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = $3
+		WHERE merchant_id = $1 AND id = $2 AND revoked_at IS NULL
+	`, merchantID, id, at)
+	if err != nil {
+		return false, fmt.Errorf("revoke api key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("revoke api key: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *APIKeyRepo) Touch(ctx context.Context, id int64, at time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, id, at, at.Add(-lastUsedGranularity))
+	if err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(s rowScanner) (*domain.APIKey, error) {
+	var (
+		k                   domain.APIKey
+		scopes, ips         string
+		lastUsed, revokedAt sql.NullTime
+	)
+	err := s.Scan(&k.ID, &k.MerchantID, &k.Name, &k.Prefix, &k.SecretHash,
+		&scopes, &ips, &k.CreatedBy, &k.CreatedAt, &lastUsed, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			k.Scopes = append(k.Scopes, domain.Scope(scope))
+		}
+	}
+	for _, ip := range strings.Split(ips, ",") {
+		if ip == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(ip)
+		if err != nil {
+			return nil, fmt.Errorf("allowed ip %q: %w", ip, err)
+		}
+		k.AllowedIPs = append(k.AllowedIPs, p)
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return &k, nil
+}
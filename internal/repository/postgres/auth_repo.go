@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"token13/merchant-backend-go/internal/domain"
 )
 
 type AuthRepo struct {
@@ -17,14 +19,17 @@ func NewAuthRepo(db *sql.DB) *AuthRepo {
 }
 
 // CreateMerchantAndUserTx creates merchant + user in a single DB transaction.
+// outbox messages (e.g. merchant.created) are written in the same transaction,
+// so a committed merchant can never miss its event.
 // Returns: merchantID (same input), status (usually "PENDING"), error
 func (r *AuthRepo) CreateMerchantAndUserTx(
 	ctx context.Context,
 	merchantID []byte,
 	name string,
-	wallet string,
+	wallet domain.TronAddress,
 	email string,
 	passwordHash string,
+	outbox ...domain.OutboxMessage,
 ) ([]byte, string, error) {
 
 	if len(merchantID) != 32 {
@@ -55,6 +60,11 @@ func (r *AuthRepo) CreateMerchantAndUserTx(
 		return nil, "", mapSQLError(err)
 	}
 
+	// 3) outbox
+	if err := insertOutbox(ctx, tx, outbox...); err != nil {
+		return nil, "", err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, "", err
 	}
@@ -96,6 +106,46 @@ func (r *AuthRepo) GetUserByEmail(ctx context.Context, email string) (
 	return userUID, emailOut, passwordHash, role, status, merchantID, nil
 }
 
+// GetUserByUID is GetUserByEmail keyed by user_uid, for refreshing the
+// access token of a user already signed in.
+func (r *AuthRepo) GetUserByUID(ctx context.Context, userUID string) (
+	emailOut string,
+	role string,
+	status string,
+	merchantID []byte,
+	err error,
+) {
+	err = r.db.QueryRowContext(ctx, `
+		SELECT email, role, status, merchant_id
+		FROM users
+		WHERE user_uid = $1
+	`, userUID).Scan(&emailOut, &role, &status, &merchantID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", "", nil, fmt.Errorf("user not found")
+		}
+		return "", "", "", nil, err
+	}
+	return emailOut, role, status, merchantID, nil
+}
+
+// GetPasswordHash returns the password hash and status of the user, for
+// re-confirming the password on sensitive operations.
+func (r *AuthRepo) GetPasswordHash(ctx context.Context, userUID string) (passwordHash, status string, err error) {
+	err = r.db.QueryRowContext(ctx, `
+		SELECT password_hash, status
+		FROM users
+		WHERE user_uid = $1
+	`, userUID).Scan(&passwordHash, &status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", fmt.Errorf("user not found")
+		}
+		return "", "", err
+	}
+	return passwordHash, status, nil
+}
+
 func mapSQLError(err error) error {
 	msg := strings.ToLower(err.Error())
 
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type ChainCursorRepo struct {
+	db *sql.DB
+}
+
+func NewChainCursorRepo(db *sql.DB) *ChainCursorRepo {
+	return &ChainCursorRepo{db: db}
+}
+
+func (r *ChainCursorRepo) Get(ctx context.Context, name string) (int64, bool, error) {
+	var block int64
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT block_number FROM chain_cursors WHERE name = $1
+	`, name).Scan(&block)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get chain cursor %s: %w", name, err)
+	}
+	return block, true, nil
+}
+
+func (r *ChainCursorRepo) Set(ctx context.Context, name string, block int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO chain_cursors (name, block_number)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE
+		SET block_number = EXCLUDED.block_number, updated_at = NOW()
+	`, name, block)
+	if err != nil {
+		return fmt.Errorf("set chain cursor %s: %w", name, err)
+	}
+	return nil
+}
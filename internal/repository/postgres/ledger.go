@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrEventInFlight is returned by Process when another delivery of the same
+// event holds a live claim. It is transient: the redelivery retries later.
+var ErrEventInFlight = errors.New("event is being processed by another delivery")
+
+// Ledger records which consumer processed which event (processed_events).
+type Ledger struct {
+	db *sql.DB
+
+	// Lease bounds how long a claim blocks other deliveries of the event.
+	// A claim left 'processing' by a crashed worker is taken over once it
+	// lapses, so it must exceed the slowest handler.
+	Lease time.Duration
+}
+
+func NewLedger(db *sql.DB) *Ledger {
+	return &Ledger{db: db, Lease: 15 * time.Minute}
+}
+
+// Process claims (eventID, consumer) and runs fn outside any transaction,
+// so chain calls and receipt polling hold no locks. Repository units fn
+// runs through inTx join one transaction, begun by the first of them, that
+// commits together with marking the claim done; writes that deliberately
+// bypass the ambient transaction (the Set*TxID progress markers) commit on
+// their own. If the event was already processed, fn is not called and
+// duplicate is true; if another delivery holds a live claim, Process
+// returns ErrEventInFlight. On failure the transaction is rolled back and
+// the claim released so a retry can take it straight away; failure
+// bookkeeping that writes outside the transaction must run after Process
+// returns (see rabbit.OnGiveUp), as the transaction may hold its rows.
+func (l *Ledger) Process(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (duplicate bool, err error) {
+	duplicate, err = l.claim(ctx, consumer, eventID)
+	if err != nil || duplicate {
+		return duplicate, err
+	}
+
+	fctx, d := withDeferredTx(ctx, l.db)
+	defer d.rollback()
+
+	if err := fn(fctx); err != nil {
+		d.rollback()
+		l.release(consumer, eventID)
+		return false, err
+	}
+
+	tx, err := d.begin()
+	if err != nil {
+		l.release(consumer, eventID)
+		return false, fmt.Errorf("complete event %s: %w", eventID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE processed_events
+		SET status = 'done', claimed_until = NULL, processed_at = NOW()
+		WHERE event_id = $1 AND consumer = $2
+	`, eventID, consumer); err != nil {
+		d.rollback()
+		l.release(consumer, eventID)
+		return false, fmt.Errorf("complete event %s: %w", eventID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		l.release(consumer, eventID)
+		return false, fmt.Errorf("commit event %s: %w", eventID, err)
+	}
+	return false, nil
+}
+
+// claim inserts a 'processing' row, or takes over one whose lease lapsed.
+func (l *Ledger) claim(ctx context.Context, consumer, eventID string) (duplicate bool, err error) {
+	var claimed bool
+	err = l.db.QueryRowContext(ctx, `
+		INSERT INTO processed_events (event_id, consumer, status, claimed_until)
+		VALUES ($1, $2, 'processing', NOW() + make_interval(secs => $3))
+		ON CONFLICT (event_id, consumer) DO UPDATE
+		SET claimed_until = EXCLUDED.claimed_until, processed_at = NOW()
+		WHERE processed_events.status = 'processing'
+		  AND processed_events.claimed_until < NOW()
+		RETURNING true
+	`, eventID, consumer, l.Lease.Seconds()).Scan(&claimed)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("claim event: %w", err)
+	}
+
+	var status string
+	err = l.db.QueryRowContext(ctx, `
+		SELECT status FROM processed_events
+		WHERE event_id = $1 AND consumer = $2
+	`, eventID, consumer).Scan(&status)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Released between the two statements: let the retry claim it.
+		return false, ErrEventInFlight
+	case err != nil:
+		return false, fmt.Errorf("claim event: %w", err)
+	case status == "done":
+		return true, nil
+	default:
+		return false, ErrEventInFlight
+	}
+}
+
+// release drops a claim fn failed under. It must run even when ctx was
+// cancelled by shutdown; if it cannot, the lease still expires.
+func (l *Ledger) release(consumer, eventID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = l.db.ExecContext(ctx, `
+		DELETE FROM processed_events
+		WHERE event_id = $1 AND consumer = $2 AND status = 'processing'
+	`, eventID, consumer)
+}
@@ -0,0 +1,593 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type MerchantRepo struct {
+	db *sql.DB
+}
+
+func NewMerchantRepo(db *sql.DB) *MerchantRepo {
+	return &MerchantRepo{db: db}
+}
+
+func (r *MerchantRepo) GetByID(ctx context.Context, merchantID []byte) (*domain.Merchant, error) {
+	var (
+		m            domain.Merchant
+		status       string
+		txid         sql.NullString
+		registeredAt sql.NullTime
+		chainError   sql.NullString
+		chainFailed  sql.NullTime
+		requested    sql.NullString
+		statusTxID   sql.NullString
+		requestedAt  sql.NullTime
+		statusError  sql.NullString
+		statusFailed sql.NullTime
+	)
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT merchant_id, name, wallet_address, status, chain_txid, chain_registered_at,
+		       chain_error, chain_failed_at,
+		       requested_status, status_txid, status_requested_at,
+		       status_error, status_failed_at, created_at, updated_at
+		FROM merchants
+		WHERE merchant_id = $1
+	`, merchantID).Scan(
+		&m.MerchantID,
+		&m.Name,
+		&m.WalletAddress,
+		&status,
+		&txid,
+		&registeredAt,
+		&chainError,
+		&chainFailed,
+		&requested,
+		&statusTxID,
+		&requestedAt,
+		&statusError,
+		&statusFailed,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get merchant: %w", err)
+	}
+
+	m.Status = domain.MerchantStatus(status)
+	m.ChainTxID = txid.String
+	if registeredAt.Valid {
+		t := registeredAt.Time
+		m.ChainRegisteredAt = &t
+	}
+	m.ChainError = chainError.String
+	if chainFailed.Valid {
+		t := chainFailed.Time
+		m.ChainFailedAt = &t
+	}
+	m.RequestedStatus = domain.MerchantStatus(requested.String)
+	m.StatusTxID = statusTxID.String
+	if requestedAt.Valid {
+		t := requestedAt.Time
+		m.StatusRequestedAt = &t
+	}
+	m.StatusError = statusError.String
+	if statusFailed.Valid {
+		t := statusFailed.Time
+		m.StatusFailedAt = &t
+	}
+	return &m, nil
+}
+
+// SetChainTxID deliberately ignores any ambient transaction: a broadcast
+// txid must survive a rollback of the surrounding handler.
+func (r *MerchantRepo) SetChainTxID(ctx context.Context, merchantID []byte, txid string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchants
+		SET chain_txid = NULLIF($2, ''), updated_at = NOW()
+		WHERE merchant_id = $1 AND chain_registered_at IS NULL
+	`, merchantID, txid)
+	if err != nil {
+		return fmt.Errorf("set chain txid: %w", err)
+	}
+	return nil
+}
+
+// MarkChainRegistered activates the merchant and writes outbox messages in
+// the same transaction. Nothing is written if it was already registered.
+func (r *MerchantRepo) MarkChainRegistered(ctx context.Context, merchantID []byte, txid string, at time.Time, outbox ...domain.OutboxMessage) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE merchants
+			SET status = CASE WHEN status = 'PENDING' THEN 'ACTIVE' ELSE status END,
+			    chain_txid = COALESCE(NULLIF($2, ''), chain_txid),
+			    chain_registered_at = $3,
+			    chain_failed_at = NULL,
+			    chain_error = NULL,
+			    updated_at = NOW()
+			WHERE merchant_id = $1 AND chain_registered_at IS NULL
+		`, merchantID, txid, at)
+		if err != nil {
+			return fmt.Errorf("mark chain registered: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return insertOutbox(ctx, tx, outbox...)
+	})
+}
+
+// FailOnboarding ignores any ambient transaction, like SetChainTxID: the
+// handler giving up is about to fail.
+func (r *MerchantRepo) FailOnboarding(ctx context.Context, merchantID []byte, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchants
+		SET chain_txid = NULL, chain_failed_at = NOW(), chain_error = $2, updated_at = NOW()
+		WHERE merchant_id = $1 AND chain_registered_at IS NULL
+	`, merchantID, reason)
+	if err != nil {
+		return fmt.Errorf("fail onboarding: %w", err)
+	}
+	return nil
+}
+
+func (r *MerchantRepo) RetryOnboarding(ctx context.Context, merchantID []byte, outbox ...domain.OutboxMessage) (bool, error) {
+	retried := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE merchants
+			SET chain_failed_at = NULL, chain_error = NULL, updated_at = NOW()
+			WHERE merchant_id = $1 AND chain_registered_at IS NULL AND chain_failed_at IS NOT NULL
+		`, merchantID)
+		if err != nil {
+			return fmt.Errorf("retry onboarding: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+
+		retried = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	return retried, err
+}
+
+func (r *MerchantRepo) RequestStatus(ctx context.Context, merchantID []byte, status domain.MerchantStatus, at time.Time, outbox ...domain.OutboxMessage) (bool, error) {
+	requested := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE merchants
+			SET requested_status = $2, status_requested_at = $3, status_txid = NULL,
+			    status_failed_at = NULL, status_error = NULL, updated_at = NOW()
+			WHERE merchant_id = $1 AND chain_registered_at IS NOT NULL AND requested_status IS NULL
+		`, merchantID, string(status), at)
+		if err != nil {
+			return fmt.Errorf("request merchant status: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+
+		requested = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	return requested, err
+}
+
+// SetStatusTxID ignores any ambient transaction, like SetChainTxID.
+func (r *MerchantRepo) SetStatusTxID(ctx context.Context, merchantID []byte, txid string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchants
+		SET status_txid = NULLIF($2, ''), updated_at = NOW()
+		WHERE merchant_id = $1 AND requested_status IS NOT NULL
+	`, merchantID, txid)
+	if err != nil {
+		return fmt.Errorf("set status txid: %w", err)
+	}
+	return nil
+}
+
+func (r *MerchantRepo) ConfirmStatus(ctx context.Context, merchantID []byte, status domain.MerchantStatus, outbox ...domain.OutboxMessage) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE merchants
+			SET status = $2,
+			    requested_status = NULL,
+			    status_txid = NULL,
+			    status_requested_at = NULL,
+			    updated_at = NOW()
+			WHERE merchant_id = $1 AND requested_status IS NOT NULL
+		`, merchantID, string(status))
+		if err != nil {
+			return fmt.Errorf("confirm merchant status: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return insertOutbox(ctx, tx, outbox...)
+	})
+}
+
+// FailStatus ignores any ambient transaction, like FailOnboarding.
+func (r *MerchantRepo) FailStatus(ctx context.Context, merchantID []byte, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchants
+		SET requested_status = NULL,
+		    status_txid = NULL,
+		    status_requested_at = NULL,
+		    status_failed_at = NOW(),
+		    status_error = $2,
+		    updated_at = NOW()
+		WHERE merchant_id = $1 AND requested_status IS NOT NULL
+	`, merchantID, reason)
+	if err != nil {
+		return fmt.Errorf("fail merchant status: %w", err)
+	}
+	return nil
+}
+
+const receiverChangeColumns = `id, merchant_id, old_receiver, new_receiver, status, txid,
+	requested_by::text, requested_at, confirmed_at, failed_at, error`
+
+func (r *MerchantRepo) LatestReceiverChange(ctx context.Context, merchantID []byte) (*domain.ReceiverChange, error) {
+	ch, err := scanReceiverChange(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+receiverChangeColumns+`
+		FROM merchant_receiver_changes
+		WHERE merchant_id = $1
+		ORDER BY requested_at DESC, id DESC
+		LIMIT 1
+	`, merchantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("latest receiver change: %w", err)
+	}
+	return ch, nil
+}
+
+func (r *MerchantRepo) ListReceiverChanges(ctx context.Context, merchantID []byte) ([]domain.ReceiverChange, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+receiverChangeColumns+`
+		FROM merchant_receiver_changes
+		WHERE merchant_id = $1
+		ORDER BY requested_at DESC, id DESC
+	`, merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("list receiver changes: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.ReceiverChange{}
+	for rows.Next() {
+		ch, err := scanReceiverChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list receiver changes: %w", err)
+		}
+		out = append(out, *ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list receiver changes: %w", err)
+	}
+	return out, nil
+}
+
+func (r *MerchantRepo) RequestReceiverChange(ctx context.Context, ch *domain.ReceiverChange, outbox ...domain.OutboxMessage) (bool, error) {
+	requested := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		// merchants_wallet_uidx would only catch this once the rotation is
+		// confirmed, after the chain has already moved.
+		var inUse bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+			  SELECT 1 FROM merchants WHERE wallet_address = $2 AND merchant_id <> $1
+			) OR EXISTS (
+			  SELECT 1 FROM merchant_receiver_changes
+			  WHERE new_receiver = $2 AND merchant_id <> $1 AND status = 'PENDING'
+			)
+		`, ch.MerchantID, ch.NewReceiver).Scan(&inUse)
+		if err != nil {
+			return fmt.Errorf("check receiver in use: %w", err)
+		}
+		if inUse {
+			return domain.ErrWalletInUse
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO merchant_receiver_changes (
+				merchant_id, old_receiver, new_receiver, status, requested_by, requested_at
+			)
+			VALUES ($1, $2, $3, 'PENDING', NULLIF($4, '')::uuid, $5)
+			ON CONFLICT (merchant_id) WHERE status = 'PENDING' DO NOTHING
+			RETURNING id
+		`, ch.MerchantID, ch.OldReceiver, ch.NewReceiver, ch.RequestedBy, ch.RequestedAt).Scan(&ch.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("request receiver change: %w", err)
+		}
+
+		requested = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	return requested, err
+}
+
+// SetReceiverTxID ignores any ambient transaction, like SetChainTxID.
+func (r *MerchantRepo) SetReceiverTxID(ctx context.Context, changeID int64, txid string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchant_receiver_changes
+		SET txid = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $1 AND status = 'PENDING'
+	`, changeID, txid)
+	if err != nil {
+		return fmt.Errorf("set receiver txid: %w", err)
+	}
+	return nil
+}
+
+func (r *MerchantRepo) ConfirmReceiverChange(ctx context.Context, changeID int64, at time.Time, outbox ...domain.OutboxMessage) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var (
+			merchantID []byte
+			receiver   string
+		)
+		err := tx.QueryRowContext(ctx, `
+			UPDATE merchant_receiver_changes
+			SET status = 'CONFIRMED', confirmed_at = $2, updated_at = NOW()
+			WHERE id = $1 AND status = 'PENDING'
+			RETURNING merchant_id, new_receiver
+		`, changeID, at).Scan(&merchantID, &receiver)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("confirm receiver change: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE merchants
+			SET wallet_address = $2, updated_at = NOW()
+			WHERE merchant_id = $1
+		`, merchantID, receiver)
+		if err != nil {
+			return fmt.Errorf("update merchant wallet: %w", err)
+		}
+		return insertOutbox(ctx, tx, outbox...)
+	})
+}
+
+// FailReceiverChange ignores any ambient transaction, like FailOnboarding.
+func (r *MerchantRepo) FailReceiverChange(ctx context.Context, changeID int64, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchant_receiver_changes
+		SET status = 'FAILED', txid = NULL, failed_at = NOW(), error = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'PENDING'
+	`, changeID, reason)
+	if err != nil {
+		return fmt.Errorf("fail receiver change: %w", err)
+	}
+	return nil
+}
+
+func scanReceiverChange(s rowScanner) (*domain.ReceiverChange, error) {
+	var (
+		ch          domain.ReceiverChange
+		status      string
+		txid        sql.NullString
+		requestedBy sql.NullString
+		confirmedAt sql.NullTime
+		failedAt    sql.NullTime
+		failure     sql.NullString
+	)
+	err := s.Scan(
+		&ch.ID,
+		&ch.MerchantID,
+		&ch.OldReceiver,
+		&ch.NewReceiver,
+		&status,
+		&txid,
+		&requestedBy,
+		&ch.RequestedAt,
+		&confirmedAt,
+		&failedAt,
+		&failure,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.Status = domain.ReceiverChangeStatus(status)
+	ch.TxID = txid.String
+	ch.RequestedBy = requestedBy.String
+	if confirmedAt.Valid {
+		t := confirmedAt.Time
+		ch.ConfirmedAt = &t
+	}
+	if failedAt.Valid {
+		t := failedAt.Time
+		ch.FailedAt = &t
+	}
+	ch.Error = failure.String
+	return &ch, nil
+}
+
+const merchantTokenColumns = `merchant_id, token_address, enabled,
+	requested_enabled, txid, requested_at, failed_at, error, updated_at`
+
+func (r *MerchantRepo) GetToken(ctx context.Context, merchantID []byte, token domain.TronAddress) (*domain.MerchantToken, error) {
+	t, err := scanMerchantToken(conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+merchantTokenColumns+`
+		FROM merchant_tokens
+		WHERE merchant_id = $1 AND token_address = $2
+	`, merchantID, token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get merchant token: %w", err)
+	}
+	return t, nil
+}
+
+func (r *MerchantRepo) ListTokens(ctx context.Context, merchantID []byte) ([]domain.MerchantToken, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+merchantTokenColumns+`
+		FROM merchant_tokens
+		WHERE merchant_id = $1
+		ORDER BY created_at, token_address
+	`, merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("list merchant tokens: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.MerchantToken{}
+	for rows.Next() {
+		t, err := scanMerchantToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list merchant tokens: %w", err)
+		}
+		out = append(out, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list merchant tokens: %w", err)
+	}
+	return out, nil
+}
+
+func (r *MerchantRepo) RequestToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool, requestedBy string, at time.Time, outbox ...domain.OutboxMessage) (bool, error) {
+	requested := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO merchant_tokens (
+				merchant_id, token_address, requested_enabled, requested_by, requested_at
+			)
+			VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5)
+			ON CONFLICT (merchant_id, token_address) DO UPDATE
+			SET requested_enabled = EXCLUDED.requested_enabled,
+			    requested_by = EXCLUDED.requested_by,
+			    requested_at = EXCLUDED.requested_at,
+			    txid = NULL,
+			    failed_at = NULL,
+			    error = NULL,
+			    updated_at = NOW()
+			WHERE merchant_tokens.requested_enabled IS NULL
+		`, merchantID, token, enabled, requestedBy, at)
+		if err != nil {
+			return fmt.Errorf("request merchant token: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+
+		requested = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	return requested, err
+}
+
+// SetTokenTxID ignores any ambient transaction, like SetChainTxID.
+func (r *MerchantRepo) SetTokenTxID(ctx context.Context, merchantID []byte, token domain.TronAddress, txid string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchant_tokens
+		SET txid = NULLIF($3, ''), updated_at = NOW()
+		WHERE merchant_id = $1 AND token_address = $2 AND requested_enabled IS NOT NULL
+	`, merchantID, token, txid)
+	if err != nil {
+		return fmt.Errorf("set token txid: %w", err)
+	}
+	return nil
+}
+
+func (r *MerchantRepo) ConfirmToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool, outbox ...domain.OutboxMessage) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE merchant_tokens
+			SET enabled = $3,
+			    requested_enabled = NULL,
+			    txid = NULL,
+			    requested_by = NULL,
+			    requested_at = NULL,
+			    updated_at = NOW()
+			WHERE merchant_id = $1 AND token_address = $2 AND requested_enabled IS NOT NULL
+		`, merchantID, token, enabled)
+		if err != nil {
+			return fmt.Errorf("confirm merchant token: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return insertOutbox(ctx, tx, outbox...)
+	})
+}
+
+// FailToken ignores any ambient transaction, like FailOnboarding.
+func (r *MerchantRepo) FailToken(ctx context.Context, merchantID []byte, token domain.TronAddress, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE merchant_tokens
+		SET requested_enabled = NULL,
+		    txid = NULL,
+		    requested_by = NULL,
+		    requested_at = NULL,
+		    failed_at = NOW(),
+		    error = $3,
+		    updated_at = NOW()
+		WHERE merchant_id = $1 AND token_address = $2 AND requested_enabled IS NOT NULL
+	`, merchantID, token, reason)
+	if err != nil {
+		return fmt.Errorf("fail merchant token: %w", err)
+	}
+	return nil
+}
+
+func scanMerchantToken(s rowScanner) (*domain.MerchantToken, error) {
+	var (
+		t           domain.MerchantToken
+		requested   sql.NullBool
+		txid        sql.NullString
+		requestedAt sql.NullTime
+		failedAt    sql.NullTime
+		failure     sql.NullString
+	)
+	err := s.Scan(
+		&t.MerchantID,
+		&t.Token,
+		&t.Enabled,
+		&requested,
+		&txid,
+		&requestedAt,
+		&failedAt,
+		&failure,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if requested.Valid {
+		v := requested.Bool
+		t.RequestedEnabled = &v
+	}
+	t.TxID = txid.String
+	if requestedAt.Valid {
+		at := requestedAt.Time
+		t.RequestedAt = &at
+	}
+	if failedAt.Valid {
+		at := failedAt.Time
+		t.FailedAt = &at
+	}
+	t.Error = failure.String
+	return &t, nil
+}
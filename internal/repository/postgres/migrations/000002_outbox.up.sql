@@ -0,0 +1,26 @@
+-- =====================================================
+-- 002_outbox.sql
+-- Transactional outbox: events are written in the same
+-- transaction as the rows they describe and relayed to
+-- RabbitMQ afterwards.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id                 BIGSERIAL PRIMARY KEY,
+
+  routing_key        TEXT NOT NULL,
+  payload            JSONB NOT NULL,
+
+  attempts           INT NOT NULL DEFAULT 0,
+  last_error         TEXT,
+  next_attempt_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  dispatched_at      TIMESTAMPTZ,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Relay scans only undispatched rows that are due
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+  ON outbox (next_attempt_at, id)
+  WHERE dispatched_at IS NULL;
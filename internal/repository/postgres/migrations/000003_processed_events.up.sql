@@ -0,0 +1,18 @@
+-- =====================================================
+-- 003_processed_events.sql
+-- Ledger of consumed events, written in the same
+-- transaction as the consumer's own DB work so
+-- redeliveries are skipped exactly once.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS processed_events (
+  event_id       TEXT NOT NULL,
+  consumer       TEXT NOT NULL,
+
+  processed_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (event_id, consumer)
+);
+
+CREATE INDEX IF NOT EXISTS processed_events_processed_at_idx
+  ON processed_events (processed_at);
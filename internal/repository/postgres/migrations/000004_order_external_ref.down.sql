@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS orders_merchant_external_ref_uidx;
+
+ALTER TABLE orders
+  DROP COLUMN IF EXISTS external_ref;
@@ -0,0 +1,13 @@
+-- =====================================================
+-- 004_order_external_ref.sql
+-- Merchant-supplied reference for an order (their
+-- cart / invoice number), unique per merchant so a
+-- retried create cannot open a second order.
+-- =====================================================
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS external_ref TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS orders_merchant_external_ref_uidx
+  ON orders (merchant_id, external_ref)
+  WHERE external_ref IS NOT NULL;
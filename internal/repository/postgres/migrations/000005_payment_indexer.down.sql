@@ -0,0 +1,7 @@
+ALTER TABLE payments
+  DROP COLUMN IF EXISTS paid_at,
+  DROP COLUMN IF EXISTS log_index,
+  DROP COLUMN IF EXISTS block_number,
+  DROP COLUMN IF EXISTS amount_raw;
+
+DROP TABLE IF EXISTS chain_cursors;
@@ -0,0 +1,18 @@
+-- =====================================================
+-- 005_payment_indexer.sql
+-- Block cursor for the PaymentDetected indexer and the
+-- on-chain position of every detected payment.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS chain_cursors (
+  name           TEXT PRIMARY KEY,
+  block_number   BIGINT NOT NULL,
+
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE payments
+  ADD COLUMN IF NOT EXISTS amount_raw    NUMERIC(78,0),  -- uint256 base units
+  ADD COLUMN IF NOT EXISTS block_number  BIGINT,
+  ADD COLUMN IF NOT EXISTS log_index     INT,
+  ADD COLUMN IF NOT EXISTS paid_at       TIMESTAMPTZ;
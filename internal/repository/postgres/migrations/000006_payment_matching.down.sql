@@ -0,0 +1,8 @@
+ALTER TABLE orders
+  DROP CONSTRAINT IF EXISTS orders_settlement_check,
+  DROP COLUMN IF EXISTS settlement,
+  DROP COLUMN IF EXISTS amount_paid;
+
+ALTER TABLE payments
+  DROP CONSTRAINT IF EXISTS payments_match_status_check,
+  DROP COLUMN IF EXISTS match_status;
@@ -0,0 +1,16 @@
+-- =====================================================
+-- 006_payment_matching.sql
+-- Outcome of matching confirmed payments against the
+-- amount an order owes.
+-- =====================================================
+
+ALTER TABLE payments
+  ADD COLUMN IF NOT EXISTS match_status TEXT,
+  ADD CONSTRAINT payments_match_status_check
+    CHECK (match_status IN ('EXACT','PARTIAL','OVERPAID','WRONG_TOKEN'));
+
+ALTER TABLE orders
+  ADD COLUMN IF NOT EXISTS amount_paid NUMERIC(36,18) NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS settlement  TEXT NOT NULL DEFAULT 'UNPAID',
+  ADD CONSTRAINT orders_settlement_check
+    CHECK (settlement IN ('UNPAID','PARTIAL','PAID','OVERPAID'));
@@ -0,0 +1,5 @@
+ALTER TABLE merchants
+  DROP CONSTRAINT IF EXISTS merchants_requested_status_check,
+  DROP COLUMN IF EXISTS status_requested_at,
+  DROP COLUMN IF EXISTS status_txid,
+  DROP COLUMN IF EXISTS requested_status;
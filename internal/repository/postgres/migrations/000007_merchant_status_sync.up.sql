@@ -0,0 +1,13 @@
+-- =====================================================
+-- 007_merchant_status_sync.sql
+-- Admin-requested ACTIVE/INACTIVE changes waiting to be
+-- applied in MerchantRegistryV1. status only changes once
+-- the chain has confirmed.
+-- =====================================================
+
+ALTER TABLE merchants
+  ADD COLUMN IF NOT EXISTS requested_status    TEXT,
+  ADD COLUMN IF NOT EXISTS status_txid         TEXT,
+  ADD COLUMN IF NOT EXISTS status_requested_at TIMESTAMPTZ,
+  ADD CONSTRAINT merchants_requested_status_check
+    CHECK (requested_status IN ('ACTIVE','INACTIVE'));
@@ -0,0 +1,35 @@
+-- =====================================================
+-- 008_merchant_receiver_changes.sql
+-- History of fund-receiver rotations. merchants.wallet_address
+-- only changes once MerchantRegistryV1 has confirmed.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS merchant_receiver_changes (
+  id             BIGSERIAL PRIMARY KEY,
+
+  merchant_id    BYTEA NOT NULL REFERENCES merchants(merchant_id),
+
+  old_receiver   TEXT NOT NULL,
+  new_receiver   TEXT NOT NULL,
+
+  status         TEXT NOT NULL DEFAULT 'PENDING',
+  txid           TEXT,
+
+  requested_by   UUID,
+  requested_at   TIMESTAMPTZ NOT NULL,
+  confirmed_at   TIMESTAMPTZ,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT merchant_receiver_changes_status_check
+    CHECK (status IN ('PENDING','CONFIRMED'))
+);
+
+-- At most one rotation in flight per merchant.
+CREATE UNIQUE INDEX IF NOT EXISTS merchant_receiver_changes_pending_uidx
+  ON merchant_receiver_changes (merchant_id)
+  WHERE status = 'PENDING';
+
+CREATE INDEX IF NOT EXISTS merchant_receiver_changes_merchant_idx
+  ON merchant_receiver_changes (merchant_id, requested_at DESC);
@@ -0,0 +1,23 @@
+-- =====================================================
+-- 009_merchant_tokens.sql
+-- TRC-20 tokens each merchant accepts, mirrored from
+-- MerchantRegistryV1. enabled only changes once the chain
+-- has confirmed.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS merchant_tokens (
+  merchant_id        BYTEA NOT NULL REFERENCES merchants(merchant_id),
+  token_address      TEXT NOT NULL,
+
+  enabled            BOOLEAN NOT NULL DEFAULT FALSE,
+
+  requested_enabled  BOOLEAN,
+  txid               TEXT,
+  requested_by       UUID,
+  requested_at       TIMESTAMPTZ,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (merchant_id, token_address)
+);
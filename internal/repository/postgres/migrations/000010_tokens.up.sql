@@ -0,0 +1,28 @@
+-- =====================================================
+-- 010_tokens.sql
+-- TRC-20 metadata read from chain (decimals, symbol,
+-- name), cached so it is fetched once per token. allowed
+-- marks the tokens orders may be priced in.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS tokens (
+  address      TEXT PRIMARY KEY,          -- Tron base58
+
+  symbol       TEXT NOT NULL,
+  name         TEXT NOT NULL,
+  decimals     INT NOT NULL,
+
+  allowed      BOOLEAN NOT NULL DEFAULT FALSE,
+
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  -- Beyond 18 a single base unit does not fit NUMERIC(36,18).
+  CONSTRAINT tokens_decimals_check
+    CHECK (decimals BETWEEN 0 AND 18)
+);
+
+-- Orders pick tokens by symbol: it must be unambiguous.
+CREATE UNIQUE INDEX IF NOT EXISTS tokens_allowed_symbol_uidx
+  ON tokens (UPPER(symbol))
+  WHERE allowed;
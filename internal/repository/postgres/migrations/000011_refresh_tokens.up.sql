@@ -0,0 +1,35 @@
+-- =====================================================
+-- 011_refresh_tokens.sql
+-- Rotating refresh tokens. Only a SHA-256 of each token is
+-- stored; every refresh replaces the token with a successor
+-- in the same family (one login session), and presenting a
+-- rotated token again revokes the whole family.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id             BIGSERIAL PRIMARY KEY,
+
+  token_hash     BYTEA NOT NULL,
+  family_id      UUID NOT NULL DEFAULT gen_random_uuid(),
+  user_uid       UUID NOT NULL REFERENCES users(user_uid) ON DELETE CASCADE,
+
+  expires_at     TIMESTAMPTZ NOT NULL,
+  rotated_at     TIMESTAMPTZ,
+  revoked_at     TIMESTAMPTZ,
+  revoke_reason  TEXT,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT refresh_tokens_revoke_reason_check
+    CHECK (revoke_reason IN ('LOGOUT','LOGOUT_ALL','REUSE'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_tokens_hash_uidx
+  ON refresh_tokens (token_hash);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx
+  ON refresh_tokens (family_id);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_active_idx
+  ON refresh_tokens (user_uid)
+  WHERE revoked_at IS NULL;
@@ -0,0 +1,32 @@
+-- =====================================================
+-- 012_api_keys.sql
+-- Merchant API keys for server-to-server calls. A key is
+-- "tk_<prefix>_<secret>": the prefix is stored in clear to
+-- find the key, the secret only as a SHA-256.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id            BIGSERIAL PRIMARY KEY,
+  merchant_id   BYTEA NOT NULL REFERENCES merchants(merchant_id) ON DELETE CASCADE,
+
+  name          TEXT NOT NULL,
+  prefix        TEXT NOT NULL,
+  secret_hash   BYTEA NOT NULL,
+
+  scopes        TEXT[] NOT NULL,
+  allowed_ips   TEXT[] NOT NULL DEFAULT '{}', -- CIDRs; empty = any address
+
+  created_by    UUID REFERENCES users(user_uid) ON DELETE SET NULL,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_used_at  TIMESTAMPTZ,
+  revoked_at    TIMESTAMPTZ,
+
+  CONSTRAINT api_keys_scopes_check
+    CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['orders:write','payments:read'])
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_prefix_uidx
+  ON api_keys (prefix);
+
+CREATE INDEX IF NOT EXISTS api_keys_merchant_idx
+  ON api_keys (merchant_id, created_at DESC);
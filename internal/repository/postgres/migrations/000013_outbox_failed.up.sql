@@ -0,0 +1,14 @@
+-- =====================================================
+-- 013_outbox_failed.sql
+-- Park outbox rows retrying cannot deliver (unroutable
+-- after several attempts, malformed payload) instead of
+-- retrying them forever.
+-- =====================================================
+
+ALTER TABLE outbox
+  ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+  ON outbox (next_attempt_at, id)
+  WHERE dispatched_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1,5 @@
+DELETE FROM processed_events WHERE status = 'processing';
+
+ALTER TABLE processed_events
+  DROP COLUMN IF EXISTS claimed_until,
+  DROP COLUMN IF EXISTS status;
@@ -0,0 +1,12 @@
+-- =====================================================
+-- 014_processed_events_claims.sql
+-- Claim events in a short transaction of their own
+-- ('processing' with a lease) and mark them 'done'
+-- together with the handler's writes, so chain calls no
+-- longer run inside an open transaction.
+-- =====================================================
+
+ALTER TABLE processed_events
+  ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'done'
+    CHECK (status IN ('processing', 'done')),
+  ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
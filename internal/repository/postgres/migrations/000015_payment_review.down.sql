@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS payments_review_idx;
+
+ALTER TABLE payments
+  DROP COLUMN IF EXISTS review_at,
+  DROP COLUMN IF EXISTS review_reason;
@@ -0,0 +1,15 @@
+-- =====================================================
+-- 015_payment_review.sql
+-- Payments the tracker cannot confirm for a reason
+-- retrying will not fix (e.g. an order in a token that
+-- is no longer supported) are held for manual review
+-- instead of blocking every later payment.
+-- =====================================================
+
+ALTER TABLE payments
+  ADD COLUMN IF NOT EXISTS review_reason TEXT,
+  ADD COLUMN IF NOT EXISTS review_at     TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS payments_review_idx
+  ON payments (review_at)
+  WHERE review_at IS NOT NULL;
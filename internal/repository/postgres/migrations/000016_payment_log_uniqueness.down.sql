@@ -0,0 +1,7 @@
+-- Fails if a transaction has since recorded more than one payment.
+DROP INDEX IF EXISTS payments_tx_hash_idx;
+DROP INDEX IF EXISTS payments_tx_log_uidx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS payments_tx_hash_uidx
+  ON payments (tx_hash)
+  WHERE tx_hash IS NOT NULL;
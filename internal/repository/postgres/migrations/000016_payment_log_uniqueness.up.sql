@@ -0,0 +1,15 @@
+-- =====================================================
+-- 016_payment_log_uniqueness.sql
+-- A payment is one PaymentDetected log, not one
+-- transaction: a tx that pays several orders emits one
+-- log per order, and each must be recorded.
+-- =====================================================
+
+DROP INDEX IF EXISTS payments_tx_hash_uidx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS payments_tx_log_uidx
+  ON payments (tx_hash, log_index)
+  WHERE tx_hash IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS payments_tx_hash_idx
+  ON payments (tx_hash);
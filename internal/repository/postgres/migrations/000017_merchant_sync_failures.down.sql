@@ -0,0 +1,5 @@
+ALTER TABLE merchants
+  DROP COLUMN IF EXISTS status_error,
+  DROP COLUMN IF EXISTS status_failed_at,
+  DROP COLUMN IF EXISTS chain_error,
+  DROP COLUMN IF EXISTS chain_failed_at;
@@ -0,0 +1,13 @@
+-- =====================================================
+-- 017_merchant_sync_failures.sql
+-- Onboarding and status changes the worker gave up on
+-- (permanent error or retries used up). The pending
+-- columns are cleared so the change can be retried
+-- instead of staying pending forever.
+-- =====================================================
+
+ALTER TABLE merchants
+  ADD COLUMN IF NOT EXISTS chain_failed_at   TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS chain_error       TEXT,
+  ADD COLUMN IF NOT EXISTS status_failed_at  TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS status_error      TEXT;
@@ -0,0 +1,8 @@
+DELETE FROM merchant_receiver_changes WHERE status = 'FAILED';
+
+ALTER TABLE merchant_receiver_changes
+  DROP CONSTRAINT IF EXISTS merchant_receiver_changes_status_check,
+  ADD CONSTRAINT merchant_receiver_changes_status_check
+    CHECK (status IN ('PENDING','CONFIRMED')),
+  DROP COLUMN IF EXISTS error,
+  DROP COLUMN IF EXISTS failed_at;
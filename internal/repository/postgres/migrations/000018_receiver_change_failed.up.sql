@@ -0,0 +1,13 @@
+-- =====================================================
+-- 018_receiver_change_failed.sql
+-- Rotations the worker gave up on are FAILED (with the
+-- reason) instead of staying PENDING, which would block
+-- every later rotation.
+-- =====================================================
+
+ALTER TABLE merchant_receiver_changes
+  ADD COLUMN IF NOT EXISTS failed_at  TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS error      TEXT,
+  DROP CONSTRAINT IF EXISTS merchant_receiver_changes_status_check,
+  ADD CONSTRAINT merchant_receiver_changes_status_check
+    CHECK (status IN ('PENDING','CONFIRMED','FAILED'));
@@ -0,0 +1,3 @@
+ALTER TABLE merchant_tokens
+  DROP COLUMN IF EXISTS error,
+  DROP COLUMN IF EXISTS failed_at;
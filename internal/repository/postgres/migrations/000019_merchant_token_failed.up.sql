@@ -0,0 +1,10 @@
+-- =====================================================
+-- 019_merchant_token_failed.sql
+-- Token changes the worker gave up on are cleared, with
+-- the reason kept, instead of staying pending and
+-- blocking every later change of that token.
+-- =====================================================
+
+ALTER TABLE merchant_tokens
+  ADD COLUMN IF NOT EXISTS failed_at  TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS error      TEXT;
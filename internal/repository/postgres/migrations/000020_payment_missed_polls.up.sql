@@ -0,0 +1,11 @@
+-- =====================================================
+-- 020_payment_missed_polls.sql
+-- A payment whose tx the tracker cannot find is only
+-- reverted after several polls in a row miss it, so a
+-- lagging node does not fail a good payment. The misses
+-- are counted on the payment.
+-- =====================================================
+
+ALTER TABLE payments
+  ADD COLUMN IF NOT EXISTS missed_polls   INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS last_missed_at TIMESTAMPTZ;
@@ -0,0 +1,38 @@
+-- =====================================================
+-- 021_unmatched_payments.sql
+-- PaymentDetected logs for an order the backend never
+-- issued cannot reference orders, so they are kept here
+-- for manual review instead of being dropped: the funds
+-- did arrive on chain.
+-- =====================================================
+
+CREATE TABLE IF NOT EXISTS unmatched_payments (
+  id                  BIGSERIAL PRIMARY KEY,
+
+  order_id            BYTEA NOT NULL,
+  invoice_id          BYTEA NOT NULL,
+  merchant_id         BYTEA NOT NULL,
+
+  token_address       TEXT,
+  payer_address       TEXT,
+
+  amount              NUMERIC(36,18) NOT NULL,
+  amount_raw          NUMERIC(78,0) NOT NULL,  -- uint256 base units
+  currency            TEXT NOT NULL,
+
+  tx_hash             TEXT NOT NULL,
+  log_index           INT NOT NULL,
+  block_number        BIGINT NOT NULL,
+  paid_at             TIMESTAMPTZ,
+
+  review_reason       TEXT NOT NULL,
+  review_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unmatched_payments_tx_log_uidx
+  ON unmatched_payments (tx_hash, log_index);
+
+CREATE INDEX IF NOT EXISTS unmatched_payments_merchant_idx
+  ON unmatched_payments (merchant_id);
@@ -0,0 +1,9 @@
+-- =====================================================
+-- 022_outbox_claims.sql
+-- Relays claim outbox rows with a lease instead of holding
+-- row locks across broker publishes. A claim left behind by
+-- a crashed relay is taken over once it lapses.
+-- =====================================================
+
+ALTER TABLE outbox
+  ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
@@ -0,0 +1,6 @@
+-- Normalised addresses are left in base58check form.
+ALTER TABLE merchant_receiver_changes
+  DROP CONSTRAINT IF EXISTS merchant_receiver_changes_address_check;
+
+ALTER TABLE merchants
+  DROP CONSTRAINT IF EXISTS merchants_wallet_address_check;
@@ -0,0 +1,114 @@
+-- =====================================================
+-- 023_merchant_address_check.sql
+-- Wallet and receiver addresses are read back through
+-- domain.TronAddress, which rejects anything but a valid
+-- base58check or 41-prefixed hex address. Rows written
+-- before that check are normalised to base58check; rows
+-- that are not a Tron address at all stop the migration
+-- with their merchant ids, to be corrected by hand.
+-- =====================================================
+
+-- base58check text of a 21-byte address.
+CREATE FUNCTION pg_temp.tron_base58check(raw BYTEA) RETURNS TEXT AS $$
+DECLARE
+  alphabet CONSTANT TEXT := '123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz';
+  payload  BYTEA := raw || substring(sha256(sha256(raw)) FROM 1 FOR 4);
+  n        NUMERIC := 0;
+  encoded  TEXT := '';
+BEGIN
+  FOR i IN 0 .. length(payload) - 1 LOOP
+    n := n * 256 + get_byte(payload, i);
+  END LOOP;
+  WHILE n > 0 LOOP
+    encoded := substr(alphabet, (n % 58)::INT + 1, 1) || encoded;
+    n := div(n, 58);
+  END LOOP;
+  RETURN encoded;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- The canonical form of a stored address, or NULL if it is not one.
+CREATE FUNCTION pg_temp.tron_address(s TEXT) RETURNS TEXT AS $$
+DECLARE
+  alphabet CONSTANT TEXT := '123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz';
+  n        NUMERIC := 0;
+  raw      BYTEA := ''::BYTEA;
+BEGIN
+  s := btrim(s, E' \t\r\n');
+  IF s ~ '^41[0-9A-Fa-f]{40}$' THEN
+    RETURN pg_temp.tron_base58check(decode(s, 'hex'));
+  END IF;
+  IF s !~ '^T[1-9A-HJ-NP-Za-km-z]{33}$' THEN
+    RETURN NULL;
+  END IF;
+
+  FOR i IN 1 .. length(s) LOOP
+    n := n * 58 + strpos(alphabet, substr(s, i, 1)) - 1;
+  END LOOP;
+  WHILE n > 0 LOOP
+    raw := decode(lpad(to_hex((n % 256)::INT), 2, '0'), 'hex') || raw;
+    n := div(n, 256);
+  END LOOP;
+
+  IF length(raw) <> 25 OR get_byte(raw, 0) <> 65
+     OR pg_temp.tron_base58check(substring(raw FROM 1 FOR 21)) <> s THEN
+    RETURN NULL;
+  END IF;
+  RETURN s;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+DO $$
+DECLARE
+  bad TEXT;
+BEGIN
+  SELECT string_agg(DISTINCT encode(merchant_id, 'hex'), ', ') INTO bad
+  FROM (
+    SELECT merchant_id FROM merchants
+    WHERE pg_temp.tron_address(wallet_address) IS NULL
+    UNION ALL
+    SELECT merchant_id FROM merchant_receiver_changes
+    WHERE pg_temp.tron_address(old_receiver) IS NULL
+       OR pg_temp.tron_address(new_receiver) IS NULL
+  ) invalid;
+
+  IF bad IS NOT NULL THEN
+    RAISE EXCEPTION 'invalid tron wallet or receiver address for merchants: %', bad
+      USING HINT = 'Correct merchants.wallet_address and merchant_receiver_changes.old_receiver/new_receiver, then re-run the migration.';
+  END IF;
+
+  -- The same wallet stored in two forms would break merchants_wallet_uidx.
+  SELECT string_agg(encode(merchant_id, 'hex'), ', ') INTO bad
+  FROM (
+    SELECT merchant_id,
+           count(*) OVER (PARTITION BY pg_temp.tron_address(wallet_address)) AS n
+    FROM merchants
+  ) shared
+  WHERE n > 1;
+
+  IF bad IS NOT NULL THEN
+    RAISE EXCEPTION 'merchants share a tron wallet address: %', bad;
+  END IF;
+END
+$$;
+
+UPDATE merchants
+SET wallet_address = pg_temp.tron_address(wallet_address), updated_at = NOW()
+WHERE wallet_address <> pg_temp.tron_address(wallet_address);
+
+UPDATE merchant_receiver_changes
+SET old_receiver = pg_temp.tron_address(old_receiver),
+    new_receiver = pg_temp.tron_address(new_receiver),
+    updated_at = NOW()
+WHERE old_receiver <> pg_temp.tron_address(old_receiver)
+   OR new_receiver <> pg_temp.tron_address(new_receiver);
+
+-- The checksum is verified by the application; the shape is enforced here.
+ALTER TABLE merchants
+  ADD CONSTRAINT merchants_wallet_address_check
+    CHECK (wallet_address ~ '^T[1-9A-HJ-NP-Za-km-z]{33}$');
+
+ALTER TABLE merchant_receiver_changes
+  ADD CONSTRAINT merchant_receiver_changes_address_check
+    CHECK (old_receiver ~ '^T[1-9A-HJ-NP-Za-km-z]{33}$'
+       AND new_receiver ~ '^T[1-9A-HJ-NP-Za-km-z]{33}$');
@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type OrderRepo struct {
+	db *sql.DB
+}
+
+func NewOrderRepo(db *sql.DB) *OrderRepo {
+	return &OrderRepo{db: db}
+}
+
+const orderColumns = `order_id, invoice_id, merchant_id, amount::text, currency, token_address, external_ref, payment_status, amount_paid::text, settlement, created_at, updated_at`
+
+func (r *OrderRepo) Create(ctx context.Context, o *domain.Order) error {
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO orders (order_id, invoice_id, merchant_id, amount, currency, token_address, external_ref, payment_status)
+		VALUES ($1, $2, $3, $4::numeric, $5, NULLIF($6, ''), NULLIF($7, ''), $8)
+		RETURNING created_at, updated_at
+	`, o.OrderID, o.InvoiceID, o.MerchantID, o.Amount, o.Currency, o.TokenAddress, o.ExternalRef, string(o.PaymentStatus),
+	).Scan(&o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "orders_merchant_external_ref_uidx") {
+			return domain.ErrExternalRefExists
+		}
+		return fmt.Errorf("create order: %w", mapSQLError(err))
+	}
+	return nil
+}
+
+func (r *OrderRepo) GetByOrderID(ctx context.Context, merchantID, orderID []byte) (*domain.Order, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE merchant_id = $1 AND order_id = $2
+	`, merchantID, orderID)
+
+	o, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get order: %w", err)
+	}
+	return o, nil
+}
+
+func (r *OrderRepo) ListByMerchant(ctx context.Context, merchantID []byte, limit, offset int) ([]domain.Order, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE merchant_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`, merchantID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list orders: %w", err)
+		}
+		out = append(out, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	return out, nil
+}
+
+// GetForUpdate locks the order row for the rest of the caller's
+// transaction, serialising settlement of concurrent payments.
+func (r *OrderRepo) GetForUpdate(ctx context.Context, orderID []byte) (*domain.Order, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE order_id = $1
+		FOR UPDATE
+	`, orderID)
+
+	o, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock order: %w", err)
+	}
+	return o, nil
+}
+
+func (r *OrderRepo) SetSettlement(ctx context.Context, orderID []byte, amountPaid string, settlement domain.Settlement, status domain.PaymentStatus) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE orders
+		SET amount_paid = $2::numeric, settlement = $3, payment_status = $4, updated_at = NOW()
+		WHERE order_id = $1
+	`, orderID, amountPaid, string(settlement), string(status))
+	if err != nil {
+		return fmt.Errorf("set order settlement: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(s rowScanner) (*domain.Order, error) {
+	var (
+		o           domain.Order
+		status      string
+		settlement  string
+		token       sql.NullString
+		externalRef sql.NullString
+	)
+	err := s.Scan(
+		&o.OrderID,
+		&o.InvoiceID,
+		&o.MerchantID,
+		&o.Amount,
+		&o.Currency,
+		&token,
+		&externalRef,
+		&status,
+		&o.AmountPaid,
+		&settlement,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Amount = trimNumeric(o.Amount)
+	o.TokenAddress = token.String
+	o.ExternalRef = externalRef.String
+	o.PaymentStatus = domain.PaymentStatus(status)
+	o.AmountPaid = trimNumeric(o.AmountPaid)
+	o.Settlement = domain.Settlement(settlement)
+	return &o, nil
+}
+
+// trimNumeric drops the trailing zeros NUMERIC(36,18) pads values with.
+func trimNumeric(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
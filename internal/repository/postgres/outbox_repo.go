@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// markTimeout bounds recording a publish outcome.
+const markTimeout = 5 * time.Second
+
+type OutboxRepo struct {
+	db *sql.DB
+
+	// Lease bounds how long a claimed batch is withheld from other relays.
+	// A batch left behind by a crashed relay is claimed again once it
+	// lapses; publishing stops when it runs out, so it should cover a
+	// batch of slow broker confirms.
+	Lease time.Duration
+}
+
+func NewOutboxRepo(db *sql.DB) *OutboxRepo {
+	return &OutboxRepo{db: db, Lease: 2 * time.Minute}
+}
+
+// insertOutbox writes messages inside the caller's transaction, so they
+// commit or roll back together with the business rows.
+func insertOutbox(ctx context.Context, tx *sql.Tx, msgs ...domain.OutboxMessage) error {
+	for _, m := range msgs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO outbox (routing_key, payload)
+			VALUES ($1, $2)
+		`, m.RoutingKey, []byte(m.Payload))
+		if err != nil {
+			return fmt.Errorf("insert outbox %s: %w", m.RoutingKey, err)
+		}
+	}
+	return nil
+}
+
+// Enqueue writes msgs in a transaction of its own (ports.Outbox).
+func (r *OutboxRepo) Enqueue(ctx context.Context, msgs ...domain.OutboxMessage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertOutbox(ctx, tx, msgs...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Dispatch claims up to limit due messages, hands each one to publish and
+// records the outcome. The claim is a single statement that leases the
+// rows; publishing happens outside any transaction and each row is marked
+// on its own, so a slow broker holds no locks and one failed mark does not
+// undo the others. Rows claimed by another relay are skipped, so several
+// API replicas can run the relay concurrently. Rows still unpublished when
+// the lease runs out are left for the next claim.
+// backoff maps the new attempt count to the delay before the next try.
+// Failures wrapping domain.ErrOutboxDead park the row instead.
+func (r *OutboxRepo) Dispatch(
+	ctx context.Context,
+	limit int,
+	publish func(ctx context.Context, m domain.OutboxMessage) error,
+	backoff func(attempts int) time.Duration,
+) (int, error) {
+	leased := time.Now().Add(r.Lease)
+	batch, err := r.claim(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, m := range batch {
+		if time.Now().After(leased) {
+			break
+		}
+		if perr := publish(ctx, m); perr != nil {
+			errs = append(errs, r.markFailed(ctx, m, perr, backoff))
+			continue
+		}
+		errs = append(errs, r.markDispatched(ctx, m))
+	}
+	return len(batch), errors.Join(errs...)
+}
+
+// claim leases due rows that no live claim holds and returns them in id
+// order.
+func (r *OutboxRepo) claim(ctx context.Context, limit int) ([]domain.OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE outbox
+		SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id
+			FROM outbox
+			WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+			  AND (claimed_until IS NULL OR claimed_until < NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, routing_key, payload, attempts
+	`, limit, r.Lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []domain.OutboxMessage
+	for rows.Next() {
+		var m domain.OutboxMessage
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.RoutingKey, &payload, &m.Attempts); err != nil {
+			return nil, fmt.Errorf("scan outbox: %w", err)
+		}
+		m.Payload = payload
+		batch = append(batch, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].ID < batch[j].ID })
+	return batch, nil
+}
+
+// markDispatched records a confirmed publish. It runs even when ctx was
+// cancelled by shutdown, so a message the broker already has is not sent
+// again once the lease lapses.
+func (r *OutboxRepo) markDispatched(ctx context.Context, m domain.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), markTimeout)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = NULL, dispatched_at = NOW(), claimed_until = NULL
+		WHERE id = $1
+	`, m.ID)
+	if err != nil {
+		return fmt.Errorf("mark outbox %d dispatched: %w", m.ID, err)
+	}
+	return nil
+}
+
+func (r *OutboxRepo) markFailed(ctx context.Context, m domain.OutboxMessage, perr error, backoff func(attempts int) time.Duration) error {
+	attempts := m.Attempts + 1
+	var failedAt *time.Time
+	if errors.Is(perr, domain.ErrOutboxDead) {
+		now := time.Now()
+		failedAt = &now
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), markTimeout)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE outbox
+		SET attempts = $2, last_error = $3, next_attempt_at = $4, failed_at = $5, claimed_until = NULL
+		WHERE id = $1
+	`, m.ID, attempts, perr.Error(), time.Now().Add(backoff(attempts)), failedAt)
+	if err != nil {
+		return fmt.Errorf("mark outbox %d failed: %w", m.ID, err)
+	}
+	return nil
+}
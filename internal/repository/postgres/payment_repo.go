@@ -0,0 +1,353 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type PaymentRepo struct {
+	db *sql.DB
+}
+
+func NewPaymentRepo(db *sql.DB) *PaymentRepo {
+	return &PaymentRepo{db: db}
+}
+
+// InsertDetected relies on payments_tx_log_uidx for idempotency: a block
+// scanned twice inserts (and enqueues) nothing the second time.
+func (r *PaymentRepo) InsertDetected(ctx context.Context, p *domain.Payment, outbox ...domain.OutboxMessage) (bool, error) {
+	inserted := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var merchantAddress string
+		err := tx.QueryRowContext(ctx, `
+			SELECT m.wallet_address
+			FROM orders o
+			JOIN merchants m ON m.merchant_id = o.merchant_id
+			WHERE o.order_id = $1 AND o.merchant_id = $2 AND o.invoice_id = $3
+		`, p.OrderID, p.MerchantID, p.InvoiceID).Scan(&merchantAddress)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrUnknownOrder
+		}
+		if err != nil {
+			return fmt.Errorf("lookup payment order: %w", err)
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO payments (
+				order_id, invoice_id, merchant_id,
+				token_address, payer_address, merchant_address,
+				amount, amount_raw, currency,
+				tx_hash, block_number, log_index, paid_at, status
+			)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7::numeric, $8::numeric, $9, $10, $11, $12, $13, $14)
+			ON CONFLICT (tx_hash, log_index) WHERE tx_hash IS NOT NULL DO NOTHING
+			RETURNING payment_uid, created_at, updated_at
+		`,
+			p.OrderID, p.InvoiceID, p.MerchantID,
+			p.TokenAddress, p.PayerAddress, merchantAddress,
+			p.Amount, p.AmountRaw, p.Currency,
+			p.TxHash, p.BlockNumber, p.LogIndex, p.PaidAt, string(p.Status),
+		).Scan(&p.PaymentUID, &p.CreatedAt, &p.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // already recorded
+		}
+		if err != nil {
+			return fmt.Errorf("insert payment: %w", err)
+		}
+
+		p.MerchantAddress = merchantAddress
+		inserted = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+func (r *PaymentRepo) InsertUnmatched(ctx context.Context, p *domain.Payment, reason string) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO unmatched_payments (
+			order_id, invoice_id, merchant_id,
+			token_address, payer_address,
+			amount, amount_raw, currency,
+			tx_hash, log_index, block_number, paid_at, review_reason
+		)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6::numeric, $7::numeric, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (tx_hash, log_index) DO NOTHING
+	`,
+		p.OrderID, p.InvoiceID, p.MerchantID,
+		p.TokenAddress, p.PayerAddress,
+		p.Amount, p.AmountRaw, p.Currency,
+		p.TxHash, p.LogIndex, p.BlockNumber, p.PaidAt, reason,
+	)
+	if err != nil {
+		return false, fmt.Errorf("insert unmatched payment: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+const paymentColumns = `payment_uid, order_id, invoice_id, merchant_id,
+	token_address, payer_address, merchant_address,
+	amount::text, amount_raw::text, currency,
+	tx_hash, block_number, log_index,
+	status, match_status, paid_at, confirmed_at,
+	review_reason, review_at, missed_polls, last_missed_at,
+	created_at, updated_at`
+
+func (r *PaymentRepo) ListPending(ctx context.Context, maxBlock int64, limit int) ([]domain.Payment, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE status = 'PENDING' AND review_at IS NULL
+		  AND block_number IS NOT NULL AND block_number <= $1
+		ORDER BY block_number, id
+		LIMIT $2
+	`, maxBlock, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list pending payments: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list pending payments: %w", err)
+		}
+		out = append(out, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list pending payments: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PaymentRepo) HoldForReview(ctx context.Context, paymentUID string, reason string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE payments
+		SET review_reason = $2, review_at = NOW(), updated_at = NOW()
+		WHERE payment_uid = $1 AND status = 'PENDING' AND review_at IS NULL
+	`, paymentUID, reason)
+	if err != nil {
+		return fmt.Errorf("hold payment for review: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentRepo) RecordMiss(ctx context.Context, paymentUID string) (int, error) {
+	var misses int
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		UPDATE payments
+		SET missed_polls = missed_polls + 1, last_missed_at = NOW(), updated_at = NOW()
+		WHERE payment_uid = $1 AND status = 'PENDING'
+		RETURNING missed_polls
+	`, paymentUID).Scan(&misses)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("record payment miss: %w", err)
+	}
+	return misses, nil
+}
+
+func (r *PaymentRepo) ClearMisses(ctx context.Context, paymentUID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE payments
+		SET missed_polls = 0, updated_at = NOW()
+		WHERE payment_uid = $1 AND status = 'PENDING' AND missed_polls > 0
+	`, paymentUID)
+	if err != nil {
+		return fmt.Errorf("clear payment misses: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentRepo) SetBlockNumber(ctx context.Context, paymentUID string, block int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE payments
+		SET block_number = $2, updated_at = NOW()
+		WHERE payment_uid = $1 AND status = 'PENDING'
+	`, paymentUID, block)
+	if err != nil {
+		return fmt.Errorf("set payment block: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentRepo) MarkConfirmed(ctx context.Context, paymentUID string, block int64, at time.Time, outbox ...domain.OutboxMessage) (bool, error) {
+	changed := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE payments
+			SET status = 'SUCCESS', block_number = $2, confirmed_at = $3, updated_at = NOW()
+			WHERE payment_uid = $1 AND status = 'PENDING'
+		`, paymentUID, block, at)
+		if err != nil {
+			return fmt.Errorf("confirm payment: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+
+		changed = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	return changed, err
+}
+
+func (r *PaymentRepo) MarkReverted(ctx context.Context, paymentUID string, outbox ...domain.OutboxMessage) (bool, error) {
+	changed := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var orderID []byte
+		err := tx.QueryRowContext(ctx, `
+			UPDATE payments
+			SET status = 'FAILED', updated_at = NOW()
+			WHERE payment_uid = $1 AND status = 'PENDING'
+			RETURNING order_id
+		`, paymentUID).Scan(&orderID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("revert payment: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE orders
+			SET payment_status = 'FAILED', updated_at = NOW()
+			WHERE order_id = $1 AND payment_status = 'PENDING'
+			  AND NOT EXISTS (
+			    SELECT 1 FROM payments
+			    WHERE order_id = $1 AND status IN ('PENDING', 'SUCCESS')
+			  )
+		`, orderID)
+		if err != nil {
+			return fmt.Errorf("fail order: %w", err)
+		}
+
+		changed = true
+		return insertOutbox(ctx, tx, outbox...)
+	})
+	return changed, err
+}
+
+// ListByOrder returns every payment for the order, in chain order.
+func (r *PaymentRepo) ListByOrder(ctx context.Context, orderID []byte) ([]domain.Payment, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE order_id = $1
+		ORDER BY block_number NULLS LAST, log_index, id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("list order payments: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list order payments: %w", err)
+		}
+		out = append(out, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list order payments: %w", err)
+	}
+	return out, nil
+}
+
+func (r *PaymentRepo) SetMatch(ctx context.Context, paymentUID string, match domain.PaymentMatch) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE payments
+		SET match_status = NULLIF($2, ''), updated_at = NOW()
+		WHERE payment_uid = $1 AND match_status IS DISTINCT FROM NULLIF($2, '')
+	`, paymentUID, string(match))
+	if err != nil {
+		return fmt.Errorf("set payment match: %w", err)
+	}
+	return nil
+}
+
+func scanPayment(s rowScanner) (*domain.Payment, error) {
+	var (
+		p               domain.Payment
+		status          string
+		token           sql.NullString
+		payer           sql.NullString
+		merchantAddress sql.NullString
+		amountRaw       sql.NullString
+		txHash          sql.NullString
+		block           sql.NullInt64
+		logIndex        sql.NullInt64
+		match           sql.NullString
+		paidAt          sql.NullTime
+		confirmedAt     sql.NullTime
+		reviewReason    sql.NullString
+		reviewAt        sql.NullTime
+		lastMissedAt    sql.NullTime
+	)
+	err := s.Scan(
+		&p.PaymentUID,
+		&p.OrderID,
+		&p.InvoiceID,
+		&p.MerchantID,
+		&token,
+		&payer,
+		&merchantAddress,
+		&p.Amount,
+		&amountRaw,
+		&p.Currency,
+		&txHash,
+		&block,
+		&logIndex,
+		&status,
+		&match,
+		&paidAt,
+		&confirmedAt,
+		&reviewReason,
+		&reviewAt,
+		&p.MissedPolls,
+		&lastMissedAt,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p.TokenAddress = token.String
+	p.PayerAddress = payer.String
+	p.MerchantAddress = merchantAddress.String
+	p.Amount = trimNumeric(p.Amount)
+	p.AmountRaw = amountRaw.String
+	p.TxHash = txHash.String
+	p.BlockNumber = block.Int64
+	p.LogIndex = int(logIndex.Int64)
+	p.Status = domain.PaymentStatus(status)
+	p.Match = domain.PaymentMatch(match.String)
+	p.PaidAt = paidAt.Time
+	if confirmedAt.Valid {
+		t := confirmedAt.Time
+		p.ConfirmedAt = &t
+	}
+	p.ReviewReason = reviewReason.String
+	if reviewAt.Valid {
+		t := reviewAt.Time
+		p.ReviewAt = &t
+	}
+	if lastMissedAt.Valid {
+		t := lastMissedAt.Time
+		p.LastMissedAt = &t
+	}
+	return &p, nil
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type RefreshTokenRepo struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepo(db *sql.DB) *RefreshTokenRepo {
+	return &RefreshTokenRepo{db: db}
+}
+
+func (r *RefreshTokenRepo) Create(ctx context.Context, t *domain.RefreshToken) error {
+	if err := createRefreshToken(ctx, conn(ctx, r.db), t); err != nil {
+		return fmt.Errorf("create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) GetByHash(ctx context.Context, hash []byte) (*domain.RefreshToken, error) {
+	var (
+		t                  domain.RefreshToken
+		rotatedAt, revoked sql.NullTime
+	)
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT id, token_hash, family_id::text, user_uid::text,
+		       expires_at, rotated_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, hash).Scan(&t.ID, &t.Hash, &t.FamilyID, &t.UserUID,
+		&t.ExpiresAt, &rotatedAt, &revoked, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+	if rotatedAt.Valid {
+		t.RotatedAt = &rotatedAt.Time
+	}
+	if revoked.Valid {
+		t.RevokedAt = &revoked.Time
+	}
+	return &t, nil
+}
+
+func (r *RefreshTokenRepo) Rotate(ctx context.Context, id int64, next *domain.RefreshToken, at time.Time) (bool, error) {
+	rotated := false
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE refresh_tokens
+			SET rotated_at = $2
+			WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+		`, id, at)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if err := createRefreshToken(ctx, tx, next); err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("rotate refresh token: %w", err)
+	}
+	return rotated, nil
+}
+
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string, reason domain.RevokeReason, at time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $2, revoke_reason = $3
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID, at, string(reason))
+	if err != nil {
+		return fmt.Errorf("revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepo) RevokeUser(ctx context.Context, userUID string, reason domain.RevokeReason, at time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $2, revoke_reason = $3
+		WHERE user_uid = $1 AND revoked_at IS NULL
+	`, userUID, at, string(reason))
+	if err != nil {
+		return fmt.Errorf("revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+func createRefreshToken(ctx context.Context, db dbtx, t *domain.RefreshToken) error {
+	return db.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, family_id, user_uid, expires_at)
+		VALUES ($1, COALESCE(NULLIF($2, '')::uuid, gen_random_uuid()), $3, $4)
+		RETURNING id, family_id::text, created_at
+	`, t.Hash, t.FamilyID, t.UserUID, t.ExpiresAt).Scan(&t.ID, &t.FamilyID, &t.CreatedAt)
+}
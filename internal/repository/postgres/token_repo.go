@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+type TokenRepo struct {
+	db *sql.DB
+}
+
+func NewTokenRepo(db *sql.DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+func (r *TokenRepo) ListAllowed(ctx context.Context) ([]domain.Token, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT address, symbol, name, decimals
+		FROM tokens
+		WHERE allowed
+		ORDER BY symbol, address
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list tokens: %w", err)
+	}
+	defer rows.Close()
+
+	out := []domain.Token{}
+	for rows.Next() {
+		var t domain.Token
+		if err := rows.Scan(&t.Address, &t.Symbol, &t.Name, &t.Decimals); err != nil {
+			return nil, fmt.Errorf("list tokens: %w", err)
+		}
+		out = append(out, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list tokens: %w", err)
+	}
+	return out, nil
+}
+
+func (r *TokenRepo) Get(ctx context.Context, address string) (*domain.Token, error) {
+	var t domain.Token
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT address, symbol, name, decimals
+		FROM tokens
+		WHERE address = $1
+	`, address).Scan(&t.Address, &t.Symbol, &t.Name, &t.Decimals)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get token: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *TokenRepo) Save(ctx context.Context, t domain.Token) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO tokens (address, symbol, name, decimals)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (address) DO UPDATE
+		SET symbol = EXCLUDED.symbol,
+		    name = EXCLUDED.name,
+		    decimals = EXCLUDED.decimals,
+		    updated_at = NOW()
+	`, t.Address, t.Symbol, t.Name, t.Decimals)
+	if err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	return nil
+}
+
+func (r *TokenRepo) SetAllowed(ctx context.Context, addresses []string) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		// Clear first: tokens_allowed_symbol_uidx is checked per row.
+		_, err := tx.ExecContext(ctx, `
+			UPDATE tokens SET allowed = FALSE, updated_at = NOW()
+			WHERE allowed AND NOT (address = ANY($1))
+		`, addresses)
+		if err != nil {
+			return fmt.Errorf("allow-list tokens: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE tokens SET allowed = TRUE, updated_at = NOW()
+			WHERE NOT allowed AND address = ANY($1)
+		`, addresses)
+		if err != nil {
+			if strings.Contains(err.Error(), "tokens_allowed_symbol_uidx") {
+				return fmt.Errorf("allow-list tokens: two tokens share a symbol")
+			}
+			return fmt.Errorf("allow-list tokens: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// WithTx makes repositories called with the returned ctx run inside tx.
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func txFrom(ctx context.Context) *sql.Tx {
+	switch v := ctx.Value(txKey{}).(type) {
+	case *sql.Tx:
+		return v
+	case *deferredTx:
+		return v.tx
+	}
+	return nil
+}
+
+// deferredTx is a transaction that is only begun by the first inTx unit
+// that needs it and is committed by its owner (Ledger.Process). Until then
+// repositories use the pool, so slow work between units holds no
+// transaction open.
+type deferredTx struct {
+	ctx context.Context // outlives the per-call contexts of the units
+	db  *sql.DB
+	tx  *sql.Tx
+}
+
+func withDeferredTx(ctx context.Context, db *sql.DB) (context.Context, *deferredTx) {
+	d := &deferredTx{ctx: ctx, db: db}
+	return context.WithValue(ctx, txKey{}, d), d
+}
+
+func (d *deferredTx) begin() (*sql.Tx, error) {
+	if d.tx == nil {
+		tx, err := d.db.BeginTx(d.ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		d.tx = tx
+	}
+	return d.tx, nil
+}
+
+func (d *deferredTx) rollback() {
+	if d.tx != nil {
+		_ = d.tx.Rollback()
+	}
+}
+
+// conn returns the ambient transaction if there is one, db otherwise.
+func conn(ctx context.Context, db *sql.DB) dbtx {
+	if tx := txFrom(ctx); tx != nil {
+		return tx
+	}
+	return db
+}
+
+// inTx runs fn in the ambient transaction, or in a new one that is committed
+// when fn succeeds. The caller owning an ambient transaction commits it; a
+// deferred one is begun here if this is its first unit.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if tx := txFrom(ctx); tx != nil {
+		return fn(tx)
+	}
+	if d, ok := ctx.Value(txKey{}).(*deferredTx); ok {
+		tx, err := d.begin()
+		if err != nil {
+			return err
+		}
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Transactor exposes ambient transactions to services (ports.Transactor).
+type Transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// InTx runs fn in a transaction that repositories called with fn's ctx
+// join. It joins the ambient transaction if ctx already carries one.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return inTx(ctx, t.db, func(tx *sql.Tx) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+var (
+	ErrAPIKeyNameRequired = errors.New("name is required")
+	ErrInvalidAllowedIP   = errors.New("allowed_ips must be IP addresses or CIDRs")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+	ErrAPIKeyIPNotAllowed = errors.New("api key not allowed from this address")
+)
+
+type CreateAPIKeyInput struct {
+	Name       string
+	Scopes     []string
+	AllowedIPs []string // addresses or CIDRs; empty = any
+	CreatedBy  string   // user_uid
+}
+
+// APIKeyService manages merchant API keys and authenticates requests
+// made with them.
+type APIKeyService struct {
+	repo ports.APIKeyRepo
+	log  *slog.Logger
+}
+
+func NewAPIKeyService(repo ports.APIKeyRepo, log *slog.Logger) *APIKeyService {
+	return &APIKeyService{repo: repo, log: log}
+}
+
+// Create issues a key for the merchant. The returned secret key is the only
+// copy: it cannot be read back later.
+func (s *APIKeyService) Create(ctx context.Context, merchantID []byte, in CreateAPIKeyInput) (*domain.APIKey, string, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, "", ErrAPIKeyNameRequired
+	}
+
+	var scopes []domain.Scope
+	for _, raw := range in.Scopes {
+		scope := domain.Scope(strings.TrimSpace(raw))
+		if !scope.Valid() {
+			return nil, "", fmt.Errorf("%w: %q", domain.ErrInvalidScope, raw)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, "", domain.ErrInvalidScope
+	}
+
+	var allowed []netip.Prefix
+	for _, raw := range in.AllowedIPs {
+		p, err := parseAllowedIP(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q", ErrInvalidAllowedIP, raw)
+		}
+		allowed = append(allowed, p)
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	k := &domain.APIKey{
+		MerchantID: merchantID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hash,
+		Scopes:     scopes,
+		AllowedIPs: allowed,
+		CreatedBy:  in.CreatedBy,
+	}
+	if err := s.repo.Create(ctx, k); err != nil {
+		return nil, "", err
+	}
+	s.log.Info("api_key_created", "merchant_id", hexID(merchantID), "key_id", k.ID, "prefix", prefix, "created_by", in.CreatedBy)
+	return k, key, nil
+}
+
+// List returns the merchant's keys, revoked ones included.
+func (s *APIKeyService) List(ctx context.Context, merchantID []byte) ([]domain.APIKey, error) {
+	return s.repo.List(ctx, merchantID)
+}
+
+// Revoke disables the merchant's key id for good.
+func (s *APIKeyService) Revoke(ctx context.Context, merchantID []byte, id int64) error {
+	revoked, err := s.repo.Revoke(ctx, merchantID, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	s.log.Info("api_key_revoked", "merchant_id", hexID(merchantID), "key_id", id)
+	return nil
+}
+
+// Authenticate returns the live key matching key, used from ip.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string, ip netip.Addr) (*domain.APIKey, error) {
+	prefix, secret, ok := auth.ParseAPIKey(key)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	k, err := s.repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil || k.RevokedAt != nil ||
+		subtle.ConstantTimeCompare(k.SecretHash, auth.HashAPIKeySecret(secret)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if !k.AllowsIP(ip) {
+		s.log.Warn("api_key_ip_refused", "merchant_id", hexID(k.MerchantID), "key_id", k.ID, "ip", ip.String())
+		return nil, ErrAPIKeyIPNotAllowed
+	}
+
+	if err := s.repo.Touch(ctx, k.ID, time.Now().UTC()); err != nil {
+		s.log.Warn("api_key_touch_failed", "key_id", k.ID, "err", err)
+	}
+	return k, nil
+}
+
+// parseAllowedIP accepts an address (a single-host prefix) or a CIDR.
+func parseAllowedIP(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+)
+
+// DefaultReceiverCooldown is the minimum time between two receiver rotations.
+const DefaultReceiverCooldown = 24 * time.Hour
+
+var (
+	ErrReceiverUnchanged     = errors.New("wallet is already the fund receiver")
+	ErrReceiverChangePending = errors.New("a receiver change is already pending")
+	ErrReceiverCooldown      = errors.New("receiver was changed recently")
+)
+
+// RequestReceiverChange asks for the merchant's funds to go to receiver
+// from now on. The rotation is recorded as pending and applied on chain by
+// the worker (SyncReceiver); wallet_address only follows once the chain
+// confirms. One rotation is allowed per ReceiverCooldown; a FAILED one
+// does not count, so it can be requested again straight away.
+func (s *MerchantService) RequestReceiverChange(ctx context.Context, merchantID []byte, receiver domain.TronAddress, requestedBy string) (*domain.ReceiverChange, error) {
+	if receiver.IsZero() {
+		return nil, fmt.Errorf("%w: zero address", domain.ErrInvalidTronAddress)
+	}
+
+	m, err := s.Get(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.OnChain() {
+		return nil, ErrMerchantNotOnChain
+	}
+	if m.WalletAddress == receiver {
+		return nil, ErrReceiverUnchanged
+	}
+
+	now := time.Now().UTC()
+	changes, err := s.repo.ListReceiverChanges(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, last := range changes {
+		if last.Status == domain.ReceiverChangeFailed {
+			continue
+		}
+		if last.Status == domain.ReceiverChangePending {
+			return nil, ErrReceiverChangePending
+		}
+		if until := last.RequestedAt.Add(s.ReceiverCooldown); now.Before(until) {
+			return nil, fmt.Errorf("%w: next change allowed after %s", ErrReceiverCooldown, until.UTC().Format(time.RFC3339))
+		}
+		break
+	}
+
+	ch := &domain.ReceiverChange{
+		MerchantID:  merchantID,
+		OldReceiver: m.WalletAddress,
+		NewReceiver: receiver,
+		Status:      domain.ReceiverChangePending,
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+	}
+	env, err := events.New(ctx, events.MerchantReceiverChangeRequested{
+		MerchantID:  hexID(merchantID),
+		OldReceiver: ch.OldReceiver.String(),
+		NewReceiver: ch.NewReceiver.String(),
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return nil, err
+	}
+
+	requested, err := s.repo.RequestReceiverChange(ctx, ch, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !requested {
+		return nil, ErrReceiverChangePending
+	}
+	s.log.Info("merchant_receiver_change_requested",
+		"merchant_id", hexID(merchantID),
+		"change_id", ch.ID,
+		"old_receiver", ch.OldReceiver.String(),
+		"new_receiver", ch.NewReceiver.String(),
+		"requested_by", requestedBy,
+	)
+	return ch, nil
+}
+
+// ReceiverChanges returns the merchant's receiver rotations, newest first.
+func (s *MerchantService) ReceiverChanges(ctx context.Context, merchantID []byte) ([]domain.ReceiverChange, error) {
+	return s.repo.ListReceiverChanges(ctx, merchantID)
+}
+
+// SyncReceiver applies the merchant's pending receiver rotation in
+// MerchantRegistryV1 and confirms it from the MerchantReceiverAddressUpdated
+// event the call emits. Like SyncStatus it is safe to call repeatedly.
+func (s *MerchantService) SyncReceiver(ctx context.Context, merchantID []byte) error {
+	ch, err := s.repo.LatestReceiverChange(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	if ch == nil || ch.Status != domain.ReceiverChangePending {
+		return nil
+	}
+
+	txid := ch.TxID
+	if txid == "" {
+		current, err := s.chain.MerchantFundReceiver(ctx, merchantID)
+		if err != nil {
+			return fmt.Errorf("read fund receiver: %w", err)
+		}
+		if current == ch.NewReceiver {
+			s.log.Warn("merchant_receiver_already_on_chain", "merchant_id", hexID(merchantID), "change_id", ch.ID)
+			return s.confirmReceiver(ctx, ch, "")
+		}
+
+		txid, err = s.chain.UpdateMerchantReceiver(ctx, merchantID, ch.NewReceiver)
+		if err != nil {
+			return fmt.Errorf("update merchant receiver: %w", err)
+		}
+		if err := s.repo.SetReceiverTxID(ctx, ch.ID, txid); err != nil {
+			return err
+		}
+		s.log.Info("merchant_receiver_broadcast", "merchant_id", hexID(merchantID), "change_id", ch.ID, "txid", txid)
+	}
+
+	receipt, err := s.waitReceipt(ctx, txid)
+	if err != nil {
+		return err
+	}
+	if !receipt.Found || !receipt.Success {
+		if err := s.repo.SetReceiverTxID(ctx, ch.ID, ""); err != nil {
+			return err
+		}
+		if !receipt.Found {
+			return fmt.Errorf("receiver tx %s not confirmed within %s", txid, s.ConfirmTimeout)
+		}
+		return fmt.Errorf("receiver tx %s failed: %s", txid, receipt.Result)
+	}
+
+	receiver, found, err := s.chain.MerchantReceiverUpdated(ctx, txid, merchantID)
+	if err != nil {
+		return fmt.Errorf("read receiver event: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("receiver tx %s emitted no MerchantReceiverAddressUpdated", txid)
+	}
+	if receiver != ch.NewReceiver {
+		return fmt.Errorf("receiver tx %s set receiver %s, want %s", txid, receiver, ch.NewReceiver)
+	}
+	return s.confirmReceiver(ctx, ch, txid)
+}
+
+// FailReceiver gives up the merchant's pending rotation after cause: it
+// becomes FAILED and the merchant may request it again. SyncReceiver asks
+// the chain first, so a tx that lands after all is confirmed then rather
+// than sent twice.
+func (s *MerchantService) FailReceiver(ctx context.Context, merchantID []byte, cause error) error {
+	ch, err := s.repo.LatestReceiverChange(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	if ch == nil || ch.Status != domain.ReceiverChangePending {
+		return nil
+	}
+	if err := s.repo.FailReceiverChange(ctx, ch.ID, cause.Error()); err != nil {
+		return err
+	}
+	s.log.Error("merchant_receiver_failed", "merchant_id", hexID(merchantID), "change_id", ch.ID, "err", cause)
+	return nil
+}
+
+func (s *MerchantService) confirmReceiver(ctx context.Context, ch *domain.ReceiverChange, txid string) error {
+	now := time.Now().UTC()
+	env, err := events.New(ctx, events.MerchantReceiverUpdated{
+		MerchantID:  hexID(ch.MerchantID),
+		ChangeID:    ch.ID,
+		OldReceiver: ch.OldReceiver.String(),
+		NewReceiver: ch.NewReceiver.String(),
+		TxID:        txid,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.ConfirmReceiverChange(ctx, ch.ID, now, msg); err != nil {
+		return err
+	}
+	s.log.Info("merchant_receiver_confirmed", "merchant_id", hexID(ch.MerchantID), "change_id", ch.ID, "txid", txid)
+	return nil
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+)
+
+func (r *memMerchants) ListReceiverChanges(_ context.Context, merchantID []byte) ([]domain.ReceiverChange, error) {
+	var out []domain.ReceiverChange
+	for i := len(r.changes) - 1; i >= 0; i-- {
+		if bytes.Equal(r.changes[i].MerchantID, merchantID) {
+			out = append(out, r.changes[i])
+		}
+	}
+	return out, nil
+}
+
+func (r *memMerchants) LatestReceiverChange(ctx context.Context, merchantID []byte) (*domain.ReceiverChange, error) {
+	changes, _ := r.ListReceiverChanges(ctx, merchantID)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &changes[0], nil
+}
+
+func (r *memMerchants) RequestReceiverChange(_ context.Context, ch *domain.ReceiverChange, outbox ...domain.OutboxMessage) (bool, error) {
+	for _, m := range r.merchants {
+		if m.WalletAddress == ch.NewReceiver && !bytes.Equal(m.MerchantID, ch.MerchantID) {
+			return false, domain.ErrWalletInUse
+		}
+	}
+	for _, c := range r.changes {
+		if bytes.Equal(c.MerchantID, ch.MerchantID) && c.Status == domain.ReceiverChangePending {
+			return false, nil
+		}
+	}
+	ch.ID = int64(len(r.changes) + 1)
+	r.changes = append(r.changes, *ch)
+	r.outbox = append(r.outbox, outbox...)
+	return true, nil
+}
+
+func (r *memMerchants) change(id int64) *domain.ReceiverChange {
+	return &r.changes[id-1]
+}
+
+func (r *memMerchants) SetReceiverTxID(_ context.Context, changeID int64, txid string) error {
+	r.change(changeID).TxID = txid
+	r.txids = append(r.txids, txid)
+	return nil
+}
+
+func (r *memMerchants) ConfirmReceiverChange(_ context.Context, changeID int64, at time.Time, outbox ...domain.OutboxMessage) error {
+	ch := r.change(changeID)
+	if ch.Status != domain.ReceiverChangePending {
+		return nil
+	}
+	ch.Status, ch.ConfirmedAt = domain.ReceiverChangeConfirmed, &at
+	r.merchants[string(ch.MerchantID)].WalletAddress = ch.NewReceiver
+	r.outbox = append(r.outbox, outbox...)
+	return nil
+}
+
+func (r *memMerchants) FailReceiverChange(_ context.Context, changeID int64, reason string) error {
+	ch, now := r.change(changeID), time.Now()
+	if ch.Status != domain.ReceiverChangePending {
+		return nil
+	}
+	ch.Status, ch.TxID, ch.Error, ch.FailedAt = domain.ReceiverChangeFailed, "", reason, &now
+	return nil
+}
+
+func (c *fakeChain) MerchantFundReceiver(context.Context, []byte) (domain.TronAddress, error) {
+	c.calls = append(c.calls, "MerchantFundReceiver")
+	return c.receiver, nil
+}
+
+func (c *fakeChain) UpdateMerchantReceiver(_ context.Context, _ []byte, receiver domain.TronAddress) (string, error) {
+	c.calls = append(c.calls, "UpdateMerchantReceiver")
+	if c.receiverEvent == nil {
+		c.receiverEvent = &receiver
+	}
+	return c.txid, nil
+}
+
+func (c *fakeChain) MerchantReceiverUpdated(context.Context, string, []byte) (domain.TronAddress, bool, error) {
+	c.calls = append(c.calls, "MerchantReceiverUpdated")
+	if c.receiverEvent == nil {
+		return domain.TronAddress{}, false, nil
+	}
+	return *c.receiverEvent, true, nil
+}
+
+// pastChange is a rotation of the test merchant requested ago before now.
+func pastChange(t *testing.T, status domain.ReceiverChangeStatus, ago time.Duration) domain.ReceiverChange {
+	return domain.ReceiverChange{
+		MerchantID:  testMerchantID,
+		OldReceiver: testAddress(t, testWTRX.Address),
+		NewReceiver: testAddress(t, testUSDT.Address),
+		Status:      status,
+		RequestedAt: time.Now().Add(-ago),
+	}
+}
+
+func TestRequestReceiverChange(t *testing.T) {
+	other, _ := domain.TronAddressFromBytes(bytes.Repeat([]byte{0x07}, 20))
+	tests := []struct {
+		name     string
+		setup    func(r *memMerchants, m *domain.Merchant)
+		receiver string // base58; testWTRX when empty
+		err      error
+	}{
+		{name: "first rotation"},
+		{name: "same wallet", receiver: testUSDT.Address, err: ErrReceiverUnchanged},
+		{name: "zero address", receiver: "-", err: domain.ErrInvalidTronAddress},
+		{
+			name:  "not on chain yet",
+			setup: func(_ *memMerchants, m *domain.Merchant) { m.ChainRegisteredAt = nil },
+			err:   ErrMerchantNotOnChain,
+		},
+		{
+			name: "already pending",
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.changes = append(r.changes, pastChange(t, domain.ReceiverChangePending, time.Minute))
+			},
+			err: ErrReceiverChangePending,
+		},
+		{
+			name: "within the cooldown",
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.changes = append(r.changes, pastChange(t, domain.ReceiverChangeConfirmed, time.Hour))
+			},
+			err: ErrReceiverCooldown,
+		},
+		{
+			name: "after the cooldown",
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.changes = append(r.changes, pastChange(t, domain.ReceiverChangeConfirmed, 25*time.Hour))
+			},
+		},
+		{
+			name: "failed rotations do not count",
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.changes = append(r.changes,
+					pastChange(t, domain.ReceiverChangeConfirmed, 48*time.Hour),
+					pastChange(t, domain.ReceiverChangeFailed, time.Hour),
+				)
+			},
+		},
+		{
+			name: "wallet of another merchant",
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.merchants["other"] = &domain.Merchant{MerchantID: []byte("other"), WalletAddress: other}
+			},
+			receiver: other.String(),
+			err:      domain.ErrWalletInUse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := activeMerchant(t)
+			repo := newMemMerchants(m)
+			if tt.setup != nil {
+				tt.setup(repo, m)
+			}
+			var receiver domain.TronAddress
+			switch tt.receiver {
+			case "":
+				receiver = testAddress(t, testWTRX.Address)
+			case "-":
+			default:
+				receiver = testAddress(t, tt.receiver)
+			}
+			before := len(repo.changes)
+
+			ch, err := newTestMerchantService(repo, &fakeChain{}).RequestReceiverChange(context.Background(), testMerchantID, receiver, "admin-1")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if len(repo.changes) != before || len(repo.outbox) != 0 {
+					t.Fatal("a rejected rotation was recorded")
+				}
+				return
+			}
+			if ch.Status != domain.ReceiverChangePending || ch.OldReceiver != m.WalletAddress || ch.NewReceiver != receiver {
+				t.Fatalf("change = %+v", ch)
+			}
+			if m.WalletAddress != testAddress(t, testUSDT.Address) {
+				t.Fatal("wallet_address moved before the chain confirmed")
+			}
+			ev := outboxEvent[events.MerchantReceiverChangeRequested](t, repo.outbox)
+			if ev.NewReceiver != receiver.String() || ev.OldReceiver != testUSDT.Address || ev.RequestedBy != "admin-1" {
+				t.Fatalf("event = %+v", ev)
+			}
+		})
+	}
+}
+
+func TestSyncReceiver(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(ch *domain.ReceiverChange, c *fakeChain)
+		calls  string
+		err    string
+		status domain.ReceiverChangeStatus
+		txid   string // in merchant.receiver_updated, or left on the pending change
+	}{
+		{
+			name:   "applies on chain",
+			calls:  "MerchantFundReceiver UpdateMerchantReceiver TransactionInfo MerchantReceiverUpdated",
+			status: domain.ReceiverChangeConfirmed,
+			txid:   "tx1",
+		},
+		{
+			name: "already applied on chain",
+			setup: func(ch *domain.ReceiverChange, c *fakeChain) {
+				c.receiver = ch.NewReceiver
+			},
+			calls:  "MerchantFundReceiver",
+			status: domain.ReceiverChangeConfirmed,
+		},
+		{
+			name: "persisted txid is awaited, not rebroadcast",
+			setup: func(ch *domain.ReceiverChange, c *fakeChain) {
+				ch.TxID = "tx1"
+				c.receiverEvent = &ch.NewReceiver
+			},
+			calls:  "TransactionInfo MerchantReceiverUpdated",
+			status: domain.ReceiverChangeConfirmed,
+			txid:   "tx1",
+		},
+		{
+			name: "reverted",
+			setup: func(_ *domain.ReceiverChange, c *fakeChain) {
+				c.receipts["tx1"] = domain.ChainReceipt{TxID: "tx1", Found: true, Result: "REVERT"}
+			},
+			calls:  "MerchantFundReceiver UpdateMerchantReceiver TransactionInfo",
+			err:    "receiver tx tx1 failed: REVERT",
+			status: domain.ReceiverChangePending,
+		},
+		{
+			name: "event names another receiver",
+			setup: func(ch *domain.ReceiverChange, c *fakeChain) {
+				c.receiverEvent = &ch.OldReceiver
+			},
+			calls:  "MerchantFundReceiver UpdateMerchantReceiver TransactionInfo MerchantReceiverUpdated",
+			err:    "receiver tx tx1 set receiver " + testUSDT.Address + ", want " + testWTRX.Address,
+			status: domain.ReceiverChangePending,
+			txid:   "tx1",
+		},
+		{
+			name:   "already confirmed",
+			setup:  func(ch *domain.ReceiverChange, _ *fakeChain) { ch.Status = domain.ReceiverChangeConfirmed },
+			status: domain.ReceiverChangeConfirmed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := activeMerchant(t)
+			repo := newMemMerchants(m)
+			repo.changes = []domain.ReceiverChange{{
+				ID:          1,
+				MerchantID:  testMerchantID,
+				OldReceiver: m.WalletAddress,
+				NewReceiver: testAddress(t, testWTRX.Address),
+				Status:      domain.ReceiverChangePending,
+				RequestedAt: testBlockTime,
+			}}
+			chain := &fakeChain{receiver: m.WalletAddress, txid: "tx1", receipts: map[string]domain.ChainReceipt{
+				"tx1": {TxID: "tx1", Found: true, Success: true},
+			}}
+			if tt.setup != nil {
+				tt.setup(&repo.changes[0], chain)
+			}
+			ch := &repo.changes[0]
+
+			err := newTestMerchantService(repo, chain).SyncReceiver(context.Background(), testMerchantID)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if got := strings.Join(chain.calls, " "); got != tt.calls {
+				t.Fatalf("chain calls = %q, want %q", got, tt.calls)
+			}
+			if ch.Status != tt.status {
+				t.Fatalf("status = %s, want %s", ch.Status, tt.status)
+			}
+			if tt.status == domain.ReceiverChangePending || tt.calls == "" {
+				if ch.TxID != tt.txid {
+					t.Fatalf("txid = %q, want %q", ch.TxID, tt.txid)
+				}
+				if m.WalletAddress != ch.OldReceiver || len(repo.outbox) != 0 {
+					t.Fatalf("unconfirmed rotation applied: wallet %s, outbox %d", m.WalletAddress, len(repo.outbox))
+				}
+				return
+			}
+			if m.WalletAddress != ch.NewReceiver {
+				t.Fatalf("wallet_address = %s, want %s", m.WalletAddress, ch.NewReceiver)
+			}
+			ev := outboxEvent[events.MerchantReceiverUpdated](t, repo.outbox)
+			if ev.ChangeID != 1 || ev.NewReceiver != testWTRX.Address || ev.TxID != tt.txid {
+				t.Fatalf("event = %+v", ev)
+			}
+		})
+	}
+}
+
+// A rotation the worker gave up on can be requested again straight away.
+func TestFailReceiver(t *testing.T) {
+	m := activeMerchant(t)
+	repo := newMemMerchants(m)
+	s := newTestMerchantService(repo, &fakeChain{})
+	receiver := testAddress(t, testWTRX.Address)
+
+	if err := s.FailReceiver(context.Background(), testMerchantID, errors.New("nothing pending")); err != nil {
+		t.Fatalf("FailReceiver without a rotation: %v", err)
+	}
+	if _, err := s.RequestReceiverChange(context.Background(), testMerchantID, receiver, "admin-1"); err != nil {
+		t.Fatal(err)
+	}
+	repo.change(1).TxID = "tx1"
+	if err := s.FailReceiver(context.Background(), testMerchantID, errors.New("tx expired")); err != nil {
+		t.Fatal(err)
+	}
+	if ch := repo.change(1); ch.Status != domain.ReceiverChangeFailed || ch.Error != "tx expired" || ch.TxID != "" {
+		t.Fatalf("after FailReceiver: %+v", ch)
+	}
+
+	ch, err := s.RequestReceiverChange(context.Background(), testMerchantID, receiver, "admin-1")
+	if err != nil {
+		t.Fatalf("request after failure: %v", err)
+	}
+	if ch.ID != 2 || ch.Status != domain.ReceiverChangePending {
+		t.Fatalf("change = %+v", ch)
+	}
+	history, err := s.ReceiverChanges(context.Background(), testMerchantID)
+	if err != nil || len(history) != 2 || history[0].ID != 2 {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+}
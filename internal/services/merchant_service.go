@@ -1 +1,380 @@
 package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// ErrMerchantNotFound is permanent: retrying the message will not help.
+var ErrMerchantNotFound = errors.New("merchant not found")
+
+var (
+	ErrInvalidMerchantStatus = errors.New("status must be ACTIVE or INACTIVE")
+	ErrMerchantNotOnChain    = errors.New("merchant is not registered on chain yet")
+	ErrStatusChangePending   = errors.New("a status change is already pending")
+	ErrOnboardingNotFailed   = errors.New("merchant onboarding has not failed")
+)
+
+type MerchantService struct {
+	repo  ports.MerchantRepo
+	chain ports.TronClient
+	log   *slog.Logger
+
+	// How long OnboardOnChain waits for the onboarding tx to land in a block.
+	ConfirmTimeout time.Duration
+	PollInterval   time.Duration
+
+	// Minimum time between two fund-receiver rotations.
+	ReceiverCooldown time.Duration
+
+	// Tokens merchants may enable.
+	Tokens *TokenSet
+}
+
+func NewMerchantService(repo ports.MerchantRepo, chain ports.TronClient, log *slog.Logger) *MerchantService {
+	return &MerchantService{
+		repo:             repo,
+		chain:            chain,
+		log:              log,
+		ConfirmTimeout:   90 * time.Second,
+		PollInterval:     3 * time.Second,
+		ReceiverCooldown: DefaultReceiverCooldown,
+	}
+}
+
+// OnboardOnChain registers the merchant in MerchantRegistryV1 and activates it.
+//
+// It is safe to call repeatedly for the same merchant:
+//   - a merchant already registered is a no-op (its follow-up event was
+//     committed to the outbox together with the activation)
+//   - a persisted chain_txid is awaited instead of rebroadcast
+//   - the chain is asked before broadcasting, so a crash between broadcast
+//     and persisting the txid never onboards twice
+//
+// An onboarding that was given up (FailOnboarding) is left alone until an
+// admin retries it (RetryOnboarding).
+func (s *MerchantService) OnboardOnChain(ctx context.Context, merchantID []byte) error {
+	m, err := s.repo.GetByID(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return ErrMerchantNotFound
+	}
+
+	if m.OnChain() {
+		return nil
+	}
+	if m.OnboardingFailed() {
+		s.log.Warn("merchant_onboard_skipped_failed", "merchant_id", hexID(merchantID), "error", m.ChainError)
+		return nil
+	}
+
+	txid := m.ChainTxID
+	if txid == "" {
+		onboarded, err := s.chain.IsMerchantOnboarded(ctx, merchantID)
+		if err != nil {
+			return fmt.Errorf("check onboarded: %w", err)
+		}
+		if onboarded {
+			s.log.Warn("merchant_already_onboarded", "merchant_id", hexID(merchantID))
+			return s.finalize(ctx, m, "", time.Now().UTC())
+		}
+
+		txid, err = s.chain.RegisterMerchant(ctx, merchantID, m.WalletAddress)
+		if err != nil {
+			return fmt.Errorf("register merchant: %w", err)
+		}
+		if err := s.repo.SetChainTxID(ctx, merchantID, txid); err != nil {
+			return err
+		}
+		s.log.Info("merchant_onboard_broadcast", "merchant_id", hexID(merchantID), "txid", txid)
+	}
+
+	receipt, err := s.waitReceipt(ctx, txid)
+	if err != nil {
+		return err
+	}
+	if !receipt.Found || !receipt.Success {
+		// Expired or reverted: forget the txid so the next attempt starts over
+		// (guarded by the IsMerchantOnboarded check above).
+		if err := s.repo.SetChainTxID(ctx, merchantID, ""); err != nil {
+			return err
+		}
+		if !receipt.Found {
+			return fmt.Errorf("onboard tx %s not confirmed within %s", txid, s.ConfirmTimeout)
+		}
+		return fmt.Errorf("onboard tx %s failed: %s", txid, receipt.Result)
+	}
+
+	return s.finalize(ctx, m, txid, receipt.BlockTime)
+}
+
+func (s *MerchantService) waitReceipt(ctx context.Context, txid string) (domain.ChainReceipt, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.ConfirmTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := s.chain.TransactionInfo(ctx, txid)
+		if err == nil && receipt.Found {
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return domain.ChainReceipt{TxID: txid}, nil
+			}
+			return domain.ChainReceipt{}, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *MerchantService) finalize(ctx context.Context, m *domain.Merchant, txid string, at time.Time) error {
+	env, err := events.New(ctx, events.MerchantOnchainRegistered{
+		MerchantID:    hexID(m.MerchantID),
+		WalletAddress: m.WalletAddress.String(),
+		TxID:          txid,
+		RegisteredAt:  at,
+	})
+	if err != nil {
+		return err
+	}
+	registered, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.MarkChainRegistered(ctx, m.MerchantID, txid, at, registered); err != nil {
+		return err
+	}
+	s.log.Info("merchant_onchain_registered", "merchant_id", hexID(m.MerchantID), "txid", txid)
+	return nil
+}
+
+// FailOnboarding gives up onboarding the merchant after cause, so it
+// shows as failed instead of pending forever. The chain is asked again on
+// retry, so a tx that lands after all is picked up then.
+func (s *MerchantService) FailOnboarding(ctx context.Context, merchantID []byte, cause error) error {
+	if err := s.repo.FailOnboarding(ctx, merchantID, cause.Error()); err != nil {
+		return err
+	}
+	s.log.Error("merchant_onboard_failed", "merchant_id", hexID(merchantID), "err", cause)
+	return nil
+}
+
+// RetryOnboarding asks the worker to onboard a merchant whose onboarding
+// was given up. It fails with ErrOnboardingNotFailed otherwise.
+func (s *MerchantService) RetryOnboarding(ctx context.Context, merchantID []byte, requestedBy string) (*domain.Merchant, error) {
+	m, err := s.Get(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.OnboardingFailed() {
+		return nil, ErrOnboardingNotFailed
+	}
+
+	env, err := events.New(ctx, events.MerchantOnboardRequested{
+		MerchantID:  hexID(merchantID),
+		RequestedBy: requestedBy,
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return nil, err
+	}
+
+	retried, err := s.repo.RetryOnboarding(ctx, merchantID, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !retried {
+		return nil, ErrOnboardingNotFailed
+	}
+	s.log.Info("merchant_onboard_retried", "merchant_id", hexID(merchantID), "requested_by", requestedBy)
+
+	m.ChainError, m.ChainFailedAt = "", nil
+	return m, nil
+}
+
+// Get returns the merchant or ErrMerchantNotFound.
+func (s *MerchantService) Get(ctx context.Context, merchantID []byte) (*domain.Merchant, error) {
+	m, err := s.repo.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMerchantNotFound
+	}
+	return m, nil
+}
+
+// RequestStatus asks for the merchant to be activated or deactivated. The
+// change is recorded as pending and applied on chain by the worker
+// (SyncStatus); merchants.status only follows once the chain confirms.
+// Requesting the current status with nothing pending is a no-op.
+func (s *MerchantService) RequestStatus(ctx context.Context, merchantID []byte, status domain.MerchantStatus, requestedBy string) (*domain.Merchant, error) {
+	if status != domain.MerchantActive && status != domain.MerchantInactive {
+		return nil, ErrInvalidMerchantStatus
+	}
+
+	m, err := s.Get(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !m.OnChain():
+		return nil, ErrMerchantNotOnChain
+	case m.StatusPending():
+		return nil, ErrStatusChangePending
+	case m.Status == status:
+		return m, nil
+	}
+
+	now := time.Now().UTC()
+	env, err := events.New(ctx, events.MerchantStatusChangeRequested{
+		MerchantID:  hexID(merchantID),
+		Status:      string(status),
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return nil, err
+	}
+
+	requested, err := s.repo.RequestStatus(ctx, merchantID, status, now, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !requested {
+		return nil, ErrStatusChangePending
+	}
+	s.log.Info("merchant_status_requested", "merchant_id", hexID(merchantID), "status", status, "requested_by", requestedBy)
+
+	m.RequestedStatus, m.StatusTxID, m.StatusRequestedAt = status, "", &now
+	m.StatusError, m.StatusFailedAt = "", nil
+	return m, nil
+}
+
+// FailStatus gives up the merchant's pending status change after cause.
+// The change is cleared, with cause recorded, so it can be requested again;
+// SyncStatus asks the chain first, so a tx that lands after all is
+// confirmed then rather than sent twice.
+func (s *MerchantService) FailStatus(ctx context.Context, merchantID []byte, cause error) error {
+	if err := s.repo.FailStatus(ctx, merchantID, cause.Error()); err != nil {
+		return err
+	}
+	s.log.Error("merchant_status_failed", "merchant_id", hexID(merchantID), "err", cause)
+	return nil
+}
+
+// SyncStatus applies the merchant's pending status change in
+// MerchantRegistryV1 and confirms it from the MerchantStatusUpdated event
+// the call emits. Like OnboardOnChain it is safe to call repeatedly: the
+// chain is asked before broadcasting and a persisted txid is awaited
+// instead of rebroadcast.
+func (s *MerchantService) SyncStatus(ctx context.Context, merchantID []byte) error {
+	m, err := s.Get(ctx, merchantID)
+	if err != nil {
+		return err
+	}
+	if !m.StatusPending() {
+		return nil
+	}
+	active := m.RequestedStatus == domain.MerchantActive
+
+	txid := m.StatusTxID
+	if txid == "" {
+		onChain, err := s.chain.IsMerchantActive(ctx, merchantID)
+		if err != nil {
+			return fmt.Errorf("check merchant active: %w", err)
+		}
+		if onChain == active {
+			s.log.Warn("merchant_status_already_on_chain", "merchant_id", hexID(merchantID), "status", m.RequestedStatus)
+			return s.confirmStatus(ctx, m, m.RequestedStatus, "")
+		}
+
+		txid, err = s.chain.UpdateMerchantStatus(ctx, merchantID, active)
+		if err != nil {
+			return fmt.Errorf("update merchant status: %w", err)
+		}
+		if err := s.repo.SetStatusTxID(ctx, merchantID, txid); err != nil {
+			return err
+		}
+		s.log.Info("merchant_status_broadcast", "merchant_id", hexID(merchantID), "status", m.RequestedStatus, "txid", txid)
+	}
+
+	receipt, err := s.waitReceipt(ctx, txid)
+	if err != nil {
+		return err
+	}
+	if !receipt.Found || !receipt.Success {
+		if err := s.repo.SetStatusTxID(ctx, merchantID, ""); err != nil {
+			return err
+		}
+		if !receipt.Found {
+			return fmt.Errorf("status tx %s not confirmed within %s", txid, s.ConfirmTimeout)
+		}
+		return fmt.Errorf("status tx %s failed: %s", txid, receipt.Result)
+	}
+
+	confirmed, found, err := s.chain.MerchantStatusUpdated(ctx, txid, merchantID)
+	if err != nil {
+		return fmt.Errorf("read status event: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("status tx %s emitted no MerchantStatusUpdated", txid)
+	}
+
+	status := domain.MerchantInactive
+	if confirmed {
+		status = domain.MerchantActive
+	}
+	return s.confirmStatus(ctx, m, status, txid)
+}
+
+func (s *MerchantService) confirmStatus(ctx context.Context, m *domain.Merchant, status domain.MerchantStatus, txid string) error {
+	env, err := events.New(ctx, events.MerchantStatusUpdated{
+		MerchantID: hexID(m.MerchantID),
+		Status:     string(status),
+		TxID:       txid,
+		UpdatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.ConfirmStatus(ctx, m.MerchantID, status, msg); err != nil {
+		return err
+	}
+	s.log.Info("merchant_status_confirmed", "merchant_id", hexID(m.MerchantID), "status", status, "txid", txid)
+	return nil
+}
+
+func hexID(b []byte) string {
+	s, _ := ids.Bytes32ToHex(b)
+	return s
+}
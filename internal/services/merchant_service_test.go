@@ -0,0 +1,586 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// memMerchants keeps merchants by id and records what the service
+// enqueued; GetByID hands out copies, as rows read from the database.
+type memMerchants struct {
+	ports.MerchantRepo
+	merchants map[string]*domain.Merchant
+	outbox    []domain.OutboxMessage
+	txids     []string                // every SetChainTxID, in order
+	changes   []domain.ReceiverChange // receiver rotations, oldest first
+	tokens    []*domain.MerchantToken
+}
+
+func newMemMerchants(merchants ...*domain.Merchant) *memMerchants {
+	r := &memMerchants{merchants: map[string]*domain.Merchant{}}
+	for _, m := range merchants {
+		r.merchants[string(m.MerchantID)] = m
+	}
+	return r
+}
+
+func (r *memMerchants) GetByID(_ context.Context, merchantID []byte) (*domain.Merchant, error) {
+	m, ok := r.merchants[string(merchantID)]
+	if !ok {
+		return nil, nil
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (r *memMerchants) SetChainTxID(_ context.Context, merchantID []byte, txid string) error {
+	r.merchants[string(merchantID)].ChainTxID = txid
+	r.txids = append(r.txids, txid)
+	return nil
+}
+
+func (r *memMerchants) MarkChainRegistered(_ context.Context, merchantID []byte, txid string, at time.Time, outbox ...domain.OutboxMessage) error {
+	m := r.merchants[string(merchantID)]
+	if m.OnChain() {
+		return nil
+	}
+	m.Status, m.ChainTxID, m.ChainRegisteredAt = domain.MerchantActive, txid, &at
+	r.outbox = append(r.outbox, outbox...)
+	return nil
+}
+
+func (r *memMerchants) FailOnboarding(_ context.Context, merchantID []byte, reason string) error {
+	m, now := r.merchants[string(merchantID)], time.Now()
+	m.ChainError, m.ChainFailedAt, m.ChainTxID = reason, &now, ""
+	return nil
+}
+
+func (r *memMerchants) RetryOnboarding(_ context.Context, merchantID []byte, outbox ...domain.OutboxMessage) (bool, error) {
+	m := r.merchants[string(merchantID)]
+	if !m.OnboardingFailed() {
+		return false, nil
+	}
+	m.ChainError, m.ChainFailedAt = "", nil
+	r.outbox = append(r.outbox, outbox...)
+	return true, nil
+}
+
+func (r *memMerchants) RequestStatus(_ context.Context, merchantID []byte, status domain.MerchantStatus, at time.Time, outbox ...domain.OutboxMessage) (bool, error) {
+	m := r.merchants[string(merchantID)]
+	if m.StatusPending() {
+		return false, nil
+	}
+	m.RequestedStatus, m.StatusRequestedAt, m.StatusError, m.StatusFailedAt = status, &at, "", nil
+	r.outbox = append(r.outbox, outbox...)
+	return true, nil
+}
+
+func (r *memMerchants) SetStatusTxID(_ context.Context, merchantID []byte, txid string) error {
+	r.merchants[string(merchantID)].StatusTxID = txid
+	r.txids = append(r.txids, txid)
+	return nil
+}
+
+func (r *memMerchants) ConfirmStatus(_ context.Context, merchantID []byte, status domain.MerchantStatus, outbox ...domain.OutboxMessage) error {
+	m := r.merchants[string(merchantID)]
+	if !m.StatusPending() {
+		return nil
+	}
+	m.Status, m.RequestedStatus, m.StatusTxID, m.StatusRequestedAt = status, "", "", nil
+	r.outbox = append(r.outbox, outbox...)
+	return nil
+}
+
+func (r *memMerchants) FailStatus(_ context.Context, merchantID []byte, reason string) error {
+	m, now := r.merchants[string(merchantID)], time.Now()
+	if !m.StatusPending() {
+		return nil
+	}
+	m.RequestedStatus, m.StatusTxID, m.StatusRequestedAt = "", "", nil
+	m.StatusError, m.StatusFailedAt = reason, &now
+	return nil
+}
+
+// fakeChain answers for MerchantRegistryV1 and records the calls made.
+type fakeChain struct {
+	ports.TronClient
+	calls []string
+
+	onboarded   bool
+	registerErr error
+	txid        string // returned by every broadcast
+	receipts    map[string]domain.ChainReceipt
+
+	active        bool  // merchant status in the registry
+	statusEvent   *bool // what MerchantStatusUpdated reports; the broadcast value by default
+	statusMissing bool  // the status tx emitted no MerchantStatusUpdated
+
+	receiver      domain.TronAddress  // fund receiver in the registry
+	receiverEvent *domain.TronAddress // what MerchantReceiverUpdated reports; the broadcast value by default
+
+	supported  map[domain.TronAddress]bool // tokens the registry accepts for the merchant
+	tokenEvent *domain.MerchantToken       // what MerchantTokenUpdated reports; the broadcast values by default
+}
+
+func (c *fakeChain) IsMerchantOnboarded(context.Context, []byte) (bool, error) {
+	c.calls = append(c.calls, "IsMerchantOnboarded")
+	return c.onboarded, nil
+}
+
+func (c *fakeChain) RegisterMerchant(context.Context, []byte, domain.TronAddress) (string, error) {
+	c.calls = append(c.calls, "RegisterMerchant")
+	if c.registerErr != nil {
+		return "", c.registerErr
+	}
+	return c.txid, nil
+}
+
+func (c *fakeChain) TransactionInfo(_ context.Context, txid string) (domain.ChainReceipt, error) {
+	c.calls = append(c.calls, "TransactionInfo")
+	return c.receipts[txid], nil
+}
+
+func (c *fakeChain) IsMerchantActive(context.Context, []byte) (bool, error) {
+	c.calls = append(c.calls, "IsMerchantActive")
+	return c.active, nil
+}
+
+func (c *fakeChain) UpdateMerchantStatus(_ context.Context, _ []byte, active bool) (string, error) {
+	c.calls = append(c.calls, "UpdateMerchantStatus")
+	if c.statusEvent == nil {
+		c.statusEvent = &active
+	}
+	return c.txid, nil
+}
+
+func (c *fakeChain) MerchantStatusUpdated(context.Context, string, []byte) (bool, bool, error) {
+	c.calls = append(c.calls, "MerchantStatusUpdated")
+	if c.statusMissing || c.statusEvent == nil {
+		return false, false, nil
+	}
+	return *c.statusEvent, true, nil
+}
+
+var (
+	testMerchantID = []byte(strings.Repeat("\x42", 32))
+	testBlockTime  = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func testAddress(t *testing.T, s string) domain.TronAddress {
+	t.Helper()
+	a, err := domain.ParseTronAddress(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func pendingMerchant(t *testing.T) *domain.Merchant {
+	return &domain.Merchant{
+		MerchantID:    testMerchantID,
+		WalletAddress: testAddress(t, testUSDT.Address),
+		Status:        domain.MerchantPending,
+	}
+}
+
+func newTestMerchantService(repo *memMerchants, chain *fakeChain) *MerchantService {
+	s := NewMerchantService(repo, chain, discardLog())
+	s.ConfirmTimeout, s.PollInterval = 50*time.Millisecond, time.Millisecond
+	return s
+}
+
+// outboxEvent decodes the payload of the only message in msgs.
+func outboxEvent[T events.Event](t *testing.T, msgs []domain.OutboxMessage) T {
+	t.Helper()
+	var payload T
+	if len(msgs) != 1 {
+		t.Fatalf("outbox = %d messages, want 1", len(msgs))
+	}
+	var env events.Envelope
+	if err := json.Unmarshal(msgs[0].Payload, &env); err != nil {
+		t.Fatal(err)
+	}
+	if msgs[0].RoutingKey != payload.EventType() || env.Type != payload.EventType() {
+		t.Fatalf("outbox message %s (%s), want %s", msgs[0].RoutingKey, env.Type, payload.EventType())
+	}
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestOnboardOnChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(m *domain.Merchant, c *fakeChain)
+		calls     string
+		txid      string // in merchant.onchain_registered
+		broadcast bool
+	}{
+		{
+			name:      "registers and activates",
+			calls:     "IsMerchantOnboarded RegisterMerchant TransactionInfo",
+			txid:      "tx1",
+			broadcast: true,
+		},
+		{
+			name:  "already onboarded on chain",
+			setup: func(_ *domain.Merchant, c *fakeChain) { c.onboarded = true },
+			calls: "IsMerchantOnboarded",
+		},
+		{
+			name: "persisted txid is awaited, not rebroadcast",
+			setup: func(m *domain.Merchant, c *fakeChain) {
+				m.ChainTxID = "tx0"
+				c.receipts["tx0"] = domain.ChainReceipt{TxID: "tx0", Found: true, Success: true, BlockTime: testBlockTime}
+			},
+			calls: "TransactionInfo",
+			txid:  "tx0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := pendingMerchant(t)
+			chain := &fakeChain{txid: "tx1", receipts: map[string]domain.ChainReceipt{
+				"tx1": {TxID: "tx1", Found: true, Success: true, BlockTime: testBlockTime},
+			}}
+			if tt.setup != nil {
+				tt.setup(m, chain)
+			}
+			repo := newMemMerchants(m)
+
+			if err := newTestMerchantService(repo, chain).OnboardOnChain(context.Background(), testMerchantID); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(chain.calls, " "); got != tt.calls {
+				t.Fatalf("chain calls = %q, want %q", got, tt.calls)
+			}
+			if m.Status != domain.MerchantActive || !m.OnChain() {
+				t.Fatalf("merchant = %s, registered at %v", m.Status, m.ChainRegisteredAt)
+			}
+			if tt.broadcast != (len(repo.txids) == 1 && repo.txids[0] == tt.txid) {
+				t.Fatalf("stored txids = %q", repo.txids)
+			}
+
+			ev := outboxEvent[events.MerchantOnchainRegistered](t, repo.outbox)
+			if ev.MerchantID != "0x"+strings.Repeat("42", 32) || ev.WalletAddress != testUSDT.Address || ev.TxID != tt.txid {
+				t.Fatalf("event = %+v", ev)
+			}
+			if tt.txid != "" && !ev.RegisteredAt.Equal(testBlockTime) {
+				t.Fatalf("registered at %v, want the block time", ev.RegisteredAt)
+			}
+		})
+	}
+}
+
+// Merchants already on chain, or whose onboarding was given up, are left
+// alone without asking the chain.
+func TestOnboardOnChainSkips(t *testing.T) {
+	now := time.Now()
+	for name, setup := range map[string]func(m *domain.Merchant){
+		"registered": func(m *domain.Merchant) { m.Status, m.ChainRegisteredAt = domain.MerchantActive, &now },
+		"failed":     func(m *domain.Merchant) { m.ChainError, m.ChainFailedAt = "reverted", &now },
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := pendingMerchant(t)
+			setup(m)
+			repo, chain := newMemMerchants(m), &fakeChain{}
+
+			if err := newTestMerchantService(repo, chain).OnboardOnChain(context.Background(), testMerchantID); err != nil {
+				t.Fatal(err)
+			}
+			if len(chain.calls) != 0 || len(repo.outbox) != 0 || len(repo.txids) != 0 {
+				t.Fatalf("calls %v, outbox %d, txids %q", chain.calls, len(repo.outbox), repo.txids)
+			}
+		})
+	}
+}
+
+func TestOnboardOnChainFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(c *fakeChain)
+		err     string
+		txids   string // stored txids, in order
+		unknown bool
+	}{
+		{
+			name: "reverted",
+			setup: func(c *fakeChain) {
+				c.receipts["tx1"] = domain.ChainReceipt{TxID: "tx1", Found: true, Result: "REVERT"}
+			},
+			err:   "onboard tx tx1 failed: REVERT",
+			txids: "tx1,",
+		},
+		{
+			name:  "not confirmed in time",
+			setup: func(c *fakeChain) { delete(c.receipts, "tx1") },
+			err:   "onboard tx tx1 not confirmed within",
+			txids: "tx1,",
+		},
+		{
+			name:  "broadcast fails",
+			setup: func(c *fakeChain) { c.registerErr = errors.New("node down") },
+			err:   "register merchant: node down",
+		},
+		{
+			name:    "unknown merchant",
+			err:     ErrMerchantNotFound.Error(),
+			unknown: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := pendingMerchant(t)
+			chain := &fakeChain{txid: "tx1", receipts: map[string]domain.ChainReceipt{
+				"tx1": {TxID: "tx1", Found: true, Success: true, BlockTime: testBlockTime},
+			}}
+			if tt.setup != nil {
+				tt.setup(chain)
+			}
+			repo := newMemMerchants(m)
+			id := testMerchantID
+			if tt.unknown {
+				id = make([]byte, 32)
+			}
+
+			err := newTestMerchantService(repo, chain).OnboardOnChain(context.Background(), id)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if tt.unknown && !errors.Is(err, ErrMerchantNotFound) {
+				t.Fatalf("err = %v, want ErrMerchantNotFound", err)
+			}
+			if got := strings.Join(repo.txids, ","); got != tt.txids {
+				t.Fatalf("stored txids = %q, want %q", got, tt.txids)
+			}
+			if m.Status != domain.MerchantPending || m.ChainTxID != "" || len(repo.outbox) != 0 {
+				t.Fatalf("merchant = %s, txid %q, outbox %d", m.Status, m.ChainTxID, len(repo.outbox))
+			}
+		})
+	}
+}
+
+func activeMerchant(t *testing.T) *domain.Merchant {
+	m := pendingMerchant(t)
+	registered := testBlockTime
+	m.Status, m.ChainRegisteredAt, m.ChainTxID = domain.MerchantActive, &registered, "tx0"
+	return m
+}
+
+func TestRequestStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(m *domain.Merchant)
+		status domain.MerchantStatus
+		err    error
+		queued bool
+	}{
+		{name: "deactivate", status: domain.MerchantInactive, queued: true},
+		{name: "already active", status: domain.MerchantActive},
+		{
+			name: "reactivate", status: domain.MerchantActive, queued: true,
+			setup: func(m *domain.Merchant) { m.Status = domain.MerchantInactive },
+		},
+		{name: "not a status", status: domain.MerchantPending, err: ErrInvalidMerchantStatus},
+		{
+			name: "not on chain yet", status: domain.MerchantInactive, err: ErrMerchantNotOnChain,
+			setup: func(m *domain.Merchant) { m.ChainRegisteredAt, m.Status = nil, domain.MerchantPending },
+		},
+		{
+			name: "change already pending", status: domain.MerchantActive, err: ErrStatusChangePending,
+			setup: func(m *domain.Merchant) { m.RequestedStatus = domain.MerchantInactive },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := activeMerchant(t)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			repo := newMemMerchants(m)
+
+			got, err := newTestMerchantService(repo, &fakeChain{}).RequestStatus(context.Background(), testMerchantID, tt.status, "admin-1")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if !tt.queued {
+				if len(repo.outbox) != 0 {
+					t.Fatalf("enqueued %d events", len(repo.outbox))
+				}
+				return
+			}
+			if got.RequestedStatus != tt.status || m.RequestedStatus != tt.status {
+				t.Fatalf("requested = %s (stored %s), want %s", got.RequestedStatus, m.RequestedStatus, tt.status)
+			}
+			ev := outboxEvent[events.MerchantStatusChangeRequested](t, repo.outbox)
+			if ev.Status != string(tt.status) || ev.RequestedBy != "admin-1" {
+				t.Fatalf("event = %+v", ev)
+			}
+		})
+	}
+
+	if _, err := newTestMerchantService(newMemMerchants(), &fakeChain{}).RequestStatus(context.Background(), testMerchantID, domain.MerchantInactive, "admin-1"); !errors.Is(err, ErrMerchantNotFound) {
+		t.Fatalf("unknown merchant: %v", err)
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(m *domain.Merchant, c *fakeChain)
+		calls   string
+		status  domain.MerchantStatus // merchants.status afterwards
+		err     string
+		pending bool
+		txid    string // status txid left for the next attempt
+	}{
+		{
+			name:   "deactivates on chain",
+			calls:  "IsMerchantActive UpdateMerchantStatus TransactionInfo MerchantStatusUpdated",
+			status: domain.MerchantInactive,
+		},
+		{
+			name:   "already applied on chain",
+			setup:  func(_ *domain.Merchant, c *fakeChain) { c.active = false },
+			calls:  "IsMerchantActive",
+			status: domain.MerchantInactive,
+		},
+		{
+			name: "persisted txid is awaited, not rebroadcast",
+			setup: func(m *domain.Merchant, c *fakeChain) {
+				m.StatusTxID = "tx1"
+				off := false
+				c.statusEvent = &off
+			},
+			calls:  "TransactionInfo MerchantStatusUpdated",
+			status: domain.MerchantInactive,
+		},
+		{
+			name: "chain reports the other status",
+			setup: func(_ *domain.Merchant, c *fakeChain) {
+				on := true
+				c.statusEvent = &on
+			},
+			calls:  "IsMerchantActive UpdateMerchantStatus TransactionInfo MerchantStatusUpdated",
+			status: domain.MerchantActive,
+		},
+		{
+			name: "reverted",
+			setup: func(_ *domain.Merchant, c *fakeChain) {
+				c.receipts["tx1"] = domain.ChainReceipt{TxID: "tx1", Found: true, Result: "REVERT"}
+			},
+			calls:   "IsMerchantActive UpdateMerchantStatus TransactionInfo",
+			status:  domain.MerchantActive,
+			err:     "status tx tx1 failed: REVERT",
+			pending: true,
+		},
+		{
+			name:    "no status event",
+			setup:   func(_ *domain.Merchant, c *fakeChain) { c.statusMissing = true },
+			calls:   "IsMerchantActive UpdateMerchantStatus TransactionInfo MerchantStatusUpdated",
+			status:  domain.MerchantActive,
+			err:     "status tx tx1 emitted no MerchantStatusUpdated",
+			pending: true,
+			txid:    "tx1",
+		},
+		{
+			name:   "nothing pending",
+			setup:  func(m *domain.Merchant, _ *fakeChain) { m.RequestedStatus = "" },
+			status: domain.MerchantActive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := activeMerchant(t)
+			requested := testBlockTime
+			m.RequestedStatus, m.StatusRequestedAt = domain.MerchantInactive, &requested
+			chain := &fakeChain{active: true, txid: "tx1", receipts: map[string]domain.ChainReceipt{
+				"tx1": {TxID: "tx1", Found: true, Success: true},
+			}}
+			if tt.setup != nil {
+				tt.setup(m, chain)
+			}
+			repo := newMemMerchants(m)
+
+			err := newTestMerchantService(repo, chain).SyncStatus(context.Background(), testMerchantID)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if got := strings.Join(chain.calls, " "); got != tt.calls {
+				t.Fatalf("chain calls = %q, want %q", got, tt.calls)
+			}
+			if m.Status != tt.status || m.StatusPending() != tt.pending {
+				t.Fatalf("status = %s, pending %v", m.Status, m.StatusPending())
+			}
+			if tt.pending {
+				if m.StatusTxID != tt.txid || len(repo.outbox) != 0 {
+					t.Fatalf("txid %q, outbox %d; want txid %q", m.StatusTxID, len(repo.outbox), tt.txid)
+				}
+				return
+			}
+			if tt.calls == "" {
+				return
+			}
+			ev := outboxEvent[events.MerchantStatusUpdated](t, repo.outbox)
+			if ev.Status != string(tt.status) {
+				t.Fatalf("event = %+v", ev)
+			}
+		})
+	}
+}
+
+// A given-up change can be requested again; a given-up onboarding waits
+// for an admin to retry it.
+func TestFailAndRetry(t *testing.T) {
+	m := activeMerchant(t)
+	m.RequestedStatus, m.StatusTxID = domain.MerchantInactive, "tx1"
+	repo := newMemMerchants(m)
+	s := newTestMerchantService(repo, &fakeChain{})
+
+	if err := s.FailStatus(context.Background(), testMerchantID, errors.New("out of energy")); err != nil {
+		t.Fatal(err)
+	}
+	if m.StatusPending() || m.StatusError != "out of energy" || m.StatusFailedAt == nil {
+		t.Fatalf("after FailStatus: %+v", m)
+	}
+	if _, err := s.RequestStatus(context.Background(), testMerchantID, domain.MerchantInactive, "admin-1"); err != nil {
+		t.Fatalf("request after failure: %v", err)
+	}
+	if m.StatusError != "" || m.StatusFailedAt != nil {
+		t.Fatal("a new request kept the old failure")
+	}
+
+	p := pendingMerchant(t)
+	repo = newMemMerchants(p)
+	s = newTestMerchantService(repo, &fakeChain{})
+	if _, err := s.RetryOnboarding(context.Background(), testMerchantID, "admin-1"); !errors.Is(err, ErrOnboardingNotFailed) {
+		t.Fatalf("retry of a pending onboarding: %v", err)
+	}
+	if err := s.FailOnboarding(context.Background(), testMerchantID, errors.New("tx expired")); err != nil {
+		t.Fatal(err)
+	}
+	if !p.OnboardingFailed() || p.ChainError != "tx expired" {
+		t.Fatalf("after FailOnboarding: %+v", p)
+	}
+
+	got, err := s.RetryOnboarding(context.Background(), testMerchantID, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OnboardingFailed() || p.OnboardingFailed() {
+		t.Fatal("onboarding still failed after retry")
+	}
+	ev := outboxEvent[events.MerchantOnboardRequested](t, repo.outbox)
+	if ev.MerchantID != "0x"+strings.Repeat("42", 32) || ev.RequestedBy != "admin-1" {
+		t.Fatalf("event = %+v", ev)
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+)
+
+var ErrTokenChangePending = errors.New("a change for this token is already pending")
+
+// MerchantTokens returns the merchant's state for every token it may enable,
+// followed by any other token it has requested.
+func (s *MerchantService) MerchantTokens(ctx context.Context, merchantID []byte) ([]domain.MerchantToken, error) {
+	rows, err := s.repo.ListTokens(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	byAddress := make(map[domain.TronAddress]domain.MerchantToken, len(rows))
+	for _, t := range rows {
+		byAddress[t.Token] = t
+	}
+
+	tokens := s.Tokens.List()
+	out := make([]domain.MerchantToken, 0, len(tokens)+len(rows))
+	for _, tok := range tokens {
+		addr, err := domain.ParseTronAddress(tok.Address)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", tok.Symbol, err)
+		}
+		t, ok := byAddress[addr]
+		if !ok {
+			t = domain.MerchantToken{MerchantID: merchantID, Token: addr}
+		}
+		t.Symbol = tok.Symbol
+		out = append(out, t)
+		delete(byAddress, addr)
+	}
+	for _, t := range rows {
+		if _, ok := byAddress[t.Token]; ok {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// RequestToken asks for the merchant to start (enabled) or stop accepting
+// token, a symbol or address from Tokens. The change is recorded as
+// pending and applied on chain by the worker (SyncToken); orders only
+// follow once the chain confirms. Requesting the current state with
+// nothing pending is a no-op.
+func (s *MerchantService) RequestToken(ctx context.Context, merchantID []byte, token string, enabled bool, requestedBy string) (*domain.MerchantToken, error) {
+	tok, err := findToken(s.Tokens.List(), token)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := domain.ParseTronAddress(tok.Address)
+	if err != nil {
+		return nil, fmt.Errorf("token %s: %w", tok.Symbol, err)
+	}
+
+	m, err := s.Get(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.OnChain() {
+		return nil, ErrMerchantNotOnChain
+	}
+
+	t, err := s.repo.GetToken(ctx, merchantID, addr)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		t = &domain.MerchantToken{MerchantID: merchantID, Token: addr}
+	}
+	t.Symbol = tok.Symbol
+	switch {
+	case t.Pending():
+		return nil, ErrTokenChangePending
+	case t.Enabled == enabled:
+		return t, nil
+	}
+
+	now := time.Now().UTC()
+	env, err := events.New(ctx, events.MerchantTokenChangeRequested{
+		MerchantID:   hexID(merchantID),
+		TokenAddress: addr.String(),
+		Enabled:      enabled,
+		RequestedBy:  requestedBy,
+		RequestedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return nil, err
+	}
+
+	requested, err := s.repo.RequestToken(ctx, merchantID, addr, enabled, requestedBy, now, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !requested {
+		return nil, ErrTokenChangePending
+	}
+	s.log.Info("merchant_token_requested", "merchant_id", hexID(merchantID), "token", addr.String(), "enabled", enabled, "requested_by", requestedBy)
+
+	t.RequestedEnabled, t.TxID, t.RequestedAt = &enabled, "", &now
+	t.Error, t.FailedAt = "", nil
+	return t, nil
+}
+
+// FailToken gives up the merchant's pending change of token after cause.
+// The change is cleared, with cause recorded, so it can be requested
+// again; SyncToken asks the chain first, so a tx that lands after all is
+// confirmed then rather than sent twice.
+func (s *MerchantService) FailToken(ctx context.Context, merchantID []byte, token domain.TronAddress, cause error) error {
+	if err := s.repo.FailToken(ctx, merchantID, token, cause.Error()); err != nil {
+		return err
+	}
+	s.log.Error("merchant_token_failed", "merchant_id", hexID(merchantID), "token", token.String(), "err", cause)
+	return nil
+}
+
+// SyncToken applies the merchant's pending change for token in
+// MerchantRegistryV1 and confirms it from the MerchantTokenUpdated event
+// the call emits. Like SyncStatus it is safe to call repeatedly.
+func (s *MerchantService) SyncToken(ctx context.Context, merchantID []byte, token domain.TronAddress) error {
+	t, err := s.repo.GetToken(ctx, merchantID, token)
+	if err != nil {
+		return err
+	}
+	if t == nil || !t.Pending() {
+		return nil
+	}
+	enabled := *t.RequestedEnabled
+
+	txid := t.TxID
+	if txid == "" {
+		onChain, err := s.chain.IsMerchantTokenSupported(ctx, merchantID, token)
+		if err != nil {
+			return fmt.Errorf("check merchant token: %w", err)
+		}
+		if onChain == enabled {
+			s.log.Warn("merchant_token_already_on_chain", "merchant_id", hexID(merchantID), "token", token.String(), "enabled", enabled)
+			return s.confirmToken(ctx, merchantID, token, enabled, "")
+		}
+
+		txid, err = s.chain.UpdateMerchantToken(ctx, merchantID, token, enabled)
+		if err != nil {
+			return fmt.Errorf("update merchant token: %w", err)
+		}
+		if err := s.repo.SetTokenTxID(ctx, merchantID, token, txid); err != nil {
+			return err
+		}
+		s.log.Info("merchant_token_broadcast", "merchant_id", hexID(merchantID), "token", token.String(), "enabled", enabled, "txid", txid)
+	}
+
+	receipt, err := s.waitReceipt(ctx, txid)
+	if err != nil {
+		return err
+	}
+	if !receipt.Found || !receipt.Success {
+		if err := s.repo.SetTokenTxID(ctx, merchantID, token, ""); err != nil {
+			return err
+		}
+		if !receipt.Found {
+			return fmt.Errorf("token tx %s not confirmed within %s", txid, s.ConfirmTimeout)
+		}
+		return fmt.Errorf("token tx %s failed: %s", txid, receipt.Result)
+	}
+
+	updated, confirmed, found, err := s.chain.MerchantTokenUpdated(ctx, txid, merchantID)
+	if err != nil {
+		return fmt.Errorf("read token event: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("token tx %s emitted no MerchantTokenUpdated", txid)
+	}
+	if updated != token {
+		return fmt.Errorf("token tx %s updated token %s, want %s", txid, updated, token)
+	}
+	return s.confirmToken(ctx, merchantID, token, confirmed, txid)
+}
+
+func (s *MerchantService) confirmToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool, txid string) error {
+	env, err := events.New(ctx, events.MerchantTokenUpdated{
+		MerchantID:   hexID(merchantID),
+		TokenAddress: token.String(),
+		Enabled:      enabled,
+		TxID:         txid,
+		UpdatedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.ConfirmToken(ctx, merchantID, token, enabled, msg); err != nil {
+		return err
+	}
+	s.log.Info("merchant_token_confirmed", "merchant_id", hexID(merchantID), "token", token.String(), "enabled", enabled, "txid", txid)
+	return nil
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+)
+
+func (r *memMerchants) findToken(merchantID []byte, token domain.TronAddress) *domain.MerchantToken {
+	for _, t := range r.tokens {
+		if bytes.Equal(t.MerchantID, merchantID) && t.Token == token {
+			return t
+		}
+	}
+	return nil
+}
+
+func (r *memMerchants) GetToken(_ context.Context, merchantID []byte, token domain.TronAddress) (*domain.MerchantToken, error) {
+	t := r.findToken(merchantID, token)
+	if t == nil {
+		return nil, nil
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (r *memMerchants) ListTokens(_ context.Context, merchantID []byte) ([]domain.MerchantToken, error) {
+	var out []domain.MerchantToken
+	for _, t := range r.tokens {
+		if bytes.Equal(t.MerchantID, merchantID) {
+			out = append(out, *t)
+		}
+	}
+	return out, nil
+}
+
+func (r *memMerchants) RequestToken(_ context.Context, merchantID []byte, token domain.TronAddress, enabled bool, _ string, at time.Time, outbox ...domain.OutboxMessage) (bool, error) {
+	t := r.findToken(merchantID, token)
+	if t == nil {
+		t = &domain.MerchantToken{MerchantID: merchantID, Token: token}
+		r.tokens = append(r.tokens, t)
+	}
+	if t.Pending() {
+		return false, nil
+	}
+	t.RequestedEnabled, t.RequestedAt, t.TxID, t.Error, t.FailedAt = &enabled, &at, "", "", nil
+	r.outbox = append(r.outbox, outbox...)
+	return true, nil
+}
+
+func (r *memMerchants) SetTokenTxID(_ context.Context, merchantID []byte, token domain.TronAddress, txid string) error {
+	r.findToken(merchantID, token).TxID = txid
+	r.txids = append(r.txids, txid)
+	return nil
+}
+
+func (r *memMerchants) ConfirmToken(_ context.Context, merchantID []byte, token domain.TronAddress, enabled bool, outbox ...domain.OutboxMessage) error {
+	t := r.findToken(merchantID, token)
+	if t == nil || !t.Pending() {
+		return nil
+	}
+	t.Enabled, t.RequestedEnabled, t.TxID, t.RequestedAt = enabled, nil, "", nil
+	r.outbox = append(r.outbox, outbox...)
+	return nil
+}
+
+func (r *memMerchants) FailToken(_ context.Context, merchantID []byte, token domain.TronAddress, reason string) error {
+	t := r.findToken(merchantID, token)
+	if t == nil || !t.Pending() {
+		return nil
+	}
+	now := time.Now()
+	t.RequestedEnabled, t.TxID, t.RequestedAt, t.Error, t.FailedAt = nil, "", nil, reason, &now
+	return nil
+}
+
+func (c *fakeChain) IsMerchantTokenSupported(_ context.Context, _ []byte, token domain.TronAddress) (bool, error) {
+	c.calls = append(c.calls, "IsMerchantTokenSupported")
+	return c.supported[token], nil
+}
+
+func (c *fakeChain) UpdateMerchantToken(_ context.Context, _ []byte, token domain.TronAddress, enabled bool) (string, error) {
+	c.calls = append(c.calls, "UpdateMerchantToken")
+	if c.tokenEvent == nil {
+		c.tokenEvent = &domain.MerchantToken{Token: token, Enabled: enabled}
+	}
+	return c.txid, nil
+}
+
+func (c *fakeChain) MerchantTokenUpdated(context.Context, string, []byte) (domain.TronAddress, bool, bool, error) {
+	c.calls = append(c.calls, "MerchantTokenUpdated")
+	if c.tokenEvent == nil {
+		return domain.TronAddress{}, false, false, nil
+	}
+	return c.tokenEvent.Token, c.tokenEvent.Enabled, true, nil
+}
+
+// merchantToken is the test merchant's row for token.
+func merchantToken(t *testing.T, token domain.Token, enabled bool) *domain.MerchantToken {
+	return &domain.MerchantToken{MerchantID: testMerchantID, Token: testAddress(t, token.Address), Enabled: enabled}
+}
+
+func newTestTokenService(repo *memMerchants, chain *fakeChain) *MerchantService {
+	s := newTestMerchantService(repo, chain)
+	s.Tokens = NewTokenSet([]domain.Token{testUSDT, testWTRX}, true)
+	return s
+}
+
+func TestMerchantTokens(t *testing.T) {
+	other, _ := domain.TronAddressFromBytes(bytes.Repeat([]byte{0x07}, 20))
+	repo := newMemMerchants(activeMerchant(t))
+	repo.tokens = []*domain.MerchantToken{
+		{MerchantID: testMerchantID, Token: other, Enabled: true},
+		merchantToken(t, testWTRX, true),
+	}
+
+	got, err := newTestTokenService(repo, &fakeChain{}).MerchantTokens(context.Background(), testMerchantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every listed token in list order, then the merchant's other rows.
+	want := []struct {
+		token   string
+		symbol  string
+		enabled bool
+	}{
+		{testUSDT.Address, "USDT", false},
+		{testWTRX.Address, "WTRX", true},
+		{other.String(), "", true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("tokens = %+v", got)
+	}
+	for i, w := range want {
+		if got[i].Token.String() != w.token || got[i].Symbol != w.symbol || got[i].Enabled != w.enabled {
+			t.Fatalf("tokens[%d] = %s %q enabled %v, want %+v", i, got[i].Token, got[i].Symbol, got[i].Enabled, w)
+		}
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	pending := true
+	tests := []struct {
+		name    string
+		setup   func(r *memMerchants, m *domain.Merchant)
+		token   string
+		enabled bool
+		err     error
+		queued  bool
+	}{
+		{name: "enable", token: "usdt", enabled: true, queued: true},
+		{name: "by address", token: testWTRX.Address, enabled: true, queued: true},
+		{
+			name: "disable", token: "USDT", queued: true,
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.tokens = append(r.tokens, merchantToken(t, testUSDT, true))
+			},
+		},
+		{
+			name: "already enabled", token: "USDT", enabled: true,
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				r.tokens = append(r.tokens, merchantToken(t, testUSDT, true))
+			},
+		},
+		{name: "already disabled", token: "USDT"},
+		{
+			name: "change pending", token: "USDT", enabled: true, err: ErrTokenChangePending,
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				mt := merchantToken(t, testUSDT, false)
+				mt.RequestedEnabled = &pending
+				r.tokens = append(r.tokens, mt)
+			},
+		},
+		{
+			name: "retry after failure", token: "USDT", enabled: true, queued: true,
+			setup: func(r *memMerchants, _ *domain.Merchant) {
+				mt := merchantToken(t, testUSDT, false)
+				mt.Error, mt.FailedAt = "tx expired", &testBlockTime
+				r.tokens = append(r.tokens, mt)
+			},
+		},
+		{name: "not listed", token: "USDC", enabled: true, err: ErrUnsupportedToken},
+		{
+			name: "not on chain yet", token: "USDT", enabled: true, err: ErrMerchantNotOnChain,
+			setup: func(_ *memMerchants, m *domain.Merchant) { m.ChainRegisteredAt = nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := activeMerchant(t)
+			repo := newMemMerchants(m)
+			if tt.setup != nil {
+				tt.setup(repo, m)
+			}
+
+			got, err := newTestTokenService(repo, &fakeChain{}).RequestToken(context.Background(), testMerchantID, tt.token, tt.enabled, "admin-1")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !tt.queued {
+				if got.Pending() || got.Enabled != tt.enabled || len(repo.outbox) != 0 {
+					t.Fatalf("no-op request queued a change: %+v, outbox %d", got, len(repo.outbox))
+				}
+				return
+			}
+			if !got.Pending() || *got.RequestedEnabled != tt.enabled || got.Error != "" {
+				t.Fatalf("token = %+v", got)
+			}
+			if stored := repo.findToken(testMerchantID, got.Token); stored.Enabled == tt.enabled {
+				t.Fatal("enabled moved before the chain confirmed")
+			}
+			ev := outboxEvent[events.MerchantTokenChangeRequested](t, repo.outbox)
+			if ev.TokenAddress != got.Token.String() || ev.Enabled != tt.enabled || ev.RequestedBy != "admin-1" {
+				t.Fatalf("event = %+v", ev)
+			}
+		})
+	}
+}
+
+func TestSyncToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(mt *domain.MerchantToken, c *fakeChain)
+		calls   string
+		err     string
+		enabled bool
+		pending bool
+		txid    string // in merchant.token_updated, or left on the pending change
+	}{
+		{
+			name:    "enables on chain",
+			calls:   "IsMerchantTokenSupported UpdateMerchantToken TransactionInfo MerchantTokenUpdated",
+			enabled: true,
+			txid:    "tx1",
+		},
+		{
+			name: "already applied on chain",
+			setup: func(mt *domain.MerchantToken, c *fakeChain) {
+				c.supported[mt.Token] = true
+			},
+			calls:   "IsMerchantTokenSupported",
+			enabled: true,
+		},
+		{
+			name: "persisted txid is awaited, not rebroadcast",
+			setup: func(mt *domain.MerchantToken, c *fakeChain) {
+				mt.TxID = "tx1"
+				c.tokenEvent = &domain.MerchantToken{Token: mt.Token, Enabled: true}
+			},
+			calls:   "TransactionInfo MerchantTokenUpdated",
+			enabled: true,
+			txid:    "tx1",
+		},
+		{
+			name: "reverted",
+			setup: func(_ *domain.MerchantToken, c *fakeChain) {
+				c.receipts["tx1"] = domain.ChainReceipt{TxID: "tx1", Found: true, Result: "REVERT"}
+			},
+			calls:   "IsMerchantTokenSupported UpdateMerchantToken TransactionInfo",
+			err:     "token tx tx1 failed: REVERT",
+			pending: true,
+		},
+		{
+			name: "event for another token",
+			setup: func(_ *domain.MerchantToken, c *fakeChain) {
+				c.tokenEvent = &domain.MerchantToken{Token: testAddress(t, testWTRX.Address), Enabled: true}
+			},
+			calls:   "IsMerchantTokenSupported UpdateMerchantToken TransactionInfo MerchantTokenUpdated",
+			err:     "token tx tx1 updated token " + testWTRX.Address + ", want " + testUSDT.Address,
+			pending: true,
+			txid:    "tx1",
+		},
+		{
+			name:  "nothing pending",
+			setup: func(mt *domain.MerchantToken, _ *fakeChain) { mt.RequestedEnabled = nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemMerchants(activeMerchant(t))
+			enable := true
+			mt := merchantToken(t, testUSDT, false)
+			mt.RequestedEnabled = &enable
+			repo.tokens = []*domain.MerchantToken{mt}
+			chain := &fakeChain{txid: "tx1", supported: map[domain.TronAddress]bool{}, receipts: map[string]domain.ChainReceipt{
+				"tx1": {TxID: "tx1", Found: true, Success: true},
+			}}
+			if tt.setup != nil {
+				tt.setup(mt, chain)
+			}
+
+			err := newTestTokenService(repo, chain).SyncToken(context.Background(), testMerchantID, mt.Token)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if got := strings.Join(chain.calls, " "); got != tt.calls {
+				t.Fatalf("chain calls = %q, want %q", got, tt.calls)
+			}
+			if mt.Enabled != tt.enabled || mt.Pending() != tt.pending {
+				t.Fatalf("enabled = %v, pending %v", mt.Enabled, mt.Pending())
+			}
+			if tt.pending || tt.calls == "" {
+				if mt.TxID != tt.txid || len(repo.outbox) != 0 {
+					t.Fatalf("txid %q, outbox %d; want txid %q", mt.TxID, len(repo.outbox), tt.txid)
+				}
+				return
+			}
+			ev := outboxEvent[events.MerchantTokenUpdated](t, repo.outbox)
+			if ev.TokenAddress != testUSDT.Address || !ev.Enabled || ev.TxID != tt.txid {
+				t.Fatalf("event = %+v", ev)
+			}
+		})
+	}
+}
+
+// A change the worker gave up on is cleared and can be requested again.
+func TestFailToken(t *testing.T) {
+	repo := newMemMerchants(activeMerchant(t))
+	s := newTestTokenService(repo, &fakeChain{})
+
+	mt, err := s.RequestToken(context.Background(), testMerchantID, "USDT", true, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FailToken(context.Background(), testMerchantID, mt.Token, errors.New("tx expired")); err != nil {
+		t.Fatal(err)
+	}
+	stored := repo.findToken(testMerchantID, mt.Token)
+	if stored.Pending() || stored.Enabled || stored.Error != "tx expired" {
+		t.Fatalf("after FailToken: %+v", stored)
+	}
+
+	again, err := s.RequestToken(context.Background(), testMerchantID, "USDT", true, "admin-1")
+	if err != nil {
+		t.Fatalf("request after failure: %v", err)
+	}
+	if !again.Pending() || again.Error != "" || stored.Error != "" {
+		t.Fatalf("retried change kept the failure: %+v", stored)
+	}
+}
+
+type createdOrders struct {
+	memOrders
+	created []*domain.Order
+}
+
+func (r *createdOrders) Create(_ context.Context, o *domain.Order) error {
+	r.created = append(r.created, o)
+	return nil
+}
+
+// Orders are only opened in tokens the chain confirmed for the merchant.
+func TestCreateOrderNeedsEnabledToken(t *testing.T) {
+	merchants := newMemMerchants(activeMerchant(t))
+	merchants.tokens = []*domain.MerchantToken{merchantToken(t, testUSDT, true)}
+	enable := true
+	pendingWTRX := merchantToken(t, testWTRX, false)
+	pendingWTRX.RequestedEnabled = &enable
+	merchants.tokens = append(merchants.tokens, pendingWTRX)
+
+	orders := &createdOrders{}
+	s, err := NewOrderService(orders, merchants, &memPayments{}, NewTokenSet([]domain.Token{testUSDT, testWTRX}, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := s.Create(context.Background(), testMerchantID, CreateOrderInput{Amount: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Currency != "USDT" || len(orders.created) != 1 {
+		t.Fatalf("order = %+v", o)
+	}
+
+	_, err = s.Create(context.Background(), testMerchantID, CreateOrderInput{Amount: "10", Token: "WTRX"})
+	if !errors.Is(err, ErrTokenNotEnabled) || len(orders.created) != 1 {
+		t.Fatalf("order in a token not yet confirmed: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+var (
+	// ErrOperatorLowResources is transient: the transaction stays queued,
+	// without using up retries, until the operator account is topped up.
+	ErrOperatorLowResources = errors.New("operator resources below budget")
+
+	// ErrOperatorBudgetRefused is returned instead in refuse mode: the
+	// transaction is retried like any transient failure and given up once
+	// the retries run out.
+	ErrOperatorBudgetRefused = fmt.Errorf("%w: transaction refused", ErrOperatorLowResources)
+)
+
+// OperatorBudget guards the operator account: a transaction is only
+// signed if the TRX it would burn leaves at least MinBalanceSun behind.
+// Otherwise it raises an ops.operator_low_resources alert, repeated every
+// AlertInterval while the account stays short, and holds the transaction
+// back. It implements tron.BudgetGuard.
+type OperatorBudget struct {
+	chain  ports.OperatorChain
+	outbox ports.Outbox
+	log    *slog.Logger
+
+	// MinBalanceSun is the TRX (in sun) that must remain after paying for
+	// the transaction's missing energy and bandwidth.
+	MinBalanceSun int64
+
+	// Refuse gives over-budget transactions the usual retries instead of
+	// leaving them queued until the account is topped up.
+	Refuse bool
+
+	// AlertInterval rate-limits alerts while the account stays short.
+	AlertInterval time.Duration
+
+	mu        sync.Mutex
+	lastAlert time.Time
+}
+
+func NewOperatorBudget(chain ports.OperatorChain, outbox ports.Outbox, log *slog.Logger) *OperatorBudget {
+	return &OperatorBudget{
+		chain:         chain,
+		outbox:        outbox,
+		log:           log,
+		MinBalanceSun: 20_000_000,
+		AlertInterval: 15 * time.Minute,
+	}
+}
+
+// Check returns nil if the operator can afford cost within the budget.
+func (b *OperatorBudget) Check(ctx context.Context, function string, cost domain.TxCost) error {
+	res, err := b.chain.OperatorResources(ctx)
+	if err != nil {
+		return fmt.Errorf("operator resources: %w", err)
+	}
+
+	burn := res.BurnSun(cost)
+	if res.BalanceSun-burn >= b.MinBalanceSun {
+		return nil
+	}
+
+	b.alert(ctx, function, res, cost, burn)
+
+	reason := ErrOperatorLowResources
+	if b.Refuse {
+		reason = ErrOperatorBudgetRefused
+	}
+	return fmt.Errorf("%w: %s needs %d energy, %d bandwidth (burns %d sun), balance %d sun, minimum %d sun",
+		reason, function, cost.Energy, cost.Bandwidth, burn, res.BalanceSun, b.MinBalanceSun)
+}
+
+func (b *OperatorBudget) alert(ctx context.Context, function string, res domain.AccountResources, cost domain.TxCost, burn int64) {
+	now := time.Now().UTC()
+	attrs := []any{
+		"address", res.Address.String(),
+		"function", function,
+		"balance_sun", res.BalanceSun,
+		"energy", res.Energy,
+		"bandwidth", res.Bandwidth,
+		"required_energy", cost.Energy,
+		"burn_sun", burn,
+		"min_balance_sun", b.MinBalanceSun,
+		"refused", b.Refuse,
+	}
+	b.log.Error("operator_low_resources", attrs...)
+
+	b.mu.Lock()
+	if !b.lastAlert.IsZero() && now.Sub(b.lastAlert) < b.AlertInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.lastAlert = now
+	b.mu.Unlock()
+
+	env, err := events.New(ctx, events.OperatorLowResources{
+		Address:           res.Address.String(),
+		Function:          function,
+		BalanceSun:        res.BalanceSun,
+		Energy:            res.Energy,
+		Bandwidth:         res.Bandwidth,
+		RequiredEnergy:    cost.Energy,
+		RequiredBandwidth: cost.Bandwidth,
+		BurnSun:           burn,
+		MinBalanceSun:     b.MinBalanceSun,
+		Refused:           b.Refuse,
+		DetectedAt:        now,
+	})
+	if err == nil {
+		var msg domain.OutboxMessage
+		if msg, err = domain.NewOutboxMessage(env.Type, env); err == nil {
+			err = b.outbox.Enqueue(ctx, msg)
+		}
+	}
+	if err != nil {
+		// Let the next check try again.
+		b.mu.Lock()
+		b.lastAlert = time.Time{}
+		b.mu.Unlock()
+		b.log.Error("operator_alert_failed", "err", err)
+	}
+}
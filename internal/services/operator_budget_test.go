@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/events"
+)
+
+type fakeOperator struct {
+	res domain.AccountResources
+	err error
+}
+
+func (f *fakeOperator) OperatorResources(context.Context) (domain.AccountResources, error) {
+	return f.res, f.err
+}
+
+type memOutbox struct {
+	msgs []domain.OutboxMessage
+	err  error
+}
+
+func (o *memOutbox) Enqueue(_ context.Context, msgs ...domain.OutboxMessage) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.msgs = append(o.msgs, msgs...)
+	return nil
+}
+
+func discardLog() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+// Prices are Tron's current 100 sun per energy unit and 1000 per byte.
+func operatorResources(balance, energy, bandwidth int64) domain.AccountResources {
+	return domain.AccountResources{
+		BalanceSun:        balance,
+		Energy:            energy,
+		Bandwidth:         bandwidth,
+		EnergyPriceSun:    100,
+		BandwidthPriceSun: 1000,
+	}
+}
+
+func TestOperatorBudgetThresholds(t *testing.T) {
+	cost := domain.TxCost{Energy: 30_000, Bandwidth: 300}
+
+	tests := []struct {
+		name string
+		res  domain.AccountResources
+		ok   bool
+	}{
+		{"covered by staked resources", operatorResources(20_000_000, 30_000, 300), true},
+		{"burn leaves exactly the minimum", operatorResources(23_300_000, 0, 0), true},
+		{"burn leaves one sun less", operatorResources(23_299_999, 0, 0), false},
+		{"energy short only", operatorResources(22_999_999, 0, 300), false},
+		{"energy short, burn affordable", operatorResources(23_000_000, 0, 300), true},
+		{"bandwidth short burns the full size", operatorResources(20_299_999, 30_000, 299), false},
+		{"below minimum before any burn", operatorResources(19_999_999, 30_000, 300), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewOperatorBudget(&fakeOperator{res: tt.res}, &memOutbox{}, discardLog())
+			b.MinBalanceSun = 20_000_000
+
+			err := b.Check(context.Background(), "onboardMerchant", cost)
+			if tt.ok && err != nil {
+				t.Fatalf("Check = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrOperatorLowResources) {
+				t.Fatalf("Check = %v, want ErrOperatorLowResources", err)
+			}
+		})
+	}
+}
+
+func TestOperatorBudgetModes(t *testing.T) {
+	short := operatorResources(1_000_000, 0, 0)
+	cost := domain.TxCost{Energy: 30_000, Bandwidth: 300}
+
+	tests := []struct {
+		name    string
+		refuse  bool
+		refused bool
+	}{
+		{"queue", false, false},
+		{"refuse", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := &memOutbox{}
+			b := NewOperatorBudget(&fakeOperator{res: short}, outbox, discardLog())
+			b.Refuse = tt.refuse
+
+			err := b.Check(context.Background(), "updateMerchantStatus", cost)
+			if !errors.Is(err, ErrOperatorLowResources) {
+				t.Fatalf("Check = %v, want ErrOperatorLowResources", err)
+			}
+			if got := errors.Is(err, ErrOperatorBudgetRefused); got != tt.refused {
+				t.Fatalf("refused = %v, want %v (err %v)", got, tt.refused, err)
+			}
+
+			if len(outbox.msgs) != 1 {
+				t.Fatalf("enqueued %d alerts, want 1", len(outbox.msgs))
+			}
+			m := outbox.msgs[0]
+			if m.RoutingKey != events.OperatorLowResourcesKey {
+				t.Fatalf("alert routing key = %s", m.RoutingKey)
+			}
+			var env events.Envelope
+			if err := json.Unmarshal(m.Payload, &env); err != nil {
+				t.Fatal(err)
+			}
+			var alert events.OperatorLowResources
+			if err := json.Unmarshal(env.Payload, &alert); err != nil {
+				t.Fatal(err)
+			}
+			if alert.Refused != tt.refused || alert.Function != "updateMerchantStatus" || alert.BurnSun != 3_300_000 {
+				t.Fatalf("alert = %+v", alert)
+			}
+		})
+	}
+}
+
+func TestOperatorBudgetRateLimitsAlerts(t *testing.T) {
+	outbox := &memOutbox{}
+	b := NewOperatorBudget(&fakeOperator{res: operatorResources(0, 0, 0)}, outbox, discardLog())
+	b.AlertInterval = time.Hour
+	cost := domain.TxCost{Energy: 1, Bandwidth: 1}
+
+	for i := 0; i < 3; i++ {
+		if err := b.Check(context.Background(), "onboardMerchant", cost); err == nil {
+			t.Fatal("Check passed with an empty account")
+		}
+	}
+	if len(outbox.msgs) != 1 {
+		t.Fatalf("enqueued %d alerts within the interval, want 1", len(outbox.msgs))
+	}
+
+	// A failed alert is retried on the next check rather than waiting out
+	// the interval.
+	b.lastAlert = time.Time{}
+	outbox.err = errors.New("db down")
+	_ = b.Check(context.Background(), "onboardMerchant", cost)
+	outbox.err = nil
+	_ = b.Check(context.Background(), "onboardMerchant", cost)
+	if len(outbox.msgs) != 2 {
+		t.Fatalf("enqueued %d alerts, want 2 after a failed one", len(outbox.msgs))
+	}
+}
+
+func TestOperatorBudgetResourceLookupFails(t *testing.T) {
+	b := NewOperatorBudget(&fakeOperator{err: errors.New("node down")}, &memOutbox{}, discardLog())
+	err := b.Check(context.Background(), "onboardMerchant", domain.TxCost{})
+	if err == nil || errors.Is(err, ErrOperatorLowResources) {
+		t.Fatalf("Check = %v, want a plain lookup error", err)
+	}
+}
@@ -1 +1,185 @@
 package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrMerchantNotActive  = errors.New("merchant is not active")
+	ErrUnsupportedToken   = errors.New("unsupported token")
+	ErrExternalRefTooLong = fmt.Errorf("external_ref must be at most %d characters", MaxExternalRefLen)
+	ErrNoOrderTokens      = errors.New("no order tokens loaded")
+	ErrTokenNotEnabled    = errors.New("token is not enabled for this merchant")
+)
+
+const (
+	MaxExternalRefLen = 128
+
+	DefaultOrderListLimit = 50
+	MaxOrderListLimit     = 200
+)
+
+type CreateOrderInput struct {
+	Amount      string
+	Token       string // symbol or address; empty = default token
+	ExternalRef string
+}
+
+type OrderService struct {
+	repo      ports.OrderRepo
+	merchants ports.MerchantRepo
+	payments  ports.PaymentRepo
+	tokens    *TokenSet
+}
+
+// NewOrderService prices orders in tokens; the first one is the default.
+// Until tokens has any, orders fail with ErrNoOrderTokens.
+func NewOrderService(repo ports.OrderRepo, merchants ports.MerchantRepo, payments ports.PaymentRepo, tokens *TokenSet) (*OrderService, error) {
+	if tokens == nil {
+		return nil, ErrNoOrderTokens
+	}
+	return &OrderService{repo: repo, merchants: merchants, payments: payments, tokens: tokens}, nil
+}
+
+// Create opens a PENDING order with fresh bytes32 order and invoice ids.
+func (s *OrderService) Create(ctx context.Context, merchantID []byte, in CreateOrderInput) (*domain.Order, error) {
+	amount, err := domain.NormalizeAmount(in.Amount)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.resolveToken(in.Token)
+	if err != nil {
+		return nil, err
+	}
+	// The amount must be payable exactly in the token's base units.
+	if _, err := token.ToBaseUnits(amount); err != nil {
+		return nil, err
+	}
+	externalRef := strings.TrimSpace(in.ExternalRef)
+	if len(externalRef) > MaxExternalRefLen {
+		return nil, ErrExternalRefTooLong
+	}
+
+	m, err := s.merchants.GetByID(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrMerchantNotFound
+	}
+	if m.Status != domain.MerchantActive {
+		// payTx only accepts merchants onboarded in MerchantRegistryV1.
+		return nil, ErrMerchantNotActive
+	}
+	if err := s.checkTokenEnabled(ctx, merchantID, token); err != nil {
+		return nil, err
+	}
+
+	orderID, err := ids.NewBytes32()
+	if err != nil {
+		return nil, fmt.Errorf("generate order_id: %w", err)
+	}
+	invoiceID, err := ids.NewBytes32()
+	if err != nil {
+		return nil, fmt.Errorf("generate invoice_id: %w", err)
+	}
+
+	o := &domain.Order{
+		OrderID:       orderID,
+		InvoiceID:     invoiceID,
+		MerchantID:    merchantID,
+		Amount:        amount,
+		Currency:      token.Symbol,
+		TokenAddress:  token.Address,
+		ExternalRef:   externalRef,
+		PaymentStatus: domain.PaymentPending,
+		AmountPaid:    "0",
+		Settlement:    domain.SettlementUnpaid,
+	}
+	if err := s.repo.Create(ctx, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// checkTokenEnabled rejects tokens the chain has not confirmed the merchant
+// accepts: payTx would revert.
+func (s *OrderService) checkTokenEnabled(ctx context.Context, merchantID []byte, token domain.Token) error {
+	addr, err := domain.ParseTronAddress(token.Address)
+	if err != nil {
+		return fmt.Errorf("token %s: %w", token.Symbol, err)
+	}
+	mt, err := s.merchants.GetToken(ctx, merchantID, addr)
+	if err != nil {
+		return err
+	}
+	if mt == nil || !mt.Enabled {
+		return fmt.Errorf("%w: %s", ErrTokenNotEnabled, token.Symbol)
+	}
+	return nil
+}
+
+func (s *OrderService) Get(ctx context.Context, merchantID, orderID []byte) (*domain.Order, error) {
+	o, err := s.repo.GetByOrderID(ctx, merchantID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, ErrOrderNotFound
+	}
+	return o, nil
+}
+
+// Payments returns the on-chain payments made for the merchant's order,
+// with their matching outcome.
+func (s *OrderService) Payments(ctx context.Context, merchantID, orderID []byte) ([]domain.Payment, error) {
+	if _, err := s.Get(ctx, merchantID, orderID); err != nil {
+		return nil, err
+	}
+	return s.payments.ListByOrder(ctx, orderID)
+}
+
+// List pages through the merchant's orders, newest first. limit is clamped
+// to MaxOrderListLimit; 0 means DefaultOrderListLimit.
+func (s *OrderService) List(ctx context.Context, merchantID []byte, limit, offset int) ([]domain.Order, error) {
+	if limit <= 0 {
+		limit = DefaultOrderListLimit
+	}
+	if limit > MaxOrderListLimit {
+		limit = MaxOrderListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.ListByMerchant(ctx, merchantID, limit, offset)
+}
+
+func (s *OrderService) resolveToken(token string) (domain.Token, error) {
+	tokens := s.tokens.List()
+	if len(tokens) == 0 {
+		return domain.Token{}, ErrNoOrderTokens
+	}
+	if strings.TrimSpace(token) == "" {
+		return tokens[0], nil
+	}
+	return findToken(tokens, token)
+}
+
+// findToken looks token up by address or (case-insensitive) symbol.
+func findToken(tokens []domain.Token, token string) (domain.Token, error) {
+	token = strings.TrimSpace(token)
+	for _, t := range tokens {
+		if t.Address == token || strings.EqualFold(t.Symbol, token) {
+			return t, nil
+		}
+	}
+	return domain.Token{}, ErrUnsupportedToken
+}
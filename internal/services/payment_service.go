@@ -1 +1,92 @@
 package service
+
+import (
+	"context"
+	"fmt"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// PaymentService ties confirmed on-chain payments to what their order owes.
+type PaymentService struct {
+	orders   ports.OrderRepo
+	payments ports.PaymentRepo
+	tokens   *TokenSet
+}
+
+func NewPaymentService(orders ports.OrderRepo, payments ports.PaymentRepo, tokens *TokenSet) *PaymentService {
+	return &PaymentService{orders: orders, payments: payments, tokens: tokens}
+}
+
+// Settle re-matches every confirmed payment of the order, in chain order,
+// and stores each payment's classification and the order's settlement.
+// The order becomes SUCCESS once it has received at least its amount in
+// its own token, and is PENDING otherwise. Until the token set is complete
+// an order in a token not loaded yet fails with a retryable error rather
+// than being held for review.
+//
+// Call it in the transaction that confirmed a payment (see
+// ports.Transactor): it locks the order row so concurrent confirmations
+// settle one after the other.
+func (s *PaymentService) Settle(ctx context.Context, orderID []byte) (domain.MatchResult, error) {
+	o, err := s.orders.GetForUpdate(ctx, orderID)
+	if err != nil {
+		return domain.MatchResult{}, err
+	}
+	if o == nil {
+		return domain.MatchResult{}, ErrOrderNotFound
+	}
+
+	token, ok := s.token(o.TokenAddress)
+	if !ok && !s.tokens.Complete() {
+		return domain.MatchResult{}, fmt.Errorf("order %s: token %s not loaded yet", hexID(orderID), o.TokenAddress)
+	}
+	if !ok {
+		return domain.MatchResult{}, fmt.Errorf("order %s: %w %s: %w", hexID(orderID), ErrUnsupportedToken, o.TokenAddress, domain.ErrNeedsReview)
+	}
+	due, err := token.ToBaseUnits(o.Amount)
+	if err != nil {
+		return domain.MatchResult{}, fmt.Errorf("order %s amount: %w", hexID(orderID), err)
+	}
+
+	payments, err := s.payments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return domain.MatchResult{}, err
+	}
+	res, err := domain.MatchPayments(due, token.Address, payments)
+	if err != nil {
+		return domain.MatchResult{}, err
+	}
+
+	for _, p := range payments {
+		if m, ok := res.Matches[p.PaymentUID]; ok && m != p.Match {
+			if err := s.payments.SetMatch(ctx, p.PaymentUID, m); err != nil {
+				return domain.MatchResult{}, err
+			}
+		}
+	}
+
+	paid, err := token.FromBaseUnits(res.Paid)
+	if err != nil {
+		return domain.MatchResult{}, fmt.Errorf("order %s paid amount: %w", hexID(orderID), err)
+	}
+	status := domain.PaymentPending
+	if res.Settlement.Settled() {
+		status = domain.PaymentSuccess
+	}
+	if err := s.orders.SetSettlement(ctx, orderID, paid, res.Settlement, status); err != nil {
+		return domain.MatchResult{}, err
+	}
+	return res, nil
+}
+
+// token looks an order's token up by address.
+func (s *PaymentService) token(address string) (domain.Token, bool) {
+	for _, t := range s.tokens.List() {
+		if t.Address == address {
+			return t, true
+		}
+	}
+	return domain.Token{}, false
+}
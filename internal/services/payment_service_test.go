@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// memOrders keeps orders by order id; methods the tests do not reach are
+// left to the embedded interface.
+type memOrders struct {
+	ports.OrderRepo
+	orders map[string]*domain.Order
+}
+
+func newMemOrders(orders ...*domain.Order) *memOrders {
+	r := &memOrders{orders: map[string]*domain.Order{}}
+	for _, o := range orders {
+		r.orders[string(o.OrderID)] = o
+	}
+	return r
+}
+
+func (r *memOrders) GetForUpdate(_ context.Context, orderID []byte) (*domain.Order, error) {
+	return r.orders[string(orderID)], nil
+}
+
+func (r *memOrders) SetSettlement(_ context.Context, orderID []byte, paid string, s domain.Settlement, status domain.PaymentStatus) error {
+	o := r.orders[string(orderID)]
+	o.AmountPaid, o.Settlement, o.PaymentStatus = paid, s, status
+	return nil
+}
+
+type memPayments struct {
+	ports.PaymentRepo
+	payments []domain.Payment
+	setMatch int
+}
+
+func (r *memPayments) ListByOrder(_ context.Context, orderID []byte) ([]domain.Payment, error) {
+	var out []domain.Payment
+	for _, p := range r.payments {
+		if string(p.OrderID) == string(orderID) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (r *memPayments) SetMatch(_ context.Context, uid string, m domain.PaymentMatch) error {
+	r.setMatch++
+	for i := range r.payments {
+		if r.payments[i].PaymentUID == uid {
+			r.payments[i].Match = m
+		}
+	}
+	return nil
+}
+
+var (
+	testUSDT  = domain.Token{Address: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Symbol: "USDT", Decimals: 6}
+	testWTRX  = domain.Token{Address: "TNUC9Qb1rRpS5CbWLmNMxXBjyFoydXjWFR", Symbol: "WTRX", Decimals: 6}
+	test18Dec = domain.Token{Address: "THb4CqiFdwNHsWsQCs4JhzwjMWys4aqCbF", Symbol: "ETH", Decimals: 18}
+	test0Dec  = domain.Token{Address: "TLa2f6VPqDgRE67v1736s7bJ8Ray5wYjU7", Symbol: "WIN", Decimals: 0}
+)
+
+var testOrderID = []byte(strings.Repeat("\x07", 32))
+
+// confirmed returns confirmed payments of raw base units in token.
+func confirmed(token domain.Token, raws ...string) []domain.Payment {
+	var out []domain.Payment
+	for i, raw := range raws {
+		out = append(out, domain.Payment{
+			PaymentUID:   fmt.Sprintf("p%d", i+1),
+			OrderID:      testOrderID,
+			TokenAddress: token.Address,
+			AmountRaw:    raw,
+			Status:       domain.PaymentSuccess,
+		})
+	}
+	return out
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    domain.Token
+		amount   string
+		payments []domain.Payment
+
+		paid       string
+		settlement domain.Settlement
+		status     domain.PaymentStatus
+		matches    []domain.PaymentMatch // by payment, in order
+	}{
+		{
+			name: "exact", token: testUSDT, amount: "10",
+			payments: confirmed(testUSDT, "10000000"),
+			paid:     "10", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchExact},
+		},
+		{
+			name: "one base unit under", token: testUSDT, amount: "10",
+			payments: confirmed(testUSDT, "9999999"),
+			paid:     "9.999999", settlement: domain.SettlementPartial, status: domain.PaymentPending,
+			matches: []domain.PaymentMatch{domain.MatchPartial},
+		},
+		{
+			name: "one base unit over", token: testUSDT, amount: "10",
+			payments: confirmed(testUSDT, "10000001"),
+			paid:     "10.000001", settlement: domain.SettlementOverpaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchOverpaid},
+		},
+		{
+			name: "paid in two parts", token: testUSDT, amount: "10.5",
+			payments: confirmed(testUSDT, "4000000", "6500000"),
+			paid:     "10.5", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchPartial, domain.MatchExact},
+		},
+		{
+			name: "second part overpays", token: testUSDT, amount: "10",
+			payments: confirmed(testUSDT, "4000000", "7000000"),
+			paid:     "11", settlement: domain.SettlementOverpaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchPartial, domain.MatchOverpaid},
+		},
+		{
+			name: "trailing zeros in the order amount", token: testUSDT, amount: "10.250000",
+			payments: confirmed(testUSDT, "10250000"),
+			paid:     "10.25", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchExact},
+		},
+		{
+			name: "smallest amount", token: testUSDT, amount: "0.000001",
+			payments: confirmed(testUSDT, "1"),
+			paid:     "0.000001", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchExact},
+		},
+		{
+			name: "18 decimals, one wei", token: test18Dec, amount: "0.000000000000000001",
+			payments: confirmed(test18Dec, "1"),
+			paid:     "0.000000000000000001", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchExact},
+		},
+		{
+			name: "18 decimals, largest NUMERIC(36,18)", token: test18Dec, amount: "999999999999999999.999999999999999999",
+			payments: confirmed(test18Dec, strings.Repeat("9", 36)),
+			paid:     "999999999999999999.999999999999999999", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchExact},
+		},
+		{
+			name: "18 decimals, one wei short", token: test18Dec, amount: "1.5",
+			payments: confirmed(test18Dec, "1499999999999999999"),
+			paid:     "1.499999999999999999", settlement: domain.SettlementPartial, status: domain.PaymentPending,
+			matches: []domain.PaymentMatch{domain.MatchPartial},
+		},
+		{
+			name: "no decimals", token: test0Dec, amount: "5",
+			payments: confirmed(test0Dec, "5"),
+			paid:     "5", settlement: domain.SettlementPaid, status: domain.PaymentSuccess,
+			matches: []domain.PaymentMatch{domain.MatchExact},
+		},
+		{
+			name: "wrong token does not count", token: testUSDT, amount: "10",
+			payments: confirmed(testWTRX, "10000000"),
+			paid:     "0", settlement: domain.SettlementUnpaid, status: domain.PaymentPending,
+			matches: []domain.PaymentMatch{domain.MatchWrongToken},
+		},
+		{
+			name: "unconfirmed payments do not count", token: testUSDT, amount: "10",
+			payments: func() []domain.Payment {
+				ps := confirmed(testUSDT, "10000000", "10000000")
+				ps[0].Status = domain.PaymentPending
+				ps[1].Status = domain.PaymentFailed
+				return ps
+			}(),
+			paid: "0", settlement: domain.SettlementUnpaid, status: domain.PaymentPending,
+			matches: []domain.PaymentMatch{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &domain.Order{OrderID: testOrderID, Amount: tt.amount, TokenAddress: tt.token.Address}
+			orders := newMemOrders(order)
+			payments := &memPayments{payments: tt.payments}
+			tokens := NewTokenSet([]domain.Token{testUSDT, testWTRX, test18Dec, test0Dec}, true)
+
+			res, err := NewPaymentService(orders, payments, tokens).Settle(context.Background(), testOrderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Settlement != tt.settlement {
+				t.Fatalf("settlement = %s, want %s", res.Settlement, tt.settlement)
+			}
+			if order.AmountPaid != tt.paid || order.Settlement != tt.settlement || order.PaymentStatus != tt.status {
+				t.Fatalf("order = paid %s, %s, %s; want %s, %s, %s",
+					order.AmountPaid, order.Settlement, order.PaymentStatus, tt.paid, tt.settlement, tt.status)
+			}
+			for i, p := range payments.payments {
+				if p.Match != tt.matches[i] {
+					t.Fatalf("payment %d match = %q, want %q", i+1, p.Match, tt.matches[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSettleOnlyStoresChangedMatches(t *testing.T) {
+	order := &domain.Order{OrderID: testOrderID, Amount: "10", TokenAddress: testUSDT.Address}
+	payments := &memPayments{payments: confirmed(testUSDT, "4000000", "6000000")}
+	payments.payments[0].Match = domain.MatchPartial
+	svc := NewPaymentService(newMemOrders(order), payments, NewTokenSet([]domain.Token{testUSDT}, true))
+
+	if _, err := svc.Settle(context.Background(), testOrderID); err != nil {
+		t.Fatal(err)
+	}
+	if payments.setMatch != 1 {
+		t.Fatalf("SetMatch called %d times, want 1", payments.setMatch)
+	}
+	if _, err := svc.Settle(context.Background(), testOrderID); err != nil {
+		t.Fatal(err)
+	}
+	if payments.setMatch != 1 {
+		t.Fatalf("re-settling stored matches again (%d calls)", payments.setMatch)
+	}
+}
+
+func TestSettleFails(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    *domain.Order
+		payments []domain.Payment
+		tokens   *TokenSet
+		err      error // errors.Is target, nil for any error
+		review   bool
+	}{
+		{
+			name:   "order not found",
+			tokens: NewTokenSet([]domain.Token{testUSDT}, true),
+			err:    ErrOrderNotFound,
+		},
+		{
+			name:   "token no longer supported",
+			order:  &domain.Order{OrderID: testOrderID, Amount: "10", TokenAddress: testWTRX.Address},
+			tokens: NewTokenSet([]domain.Token{testUSDT}, true),
+			err:    ErrUnsupportedToken,
+			review: true,
+		},
+		{
+			name:   "token not loaded yet",
+			order:  &domain.Order{OrderID: testOrderID, Amount: "10", TokenAddress: testWTRX.Address},
+			tokens: NewTokenSet([]domain.Token{testUSDT}, false),
+		},
+		{
+			name:   "order amount finer than the token",
+			order:  &domain.Order{OrderID: testOrderID, Amount: "0.0000001", TokenAddress: testUSDT.Address},
+			tokens: NewTokenSet([]domain.Token{testUSDT}, true),
+			err:    domain.ErrAmountPrecision,
+		},
+		{
+			name:     "paid total beyond NUMERIC(36,18)",
+			order:    &domain.Order{OrderID: testOrderID, Amount: "1", TokenAddress: test18Dec.Address},
+			payments: confirmed(test18Dec, strings.Repeat("9", 36), "1"),
+			tokens:   NewTokenSet([]domain.Token{test18Dec}, true),
+			err:      domain.ErrInvalidAmount,
+		},
+		{
+			name:     "bad amount_raw",
+			order:    &domain.Order{OrderID: testOrderID, Amount: "1", TokenAddress: testUSDT.Address},
+			payments: confirmed(testUSDT, "-1"),
+			tokens:   NewTokenSet([]domain.Token{testUSDT}, true),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders := newMemOrders()
+			if tt.order != nil {
+				orders = newMemOrders(tt.order)
+			}
+			svc := NewPaymentService(orders, &memPayments{payments: tt.payments}, tt.tokens)
+
+			_, err := svc.Settle(context.Background(), testOrderID)
+			if err == nil {
+				t.Fatal("Settle succeeded")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("Settle = %v, want %v", err, tt.err)
+			}
+			if got := errors.Is(err, domain.ErrNeedsReview); got != tt.review {
+				t.Fatalf("needs review = %v, want %v (%v)", got, tt.review, err)
+			}
+			if tt.order != nil && tt.order.Settlement != "" {
+				t.Fatalf("settlement stored despite the error: %s", tt.order.Settlement)
+			}
+		})
+	}
+}
+
+// MatchResult.Paid is in the token's base units, not a decimal amount.
+func TestSettleReturnsBaseUnits(t *testing.T) {
+	order := &domain.Order{OrderID: testOrderID, Amount: "1.5", TokenAddress: test18Dec.Address}
+	payments := &memPayments{payments: confirmed(test18Dec, "1500000000000000000")}
+	res, err := NewPaymentService(newMemOrders(order), payments, NewTokenSet([]domain.Token{test18Dec}, true)).
+		Settle(context.Background(), testOrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := new(big.Int).SetString("1500000000000000000", 10)
+	if res.Paid.Cmp(want) != 0 {
+		t.Fatalf("paid = %s base units, want %s", res.Paid, want)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// DefaultRefreshTTL is how long a session stays usable after login;
+// refreshing it does not extend it.
+const DefaultRefreshTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused means a rotated token was presented again: it
+	// was stolen or replayed, so its whole family has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// SessionService issues and rotates refresh tokens. Each login starts a
+// token family; every refresh exchanges the presented token for a new one
+// in the same family, which expires when the family does.
+type SessionService struct {
+	repo ports.RefreshTokenRepo
+	log  *slog.Logger
+
+	TTL time.Duration
+}
+
+func NewSessionService(repo ports.RefreshTokenRepo, log *slog.Logger) *SessionService {
+	return &SessionService{repo: repo, log: log, TTL: DefaultRefreshTTL}
+}
+
+// Issue starts a new session for the user and returns its refresh token.
+func (s *SessionService) Issue(ctx context.Context, userUID string) (token string, expiresAt time.Time, err error) {
+	token, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	t := &domain.RefreshToken{
+		Hash:      hash,
+		UserUID:   userUID,
+		ExpiresAt: time.Now().UTC().Add(s.TTL),
+	}
+	if err := s.repo.Create(ctx, t); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, t.ExpiresAt, nil
+}
+
+// Rotate exchanges token for a new refresh token of the same session and
+// returns the user it belongs to. The successor keeps the session's
+// expiry, so refreshing cannot keep a session alive past TTL after login.
+// Presenting a token that was already rotated revokes the whole session
+// (ErrRefreshTokenReused).
+func (s *SessionService) Rotate(ctx context.Context, token string) (userUID, next string, expiresAt time.Time, err error) {
+	t, err := s.repo.GetByHash(ctx, auth.HashRefreshToken(token))
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	now := time.Now().UTC()
+	switch {
+	case t == nil, t.RevokedAt != nil, !now.Before(t.ExpiresAt):
+		return "", "", time.Time{}, ErrInvalidRefreshToken
+	case t.RotatedAt != nil:
+		return "", "", time.Time{}, s.reused(ctx, t, now)
+	}
+
+	next, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	successor := &domain.RefreshToken{
+		Hash:      hash,
+		FamilyID:  t.FamilyID,
+		UserUID:   t.UserUID,
+		ExpiresAt: t.ExpiresAt,
+	}
+	rotated, err := s.repo.Rotate(ctx, t.ID, successor, now)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if !rotated {
+		// Lost a race with another refresh (or a logout) of the same token.
+		return "", "", time.Time{}, s.reused(ctx, t, now)
+	}
+	return t.UserUID, next, successor.ExpiresAt, nil
+}
+
+// Revoke ends the session token belongs to. Unknown tokens are ignored.
+func (s *SessionService) Revoke(ctx context.Context, token string) error {
+	t, err := s.repo.GetByHash(ctx, auth.HashRefreshToken(token))
+	if err != nil || t == nil {
+		return err
+	}
+	if err := s.repo.RevokeFamily(ctx, t.FamilyID, domain.RevokeLogout, time.Now().UTC()); err != nil {
+		return err
+	}
+	s.log.Info("session_revoked", "user_uid", t.UserUID, "family_id", t.FamilyID)
+	return nil
+}
+
+// RevokeAll ends every session of the user.
+func (s *SessionService) RevokeAll(ctx context.Context, userUID string) error {
+	if err := s.repo.RevokeUser(ctx, userUID, domain.RevokeLogoutAll, time.Now().UTC()); err != nil {
+		return err
+	}
+	s.log.Info("sessions_revoked", "user_uid", userUID)
+	return nil
+}
+
+func (s *SessionService) reused(ctx context.Context, t *domain.RefreshToken, now time.Time) error {
+	if err := s.repo.RevokeFamily(ctx, t.FamilyID, domain.RevokeReuse, now); err != nil {
+		return err
+	}
+	s.log.Warn("refresh_token_reused", "user_uid", t.UserUID, "family_id", t.FamilyID)
+	return ErrRefreshTokenReused
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// memRefreshTokens keeps refresh tokens in insertion order and records
+// the reason of every revocation.
+type memRefreshTokens struct {
+	ports.RefreshTokenRepo
+	tokens   []*domain.RefreshToken
+	families int
+	revoked  []domain.RevokeReason
+	loseRace bool // Rotate finds the token already rotated
+}
+
+func (r *memRefreshTokens) Create(_ context.Context, t *domain.RefreshToken) error {
+	if t.FamilyID == "" {
+		r.families++
+		t.FamilyID = fmt.Sprintf("family-%d", r.families)
+	}
+	t.ID = int64(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, t)
+	return nil
+}
+
+func (r *memRefreshTokens) GetByHash(_ context.Context, hash []byte) (*domain.RefreshToken, error) {
+	for _, t := range r.tokens {
+		if bytes.Equal(t.Hash, hash) {
+			cp := *t
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memRefreshTokens) Rotate(ctx context.Context, id int64, next *domain.RefreshToken, at time.Time) (bool, error) {
+	t := r.tokens[id-1]
+	if r.loseRace || t.RotatedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.RotatedAt = &at
+	return true, r.Create(ctx, next)
+}
+
+func (r *memRefreshTokens) revoke(match func(*domain.RefreshToken) bool, reason domain.RevokeReason, at time.Time) {
+	for _, t := range r.tokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &at
+		}
+	}
+	r.revoked = append(r.revoked, reason)
+}
+
+func (r *memRefreshTokens) RevokeFamily(_ context.Context, familyID string, reason domain.RevokeReason, at time.Time) error {
+	r.revoke(func(t *domain.RefreshToken) bool { return t.FamilyID == familyID }, reason, at)
+	return nil
+}
+
+func (r *memRefreshTokens) RevokeUser(_ context.Context, userUID string, reason domain.RevokeReason, at time.Time) error {
+	r.revoke(func(t *domain.RefreshToken) bool { return t.UserUID == userUID }, reason, at)
+	return nil
+}
+
+func TestSessionRotate(t *testing.T) {
+	repo := &memRefreshTokens{}
+	s := NewSessionService(repo, discardLog())
+	ctx := context.Background()
+
+	first, expiresAt, err := s.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expiresAt); d < DefaultRefreshTTL-time.Minute || d > DefaultRefreshTTL {
+		t.Fatalf("session expires in %s, want %s", d, DefaultRefreshTTL)
+	}
+	if bytes.Contains(repo.tokens[0].Hash, []byte(first)) {
+		t.Fatal("the token itself was stored")
+	}
+
+	user, second, nextExpiry, err := s.Rotate(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "user-1" || second == first {
+		t.Fatalf("Rotate = %q, %q", user, second)
+	}
+	// Refreshing does not extend the session.
+	if !nextExpiry.Equal(expiresAt) {
+		t.Fatalf("successor expires %v, want the session's %v", nextExpiry, expiresAt)
+	}
+	if repo.tokens[1].FamilyID != repo.tokens[0].FamilyID {
+		t.Fatal("successor started a new family")
+	}
+
+	third, err := rotate(s, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first token again: someone else holds a copy.
+	if _, err := rotate(s, first); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token: %v", err)
+	}
+	if len(repo.revoked) != 1 || repo.revoked[0] != domain.RevokeReuse {
+		t.Fatalf("revocations = %v", repo.revoked)
+	}
+	if _, err := rotate(s, third); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("latest token after reuse: %v", err)
+	}
+}
+
+func rotate(s *SessionService, token string) (string, error) {
+	_, next, _, err := s.Rotate(context.Background(), token)
+	return next, err
+}
+
+func TestSessionRotateRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *memRefreshTokens)
+		err   error
+	}{
+		{name: "unknown", setup: func(r *memRefreshTokens) { r.tokens = nil }, err: ErrInvalidRefreshToken},
+		{name: "expired", setup: func(r *memRefreshTokens) { r.tokens[0].ExpiresAt = time.Now().Add(-time.Second) }, err: ErrInvalidRefreshToken},
+		{
+			name: "logged out",
+			setup: func(r *memRefreshTokens) {
+				at := time.Now()
+				r.tokens[0].RevokedAt = &at
+			},
+			err: ErrInvalidRefreshToken,
+		},
+		// Two refreshes of the same token raced and this one lost.
+		{name: "rotated concurrently", setup: func(r *memRefreshTokens) { r.loseRace = true }, err: ErrRefreshTokenReused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memRefreshTokens{}
+			s := NewSessionService(repo, discardLog())
+			token, _, err := s.Issue(context.Background(), "user-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(repo)
+
+			if _, err := rotate(s, token); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(repo.tokens) > 1 {
+				t.Fatal("a successor was issued")
+			}
+		})
+	}
+}
+
+func TestSessionRevoke(t *testing.T) {
+	repo := &memRefreshTokens{}
+	s := NewSessionService(repo, discardLog())
+	ctx := context.Background()
+
+	phone, _, _ := s.Issue(ctx, "user-1")
+	laptop, _, _ := s.Issue(ctx, "user-1")
+	other, _, _ := s.Issue(ctx, "user-2")
+
+	if err := s.Revoke(ctx, "not-a-token"); err != nil {
+		t.Fatalf("revoking an unknown token: %v", err)
+	}
+	if err := s.Revoke(ctx, phone); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotate(s, phone); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("after logout: %v", err)
+	}
+	laptop, err := rotate(s, laptop)
+	if err != nil {
+		t.Fatalf("logout ended another session: %v", err)
+	}
+
+	if err := s.RevokeAll(ctx, "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotate(s, laptop); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("after logout everywhere: %v", err)
+	}
+	if _, err := rotate(s, other); err != nil {
+		t.Fatalf("another user's session was revoked: %v", err)
+	}
+	want := []domain.RevokeReason{domain.RevokeLogout, domain.RevokeLogoutAll}
+	if len(repo.revoked) != 2 || repo.revoked[0] != want[0] || repo.revoked[1] != want[1] {
+		t.Fatalf("revocations = %v, want %v", repo.revoked, want)
+	}
+}
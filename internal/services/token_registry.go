@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// TokenRegistry keeps the tokens table: TRC-20 metadata read from chain
+// once and the allow-list of tokens orders may be priced in.
+type TokenRegistry struct {
+	repo  ports.TokenRepo
+	chain ports.TokenChain // nil: only cached tokens can be loaded
+	log   *slog.Logger
+}
+
+func NewTokenRegistry(repo ports.TokenRepo, chain ports.TokenChain, log *slog.Logger) *TokenRegistry {
+	return &TokenRegistry{repo: repo, chain: chain, log: log}
+}
+
+// Load allow-lists exactly addresses and returns their metadata in the
+// same order, so the first one stays the default. Tokens not cached yet
+// are read from chain.
+func (r *TokenRegistry) Load(ctx context.Context, addresses []string) ([]domain.Token, error) {
+	addrs, err := parseTokenAddresses(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tokens  []domain.Token
+		allowed []string
+	)
+	for _, addr := range addrs {
+		t, err := r.resolve(ctx, addr)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", addr, err)
+		}
+		tokens = append(tokens, t)
+		allowed = append(allowed, t.Address)
+	}
+
+	if err := r.repo.SetAllowed(ctx, allowed); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Cached returns the cached metadata of addresses in the same order,
+// without reading the chain or changing the allow-list. Tokens not cached
+// yet are left out, and complete is false; if the default (first) one is
+// missing nothing is returned, so that no other token stands in for it.
+func (r *TokenRegistry) Cached(ctx context.Context, addresses []string) (tokens []domain.Token, complete bool, err error) {
+	addrs, err := parseTokenAddresses(addresses)
+	if err != nil {
+		return nil, false, err
+	}
+
+	complete = true
+	for i, addr := range addrs {
+		t, err := r.repo.Get(ctx, addr.String())
+		if err != nil {
+			return nil, false, err
+		}
+		if t == nil {
+			if i == 0 {
+				return nil, false, nil
+			}
+			complete = false
+			continue
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, complete, nil
+}
+
+func parseTokenAddresses(addresses []string) ([]domain.TronAddress, error) {
+	var (
+		out  []domain.TronAddress
+		seen = map[domain.TronAddress]bool{}
+	)
+	for _, a := range addresses {
+		addr, err := domain.ParseTronAddress(strings.TrimSpace(a))
+		if err != nil {
+			return nil, fmt.Errorf("token %q: %w", a, err)
+		}
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		out = append(out, addr)
+	}
+	return out, nil
+}
+
+// List returns the allow-listed tokens.
+func (r *TokenRegistry) List(ctx context.Context) ([]domain.Token, error) {
+	return r.repo.ListAllowed(ctx)
+}
+
+func (r *TokenRegistry) resolve(ctx context.Context, addr domain.TronAddress) (domain.Token, error) {
+	cached, err := r.repo.Get(ctx, addr.String())
+	if err != nil {
+		return domain.Token{}, err
+	}
+	if cached != nil {
+		return *cached, nil
+	}
+	if r.chain == nil {
+		return domain.Token{}, fmt.Errorf("not cached and no TRC-20 ABI to read it with")
+	}
+
+	t, err := r.chain.TokenMetadata(ctx, addr)
+	if err != nil {
+		return domain.Token{}, err
+	}
+	t.Symbol = strings.TrimSpace(t.Symbol)
+	switch {
+	case t.Symbol == "":
+		return domain.Token{}, fmt.Errorf("empty symbol")
+	case t.Decimals < 0 || t.Decimals > domain.MaxTokenDecimals:
+		return domain.Token{}, fmt.Errorf("%d decimals: at most %d are supported", t.Decimals, domain.MaxTokenDecimals)
+	}
+
+	if err := r.repo.Save(ctx, t); err != nil {
+		return domain.Token{}, err
+	}
+	r.log.Info("token_loaded", "address", t.Address, "symbol", t.Symbol, "name", t.Name, "decimals", t.Decimals)
+	return t, nil
+}
+
+// TokenSet is the in-memory allow-list orders are priced and payments
+// valued in, the default first. It starts from the tokens cache and a
+// TokenLoader replaces it once every token is loaded.
+type TokenSet struct {
+	mu       sync.RWMutex
+	tokens   []domain.Token
+	complete bool
+}
+
+// NewTokenSet starts a set from tokens; complete says whether they cover
+// every configured address.
+func NewTokenSet(tokens []domain.Token, complete bool) *TokenSet {
+	return &TokenSet{tokens: tokens, complete: complete}
+}
+
+// List returns the current tokens; callers must not modify the slice.
+func (s *TokenSet) List() []domain.Token {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens
+}
+
+// Complete reports whether every configured token is in the set.
+func (s *TokenSet) Complete() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.complete
+}
+
+// Set replaces the set with the full allow-list.
+func (s *TokenSet) Set(tokens []domain.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens, s.complete = tokens, true
+}
+
+// TokenLoader allow-lists addresses (see TokenRegistry.Load) in the
+// background and publishes the result to a TokenSet, so a slow or
+// unreachable node does not hold up startup.
+type TokenLoader struct {
+	registry  *TokenRegistry
+	addresses []string
+	set       *TokenSet
+	log       *slog.Logger
+
+	Timeout       time.Duration // per attempt
+	RetryInterval time.Duration
+}
+
+func NewTokenLoader(registry *TokenRegistry, addresses []string, set *TokenSet, log *slog.Logger) *TokenLoader {
+	return &TokenLoader{
+		registry:      registry,
+		addresses:     addresses,
+		set:           set,
+		log:           log,
+		Timeout:       30 * time.Second,
+		RetryInterval: 30 * time.Second,
+	}
+}
+
+// Run loads the tokens, retrying until it succeeds or ctx is cancelled.
+func (l *TokenLoader) Run(ctx context.Context) {
+	for {
+		actx, cancel := context.WithTimeout(ctx, l.Timeout)
+		tokens, err := l.registry.Load(actx, l.addresses)
+		cancel()
+		if err == nil {
+			l.set.Set(tokens)
+			l.log.Info("tokens_loaded", "count", len(tokens))
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		l.log.Error("tokens_load_failed", "err", err, "retry_in", l.RetryInterval.String())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.RetryInterval):
+		}
+	}
+}
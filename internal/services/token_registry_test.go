@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+)
+
+// memTokens is the tokens table: metadata by address plus the allow-list.
+type memTokens struct {
+	ports.TokenRepo
+	cached  map[string]domain.Token
+	allowed []string
+	saved   []string
+}
+
+func newMemTokens(cached ...domain.Token) *memTokens {
+	r := &memTokens{cached: map[string]domain.Token{}}
+	for _, t := range cached {
+		r.cached[t.Address] = t
+	}
+	return r
+}
+
+func (r *memTokens) Get(_ context.Context, address string) (*domain.Token, error) {
+	t, ok := r.cached[address]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (r *memTokens) Save(_ context.Context, t domain.Token) error {
+	r.cached[t.Address] = t
+	r.saved = append(r.saved, t.Address)
+	return nil
+}
+
+func (r *memTokens) SetAllowed(_ context.Context, addresses []string) error {
+	r.allowed = addresses
+	return nil
+}
+
+// tokenChain serves TRC-20 metadata; each read fails while fails > 0.
+type tokenChain struct {
+	tokens map[string]domain.Token
+	fails  int
+	reads  []string
+}
+
+func (c *tokenChain) TokenMetadata(_ context.Context, token domain.TronAddress) (domain.Token, error) {
+	c.reads = append(c.reads, token.String())
+	if c.fails > 0 {
+		c.fails--
+		return domain.Token{}, errors.New("node unreachable")
+	}
+	t, ok := c.tokens[token.String()]
+	if !ok {
+		return domain.Token{}, errors.New("not a TRC-20 contract")
+	}
+	return t, nil
+}
+
+func TestTokenRegistryLoad(t *testing.T) {
+	repo := newMemTokens(testUSDT)
+	wtrx := testWTRX
+	wtrx.Symbol = " WTRX "
+	chain := &tokenChain{tokens: map[string]domain.Token{testWTRX.Address: wtrx}}
+
+	tokens, err := NewTokenRegistry(repo, chain, discardLog()).Load(context.Background(), []string{
+		testUSDT.Address, " " + testWTRX.Address, testUSDT.Address,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0] != testUSDT || tokens[1] != testWTRX {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	if strings.Join(chain.reads, " ") != testWTRX.Address {
+		t.Fatalf("read from chain: %v, want only the uncached token", chain.reads)
+	}
+	if repo.cached[testWTRX.Address] != testWTRX || len(repo.saved) != 1 {
+		t.Fatalf("cache = %+v", repo.cached)
+	}
+	if strings.Join(repo.allowed, " ") != testUSDT.Address+" "+testWTRX.Address {
+		t.Fatalf("allowed = %v", repo.allowed)
+	}
+}
+
+func TestTokenRegistryLoadRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		meta  domain.Token
+		chain bool
+		addr  string
+		err   string
+	}{
+		{name: "empty symbol", meta: domain.Token{Address: testWTRX.Address, Symbol: " ", Decimals: 6}, chain: true, err: "empty symbol"},
+		{
+			name: "too many decimals", meta: domain.Token{Address: testWTRX.Address, Symbol: "WTRX", Decimals: domain.MaxTokenDecimals + 1}, chain: true,
+			err: "decimals: at most",
+		},
+		{name: "not a token", chain: true, err: "not a TRC-20 contract"},
+		{name: "no chain", err: "not cached"},
+		{name: "bad address", addr: "TNUC9Qb1rRpS5CbWLmNMxXBjyFoydXjWFx", err: "checksum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemTokens(testUSDT)
+			var chain ports.TokenChain
+			if tt.chain {
+				chain = &tokenChain{tokens: map[string]domain.Token{tt.meta.Address: tt.meta}}
+			}
+			addr := testWTRX.Address
+			if tt.addr != "" {
+				addr = tt.addr
+			}
+
+			_, err := NewTokenRegistry(repo, chain, discardLog()).Load(context.Background(), []string{testUSDT.Address, addr})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if repo.allowed != nil || len(repo.saved) != 0 {
+				t.Fatalf("allowed %v, saved %v after a failed load", repo.allowed, repo.saved)
+			}
+		})
+	}
+}
+
+func TestTokenRegistryCached(t *testing.T) {
+	tests := []struct {
+		name     string
+		cached   []domain.Token
+		want     []domain.Token
+		complete bool
+	}{
+		{name: "all cached", cached: []domain.Token{testUSDT, testWTRX}, want: []domain.Token{testUSDT, testWTRX}, complete: true},
+		{name: "one missing", cached: []domain.Token{testUSDT}, want: []domain.Token{testUSDT}},
+		{name: "default missing", cached: []domain.Token{testWTRX}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemTokens(tt.cached...)
+			tokens, complete, err := NewTokenRegistry(repo, nil, discardLog()).Cached(context.Background(), []string{testUSDT.Address, testWTRX.Address})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if complete != tt.complete || len(tokens) != len(tt.want) {
+				t.Fatalf("Cached = %+v, %v", tokens, complete)
+			}
+			for i := range tt.want {
+				if tokens[i] != tt.want[i] {
+					t.Fatalf("Cached = %+v", tokens)
+				}
+			}
+			if repo.allowed != nil {
+				t.Fatal("Cached changed the allow-list")
+			}
+		})
+	}
+}
+
+func TestTokenSet(t *testing.T) {
+	var unset *TokenSet
+	if unset.List() != nil || unset.Complete() {
+		t.Fatal("a nil set must be empty and incomplete")
+	}
+
+	s := NewTokenSet([]domain.Token{testUSDT}, false)
+	if len(s.List()) != 1 || s.Complete() {
+		t.Fatalf("partial set = %+v, complete %v", s.List(), s.Complete())
+	}
+	s.Set([]domain.Token{testUSDT, testWTRX})
+	if len(s.List()) != 2 || !s.Complete() {
+		t.Fatalf("loaded set = %+v, complete %v", s.List(), s.Complete())
+	}
+}
+
+func TestTokenLoaderRetries(t *testing.T) {
+	chain := &tokenChain{tokens: map[string]domain.Token{testWTRX.Address: testWTRX}, fails: 2}
+	repo := newMemTokens(testUSDT)
+	set := NewTokenSet([]domain.Token{testUSDT}, false)
+	l := NewTokenLoader(NewTokenRegistry(repo, chain, discardLog()), []string{testUSDT.Address, testWTRX.Address}, set, discardLog())
+	l.RetryInterval = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		l.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("loader did not finish")
+	}
+	if len(chain.reads) != 3 || !set.Complete() || len(set.List()) != 2 {
+		t.Fatalf("reads %d, set %+v complete %v", len(chain.reads), set.List(), set.Complete())
+	}
+
+	// Cancelling stops a loader that keeps failing.
+	chain.fails = 1 << 30
+	set = NewTokenSet(nil, false)
+	l = NewTokenLoader(NewTokenRegistry(newMemTokens(), chain, discardLog()), []string{testWTRX.Address}, set, discardLog())
+	l.RetryInterval = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	l.Run(ctx)
+	if set.Complete() || set.List() != nil {
+		t.Fatal("a failed load published tokens")
+	}
+}
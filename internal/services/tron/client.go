@@ -0,0 +1,491 @@
+package tron
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// Client is a thin wrapper over the TronGrid HTTP API (/wallet/*).
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func NewClient(baseURL, apiKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    httpClient,
+	}
+}
+
+// Transaction is an unsigned/signed Tron transaction as returned by TronGrid.
+// RawData is kept opaque so it round-trips to broadcasttransaction untouched.
+type Transaction struct {
+	Visible    bool            `json:"visible"`
+	TxID       string          `json:"txID"`
+	RawData    json.RawMessage `json:"raw_data"`
+	RawDataHex string          `json:"raw_data_hex"`
+	Signature  []string        `json:"signature,omitempty"`
+}
+
+// VerifyTxID checks that TxID is sha256(raw_data). We never sign a txID we
+// did not compute ourselves from the raw bytes.
+func (t *Transaction) VerifyTxID() ([]byte, error) {
+	raw, err := hex.DecodeString(t.RawDataHex)
+	if err != nil {
+		return nil, fmt.Errorf("decode raw_data_hex: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), t.TxID) {
+		return nil, fmt.Errorf("txID does not match raw_data hash")
+	}
+	return sum[:], nil
+}
+
+type TriggerSmartContractRequest struct {
+	OwnerAddress     string `json:"owner_address"`
+	ContractAddress  string `json:"contract_address"`
+	FunctionSelector string `json:"function_selector"`
+	Parameter        string `json:"parameter"`
+	FeeLimit         int64  `json:"fee_limit,omitempty"`
+	CallValue        int64  `json:"call_value,omitempty"`
+	Visible          bool   `json:"visible"`
+}
+
+type triggerResult struct {
+	Result struct {
+		Result  bool   `json:"result"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"result"`
+	Transaction *Transaction `json:"transaction"`
+}
+
+// TriggerSmartContract asks the node to build an unsigned contract call.
+func (c *Client) TriggerSmartContract(ctx context.Context, req TriggerSmartContractRequest) (*Transaction, error) {
+	var out triggerResult
+	if err := c.post(ctx, "/wallet/triggersmartcontract", req, &out); err != nil {
+		return nil, err
+	}
+	if !out.Result.Result {
+		return nil, fmt.Errorf("triggersmartcontract: %s: %s", out.Result.Code, decodeNodeMessage(out.Result.Message))
+	}
+	if out.Transaction == nil || out.Transaction.TxID == "" {
+		return nil, fmt.Errorf("triggersmartcontract: empty transaction")
+	}
+	return out.Transaction, nil
+}
+
+type broadcastResult struct {
+	Result  bool   `json:"result"`
+	TxID    string `json:"txid"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// BroadcastTransaction submits a signed transaction and returns its txid.
+func (c *Client) BroadcastTransaction(ctx context.Context, tx *Transaction) (string, error) {
+	if len(tx.Signature) == 0 {
+		return "", fmt.Errorf("broadcast: transaction is not signed")
+	}
+
+	var out broadcastResult
+	if err := c.post(ctx, "/wallet/broadcasttransaction", tx, &out); err != nil {
+		return "", err
+	}
+	if !out.Result {
+		return "", fmt.Errorf("broadcast: %s: %s", out.Code, decodeNodeMessage(out.Message))
+	}
+	if out.TxID == "" {
+		return tx.TxID, nil
+	}
+	return out.TxID, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("tron %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("tron %s: read body: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tron %s: http %d: %s", path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("tron %s: decode response: %w", path, err)
+	}
+	return nil
+}
+
+// decodeNodeMessage turns the hex-encoded error messages TronGrid returns
+// into readable text. Non-hex messages are returned unchanged.
+func decodeNodeMessage(msg string) string {
+	if b, err := hex.DecodeString(msg); err == nil && len(b) > 0 {
+		return string(b)
+	}
+	return msg
+}
+
+type constantResult struct {
+	Result struct {
+		Result  bool   `json:"result"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"result"`
+	EnergyUsed     int64    `json:"energy_used"`
+	ConstantResult []string `json:"constant_result"`
+	Transaction    *struct {
+		Ret []struct {
+			Ret string `json:"ret"`
+		} `json:"ret"`
+	} `json:"transaction"`
+}
+
+// CallRevertedError is returned when a read-only call reverts. Data is the
+// revert payload; decode it with the contract's abi.ABI.DecodeRevert.
+type CallRevertedError struct {
+	Function string
+	Data     []byte
+}
+
+func (e *CallRevertedError) Error() string {
+	return fmt.Sprintf("triggerconstantcontract: %s reverted", e.Function)
+}
+
+// TriggerConstantContract runs a read-only call and returns the raw
+// return data of the first result.
+func (c *Client) TriggerConstantContract(ctx context.Context, req TriggerSmartContractRequest) ([]byte, error) {
+	out, err := c.triggerConstant(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(out.ConstantResult) == 0 {
+		return nil, fmt.Errorf("triggerconstantcontract: empty result")
+	}
+	return hex.DecodeString(out.ConstantResult[0])
+}
+
+// EstimateEnergy dry-runs a state-changing call and returns the energy it
+// would consume. A call that would revert returns *CallRevertedError.
+func (c *Client) EstimateEnergy(ctx context.Context, req TriggerSmartContractRequest) (int64, error) {
+	out, err := c.triggerConstant(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return out.EnergyUsed, nil
+}
+
+func (c *Client) triggerConstant(ctx context.Context, req TriggerSmartContractRequest) (constantResult, error) {
+	var out constantResult
+	if err := c.post(ctx, "/wallet/triggerconstantcontract", req, &out); err != nil {
+		return out, err
+	}
+	if !out.Result.Result {
+		return out, fmt.Errorf("triggerconstantcontract: %s: %s", out.Result.Code, decodeNodeMessage(out.Result.Message))
+	}
+	if out.Transaction != nil && len(out.Transaction.Ret) > 0 && out.Transaction.Ret[0].Ret == "REVERT" {
+		rev := &CallRevertedError{Function: req.FunctionSelector}
+		if len(out.ConstantResult) > 0 {
+			rev.Data, _ = hex.DecodeString(out.ConstantResult[0])
+		}
+		return out, rev
+	}
+	return out, nil
+}
+
+type accountRequest struct {
+	Address string `json:"address"`
+	Visible bool   `json:"visible"`
+}
+
+type account struct {
+	Balance int64 `json:"balance"`
+}
+
+type accountResource struct {
+	FreeNetUsed  int64 `json:"freeNetUsed"`
+	FreeNetLimit int64 `json:"freeNetLimit"`
+	NetUsed      int64 `json:"NetUsed"`
+	NetLimit     int64 `json:"NetLimit"`
+	EnergyUsed   int64 `json:"EnergyUsed"`
+	EnergyLimit  int64 `json:"EnergyLimit"`
+}
+
+type chainParameters struct {
+	ChainParameter []struct {
+		Key   string `json:"key"`
+		Value int64  `json:"value"`
+	} `json:"chainParameter"`
+}
+
+// GetAccountResources returns the TRX balance, available energy and
+// bandwidth of addr, with the current burn prices for each.
+func (c *Client) GetAccountResources(ctx context.Context, addr domain.TronAddress) (domain.AccountResources, error) {
+	req := accountRequest{Address: addr.String(), Visible: true}
+
+	var acc account
+	if err := c.post(ctx, "/wallet/getaccount", req, &acc); err != nil {
+		return domain.AccountResources{}, err
+	}
+	var res accountResource
+	if err := c.post(ctx, "/wallet/getaccountresource", req, &res); err != nil {
+		return domain.AccountResources{}, err
+	}
+	var params chainParameters
+	if err := c.post(ctx, "/wallet/getchainparameters", struct{}{}, &params); err != nil {
+		return domain.AccountResources{}, err
+	}
+
+	out := domain.AccountResources{
+		Address:    addr,
+		BalanceSun: acc.Balance,
+		Energy:     max(res.EnergyLimit-res.EnergyUsed, 0),
+		Bandwidth:  max(res.FreeNetLimit-res.FreeNetUsed, 0) + max(res.NetLimit-res.NetUsed, 0),
+	}
+	for _, p := range params.ChainParameter {
+		switch p.Key {
+		case "getEnergyFee":
+			out.EnergyPriceSun = p.Value
+		case "getTransactionFee":
+			out.BandwidthPriceSun = p.Value
+		}
+	}
+	if out.EnergyPriceSun == 0 || out.BandwidthPriceSun == 0 {
+		return domain.AccountResources{}, fmt.Errorf("getchainparameters: energy/bandwidth fee missing")
+	}
+	return out, nil
+}
+
+type transactionInfo struct {
+	ID             string   `json:"id"`
+	BlockNumber    int64    `json:"blockNumber"`
+	BlockTimeStamp int64    `json:"blockTimeStamp"`
+	Result         string   `json:"result"`
+	ResMessage     string   `json:"resMessage"`
+	ContractResult []string `json:"contractResult"`
+	Receipt        struct {
+		Result string `json:"result"`
+	} `json:"receipt"`
+}
+
+// GetTransactionInfo returns the execution receipt for txid. Found is false
+// while the transaction is not yet in a block.
+func (c *Client) GetTransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error) {
+	return c.getTransactionInfo(ctx, "/wallet/gettransactioninfobyid", txid)
+}
+
+// GetSolidTransactionInfo is GetTransactionInfo restricted to solidified
+// (irreversible) blocks. Found is false until the tx's block is solidified.
+func (c *Client) GetSolidTransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error) {
+	return c.getTransactionInfo(ctx, "/walletsolidity/gettransactioninfobyid", txid)
+}
+
+func (c *Client) getTransactionInfo(ctx context.Context, path, txid string) (domain.ChainReceipt, error) {
+	var out transactionInfo
+	if err := c.post(ctx, path, map[string]string{"value": txid}, &out); err != nil {
+		return domain.ChainReceipt{}, err
+	}
+	if out.ID == "" {
+		return domain.ChainReceipt{TxID: txid}, nil
+	}
+
+	r := domain.ChainReceipt{
+		TxID:        out.ID,
+		Found:       true,
+		BlockNumber: out.BlockNumber,
+		BlockTime:   time.UnixMilli(out.BlockTimeStamp).UTC(),
+		Success:     out.Result != "FAILED" && (out.Receipt.Result == "" || out.Receipt.Result == "SUCCESS"),
+		Result:      out.Receipt.Result,
+	}
+	if out.ResMessage != "" {
+		r.Result = strings.TrimSpace(r.Result + " " + decodeNodeMessage(out.ResMessage))
+	}
+	if len(out.ContractResult) > 0 {
+		r.ReturnData, _ = hex.DecodeString(out.ContractResult[0])
+	}
+	return r, nil
+}
+
+// Log is one event emitted by a contract during a transaction.
+type Log struct {
+	Address domain.TronAddress
+	Topics  [][]byte // Topics[0] is the event signature hash
+	Data    []byte
+}
+
+// BlockTransaction is the receipt of one contract transaction in a block.
+type BlockTransaction struct {
+	TxID        string
+	BlockNumber int64
+	BlockTime   time.Time
+	Success     bool
+	Logs        []Log
+}
+
+type nowBlock struct {
+	BlockHeader struct {
+		RawData struct {
+			Number int64 `json:"number"`
+		} `json:"raw_data"`
+	} `json:"block_header"`
+}
+
+// GetNowBlockNumber returns the number of the latest block the node knows.
+func (c *Client) GetNowBlockNumber(ctx context.Context) (int64, error) {
+	return c.nowBlockNumber(ctx, "/wallet/getnowblock")
+}
+
+// GetSolidBlockNumber returns the number of the latest solidified block.
+// Blocks up to it can no longer be reorganised away.
+func (c *Client) GetSolidBlockNumber(ctx context.Context) (int64, error) {
+	return c.nowBlockNumber(ctx, "/walletsolidity/getnowblock")
+}
+
+func (c *Client) nowBlockNumber(ctx context.Context, path string) (int64, error) {
+	var out nowBlock
+	if err := c.post(ctx, path, struct{}{}, &out); err != nil {
+		return 0, err
+	}
+	if out.BlockHeader.RawData.Number == 0 {
+		return 0, fmt.Errorf("%s: missing block number", path)
+	}
+	return out.BlockHeader.RawData.Number, nil
+}
+
+type blockTransactionInfo struct {
+	transactionInfo
+	Log []struct {
+		Address string   `json:"address"`
+		Topics  []string `json:"topics"`
+		Data    string   `json:"data"`
+	} `json:"log"`
+}
+
+// GetTransactionInfoByBlockNum returns the receipts of every contract
+// transaction in block num, with their event logs.
+func (c *Client) GetTransactionInfoByBlockNum(ctx context.Context, num int64) ([]BlockTransaction, error) {
+	return c.transactionInfoByBlockNum(ctx, "/wallet/gettransactioninfobyblocknum", num)
+}
+
+// GetSolidTransactionInfoByBlockNum is GetTransactionInfoByBlockNum as seen
+// by the solidity node; it only knows blocks up to GetSolidBlockNumber.
+func (c *Client) GetSolidTransactionInfoByBlockNum(ctx context.Context, num int64) ([]BlockTransaction, error) {
+	return c.transactionInfoByBlockNum(ctx, "/walletsolidity/gettransactioninfobyblocknum", num)
+}
+
+func (c *Client) transactionInfoByBlockNum(ctx context.Context, path string, num int64) ([]BlockTransaction, error) {
+	var raw json.RawMessage
+	if err := c.post(ctx, path, map[string]int64{"num": num}, &raw); err != nil {
+		return nil, err
+	}
+	// Blocks without contract transactions come back as {} instead of [].
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.TrimSpace(raw)[0] != '[' {
+		return nil, nil
+	}
+
+	var infos []blockTransactionInfo
+	if err := json.Unmarshal(raw, &infos); err != nil {
+		return nil, fmt.Errorf("gettransactioninfobyblocknum: decode response: %w", err)
+	}
+
+	out := make([]BlockTransaction, 0, len(infos))
+	for _, info := range infos {
+		tx := BlockTransaction{
+			TxID:        info.ID,
+			BlockNumber: info.BlockNumber,
+			BlockTime:   time.UnixMilli(info.BlockTimeStamp).UTC(),
+			Success:     info.Result != "FAILED" && (info.Receipt.Result == "" || info.Receipt.Result == "SUCCESS"),
+		}
+		logs, err := info.logs()
+		if err != nil {
+			return nil, err
+		}
+		tx.Logs = logs
+		out = append(out, tx)
+	}
+	return out, nil
+}
+
+// GetTransactionLogs returns the event logs txid emitted; nil while the
+// transaction is not yet in a block.
+func (c *Client) GetTransactionLogs(ctx context.Context, txid string) ([]Log, error) {
+	var info blockTransactionInfo
+	if err := c.post(ctx, "/wallet/gettransactioninfobyid", map[string]string{"value": txid}, &info); err != nil {
+		return nil, err
+	}
+	return info.logs()
+}
+
+func (info blockTransactionInfo) logs() ([]Log, error) {
+	var out []Log
+	for i, l := range info.Log {
+		log, err := decodeLog(l.Address, l.Topics, l.Data)
+		if err != nil {
+			return nil, fmt.Errorf("tx %s log %d: %w", info.ID, i, err)
+		}
+		out = append(out, log)
+	}
+	return out, nil
+}
+
+// decodeLog parses a node log entry. The node reports the emitting
+// contract as 20-byte hex without the 0x41 prefix.
+func decodeLog(address string, topics []string, data string) (Log, error) {
+	raw, err := hex.DecodeString(address)
+	if err != nil {
+		return Log{}, fmt.Errorf("decode log address: %w", err)
+	}
+	addr, err := domain.TronAddressFromBytes(raw)
+	if err != nil {
+		return Log{}, fmt.Errorf("decode log address: %w", err)
+	}
+
+	l := Log{Address: addr}
+	for _, t := range topics {
+		b, err := hex.DecodeString(t)
+		if err != nil {
+			return Log{}, fmt.Errorf("decode log topic: %w", err)
+		}
+		l.Topics = append(l.Topics, b)
+	}
+	if l.Data, err = hex.DecodeString(data); err != nil {
+		return Log{}, fmt.Errorf("decode log data: %w", err)
+	}
+	return l, nil
+}
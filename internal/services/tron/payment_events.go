@@ -0,0 +1,112 @@
+package tron
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"token13/merchant-backend-go/internal/chain/abi"
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// PaymentDetectedEvent is the PaymentCoreV1 event emitted by payTx.
+const PaymentDetectedEvent = "PaymentDetected"
+
+// paymentDetectedLayout is the input layout the decoder understands:
+// three indexed bytes32 topics, then (address, uint256, uint256) in data.
+var paymentDetectedLayout = []struct {
+	typ     string
+	indexed bool
+}{
+	{"bytes32", true},
+	{"bytes32", true},
+	{"bytes32", true},
+	{"address", false},
+	{"uint256", false},
+	{"uint256", false},
+}
+
+// PaymentDetected is a decoded PaymentDetected log.
+type PaymentDetected struct {
+	MerchantID []byte             // bytes32
+	OrderID    []byte             // bytes32
+	InvoiceID  []byte             // bytes32
+	Token      domain.TronAddress // TRC-20 contract
+	Amount     *big.Int
+	Timestamp  time.Time
+}
+
+// PaymentEventDecoder recognises PaymentDetected logs emitted by one
+// PaymentCoreV1 deployment.
+type PaymentEventDecoder struct {
+	contract domain.TronAddress
+	event    abi.Event
+}
+
+// NewPaymentEventDecoder reads the event definition from the contract ABI,
+// so a redeployment with a different event layout fails at startup rather
+// than mis-decoding payments.
+func NewPaymentEventDecoder(contract contracts.TronContract) (*PaymentEventDecoder, error) {
+	if contract.Address == "" || len(contract.ABI) == 0 {
+		return nil, fmt.Errorf("payment core address/abi missing")
+	}
+	addr, err := domain.ParseTronAddress(contract.Address)
+	if err != nil {
+		return nil, fmt.Errorf("payment core address: %w", err)
+	}
+
+	parsed, err := abi.Parse(contract.ABI)
+	if err != nil {
+		return nil, fmt.Errorf("payment core: %w", err)
+	}
+	e, err := parsed.Event(PaymentDetectedEvent)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Inputs) != len(paymentDetectedLayout) || e.Anonymous {
+		return nil, fmt.Errorf("%s: unexpected abi %s", PaymentDetectedEvent, e.Signature)
+	}
+	seen := map[string]bool{}
+	for i, want := range paymentDetectedLayout {
+		in := e.Inputs[i]
+		if in.Type != want.typ || in.Indexed != want.indexed || in.Name == "" || seen[in.Name] {
+			return nil, fmt.Errorf("%s: unexpected abi %s", PaymentDetectedEvent, e.Signature)
+		}
+		seen[in.Name] = true
+	}
+
+	return &PaymentEventDecoder{contract: addr, event: e}, nil
+}
+
+// Decode returns ok=false for logs that are not PaymentDetected from the
+// configured contract, and an error for matching logs that are malformed.
+func (d *PaymentEventDecoder) Decode(l Log) (ev PaymentDetected, ok bool, err error) {
+	raw := abi.Log{Topics: l.Topics, Data: l.Data}
+	if l.Address != d.contract || !d.event.Matches(raw) {
+		return PaymentDetected{}, false, nil
+	}
+	if len(l.Data) != 3*32 {
+		return PaymentDetected{}, true, fmt.Errorf("%s: expected 96 data bytes, got %d", PaymentDetectedEvent, len(l.Data))
+	}
+
+	args, err := d.event.Decode(raw)
+	if err != nil {
+		return PaymentDetected{}, true, err
+	}
+	// The layout was checked at construction, so inputs are positional.
+	in := d.event.Inputs
+	ts := args[in[5].Name].(*big.Int)
+	if !ts.IsInt64() {
+		return PaymentDetected{}, true, fmt.Errorf("%s: timestamp out of range", PaymentDetectedEvent)
+	}
+
+	return PaymentDetected{
+		MerchantID: args[in[0].Name].([]byte),
+		OrderID:    args[in[1].Name].([]byte),
+		InvoiceID:  args[in[2].Name].([]byte),
+		Token:      args[in[3].Name].(domain.TronAddress),
+		Amount:     args[in[4].Name].(*big.Int),
+		Timestamp:  time.Unix(ts.Int64(), 0).UTC(),
+	}, true, nil
+}
@@ -0,0 +1,321 @@
+package tron
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"token13/merchant-backend-go/internal/chain/abi"
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/chain/signer"
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// DefaultFeeLimit caps the TRX (in sun) a single registry call may burn.
+const DefaultFeeLimit int64 = 100_000_000
+
+// txOverheadBytes is what a node adds to len(raw_data) + signatures when
+// charging bandwidth (the result slot reserved in every transaction).
+const txOverheadBytes = 64
+
+// BudgetGuard vets the estimated cost of a transaction before it is
+// signed. Returning an error aborts the send.
+type BudgetGuard interface {
+	Check(ctx context.Context, function string, cost domain.TxCost) error
+}
+
+// Registry is the real Service backed by the MerchantRegistryV1 contract.
+// It builds the call through TronGrid, has the operator signer (whose
+// account must hold MANAGER_ROLE) sign the txID and broadcasts it.
+type Registry struct {
+	client   *Client
+	contract contracts.TronContract
+	abi      *abi.ABI
+	signer   signer.Signer
+	feeLimit int64
+
+	// Guard, when set, sees every transaction's energy and bandwidth
+	// estimate before it is signed.
+	Guard BudgetGuard
+}
+
+func NewRegistry(client *Client, contract contracts.TronContract, s signer.Signer, feeLimit int64) (*Registry, error) {
+	if contract.Address == "" || len(contract.ABI) == 0 {
+		return nil, fmt.Errorf("merchant registry address/abi missing")
+	}
+	parsed, err := abi.Parse(contract.ABI)
+	if err != nil {
+		return nil, fmt.Errorf("merchant registry: %w", err)
+	}
+	if feeLimit <= 0 {
+		feeLimit = DefaultFeeLimit
+	}
+	return &Registry{
+		client:   client,
+		contract: contract,
+		abi:      parsed,
+		signer:   s,
+		feeLimit: feeLimit,
+	}, nil
+}
+
+// OperatorAddress is the address transactions are sent from.
+func (r *Registry) OperatorAddress() domain.TronAddress { return r.signer.Address() }
+
+// OperatorResources reports what the operator account has left to spend.
+func (r *Registry) OperatorResources(ctx context.Context) (domain.AccountResources, error) {
+	return r.client.GetAccountResources(ctx, r.signer.Address())
+}
+
+// RegisterMerchant calls onboardMerchant(bytes32,address) and returns the txid.
+// The txid is returned as soon as the node accepts the broadcast; callers
+// track confirmation separately.
+func (r *Registry) RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress domain.TronAddress) (string, error) {
+	return r.send(ctx, "onboardMerchant", merchantID, walletAddress)
+}
+
+// IsMerchantOnboarded reports whether the registry already has a fund
+// receiver for merchantID. Used to avoid onboarding the same merchant twice.
+func (r *Registry) IsMerchantOnboarded(ctx context.Context, merchantID []byte) (bool, error) {
+	receiver, err := r.MerchantFundReceiver(ctx, merchantID)
+	if err != nil {
+		return false, err
+	}
+	return !receiver.IsZero(), nil
+}
+
+// MerchantFundReceiver returns the address the registry pays merchantID's
+// funds to; the zero address if it is not onboarded.
+func (r *Registry) MerchantFundReceiver(ctx context.Context, merchantID []byte) (domain.TronAddress, error) {
+	out, err := r.call(ctx, "getMerchantFundReceiver", merchantID)
+	if err != nil {
+		return domain.TronAddress{}, err
+	}
+	receiver, ok := out[0].(domain.TronAddress)
+	if !ok {
+		return domain.TronAddress{}, fmt.Errorf("getMerchantFundReceiver: unexpected output %T", out[0])
+	}
+	return receiver, nil
+}
+
+// UpdateMerchantReceiver calls updateMerchantReceiverAddress(bytes32,address)
+// and returns the txid once the node accepts the broadcast.
+func (r *Registry) UpdateMerchantReceiver(ctx context.Context, merchantID []byte, receiver domain.TronAddress) (string, error) {
+	return r.send(ctx, "updateMerchantReceiverAddress", merchantID, receiver)
+}
+
+// MerchantReceiverUpdated finds the MerchantReceiverAddressUpdated(merchantId,
+// oldReceiver, newReceiver) log the registry emitted in txid for merchantID
+// and returns its new receiver.
+func (r *Registry) MerchantReceiverUpdated(ctx context.Context, txid string, merchantID []byte) (domain.TronAddress, bool, error) {
+	ev, args, found, err := r.merchantEvent(ctx, txid, merchantID, "MerchantReceiverAddressUpdated(bytes32,address,address)")
+	if err != nil || !found {
+		return domain.TronAddress{}, false, err
+	}
+	return args[ev.Inputs[2].Name].(domain.TronAddress), true, nil
+}
+
+// UpdateMerchantStatus calls updateMerchantStatus(bytes32,bool) and
+// returns the txid once the node accepts the broadcast.
+func (r *Registry) UpdateMerchantStatus(ctx context.Context, merchantID []byte, active bool) (string, error) {
+	return r.send(ctx, "updateMerchantStatus", merchantID, active)
+}
+
+// IsMerchantActive reports the merchant's status in the registry.
+func (r *Registry) IsMerchantActive(ctx context.Context, merchantID []byte) (bool, error) {
+	out, err := r.call(ctx, "isMerchantActive", merchantID)
+	if err != nil {
+		return false, err
+	}
+	active, ok := out[0].(bool)
+	if !ok {
+		return false, fmt.Errorf("isMerchantActive: unexpected output %T", out[0])
+	}
+	return active, nil
+}
+
+// UpdateMerchantToken calls updateMerchantTokenStatus(bytes32,address,bool)
+// and returns the txid once the node accepts the broadcast.
+func (r *Registry) UpdateMerchantToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool) (string, error) {
+	return r.send(ctx, "updateMerchantTokenStatus", merchantID, token, enabled)
+}
+
+// IsMerchantTokenSupported reports whether the registry accepts token for
+// merchantID.
+func (r *Registry) IsMerchantTokenSupported(ctx context.Context, merchantID []byte, token domain.TronAddress) (bool, error) {
+	out, err := r.call(ctx, "isMerchantTokenSupported", merchantID, token)
+	if err != nil {
+		return false, err
+	}
+	supported, ok := out[0].(bool)
+	if !ok {
+		return false, fmt.Errorf("isMerchantTokenSupported: unexpected output %T", out[0])
+	}
+	return supported, nil
+}
+
+// MerchantTokenUpdated finds the MerchantTokenUpdated(merchantId, token,
+// status) log the registry emitted in txid for merchantID and returns the
+// token and its new status.
+func (r *Registry) MerchantTokenUpdated(ctx context.Context, txid string, merchantID []byte) (domain.TronAddress, bool, bool, error) {
+	ev, args, found, err := r.merchantEvent(ctx, txid, merchantID, "MerchantTokenUpdated(bytes32,address,bool)")
+	if err != nil || !found {
+		return domain.TronAddress{}, false, false, err
+	}
+	return args[ev.Inputs[1].Name].(domain.TronAddress), args[ev.Inputs[2].Name].(bool), true, nil
+}
+
+// MerchantStatusUpdated finds the MerchantStatusUpdated(merchantId, active)
+// log the registry emitted in txid for merchantID.
+func (r *Registry) MerchantStatusUpdated(ctx context.Context, txid string, merchantID []byte) (active, found bool, err error) {
+	ev, args, found, err := r.merchantEvent(ctx, txid, merchantID, "MerchantStatusUpdated(bytes32,bool)")
+	if err != nil || !found {
+		return false, false, err
+	}
+	return args[ev.Inputs[1].Name].(bool), true, nil
+}
+
+// merchantEvent decodes the first log in txid the registry emitted as the
+// event with this signature for merchantID, its first (indexed) input.
+func (r *Registry) merchantEvent(ctx context.Context, txid string, merchantID []byte, signature string) (abi.Event, map[string]any, bool, error) {
+	name, _, _ := strings.Cut(signature, "(")
+	ev, err := r.abi.Event(name)
+	if err != nil {
+		return abi.Event{}, nil, false, err
+	}
+	if ev.Signature != signature {
+		return abi.Event{}, nil, false, fmt.Errorf("unexpected abi %s", ev.Signature)
+	}
+	contract, err := domain.ParseTronAddress(r.contract.Address)
+	if err != nil {
+		return abi.Event{}, nil, false, fmt.Errorf("merchant registry address: %w", err)
+	}
+
+	logs, err := r.client.GetTransactionLogs(ctx, txid)
+	if err != nil {
+		return abi.Event{}, nil, false, err
+	}
+	for _, l := range logs {
+		raw := abi.Log{Topics: l.Topics, Data: l.Data}
+		if l.Address != contract || !ev.Matches(raw) {
+			continue
+		}
+		args, err := ev.Decode(raw)
+		if err != nil {
+			return abi.Event{}, nil, false, err
+		}
+		if id := args[ev.Inputs[0].Name].([]byte); !bytes.Equal(id, merchantID) {
+			continue
+		}
+		return ev, args, true, nil
+	}
+	return abi.Event{}, nil, false, nil
+}
+
+// TransactionInfo returns the on-chain receipt for a previously broadcast
+// txid. For reverted transactions Result carries the decoded revert reason.
+func (r *Registry) TransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error) {
+	receipt, err := r.client.GetTransactionInfo(ctx, txid)
+	if err != nil {
+		return receipt, err
+	}
+	if receipt.Found && !receipt.Success && len(receipt.ReturnData) > 0 {
+		receipt.Result += ": " + r.abi.DecodeRevert(receipt.ReturnData).Error()
+	}
+	return receipt, nil
+}
+
+// call runs a read-only method and returns its decoded outputs. Reverts
+// come back as *abi.RevertError.
+func (r *Registry) call(ctx context.Context, function string, args ...any) ([]any, error) {
+	m, parameter, err := r.pack(function, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.client.TriggerConstantContract(ctx, TriggerSmartContractRequest{
+		OwnerAddress:     r.signer.Address().String(),
+		ContractAddress:  r.contract.Address,
+		FunctionSelector: m.Signature,
+		Parameter:        parameter,
+		Visible:          true,
+	})
+	var reverted *CallRevertedError
+	if errors.As(err, &reverted) {
+		return nil, fmt.Errorf("%s: %w", function, r.abi.DecodeRevert(reverted.Data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.Unpack(data)
+}
+
+func (r *Registry) send(ctx context.Context, function string, args ...any) (string, error) {
+	m, parameter, err := r.pack(function, args...)
+	if err != nil {
+		return "", err
+	}
+
+	req := TriggerSmartContractRequest{
+		OwnerAddress:     r.signer.Address().String(),
+		ContractAddress:  r.contract.Address,
+		FunctionSelector: m.Signature,
+		Parameter:        parameter,
+		FeeLimit:         r.feeLimit,
+		Visible:          true,
+	}
+
+	// Dry-run first: a call that would revert fails here, for free.
+	energy, err := r.client.EstimateEnergy(ctx, req)
+	var reverted *CallRevertedError
+	if errors.As(err, &reverted) {
+		return "", fmt.Errorf("%s: %w", function, r.abi.DecodeRevert(reverted.Data))
+	}
+	if err != nil {
+		return "", fmt.Errorf("estimate %s: %w", function, err)
+	}
+
+	tx, err := r.client.TriggerSmartContract(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	txHash, err := tx.VerifyTxID()
+	if err != nil {
+		return "", err
+	}
+
+	if r.Guard != nil {
+		cost := domain.TxCost{
+			Energy:    energy,
+			Bandwidth: int64(len(tx.RawDataHex)/2 + 65 + txOverheadBytes),
+		}
+		if err := r.Guard.Check(ctx, function, cost); err != nil {
+			return "", err
+		}
+	}
+	sig, err := r.signer.Sign(ctx, txHash)
+	if err != nil {
+		return "", fmt.Errorf("sign %s: %w", function, err)
+	}
+	tx.Signature = []string{hex.EncodeToString(sig)}
+
+	return r.client.BroadcastTransaction(ctx, tx)
+}
+
+// pack looks up function and ABI-encodes args as the hex parameter
+// TronGrid expects next to the function_selector.
+func (r *Registry) pack(function string, args ...any) (abi.Method, string, error) {
+	m, err := r.abi.Method(function)
+	if err != nil {
+		return abi.Method{}, "", err
+	}
+	params, err := m.Pack(args...)
+	if err != nil {
+		return abi.Method{}, "", err
+	}
+	return m, hex.EncodeToString(params), nil
+}
@@ -0,0 +1,196 @@
+package tron
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"token13/merchant-backend-go/internal/chain/contracts"
+	"token13/merchant-backend-go/internal/chain/signer"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/services/tron/trongridtest"
+)
+
+// Test-only operator key; never funded anywhere.
+const operatorKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+const wallet = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+
+type fixture struct {
+	node     *trongridtest.Server
+	registry *Registry
+	operator *signer.Local
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	node := trongridtest.New("test-key")
+	t.Cleanup(node.Close)
+
+	bundle, err := contracts.Load("../../config/contract.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	operator, err := signer.FromHex(operatorKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := NewRegistry(NewClient(node.URL(), "test-key", nil), bundle.MerchantRegistry, operator, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fixture{node: node, registry: registry, operator: operator}
+}
+
+func merchantID() []byte {
+	id := make([]byte, 32)
+	for i := range id {
+		id[i] = byte(i + 1)
+	}
+	return id
+}
+
+func TestRegisterMerchant(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	receiver, err := domain.ParseTronAddress(wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onboarded, err := f.registry.IsMerchantOnboarded(ctx, merchantID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onboarded {
+		t.Fatal("merchant onboarded before RegisterMerchant")
+	}
+
+	txid, err := f.registry.RegisterMerchant(ctx, merchantID(), receiver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := f.node.Broadcasts()
+	if len(sent) != 1 {
+		t.Fatalf("%d broadcasts, want 1", len(sent))
+	}
+	b := sent[0]
+	if b.TxID != txid {
+		t.Errorf("txid = %s, broadcast %s", txid, b.TxID)
+	}
+	if b.FunctionSelector != "onboardMerchant(bytes32,address)" {
+		t.Errorf("function = %s", b.FunctionSelector)
+	}
+	wantParam := hex.EncodeToString(merchantID()) + strings.Repeat("0", 24) + hex.EncodeToString(receiver.Account())
+	if b.Parameter != wantParam {
+		t.Errorf("parameter =\n%s\nwant\n%s", b.Parameter, wantParam)
+	}
+	if b.OwnerAddress != f.operator.Address().String() {
+		t.Errorf("owner = %s, want operator %s", b.OwnerAddress, f.operator.Address())
+	}
+	if b.FeeLimit != DefaultFeeLimit {
+		t.Errorf("fee limit = %d, want %d", b.FeeLimit, DefaultFeeLimit)
+	}
+
+	// The fake only accepts the broadcast if the signature recovers to the
+	// owner; double-check against the txID we were given.
+	txHash, _ := hex.DecodeString(txid)
+	sig, _ := hex.DecodeString(b.Signature)
+	if signerAddr, err := signer.Recover(txHash, sig); err != nil || signerAddr != f.operator.Address() {
+		t.Errorf("signature recovers to %s (%v), want %s", signerAddr, err, f.operator.Address())
+	}
+
+	onboarded, err = f.registry.IsMerchantOnboarded(ctx, merchantID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !onboarded {
+		t.Error("merchant not onboarded after RegisterMerchant")
+	}
+
+	receipt, err := f.registry.TransactionInfo(ctx, txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !receipt.Found || !receipt.Success || receipt.BlockNumber != b.BlockNumber {
+		t.Errorf("receipt = %+v", receipt)
+	}
+}
+
+func TestRegisterMerchantRejectsForgedTxID(t *testing.T) {
+	f := newFixture(t)
+	receiver, _ := domain.ParseTronAddress(wallet)
+
+	f.node.TamperTxID()
+	_, err := f.registry.RegisterMerchant(context.Background(), merchantID(), receiver)
+	if err == nil || !strings.Contains(err.Error(), "txID does not match raw_data hash") {
+		t.Fatalf("err = %v, want txID mismatch", err)
+	}
+	if n := len(f.node.Broadcasts()); n != 0 {
+		t.Fatalf("%d broadcasts after a forged txID", n)
+	}
+}
+
+func TestNodeErrors(t *testing.T) {
+	receiver, _ := domain.ParseTronAddress(wallet)
+
+	tests := []struct {
+		name  string
+		setup func(f *fixture)
+		want  string
+	}{
+		{
+			name: "trigger",
+			setup: func(f *fixture) {
+				f.node.FailTrigger("CONTRACT_VALIDATE_ERROR", "Contract validate error : account does not exist")
+			},
+			want: "triggersmartcontract: CONTRACT_VALIDATE_ERROR: Contract validate error : account does not exist",
+		},
+		{
+			name:  "broadcast",
+			setup: func(f *fixture) { f.node.FailBroadcast("SIGERROR", "validate signature error") },
+			want:  "broadcast: SIGERROR: validate signature error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			tt.setup(f)
+			_, err := f.registry.RegisterMerchant(context.Background(), merchantID(), receiver)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if n := len(f.node.Broadcasts()); n != 0 {
+				t.Fatalf("%d broadcasts after a node error", n)
+			}
+		})
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	f := newFixture(t)
+	client := NewClient(f.node.URL(), "", nil)
+	registry, err := NewRegistry(client, f.registry.contract, f.operator, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = registry.IsMerchantOnboarded(context.Background(), merchantID())
+	if err == nil || !strings.Contains(err.Error(), "http 401") {
+		t.Fatalf("err = %v, want http 401", err)
+	}
+}
+
+func TestDecodeNodeMessage(t *testing.T) {
+	tests := map[string]string{
+		hex.EncodeToString([]byte("balance is not sufficient")): "balance is not sufficient",
+		"plain text message": "plain text message",
+		"":                   "",
+	}
+	for in, want := range tests {
+		if got := decodeNodeMessage(in); got != want {
+			t.Errorf("decodeNodeMessage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
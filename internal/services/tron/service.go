@@ -3,16 +3,74 @@ package tron
 import (
 	"context"
 	"fmt"
+
+	"token13/merchant-backend-go/internal/domain"
 )
 
 type Service interface {
-	RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress string) (txid string, err error)
+	RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress domain.TronAddress) (txid string, err error)
+	IsMerchantOnboarded(ctx context.Context, merchantID []byte) (bool, error)
+	TransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error)
+	UpdateMerchantStatus(ctx context.Context, merchantID []byte, active bool) (txid string, err error)
+	IsMerchantActive(ctx context.Context, merchantID []byte) (bool, error)
+	MerchantStatusUpdated(ctx context.Context, txid string, merchantID []byte) (active, found bool, err error)
+	UpdateMerchantReceiver(ctx context.Context, merchantID []byte, receiver domain.TronAddress) (txid string, err error)
+	MerchantFundReceiver(ctx context.Context, merchantID []byte) (domain.TronAddress, error)
+	MerchantReceiverUpdated(ctx context.Context, txid string, merchantID []byte) (receiver domain.TronAddress, found bool, err error)
+	UpdateMerchantToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool) (txid string, err error)
+	IsMerchantTokenSupported(ctx context.Context, merchantID []byte, token domain.TronAddress) (bool, error)
+	MerchantTokenUpdated(ctx context.Context, txid string, merchantID []byte) (token domain.TronAddress, enabled, found bool, err error)
 }
 
+// Stub is used when no operator key is configured (local dev without chain).
 type Stub struct{}
 
 func NewStub() *Stub { return &Stub{} }
 
-func (s *Stub) RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress string) (string, error) {
+func (s *Stub) RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress domain.TronAddress) (string, error) {
 	return "", fmt.Errorf("tron register not implemented yet")
 }
+
+func (s *Stub) IsMerchantOnboarded(ctx context.Context, merchantID []byte) (bool, error) {
+	return false, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) TransactionInfo(ctx context.Context, txid string) (domain.ChainReceipt, error) {
+	return domain.ChainReceipt{}, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) UpdateMerchantStatus(ctx context.Context, merchantID []byte, active bool) (string, error) {
+	return "", fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) IsMerchantActive(ctx context.Context, merchantID []byte) (bool, error) {
+	return false, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) MerchantStatusUpdated(ctx context.Context, txid string, merchantID []byte) (bool, bool, error) {
+	return false, false, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) UpdateMerchantReceiver(ctx context.Context, merchantID []byte, receiver domain.TronAddress) (string, error) {
+	return "", fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) MerchantFundReceiver(ctx context.Context, merchantID []byte) (domain.TronAddress, error) {
+	return domain.TronAddress{}, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) MerchantReceiverUpdated(ctx context.Context, txid string, merchantID []byte) (domain.TronAddress, bool, error) {
+	return domain.TronAddress{}, false, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) UpdateMerchantToken(ctx context.Context, merchantID []byte, token domain.TronAddress, enabled bool) (string, error) {
+	return "", fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) IsMerchantTokenSupported(ctx context.Context, merchantID []byte, token domain.TronAddress) (bool, error) {
+	return false, fmt.Errorf("tron not configured")
+}
+
+func (s *Stub) MerchantTokenUpdated(ctx context.Context, txid string, merchantID []byte) (domain.TronAddress, bool, bool, error) {
+	return domain.TronAddress{}, false, false, fmt.Errorf("tron not configured")
+}
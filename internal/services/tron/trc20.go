@@ -0,0 +1,107 @@
+package tron
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"token13/merchant-backend-go/internal/chain/abi"
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// TRC20 reads token metadata through the TRC-20 ABI. Only view functions
+// are called, so no signer is needed.
+type TRC20 struct {
+	client *Client
+	abi    *abi.ABI
+}
+
+func NewTRC20(client *Client, contractABI json.RawMessage) (*TRC20, error) {
+	if len(contractABI) == 0 {
+		return nil, fmt.Errorf("trc20 abi missing")
+	}
+	parsed, err := abi.Parse(contractABI)
+	if err != nil {
+		return nil, fmt.Errorf("trc20: %w", err)
+	}
+	for _, fn := range []string{"decimals", "symbol", "name"} {
+		if _, err := parsed.Method(fn); err != nil {
+			return nil, fmt.Errorf("trc20: %w", err)
+		}
+	}
+	return &TRC20{client: client, abi: parsed}, nil
+}
+
+// TokenMetadata returns token's decimals, symbol and name.
+func (t *TRC20) TokenMetadata(ctx context.Context, token domain.TronAddress) (domain.Token, error) {
+	out := domain.Token{Address: token.String()}
+
+	v, err := t.call(ctx, token, "decimals")
+	if err != nil {
+		return domain.Token{}, err
+	}
+	decimals, ok := v.(*big.Int)
+	if !ok || !decimals.IsInt64() {
+		return domain.Token{}, fmt.Errorf("decimals: unexpected output %v", v)
+	}
+	out.Decimals = int(decimals.Int64())
+
+	for _, f := range []struct {
+		fn  string
+		dst *string
+	}{
+		{"symbol", &out.Symbol},
+		{"name", &out.Name},
+	} {
+		v, err := t.call(ctx, token, f.fn)
+		if err != nil {
+			return domain.Token{}, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return domain.Token{}, fmt.Errorf("%s: unexpected output %T", f.fn, v)
+		}
+		*f.dst = s
+	}
+	return out, nil
+}
+
+// call runs a no-argument view function on token and returns its single
+// output. The token itself is used as the caller.
+func (t *TRC20) call(ctx context.Context, token domain.TronAddress, function string) (any, error) {
+	m, err := t.abi.Method(function)
+	if err != nil {
+		return nil, err
+	}
+	params, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := t.client.TriggerConstantContract(ctx, TriggerSmartContractRequest{
+		OwnerAddress:     token.String(),
+		ContractAddress:  token.String(),
+		FunctionSelector: m.Signature,
+		Parameter:        hex.EncodeToString(params),
+		Visible:          true,
+	})
+	var reverted *CallRevertedError
+	if errors.As(err, &reverted) {
+		return nil, fmt.Errorf("%s: %w", function, t.abi.DecodeRevert(reverted.Data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", function, err)
+	}
+
+	out, err := m.Unpack(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", function, err)
+	}
+	if len(out) != 1 {
+		return nil, fmt.Errorf("%s: %d outputs, want 1", function, len(out))
+	}
+	return out[0], nil
+}
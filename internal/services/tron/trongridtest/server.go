@@ -0,0 +1,429 @@
+// Package trongridtest provides an in-process fake of the TronGrid HTTP API
+// so the tron client can be exercised offline.
+package trongridtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// Call is a contract call the fake built a transaction for.
+type Call struct {
+	TxID             string
+	OwnerAddress     string
+	ContractAddress  string
+	FunctionSelector string
+	Parameter        string
+	FeeLimit         int64
+}
+
+// Broadcast is a transaction accepted by /wallet/broadcasttransaction.
+type Broadcast struct {
+	Call
+	Signature   string
+	BlockNumber int64
+}
+
+type Server struct {
+	srv    *httptest.Server
+	apiKey string
+
+	mu           sync.Mutex
+	pending      map[string]Call
+	broadcasts   []Broadcast
+	nextBlock    int64
+	triggerErr   *nodeError
+	broadcastErr *nodeError
+	tamperTxID   bool
+	resources    Resources
+}
+
+// Resources is what the fake reports for every account.
+type Resources struct {
+	BalanceSun int64
+	Energy     int64
+	Bandwidth  int64
+}
+
+// Energy the fake charges for state-changing calls when dry-run.
+const onboardEnergy = 30_000
+
+type nodeError struct {
+	code    string
+	message string
+}
+
+// New starts a fake TronGrid. If apiKey is non-empty every request must
+// carry it in TRON-PRO-API-KEY.
+func New(apiKey string) *Server {
+	s := &Server{
+		apiKey:    apiKey,
+		pending:   map[string]Call{},
+		nextBlock: 1_000_000,
+		resources: Resources{BalanceSun: 1_000_000_000, Energy: 100_000, Bandwidth: 5_000},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /wallet/triggersmartcontract", s.handleTrigger)
+	mux.HandleFunc("POST /wallet/broadcasttransaction", s.handleBroadcast)
+	mux.HandleFunc("POST /wallet/triggerconstantcontract", s.handleConstant)
+	mux.HandleFunc("POST /wallet/gettransactioninfobyid", s.handleTxInfo)
+	mux.HandleFunc("POST /wallet/getaccount", s.handleAccount)
+	mux.HandleFunc("POST /wallet/getaccountresource", s.handleAccountResource)
+	mux.HandleFunc("POST /wallet/getchainparameters", s.handleChainParameters)
+
+	s.srv = httptest.NewServer(s.requireKey(mux))
+	return s
+}
+
+func (s *Server) URL() string { return s.srv.URL }
+
+// SetResources changes what the account endpoints report.
+func (s *Server) SetResources(r Resources) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources = r
+}
+
+func (s *Server) Close() { s.srv.Close() }
+
+// FailTrigger makes the next triggersmartcontract call fail with code/message.
+func (s *Server) FailTrigger(code, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.triggerErr = &nodeError{code: code, message: message}
+}
+
+// FailBroadcast makes the next broadcasttransaction call fail with code/message.
+func (s *Server) FailBroadcast(code, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcastErr = &nodeError{code: code, message: message}
+}
+
+// TamperTxID makes the next triggersmartcontract call return a txID that
+// is not the hash of its raw_data, as a malicious node could.
+func (s *Server) TamperTxID() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tamperTxID = true
+}
+
+// Broadcasts returns every transaction accepted so far.
+func (s *Server) Broadcasts() []Broadcast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Broadcast(nil), s.broadcasts...)
+}
+
+func (s *Server) requireKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" && r.Header.Get("TRON-PRO-API-KEY") != s.apiKey {
+			http.Error(w, `{"Error":"ApiKey not exists"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		OwnerAddress     string `json:"owner_address"`
+		ContractAddress  string `json:"contract_address"`
+		FunctionSelector string `json:"function_selector"`
+		Parameter        string `json:"parameter"`
+		FeeLimit         int64  `json:"fee_limit"`
+		Visible          bool   `json:"visible"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	failure := s.triggerErr
+	s.triggerErr = nil
+	s.mu.Unlock()
+
+	if failure != nil {
+		writeJSON(w, map[string]any{
+			"result": map[string]any{"code": failure.code, "message": hex.EncodeToString([]byte(failure.message))},
+		})
+		return
+	}
+
+	data := selector(req.FunctionSelector) + req.Parameter
+	rawData := map[string]any{
+		"contract": []any{map[string]any{
+			"parameter": map[string]any{
+				"value": map[string]any{
+					"data":             data,
+					"owner_address":    req.OwnerAddress,
+					"contract_address": req.ContractAddress,
+				},
+				"type_url": "type.googleapis.com/protocol.TriggerSmartContract",
+			},
+			"type": "TriggerSmartContract",
+		}},
+		"fee_limit":  req.FeeLimit,
+		"timestamp":  time.Now().UnixMilli(),
+		"expiration": time.Now().Add(time.Minute).UnixMilli(),
+	}
+	raw, _ := json.Marshal(rawData)
+	sum := sha256.Sum256(raw)
+	txID := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	if s.tamperTxID {
+		s.tamperTxID = false
+		other := sha256.Sum256(append(raw, 0))
+		txID = hex.EncodeToString(other[:])
+	}
+	s.pending[txID] = Call{
+		TxID:             txID,
+		OwnerAddress:     req.OwnerAddress,
+		ContractAddress:  req.ContractAddress,
+		FunctionSelector: req.FunctionSelector,
+		Parameter:        req.Parameter,
+		FeeLimit:         req.FeeLimit,
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"result": map[string]any{"result": true},
+		"transaction": map[string]any{
+			"visible":      req.Visible,
+			"txID":         txID,
+			"raw_data":     json.RawMessage(raw),
+			"raw_data_hex": hex.EncodeToString(raw),
+		},
+	})
+}
+
+func (s *Server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var tx struct {
+		TxID       string   `json:"txID"`
+		RawDataHex string   `json:"raw_data_hex"`
+		Signature  []string `json:"signature"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.broadcastErr != nil {
+		failure := s.broadcastErr
+		s.broadcastErr = nil
+		writeJSON(w, map[string]any{"code": failure.code, "message": hex.EncodeToString([]byte(failure.message))})
+		return
+	}
+
+	call, ok := s.pending[tx.TxID]
+	if !ok {
+		writeJSON(w, map[string]any{"code": "TRANSACTION_EXPIRATION_ERROR", "message": hex.EncodeToString([]byte("unknown transaction"))})
+		return
+	}
+	if len(tx.Signature) != 1 {
+		writeJSON(w, map[string]any{"code": "SIGERROR", "message": hex.EncodeToString([]byte("expected one signature"))})
+		return
+	}
+
+	signer, err := recoverAddress(tx.TxID, tx.Signature[0])
+	if err != nil || signer != call.OwnerAddress {
+		writeJSON(w, map[string]any{"code": "SIGERROR", "message": hex.EncodeToString([]byte("validate signature error"))})
+		return
+	}
+
+	delete(s.pending, tx.TxID)
+	s.broadcasts = append(s.broadcasts, Broadcast{Call: call, Signature: tx.Signature[0], BlockNumber: s.nextBlock})
+	s.nextBlock++
+
+	writeJSON(w, map[string]any{"result": true, "txid": tx.TxID})
+}
+
+// handleConstant answers the MerchantRegistry view functions from the
+// onboardMerchant calls broadcast so far.
+func (s *Server) handleConstant(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FunctionSelector string `json:"function_selector"`
+		Parameter        string `json:"parameter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Parameter) < 64 {
+		http.Error(w, "parameter too short", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	receiver := strings.Repeat("0", 64)
+	for _, b := range s.broadcasts {
+		if b.FunctionSelector == "onboardMerchant(bytes32,address)" && strings.HasPrefix(b.Parameter, req.Parameter[:64]) {
+			receiver = b.Parameter[64:128]
+		}
+	}
+	s.mu.Unlock()
+
+	var result string
+	switch req.FunctionSelector {
+	case "onboardMerchant(bytes32,address)":
+		writeJSON(w, map[string]any{
+			"result":          map[string]any{"result": true},
+			"energy_used":     onboardEnergy,
+			"constant_result": []string{""},
+		})
+		return
+	case "getMerchantFundReceiver(bytes32)":
+		result = receiver
+	case "isMerchantActive(bytes32)":
+		result = strings.Repeat("0", 64)
+		if receiver != strings.Repeat("0", 64) {
+			result = strings.Repeat("0", 63) + "1"
+		}
+	default:
+		writeJSON(w, map[string]any{
+			"result": map[string]any{"code": "OTHER_ERROR", "message": hex.EncodeToString([]byte("unsupported function " + req.FunctionSelector))},
+		})
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"result":          map[string]any{"result": true},
+		"energy_used":     500,
+		"constant_result": []string{result},
+	})
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	res := s.resources
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{"balance": res.BalanceSun})
+}
+
+func (s *Server) handleAccountResource(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	res := s.resources
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{
+		"freeNetLimit": 600,
+		"freeNetUsed":  max(600-res.Bandwidth, 0),
+		"NetLimit":     max(res.Bandwidth-600, 0),
+		"EnergyLimit":  res.Energy,
+	})
+}
+
+func (s *Server) handleChainParameters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"chainParameter": []map[string]any{
+			{"key": "getTransactionFee", "value": 1000},
+			{"key": "getEnergyFee", "value": 420},
+		},
+	})
+}
+
+func (s *Server) handleTxInfo(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.broadcasts {
+		if b.TxID == req.Value {
+			writeJSON(w, map[string]any{
+				"id":             b.TxID,
+				"blockNumber":    b.BlockNumber,
+				"blockTimeStamp": time.Now().UnixMilli(),
+				"receipt":        map[string]any{"result": "SUCCESS"},
+			})
+			return
+		}
+	}
+	writeJSON(w, map[string]any{})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func selector(signature string) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(signature))
+	return hex.EncodeToString(h.Sum(nil)[:4])
+}
+
+// recoverAddress returns the base58 address that produced an r||s||v signature.
+func recoverAddress(txIDHex, sigHex string) (string, error) {
+	txID, err := hex.DecodeString(txIDHex)
+	if err != nil {
+		return "", err
+	}
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil || len(sig) != 65 {
+		return "", fmt.Errorf("bad signature")
+	}
+
+	compact := append([]byte{sig[64]}, sig[:64]...)
+	if compact[0] < 27 {
+		compact[0] += 27
+	}
+	pub, _, err := ecdsa.RecoverCompact(compact, txID)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha3.NewLegacyKeccak256()
+	h.Write(pub.SerializeUncompressed()[1:])
+	addr := append([]byte{0x41}, h.Sum(nil)[12:]...)
+	first := sha256.Sum256(addr)
+	second := sha256.Sum256(first[:])
+	return base58(append(addr, second[:4]...)), nil
+}
+
+func base58(b []byte) string {
+	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var sb strings.Builder
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+	for i := len(out) - 1; i >= 0; i-- {
+		sb.WriteByte(out[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,189 @@
+// internal/transport/http/handlers/api_key.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/domain"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/transport/http/middleware"
+)
+
+// -------------------------
+// Interfaces (service)
+// -------------------------
+
+type APIKeyService interface {
+	Create(ctx context.Context, merchantID []byte, in service.CreateAPIKeyInput) (*domain.APIKey, string, error)
+	List(ctx context.Context, merchantID []byte) ([]domain.APIKey, error)
+	Revoke(ctx context.Context, merchantID []byte, id int64) error
+}
+
+// -------------------------
+// Handler
+// -------------------------
+
+type APIKeyHandler struct {
+	keys APIKeyService
+}
+
+func NewAPIKeyHandler(keys APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// -------------------------
+// DTOs
+// -------------------------
+
+type CreateAPIKeyRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Scopes     []string `json:"scopes" binding:"required"` // orders:write, payments:read
+	AllowedIPs []string `json:"allowed_ips"`               // addresses or CIDRs; empty = any
+}
+
+type APIKeyResponse struct {
+	KeyID      int64      `json:"key_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyResponse carries the key itself; it is never shown again.
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type ListAPIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+func toAPIKeyResponse(k *domain.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		KeyID:      k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     make([]string, 0, len(k.Scopes)),
+		AllowedIPs: make([]string, 0, len(k.AllowedIPs)),
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+	for _, s := range k.Scopes {
+		resp.Scopes = append(resp.Scopes, string(s))
+	}
+	for _, p := range k.AllowedIPs {
+		resp.AllowedIPs = append(resp.AllowedIPs, p.String())
+	}
+	return resp
+}
+
+// -------------------------
+// Helpers
+// -------------------------
+
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNameRequired),
+		errors.Is(err, domain.ErrInvalidScope),
+		errors.Is(err, service.ErrInvalidAllowedIP):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeAPIKeyError(c *gin.Context, err error) {
+	status := apiKeyErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// -------------------------
+// Handlers
+// -------------------------
+
+// Create issues an API key for the caller's merchant. The key is only
+// returned here.
+// POST /v1/merchants/me/api-keys
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	k, key, err := h.keys.Create(c.Request.Context(), merchantID, service.CreateAPIKeyInput{
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		CreatedBy:  middleware.Claims(c).UserUID,
+	})
+	if err != nil {
+		writeAPIKeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(k), Key: key})
+}
+
+// List
+// GET /v1/merchants/me/api-keys
+func (h *APIKeyHandler) List(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.keys.List(c.Request.Context(), merchantID)
+	if err != nil {
+		writeAPIKeyError(c, err)
+		return
+	}
+
+	resp := ListAPIKeysResponse{Keys: make([]APIKeyResponse, 0, len(keys))}
+	for i := range keys {
+		resp.Keys = append(resp.Keys, toAPIKeyResponse(&keys[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Revoke
+// DELETE /v1/merchants/me/api-keys/:key_id
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("key_id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid key_id"})
+		return
+	}
+
+	if err := h.keys.Revoke(c.Request.Context(), merchantID, id); err != nil {
+		writeAPIKeyError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
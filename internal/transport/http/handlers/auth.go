@@ -4,6 +4,8 @@ package handlers
 import (
 	"context"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -12,8 +14,11 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
 	"token13/merchant-backend-go/internal/domain/ids"
-	"token13/merchant-backend-go/internal/queue/rabbit"
+	"token13/merchant-backend-go/internal/events"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/transport/http/middleware"
 )
 
 // -------------------------
@@ -25,9 +30,10 @@ type AuthRepo interface {
 		ctx context.Context,
 		merchantID []byte, // must be 32 bytes
 		name string,
-		wallet string,
+		wallet domain.TronAddress,
 		email string,
 		passwordHash string,
+		outbox ...domain.OutboxMessage,
 	) (merchantIDOut []byte, status string, err error)
 
 	GetUserByEmail(ctx context.Context, email string) (
@@ -39,10 +45,25 @@ type AuthRepo interface {
 		merchantID []byte,
 		err error,
 	)
+
+	GetUserByUID(ctx context.Context, userUID string) (
+		emailOut string,
+		role string,
+		status string,
+		merchantID []byte,
+		err error,
+	)
+}
+
+type SessionService interface {
+	Issue(ctx context.Context, userUID string) (token string, expiresAt time.Time, err error)
+	Rotate(ctx context.Context, token string) (userUID, next string, expiresAt time.Time, err error)
+	Revoke(ctx context.Context, token string) error
+	RevokeAll(ctx context.Context, userUID string) error
 }
 
 type TronService interface {
-	RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress string) (txid string, err error)
+	RegisterMerchant(ctx context.Context, merchantID []byte, walletAddress domain.TronAddress) (txid string, err error)
 }
 
 // -------------------------
@@ -50,14 +71,14 @@ type TronService interface {
 // -------------------------
 
 type AuthHandler struct {
-	repo      AuthRepo
-	jwt       *auth.JWTManager
-	tron      TronService
-	publisher *rabbit.Publisher
+	repo     AuthRepo
+	jwt      *auth.JWTManager
+	tron     TronService
+	sessions SessionService
 }
 
-func NewAuthHandler(repo AuthRepo, jwt *auth.JWTManager, tron TronService, pub *rabbit.Publisher) *AuthHandler {
-	return &AuthHandler{repo: repo, jwt: jwt, tron: tron, publisher: pub}
+func NewAuthHandler(repo AuthRepo, jwt *auth.JWTManager, tron TronService, sessions SessionService) *AuthHandler {
+	return &AuthHandler{repo: repo, jwt: jwt, tron: tron, sessions: sessions}
 }
 
 // -------------------------
@@ -91,10 +112,12 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expires_in"` // seconds
-	User        struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"` // seconds
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // seconds
+	User             struct {
 		UserUID    string `json:"user_uid"`
 		Email      string `json:"email"`
 		Role       string `json:"role"`
@@ -102,6 +125,11 @@ type LoginResponse struct {
 	} `json:"user"`
 }
 
+// RefreshRequest is the body of /auth/refresh and /auth/logout.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // -------------------------
 // Helpers
 // -------------------------
@@ -110,8 +138,17 @@ func normalizeEmail(s string) string {
 	return strings.ToLower(strings.TrimSpace(s))
 }
 
-func normalizeWallet(s string) string {
-	return strings.TrimSpace(s)
+// parseWallet accepts a base58check or 41-hex Tron address. The zero
+// account is rejected: funds sent there are burnt.
+func parseWallet(s string) (domain.TronAddress, error) {
+	addr, err := domain.ParseTronAddress(s)
+	if err != nil {
+		return domain.TronAddress{}, err
+	}
+	if addr.IsZero() {
+		return domain.TronAddress{}, fmt.Errorf("%w: zero address", domain.ErrInvalidTronAddress)
+	}
+	return addr, nil
 }
 
 func bytes32ToHexOrEmpty(b []byte) string {
@@ -125,12 +162,35 @@ func isDisabled(status string) bool {
 	return strings.ToUpper(strings.TrimSpace(status)) == "DISABLED"
 }
 
+// loginResponse signs an access token for the user and pairs it with the
+// session's refresh token.
+func (h *AuthHandler) loginResponse(userUID, email, role string, merchantID []byte, refresh string, refreshExpiresAt time.Time) (LoginResponse, error) {
+	token, expiresAt, err := h.jwt.Sign(userUID, email, role, merchantID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	resp := LoginResponse{
+		AccessToken:      token,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(time.Until(expiresAt).Seconds()),
+		RefreshToken:     refresh,
+		RefreshExpiresIn: int64(time.Until(refreshExpiresAt).Seconds()),
+	}
+	resp.User.UserUID = userUID
+	resp.User.Email = email
+	resp.User.Role = role
+	resp.User.MerchantID = bytes32ToHexOrEmpty(merchantID)
+	return resp, nil
+}
+
 // -------------------------
 // Handlers
 // -------------------------
 
 // Register
-// Flow: create merchant+user (tx) -> call chain -> respond with chain status
+// Flow: create merchant+user+outbox(merchant.created) in one tx -> respond.
+// The outbox relay publishes the event; the worker does the chain call.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -138,8 +198,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	wallet, err := parseWallet(req.WalletAddress)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet_address: " + err.Error()})
+		return
+	}
+
 	req.Email = normalizeEmail(req.Email)
-	req.WalletAddress = normalizeWallet(req.WalletAddress)
+	req.WalletAddress = wallet.String()
 	req.Name = strings.TrimSpace(req.Name)
 
 	merchantID, err := ids.NewBytes32()
@@ -154,52 +220,49 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	merchantHex, _ := ids.Bytes32ToHex(merchantID)
+
+	ctx := events.WithCorrelationID(c.Request.Context(), c.GetHeader("X-Correlation-ID"))
+	env, err := events.New(ctx, events.MerchantCreated{
+		MerchantID:    merchantHex,
+		WalletAddress: req.WalletAddress,
+		Name:          req.Name,
+		Email:         req.Email,
+		CreatedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build merchant event"})
+		return
+	}
+	created, err := domain.NewOutboxMessage(env.Type, env)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build merchant event"})
+		return
+	}
+
 	_, status, err := h.repo.CreateMerchantAndUserTx(
-		c.Request.Context(),
+		ctx,
 		merchantID,
 		req.Name,
-		req.WalletAddress,
+		wallet,
 		req.Email,
 		string(passHash),
+		created,
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	merchantHex, _ := ids.Bytes32ToHex(merchantID)
-
 	resp := RegisterResponse{}
 	resp.Merchant.MerchantID = merchantHex
 	resp.Merchant.Name = req.Name
 	resp.Merchant.WalletAddress = req.WalletAddress
 	resp.Merchant.Status = status
 
-	// ✅ Publish event AFTER DB commit (no blockchain call here)
-	if h.publisher != nil {
-		if err := h.publisher.PublishJSON(
-			c.Request.Context(),
-			"merchant.created",
-			map[string]any{
-				"merchant_id":    merchantHex,
-				"wallet_address": req.WalletAddress,
-				"name":           req.Name,
-				"email":          req.Email,
-				"created_at":     time.Now().UTC().Format(time.RFC3339Nano),
-			},
-		); err != nil {
-			resp.Chain.Registered = false
-			resp.Chain.Error = "queue_publish_failed: " + err.Error()
-			c.JSON(http.StatusOK, resp)
-			return
-		}
-
-		resp.Chain.Registered = false
-		resp.Chain.Error = "queued"
-	} else {
-		resp.Chain.Registered = false
-		resp.Chain.Error = "publisher not configured"
-	}
+	// merchant.created is committed in the outbox; chain registration is async.
+	resp.Chain.Registered = false
+	resp.Chain.Error = "queued"
 
 	c.JSON(http.StatusOK, resp)
 }
@@ -228,23 +291,88 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, expiresAt, err := h.jwt.Sign(userUID, emailOut, role, merchantID)
+	refresh, refreshExpiresAt, err := h.sessions.Issue(c.Request.Context(), userUID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
-	merchantHex := bytes32ToHexOrEmpty(merchantID)
+	resp, err := h.loginResponse(userUID, emailOut, role, merchantID, refresh, refreshExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	resp := LoginResponse{
-		AccessToken: token,
-		TokenType:   "Bearer",
-		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token; the presented one stops working. Presenting it again
+// ends the whole session.
+// POST /v1/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+
+	userUID, refresh, refreshExpiresAt, err := h.sessions.Rotate(ctx, req.RefreshToken)
+	switch {
+	case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	email, role, status, merchantID, err := h.repo.GetUserByUID(ctx, userUID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if isDisabled(status) {
+		_ = h.sessions.RevokeAll(ctx, userUID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "account disabled"})
+		return
 	}
-	resp.User.UserUID = userUID
-	resp.User.Email = emailOut
-	resp.User.Role = role
-	resp.User.MerchantID = merchantHex
 
+	resp, err := h.loginResponse(userUID, email, role, merchantID, refresh, refreshExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
 	c.JSON(http.StatusOK, resp)
 }
+
+// Logout ends the session of the refresh token. Access tokens already
+// issued stay valid until they expire.
+// POST /v1/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.sessions.Revoke(c.Request.Context(), req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll ends every session of the calling user.
+// POST /v1/auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	claims := middleware.Claims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if err := h.sessions.RevokeAll(c.Request.Context(), claims.UserUID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
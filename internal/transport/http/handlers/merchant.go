@@ -1 +1,400 @@
+// internal/transport/http/handlers/merchant.go
 package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/transport/http/middleware"
+)
+
+// -------------------------
+// Interfaces (service)
+// -------------------------
+
+type MerchantService interface {
+	Get(ctx context.Context, merchantID []byte) (*domain.Merchant, error)
+	RequestStatus(ctx context.Context, merchantID []byte, status domain.MerchantStatus, requestedBy string) (*domain.Merchant, error)
+	RetryOnboarding(ctx context.Context, merchantID []byte, requestedBy string) (*domain.Merchant, error)
+	RequestReceiverChange(ctx context.Context, merchantID []byte, receiver domain.TronAddress, requestedBy string) (*domain.ReceiverChange, error)
+	ReceiverChanges(ctx context.Context, merchantID []byte) ([]domain.ReceiverChange, error)
+	MerchantTokens(ctx context.Context, merchantID []byte) ([]domain.MerchantToken, error)
+	RequestToken(ctx context.Context, merchantID []byte, token string, enabled bool, requestedBy string) (*domain.MerchantToken, error)
+}
+
+// PasswordRepo lets sensitive operations re-confirm the caller's password.
+type PasswordRepo interface {
+	GetPasswordHash(ctx context.Context, userUID string) (passwordHash, status string, err error)
+}
+
+// -------------------------
+// Handler
+// -------------------------
+
+type MerchantHandler struct {
+	merchants MerchantService
+	passwords PasswordRepo
+}
+
+func NewMerchantHandler(merchants MerchantService, passwords PasswordRepo) *MerchantHandler {
+	return &MerchantHandler{merchants: merchants, passwords: passwords}
+}
+
+// -------------------------
+// DTOs
+// -------------------------
+
+type SetMerchantStatusRequest struct {
+	Status string `json:"status" binding:"required"` // ACTIVE | INACTIVE
+}
+
+type MerchantResponse struct {
+	MerchantID    string `json:"merchant_id"`
+	Name          string `json:"name"`
+	WalletAddress string `json:"wallet_address"`
+	Status        string `json:"status"` // PENDING | ACTIVE | INACTIVE
+	OnChain       bool   `json:"onchain"`
+
+	// Set when the worker gave up onboarding (POST .../onboard retries).
+	ChainError    string     `json:"chain_error,omitempty"`
+	ChainFailedAt *time.Time `json:"chain_failed_at,omitempty"`
+
+	// Set while an ACTIVE/INACTIVE change waits for chain confirmation.
+	RequestedStatus   string     `json:"requested_status,omitempty"`
+	StatusTxID        string     `json:"status_txid,omitempty"`
+	StatusRequestedAt *time.Time `json:"status_requested_at,omitempty"`
+
+	// Set when the last change failed; requesting it again retries it.
+	StatusError    string     `json:"status_error,omitempty"`
+	StatusFailedAt *time.Time `json:"status_failed_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChangeReceiverRequest struct {
+	WalletAddress string `json:"wallet_address" binding:"required"`
+	Password      string `json:"password" binding:"required"` // current password, re-confirmed
+}
+
+type ReceiverChangeResponse struct {
+	ChangeID    int64      `json:"change_id"`
+	OldReceiver string     `json:"old_receiver"`
+	NewReceiver string     `json:"new_receiver"`
+	Status      string     `json:"status"` // PENDING | CONFIRMED | FAILED
+	TxID        string     `json:"txid,omitempty"`
+	RequestedAt time.Time  `json:"requested_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+	Error       string     `json:"error,omitempty"` // why it FAILED; request it again to retry
+}
+
+type ReceiverResponse struct {
+	WalletAddress string                   `json:"wallet_address"` // current fund receiver
+	Changes       []ReceiverChangeResponse `json:"changes"`        // newest first
+}
+
+type SetMerchantTokenRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type MerchantTokenResponse struct {
+	TokenAddress string `json:"token_address"`
+	Symbol       string `json:"symbol,omitempty"`
+	Enabled      bool   `json:"enabled"` // as confirmed on chain
+
+	// Set while a change waits for chain confirmation.
+	RequestedEnabled *bool      `json:"requested_enabled,omitempty"`
+	TxID             string     `json:"txid,omitempty"`
+	RequestedAt      *time.Time `json:"requested_at,omitempty"`
+
+	// Set when the last change failed; requesting it again retries it.
+	Error    string     `json:"error,omitempty"`
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+}
+
+type ListMerchantTokensResponse struct {
+	Tokens []MerchantTokenResponse `json:"tokens"`
+}
+
+func toMerchantResponse(m *domain.Merchant) MerchantResponse {
+	return MerchantResponse{
+		MerchantID:        bytes32ToHexOrEmpty(m.MerchantID),
+		Name:              m.Name,
+		WalletAddress:     m.WalletAddress.String(),
+		Status:            string(m.Status),
+		OnChain:           m.OnChain(),
+		ChainError:        m.ChainError,
+		ChainFailedAt:     m.ChainFailedAt,
+		RequestedStatus:   string(m.RequestedStatus),
+		StatusTxID:        m.StatusTxID,
+		StatusRequestedAt: m.StatusRequestedAt,
+		StatusError:       m.StatusError,
+		StatusFailedAt:    m.StatusFailedAt,
+		CreatedAt:         m.CreatedAt,
+		UpdatedAt:         m.UpdatedAt,
+	}
+}
+
+func toReceiverChangeResponse(ch *domain.ReceiverChange) ReceiverChangeResponse {
+	return ReceiverChangeResponse{
+		ChangeID:    ch.ID,
+		OldReceiver: ch.OldReceiver.String(),
+		NewReceiver: ch.NewReceiver.String(),
+		Status:      string(ch.Status),
+		TxID:        ch.TxID,
+		RequestedAt: ch.RequestedAt,
+		ConfirmedAt: ch.ConfirmedAt,
+		FailedAt:    ch.FailedAt,
+		Error:       ch.Error,
+	}
+}
+
+func toMerchantTokenResponse(t *domain.MerchantToken) MerchantTokenResponse {
+	return MerchantTokenResponse{
+		TokenAddress:     t.Token.String(),
+		Symbol:           t.Symbol,
+		Enabled:          t.Enabled,
+		RequestedEnabled: t.RequestedEnabled,
+		TxID:             t.TxID,
+		RequestedAt:      t.RequestedAt,
+		Error:            t.Error,
+		FailedAt:         t.FailedAt,
+	}
+}
+
+// -------------------------
+// Helpers
+// -------------------------
+
+// confirmPassword checks password against the caller's and answers 403
+// when it does not match.
+func (h *MerchantHandler) confirmPassword(c *gin.Context, password string) bool {
+	claims := middleware.Claims(c)
+	hash, status, err := h.passwords.GetPasswordHash(c.Request.Context(), claims.UserUID)
+	if err != nil || isDisabled(status) || !auth.VerifyPassword(hash, password) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid password"})
+		return false
+	}
+	return true
+}
+
+func merchantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidMerchantStatus),
+		errors.Is(err, domain.ErrInvalidTronAddress),
+		errors.Is(err, service.ErrReceiverUnchanged),
+		errors.Is(err, service.ErrUnsupportedToken):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrMerchantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrMerchantNotOnChain),
+		errors.Is(err, service.ErrOnboardingNotFailed),
+		errors.Is(err, service.ErrStatusChangePending),
+		errors.Is(err, service.ErrReceiverChangePending),
+		errors.Is(err, service.ErrTokenChangePending),
+		errors.Is(err, domain.ErrWalletInUse):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrReceiverCooldown):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeMerchantError(c *gin.Context, err error) {
+	status := merchantErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// -------------------------
+// Handlers
+// -------------------------
+
+// Get
+// GET /v1/admin/merchants/:merchant_id
+func (h *MerchantHandler) Get(c *gin.Context) {
+	merchantID, err := ids.HexToBytes32(c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant_id"})
+		return
+	}
+
+	m, err := h.merchants.Get(c.Request.Context(), merchantID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toMerchantResponse(m))
+}
+
+// SetStatus records the requested status and answers 202: the worker applies
+// it on chain and the merchant's status flips once the tx is confirmed.
+// PUT /v1/admin/merchants/:merchant_id/status
+func (h *MerchantHandler) SetStatus(c *gin.Context) {
+	merchantID, err := ids.HexToBytes32(c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant_id"})
+		return
+	}
+
+	var req SetMerchantStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m, err := h.merchants.RequestStatus(c.Request.Context(), merchantID, domain.MerchantStatus(req.Status), middleware.Claims(c).UserUID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+
+	code := http.StatusAccepted
+	if !m.StatusPending() {
+		code = http.StatusOK // already in that status: nothing to apply
+	}
+	c.JSON(code, toMerchantResponse(m))
+}
+
+// RetryOnboarding re-queues an onboarding the worker gave up on and
+// answers 202; 409 unless onboarding had failed.
+// POST /v1/admin/merchants/:merchant_id/onboard
+func (h *MerchantHandler) RetryOnboarding(c *gin.Context) {
+	merchantID, err := ids.HexToBytes32(c.Param("merchant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid merchant_id"})
+		return
+	}
+
+	m, err := h.merchants.RetryOnboarding(c.Request.Context(), merchantID, middleware.Claims(c).UserUID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, toMerchantResponse(m))
+}
+
+// Receiver
+// GET /v1/merchants/me/receiver
+func (h *MerchantHandler) Receiver(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	m, err := h.merchants.Get(c.Request.Context(), merchantID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+	changes, err := h.merchants.ReceiverChanges(c.Request.Context(), merchantID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+
+	resp := ReceiverResponse{
+		WalletAddress: m.WalletAddress.String(),
+		Changes:       make([]ReceiverChangeResponse, 0, len(changes)),
+	}
+	for i := range changes {
+		resp.Changes = append(resp.Changes, toReceiverChangeResponse(&changes[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ChangeReceiver re-confirms the caller's password and records the
+// rotation; the worker applies it on chain and wallet_address follows once
+// the tx is confirmed.
+// PUT /v1/merchants/me/receiver
+func (h *MerchantHandler) ChangeReceiver(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	var req ChangeReceiverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wallet, err := parseWallet(req.WalletAddress)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "wallet_address: " + err.Error()})
+		return
+	}
+
+	if !h.confirmPassword(c, req.Password) {
+		return
+	}
+
+	ch, err := h.merchants.RequestReceiverChange(c.Request.Context(), merchantID, wallet, middleware.Claims(c).UserUID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, toReceiverChangeResponse(ch))
+}
+
+// Tokens
+// GET /v1/merchants/me/tokens
+func (h *MerchantHandler) Tokens(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.merchants.MerchantTokens(c.Request.Context(), merchantID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+
+	resp := ListMerchantTokensResponse{Tokens: make([]MerchantTokenResponse, 0, len(tokens))}
+	for i := range tokens {
+		resp.Tokens = append(resp.Tokens, toMerchantTokenResponse(&tokens[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetToken records the requested state and answers 202: the worker applies
+// it on chain and orders follow once the tx is confirmed.
+// PUT /v1/merchants/me/tokens/:token (symbol or address)
+func (h *MerchantHandler) SetToken(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	var req SetMerchantTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t, err := h.merchants.RequestToken(c.Request.Context(), merchantID, c.Param("token"), *req.Enabled, middleware.Claims(c).UserUID)
+	if err != nil {
+		writeMerchantError(c, err)
+		return
+	}
+
+	code := http.StatusAccepted
+	if !t.Pending() {
+		code = http.StatusOK // already in that state: nothing to apply
+	}
+	c.JSON(code, toMerchantTokenResponse(t))
+}
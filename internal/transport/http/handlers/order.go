@@ -1 +1,283 @@
+// internal/transport/http/handlers/order.go
 package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/transport/http/middleware"
+)
+
+// -------------------------
+// Interfaces (service)
+// -------------------------
+
+type OrderService interface {
+	Create(ctx context.Context, merchantID []byte, in service.CreateOrderInput) (*domain.Order, error)
+	Get(ctx context.Context, merchantID, orderID []byte) (*domain.Order, error)
+	List(ctx context.Context, merchantID []byte, limit, offset int) ([]domain.Order, error)
+	Payments(ctx context.Context, merchantID, orderID []byte) ([]domain.Payment, error)
+}
+
+// -------------------------
+// Handler
+// -------------------------
+
+type OrderHandler struct {
+	orders OrderService
+}
+
+func NewOrderHandler(orders OrderService) *OrderHandler {
+	return &OrderHandler{orders: orders}
+}
+
+// -------------------------
+// DTOs
+// -------------------------
+
+type CreateOrderRequest struct {
+	Amount      string `json:"amount" binding:"required"` // decimal string, e.g. "12.50"
+	Token       string `json:"token"`                     // symbol or TRC-20 address; default USDT
+	ExternalRef string `json:"external_ref"`
+}
+
+type OrderResponse struct {
+	OrderID       string    `json:"order_id"`   // 0x... (hex of bytes32)
+	InvoiceID     string    `json:"invoice_id"` // 0x... (hex of bytes32)
+	MerchantID    string    `json:"merchant_id"`
+	Amount        string    `json:"amount"`
+	Currency      string    `json:"currency"`
+	TokenAddress  string    `json:"token_address,omitempty"`
+	ExternalRef   string    `json:"external_ref,omitempty"`
+	PaymentStatus string    `json:"payment_status"`
+	AmountPaid    string    `json:"amount_paid"`
+	Settlement    string    `json:"settlement"` // UNPAID | PARTIAL | PAID | OVERPAID
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Only on GET /v1/orders/:order_id
+	Payments []OrderPaymentResponse `json:"payments,omitempty"`
+}
+
+type OrderPaymentResponse struct {
+	TxHash       string     `json:"tx_hash"`
+	LogIndex     int        `json:"log_index"`
+	TokenAddress string     `json:"token_address"`
+	Amount       string     `json:"amount"`
+	AmountRaw    string     `json:"amount_raw"`
+	Currency     string     `json:"currency"`
+	Status       string     `json:"status"`          // PENDING | SUCCESS | FAILED
+	Match        string     `json:"match,omitempty"` // EXACT | PARTIAL | OVERPAID | WRONG_TOKEN, once confirmed
+	BlockNumber  int64      `json:"block_number"`
+	PaidAt       time.Time  `json:"paid_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	ReviewReason string     `json:"review_reason,omitempty"` // set while held for manual review
+}
+
+type ListOrdersResponse struct {
+	Orders []OrderResponse `json:"orders"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+func toOrderResponse(o *domain.Order) OrderResponse {
+	return OrderResponse{
+		OrderID:       bytes32ToHexOrEmpty(o.OrderID),
+		InvoiceID:     bytes32ToHexOrEmpty(o.InvoiceID),
+		MerchantID:    bytes32ToHexOrEmpty(o.MerchantID),
+		Amount:        o.Amount,
+		Currency:      o.Currency,
+		TokenAddress:  o.TokenAddress,
+		ExternalRef:   o.ExternalRef,
+		PaymentStatus: string(o.PaymentStatus),
+		AmountPaid:    o.AmountPaid,
+		Settlement:    string(o.Settlement),
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+}
+
+func toOrderPaymentResponse(p *domain.Payment) OrderPaymentResponse {
+	return OrderPaymentResponse{
+		TxHash:       p.TxHash,
+		LogIndex:     p.LogIndex,
+		TokenAddress: p.TokenAddress,
+		Amount:       p.Amount,
+		AmountRaw:    p.AmountRaw,
+		Currency:     p.Currency,
+		Status:       string(p.Status),
+		Match:        string(p.Match),
+		BlockNumber:  p.BlockNumber,
+		PaidAt:       p.PaidAt,
+		ConfirmedAt:  p.ConfirmedAt,
+		ReviewReason: p.ReviewReason,
+	}
+}
+
+// -------------------------
+// Helpers
+// -------------------------
+
+// callerMerchantID returns the merchant the caller acts for, as scoped by
+// middleware.RequireMerchant. A route mounted without it fails closed.
+func callerMerchantID(c *gin.Context) ([]byte, bool) {
+	merchantID := middleware.MerchantID(c)
+	if merchantID == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "merchant account required"})
+		return nil, false
+	}
+	return merchantID, true
+}
+
+func orderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount),
+		errors.Is(err, domain.ErrAmountPrecision),
+		errors.Is(err, service.ErrUnsupportedToken),
+		errors.Is(err, service.ErrExternalRefTooLong):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrExternalRefExists),
+		errors.Is(err, service.ErrMerchantNotActive),
+		errors.Is(err, service.ErrTokenNotEnabled):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrMerchantNotFound):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrNoOrderTokens):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeOrderError(c *gin.Context, err error) {
+	status := orderErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// -------------------------
+// Handlers
+// -------------------------
+
+// Create
+// POST /v1/orders
+func (h *OrderHandler) Create(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	o, err := h.orders.Create(c.Request.Context(), merchantID, service.CreateOrderInput{
+		Amount:      req.Amount,
+		Token:       req.Token,
+		ExternalRef: req.ExternalRef,
+	})
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toOrderResponse(o))
+}
+
+// Get
+// GET /v1/orders/:order_id
+func (h *OrderHandler) Get(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	orderID, err := ids.HexToBytes32(c.Param("order_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order_id"})
+		return
+	}
+
+	o, err := h.orders.Get(c.Request.Context(), merchantID, orderID)
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+	payments, err := h.orders.Payments(c.Request.Context(), merchantID, orderID)
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	resp := toOrderResponse(o)
+	resp.Payments = make([]OrderPaymentResponse, 0, len(payments))
+	for i := range payments {
+		resp.Payments = append(resp.Payments, toOrderPaymentResponse(&payments[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// List
+// GET /v1/orders?limit=50&offset=0
+func (h *OrderHandler) List(c *gin.Context) {
+	merchantID, ok := callerMerchantID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := queryInt(c, "limit", service.DefaultOrderListLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	if limit == 0 {
+		limit = service.DefaultOrderListLimit
+	}
+	if limit > service.MaxOrderListLimit {
+		limit = service.MaxOrderListLimit
+	}
+
+	orders, err := h.orders.List(c.Request.Context(), merchantID, limit, offset)
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	resp := ListOrdersResponse{Orders: make([]OrderResponse, 0, len(orders)), Limit: limit, Offset: offset}
+	for i := range orders {
+		resp.Orders = append(resp.Orders, toOrderResponse(&orders[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	s := c.Query(key)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + key)
+	}
+	return n, nil
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/ports"
+	service "token13/merchant-backend-go/internal/services"
+	"token13/merchant-backend-go/internal/transport/http/middleware"
+)
+
+type memOrders struct {
+	ports.OrderRepo
+	created []domain.Order
+}
+
+func (r *memOrders) Create(_ context.Context, o *domain.Order) error {
+	o.CreatedAt, o.UpdatedAt = time.Now(), time.Now()
+	r.created = append(r.created, *o)
+	return nil
+}
+
+// memMerchants knows one merchant and the tokens it has enabled.
+type memMerchants struct {
+	ports.MerchantRepo
+	merchant *domain.Merchant
+	tokens   map[domain.TronAddress]*domain.MerchantToken
+}
+
+func (r *memMerchants) GetByID(_ context.Context, merchantID []byte) (*domain.Merchant, error) {
+	if r.merchant == nil || !bytes.Equal(r.merchant.MerchantID, merchantID) {
+		return nil, nil
+	}
+	return r.merchant, nil
+}
+
+func (r *memMerchants) GetToken(_ context.Context, _ []byte, token domain.TronAddress) (*domain.MerchantToken, error) {
+	return r.tokens[token], nil
+}
+
+var (
+	orderMerchantID = bytes.Repeat([]byte{0x33}, 32)
+
+	usdt = domain.Token{Address: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Symbol: "USDT", Decimals: 6}
+	wtrx = domain.Token{Address: "TNUC9Qb1rRpS5CbWLmNMxXBjyFoydXjWFR", Symbol: "WTRX", Decimals: 6}
+	usdd = domain.Token{Address: "TPYmHEhy5n8TCEfYGqW2rPxsghSfzghPDn", Symbol: "USDD", Decimals: 18}
+)
+
+type orderFixture struct {
+	orders    *memOrders
+	merchants *memMerchants
+	tokens    *service.TokenSet
+	engine    *gin.Engine
+	token     string // merchant JWT
+}
+
+// newOrderFixture serves POST /v1/orders as NewAPI mounts it, for an
+// active merchant accepting USDT and WTRX but not USDD.
+func newOrderFixture(t *testing.T) *orderFixture {
+	t.Helper()
+	f := &orderFixture{
+		orders: &memOrders{},
+		merchants: &memMerchants{
+			merchant: &domain.Merchant{MerchantID: orderMerchantID, Status: domain.MerchantActive},
+			tokens:   map[domain.TronAddress]*domain.MerchantToken{},
+		},
+		tokens: service.NewTokenSet([]domain.Token{usdt, wtrx, usdd}, true),
+	}
+	for _, tok := range []domain.Token{usdt, wtrx} {
+		addr, err := domain.ParseTronAddress(tok.Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.merchants.tokens[addr] = &domain.MerchantToken{MerchantID: orderMerchantID, Token: addr, Enabled: true}
+	}
+
+	svc, err := service.NewOrderService(f.orders, f.merchants, nil, f.tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.GenerateKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtm, err := auth.NewJWTManager([]auth.Key{key}, "", "token13", "token13-api", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.token, _, err = jwtm.Sign("user-1", "m@example.com", auth.RoleMerchant, orderMerchantID); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	f.engine = gin.New()
+	f.engine.POST("/v1/orders", middleware.Auth(jwtm, nil), middleware.RequireMerchant(), NewOrderHandler(svc).Create)
+	return f
+}
+
+func (f *orderFixture) create(t *testing.T, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	rec := httptest.NewRecorder()
+	f.engine.ServeHTTP(rec, req)
+
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	return rec.Code, out
+}
+
+func TestCreateOrder(t *testing.T) {
+	tests := []struct {
+		name, body       string
+		amount, currency string
+	}{
+		{"default token", `{"amount":"12.50"}`, "12.5", "USDT"},
+		{"token by symbol", `{"amount":"3","token":"wtrx"}`, "3", "WTRX"},
+		{"token by address", `{"amount":"0.000001","token":"` + wtrx.Address + `"}`, "0.000001", "WTRX"},
+		{"largest amount", `{"amount":"999999999999999999.999999"}`, "999999999999999999.999999", "USDT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrderFixture(t)
+			status, body := f.create(t, tt.body)
+			if status != http.StatusCreated {
+				t.Fatalf("status = %d (%v)", status, body)
+			}
+			if body["amount"] != tt.amount || body["currency"] != tt.currency {
+				t.Fatalf("order = %v", body)
+			}
+			if body["payment_status"] != "PENDING" || body["settlement"] != "UNPAID" || body["amount_paid"] != "0" {
+				t.Fatalf("order = %v", body)
+			}
+			if len(f.orders.created) != 1 || !bytes.Equal(f.orders.created[0].MerchantID, orderMerchantID) {
+				t.Fatalf("created = %+v", f.orders.created)
+			}
+		})
+	}
+}
+
+func TestCreateOrderValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		setup  func(f *orderFixture)
+		status int
+	}{
+		{name: "unknown token symbol", body: `{"amount":"10","token":"DOGE"}`, status: http.StatusBadRequest},
+		{name: "unknown token address", body: `{"amount":"10","token":"TLa2f6VPqDgRE67v1736s7bJ8Ray5wYjU7"}`, status: http.StatusBadRequest},
+		{name: "zero amount", body: `{"amount":"0"}`, status: http.StatusBadRequest},
+		{name: "zero with decimals", body: `{"amount":"0.000000"}`, status: http.StatusBadRequest},
+		{name: "negative amount", body: `{"amount":"-5"}`, status: http.StatusBadRequest},
+		{name: "explicit sign", body: `{"amount":"+5"}`, status: http.StatusBadRequest},
+		{name: "exponent", body: `{"amount":"1e3"}`, status: http.StatusBadRequest},
+		{name: "number instead of string", body: `{"amount":12.5}`, status: http.StatusBadRequest},
+		{name: "missing amount", body: `{"token":"USDT"}`, status: http.StatusBadRequest},
+		{name: "finer than the token", body: `{"amount":"0.0000001"}`, status: http.StatusBadRequest},
+		{name: "too many integer digits", body: `{"amount":"1000000000000000000"}`, status: http.StatusBadRequest},
+		{name: "external_ref too long", body: `{"amount":"1","external_ref":"` + strings.Repeat("x", service.MaxExternalRefLen+1) + `"}`, status: http.StatusBadRequest},
+		{name: "token not enabled for the merchant", body: `{"amount":"1","token":"USDD"}`, status: http.StatusConflict},
+		{
+			name: "token enable still pending on chain", body: `{"amount":"1","token":"WTRX"}`, status: http.StatusConflict,
+			setup: func(f *orderFixture) {
+				on := true
+				for _, mt := range f.merchants.tokens {
+					if mt.Token.String() == wtrx.Address {
+						mt.Enabled, mt.RequestedEnabled = false, &on
+					}
+				}
+			},
+		},
+		{
+			name: "merchant not onboarded on chain", body: `{"amount":"1"}`, status: http.StatusConflict,
+			setup: func(f *orderFixture) { f.merchants.merchant.Status = domain.MerchantPending },
+		},
+		{
+			name: "merchant deactivated on chain", body: `{"amount":"1"}`, status: http.StatusConflict,
+			setup: func(f *orderFixture) { f.merchants.merchant.Status = domain.MerchantInactive },
+		},
+		{
+			name: "merchant gone", body: `{"amount":"1"}`, status: http.StatusForbidden,
+			setup: func(f *orderFixture) { f.merchants.merchant = nil },
+		},
+		{
+			name: "no tokens loaded yet", body: `{"amount":"1"}`, status: http.StatusServiceUnavailable,
+			setup: func(f *orderFixture) { f.tokens.Set(nil) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrderFixture(t)
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			status, body := f.create(t, tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.status, body)
+			}
+			if msg, _ := body["error"].(string); msg == "" || msg == "internal error" {
+				t.Fatalf("error = %v", body["error"])
+			}
+			if len(f.orders.created) != 0 {
+				t.Fatalf("created %d orders for a rejected request", len(f.orders.created))
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// internal/transport/http/handlers/token.go
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/domain"
+)
+
+// -------------------------
+// Interfaces (service)
+// -------------------------
+
+type TokenService interface {
+	List(ctx context.Context) ([]domain.Token, error)
+}
+
+// -------------------------
+// Handler
+// -------------------------
+
+type TokenHandler struct {
+	tokens TokenService
+}
+
+func NewTokenHandler(tokens TokenService) *TokenHandler {
+	return &TokenHandler{tokens: tokens}
+}
+
+// -------------------------
+// DTOs
+// -------------------------
+
+type TokenResponse struct {
+	Address  string `json:"address"` // TRC-20 contract, base58
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+}
+
+type ListTokensResponse struct {
+	Tokens []TokenResponse `json:"tokens"`
+}
+
+// -------------------------
+// Handlers
+// -------------------------
+
+// List returns the tokens orders can be priced and paid in.
+// GET /v1/tokens
+func (h *TokenHandler) List(c *gin.Context) {
+	tokens, err := h.tokens.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := ListTokensResponse{Tokens: make([]TokenResponse, 0, len(tokens))}
+	for _, t := range tokens {
+		resp.Tokens = append(resp.Tokens, TokenResponse{
+			Address:  t.Address,
+			Symbol:   t.Symbol,
+			Name:     t.Name,
+			Decimals: t.Decimals,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
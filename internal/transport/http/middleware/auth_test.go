@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	service "token13/merchant-backend-go/internal/services"
+)
+
+// memKeys is an in-memory ports.APIKeyRepo.
+type memKeys struct {
+	keys    map[string]*domain.APIKey // by prefix
+	touched []int64
+}
+
+func (r *memKeys) Create(_ context.Context, k *domain.APIKey) error {
+	k.ID = int64(len(r.keys) + 1)
+	r.keys[k.Prefix] = k
+	return nil
+}
+
+func (r *memKeys) GetByPrefix(_ context.Context, prefix string) (*domain.APIKey, error) {
+	return r.keys[prefix], nil
+}
+
+func (r *memKeys) List(context.Context, []byte) ([]domain.APIKey, error) { return nil, nil }
+
+func (r *memKeys) Revoke(_ context.Context, _ []byte, id int64, at time.Time) (bool, error) {
+	for _, k := range r.keys {
+		if k.ID == id {
+			k.RevokedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memKeys) Touch(_ context.Context, id int64, _ time.Time) error {
+	r.touched = append(r.touched, id)
+	return nil
+}
+
+var testMerchantID = []byte(strings.Repeat("\x11", 32))
+
+func discardLog() *slog.Logger { return slog.New(slog.NewTextHandler(io.Discard, nil)) }
+
+func testJWT(t *testing.T) *auth.JWTManager {
+	t.Helper()
+	key, err := auth.GenerateKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtm, err := auth.NewJWTManager([]auth.Key{key}, "", "token13", "token13-api", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwtm
+}
+
+// apiKeyEngine serves GET /orders to callers allowed to create orders,
+// trusting forwarding headers from 10.0.0.0/8 only, as WireAPI sets it up.
+func apiKeyEngine(t *testing.T, keys *service.APIKeyService) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/orders", Auth(testJWT(t), keys), RequireMerchant(), RequireScope(domain.ScopeOrdersWrite), func(c *gin.Context) {
+		claims := Claims(c)
+		c.JSON(http.StatusOK, gin.H{"merchant_id": claims.MerchantID, "api_key_id": claims.APIKeyID, "ip": c.ClientIP()})
+	})
+	return r
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     []string
+		allowedIPs []string
+		remoteAddr string
+		headers    map[string]string
+		status     int
+		err        string
+	}{
+		{
+			name:   "allowed address",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "203.0.113.5:4000",
+			status:     http.StatusOK,
+		},
+		{
+			name:       "no allow-list",
+			scopes:     []string{"orders:write"},
+			remoteAddr: "198.51.100.7:4000",
+			status:     http.StatusOK,
+		},
+		{
+			name:   "IPv4-mapped address",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.5"},
+			remoteAddr: "[::ffff:203.0.113.5]:4000",
+			status:     http.StatusOK,
+		},
+		{
+			name:   "address outside the allow-list",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "198.51.100.7:4000",
+			status:     http.StatusForbidden, err: service.ErrAPIKeyIPNotAllowed.Error(),
+		},
+		{
+			name:       "missing scope",
+			scopes:     []string{"payments:read"},
+			remoteAddr: "203.0.113.5:4000",
+			status:     http.StatusForbidden, err: "api key lacks scope orders:write",
+		},
+		{
+			name:   "spoofed X-Forwarded-For from a client",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "198.51.100.7:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.5"},
+			status:     http.StatusForbidden, err: service.ErrAPIKeyIPNotAllowed.Error(),
+		},
+		{
+			name:   "spoofed X-Real-IP from a client",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "198.51.100.7:4000",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.5"},
+			status:     http.StatusForbidden, err: service.ErrAPIKeyIPNotAllowed.Error(),
+		},
+		{
+			name:   "trusted proxy forwards an allowed client",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.5"},
+			status:     http.StatusOK,
+		},
+		{
+			name:   "trusted proxy forwards a client outside the allow-list",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7"},
+			status:     http.StatusForbidden, err: service.ErrAPIKeyIPNotAllowed.Error(),
+		},
+		{
+			name:   "client prepends an allowed address behind a trusted proxy",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.5, 198.51.100.7"},
+			status:     http.StatusForbidden, err: service.ErrAPIKeyIPNotAllowed.Error(),
+		},
+		{
+			name:   "untrusted proxy in the chain",
+			scopes: []string{"orders:write"}, allowedIPs: []string{"203.0.113.0/24"},
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.5, 192.0.2.10"},
+			status:     http.StatusForbidden, err: service.ErrAPIKeyIPNotAllowed.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memKeys{keys: map[string]*domain.APIKey{}}
+			keys := service.NewAPIKeyService(repo, discardLog())
+			k, secret, err := keys.Create(context.Background(), testMerchantID, service.CreateAPIKeyInput{
+				Name: "backend", Scopes: tt.scopes, AllowedIPs: tt.allowedIPs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("Authorization", "Bearer "+secret)
+			for h, v := range tt.headers {
+				req.Header.Set(h, v)
+			}
+			rec := httptest.NewRecorder()
+			apiKeyEngine(t, keys).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.status, rec.Body)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != http.StatusOK {
+				if body["error"] != tt.err {
+					t.Fatalf("error = %v, want %q", body["error"], tt.err)
+				}
+				return
+			}
+			if body["merchant_id"] != "0x"+strings.Repeat("11", 32) || body["api_key_id"] != float64(k.ID) {
+				t.Fatalf("claims = %v", body)
+			}
+			if len(repo.touched) != 1 || repo.touched[0] != k.ID {
+				t.Fatalf("touched = %v", repo.touched)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthRejectsBadKeys(t *testing.T) {
+	repo := &memKeys{keys: map[string]*domain.APIKey{}}
+	keys := service.NewAPIKeyService(repo, discardLog())
+	k, secret, err := keys.Create(context.Background(), testMerchantID, service.CreateAPIKeyInput{
+		Name: "backend", Scopes: []string{"orders:write"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := keys.Create(context.Background(), testMerchantID, service.CreateAPIKeyInput{
+		Name: "old", Scopes: []string{"orders:write"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Revoke(context.Background(), testMerchantID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, auth string
+		status     int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"not bearer", "Basic " + secret, http.StatusUnauthorized},
+		{"wrong secret", "Bearer " + auth.APIKeyPrefix + k.Prefix + "_not-the-secret", http.StatusUnauthorized},
+		{"unknown prefix", "Bearer " + auth.APIKeyPrefix + "000000000000_secret", http.StatusUnauthorized},
+		{"malformed", "Bearer " + auth.APIKeyPrefix + k.Prefix, http.StatusUnauthorized},
+		{"revoked", "Bearer " + revokedSecret, http.StatusUnauthorized},
+		{"valid", "Bearer " + secret, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.RemoteAddr = "203.0.113.5:4000"
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			apiKeyEngine(t, keys).ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+// failingKeys stands in for a key store that is down.
+type failingKeys struct{}
+
+func (failingKeys) Authenticate(context.Context, string, netip.Addr) (*domain.APIKey, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func TestAPIKeyAuthHidesStoreErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/orders", Auth(testJWT(t), failingKeys{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+auth.APIKeyPrefix+"abc_def")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "EOF") {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+}
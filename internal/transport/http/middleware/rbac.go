@@ -1 +1,101 @@
 package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	"token13/merchant-backend-go/internal/domain/ids"
+)
+
+const merchantIDKey = "auth.merchant_id"
+
+// RequireRole lets through callers whose role is one of roles. It must run
+// after Auth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if !slices.Contains(roles, claims.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role not allowed"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMerchant scopes the request to the caller's own merchant: only
+// merchant users with a valid merchant_id claim get through, and that id
+// is the only one MerchantID returns. It must run after Auth.
+func RequireMerchant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if claims.Role != auth.RoleMerchant || claims.MerchantID == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "merchant account required"})
+			return
+		}
+		merchantID, err := ids.HexToBytes32(claims.MerchantID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid merchant_id claim"})
+			return
+		}
+
+		c.Set(merchantIDKey, merchantID)
+		c.Next()
+	}
+}
+
+// MerchantID returns the merchant stored by RequireMerchant, or nil on
+// routes without it.
+func MerchantID(c *gin.Context) []byte {
+	v, ok := c.Get(merchantIDKey)
+	if !ok {
+		return nil
+	}
+	merchantID, _ := v.([]byte)
+	return merchantID
+}
+
+// RequireScope lets API key callers through only if their key has scope.
+// Users are not limited by scopes. It must run after Auth.
+func RequireScope(scope domain.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if claims.APIKeyID != 0 && !slices.Contains(claims.Scopes, string(scope)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks scope " + string(scope)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUser rejects API key callers, for routes that need a signed-in
+// user (account settings, key management). It must run after Auth.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if claims.APIKeyID != 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not available with an api key"})
+			return
+		}
+		c.Next()
+	}
+}
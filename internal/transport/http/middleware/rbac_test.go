@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"token13/merchant-backend-go/internal/auth"
+	"token13/merchant-backend-go/internal/domain"
+	service "token13/merchant-backend-go/internal/services"
+)
+
+var otherMerchantID = []byte(strings.Repeat("\x22", 32))
+
+// rbacEngine mounts the middleware the way NewAPI does: merchant-scoped
+// order routes, user-only account routes and role-gated admin routes.
+func rbacEngine(jwtm *auth.JWTManager, keys APIKeyAuthenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authn := Auth(jwtm, keys)
+
+	echo := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"merchant_id": hex.EncodeToString(MerchantID(c))})
+	}
+	orders := r.Group("/orders", authn, RequireMerchant())
+	orders.POST("", RequireScope(domain.ScopeOrdersWrite), echo)
+	orders.GET("/:merchant_id", RequireScope(domain.ScopePaymentsRead), echo)
+	r.GET("/me/api-keys", authn, RequireUser(), RequireMerchant(), echo)
+	admin := r.Group("/admin", authn)
+	admin.GET("/merchants/:merchant_id", RequireRole(auth.RoleAdmin, auth.RoleOperator), echo)
+	admin.PUT("/merchants/:merchant_id/status", RequireRole(auth.RoleAdmin), echo)
+	return r
+}
+
+// jwtIssuer issues tokens with its own settings over key.
+func jwtIssuer(t *testing.T, key auth.Key, issuer, audience string, ttl time.Duration) *auth.JWTManager {
+	t.Helper()
+	m, err := auth.NewJWTManager([]auth.Key{key}, "", issuer, audience, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func sign(t *testing.T, m *auth.JWTManager, role string, merchantID []byte) string {
+	t.Helper()
+	token, _, err := m.Sign("user-1", "user@example.com", role, merchantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRBAC(t *testing.T) {
+	key, err := auth.GenerateKey("current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := auth.GenerateKey("current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtm := jwtIssuer(t, key, "token13", "token13-api", time.Hour)
+
+	repo := &memKeys{keys: map[string]*domain.APIKey{}}
+	keys := service.NewAPIKeyService(repo, discardLog())
+	_, readKey, err := keys.Create(context.Background(), testMerchantID, service.CreateAPIKeyInput{
+		Name: "reader", Scopes: []string{"payments:read"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merchant := sign(t, jwtm, auth.RoleMerchant, testMerchantID)
+	admin := sign(t, jwtm, auth.RoleAdmin, nil)
+	operator := sign(t, jwtm, auth.RoleOperator, nil)
+	ownHex := hex.EncodeToString(testMerchantID)
+	otherHex := hex.EncodeToString(otherMerchantID)
+
+	tests := []struct {
+		name, method, path, token string
+		status                    int
+		merchant                  string // MerchantID seen by the handler
+	}{
+		{"merchant creates an order", "POST", "/orders", merchant, http.StatusOK, ownHex},
+		{"merchant reads its own orders", "GET", "/orders/" + ownHex, merchant, http.StatusOK, ownHex},
+		{"cross-merchant id in the path is ignored", "GET", "/orders/" + otherHex, merchant, http.StatusOK, ownHex},
+		{"admin has no merchant", "POST", "/orders", admin, http.StatusForbidden, ""},
+		{"merchant on an admin route", "GET", "/admin/merchants/" + otherHex, merchant, http.StatusForbidden, ""},
+		{"operator reads a merchant", "GET", "/admin/merchants/" + otherHex, operator, http.StatusOK, ""},
+		{"operator changes a status", "PUT", "/admin/merchants/" + otherHex + "/status", operator, http.StatusForbidden, ""},
+		{"admin changes a status", "PUT", "/admin/merchants/" + otherHex + "/status", admin, http.StatusOK, ""},
+		{"api key with the scope", "GET", "/orders/" + otherHex, readKey, http.StatusOK, ownHex},
+		{"api key missing the scope", "POST", "/orders", readKey, http.StatusForbidden, ""},
+		{"api key on a user route", "GET", "/me/api-keys", readKey, http.StatusForbidden, ""},
+		{"user on a user route", "GET", "/me/api-keys", merchant, http.StatusOK, ownHex},
+		{"expired token", "POST", "/orders", sign(t, jwtIssuer(t, key, "token13", "token13-api", -time.Minute), auth.RoleMerchant, testMerchantID), http.StatusUnauthorized, ""},
+		{"wrong audience", "POST", "/orders", sign(t, jwtIssuer(t, key, "token13", "other-api", time.Hour), auth.RoleMerchant, testMerchantID), http.StatusUnauthorized, ""},
+		{"wrong issuer", "POST", "/orders", sign(t, jwtIssuer(t, key, "someone-else", "token13-api", time.Hour), auth.RoleMerchant, testMerchantID), http.StatusUnauthorized, ""},
+		{"other key with the same kid", "POST", "/orders", sign(t, jwtIssuer(t, otherKey, "token13", "token13-api", time.Hour), auth.RoleMerchant, testMerchantID), http.StatusUnauthorized, ""},
+		{"tampered claims", "POST", "/orders", tamper(t, merchant, otherMerchantID), http.StatusUnauthorized, ""},
+		{"no token", "POST", "/orders", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "203.0.113.5:4000"
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			rbacEngine(jwtm, keys).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				MerchantID string `json:"merchant_id"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.MerchantID != tt.merchant {
+				t.Fatalf("merchant = %q, want %q", body.MerchantID, tt.merchant)
+			}
+		})
+	}
+}
+
+// A merchant_id claim that is not 32 bytes of hex never reaches handlers.
+func TestRequireMerchantRejectsBadClaim(t *testing.T) {
+	for _, claim := range []string{"", "0x1234", "0x" + strings.Repeat("zz", 32)} {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.GET("/orders", func(c *gin.Context) {
+			c.Set(claimsKey, &auth.Claims{Role: auth.RoleMerchant, MerchantID: claim})
+		}, RequireMerchant(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("merchant_id %q: status = %d, want 403", claim, rec.Code)
+		}
+	}
+}
+
+// tamper swaps the merchant_id in token's payload, keeping the signature.
+func tamper(t *testing.T, token string, merchantID []byte) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	claims["merchant_id"] = "0x" + hex.EncodeToString(merchantID)
+	payload, err = json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}